@@ -1,6 +1,6 @@
 # analyze_results.py — Merge C++/Python/Go/Rust CSVs and generate plots + summary.
 # Usage:
-#   python analyze_results.py [--baseline std_vector] [--repN auto] [--dpi 220]
+#   python analyze_results.py [--baseline std_vector] [--repN auto] [--dpi 220] [--dispatch per-op]
 #
 # Outputs:
 #   aggregate.csv
@@ -14,6 +14,7 @@ import pandas as pd
 import matplotlib.pyplot as plt
 
 INPUTS = ["results.csv", "python-results.csv", "go-results.csv", "rust-results.csv"]
+AMORTIZATION = "go-results.csv.amortization.csv"
 
 def load_all():
     dfs = []
@@ -28,6 +29,20 @@ def load_all():
     df["ns_per_op"] = pd.to_numeric(df["ns_per_op"], errors="coerce")
     return df
 
+def select_rows(df: pd.DataFrame, dispatch: str) -> pd.DataFrame:
+    """Keep the rows that time one whole run the way every language does:
+    the given dispatch (Go's batch and static twins are opt-in), no per-phase
+    rows of a composite, and no reps -auto-rerun discarded as outliers. CSVs
+    without these columns count as per-op, whole-run and kept."""
+    keep = pd.Series(True, index=df.index)
+    if "dispatch" in df.columns:
+        keep &= df["dispatch"].fillna("").astype(str).replace("", "per-op") == dispatch
+    if "phase" in df.columns:
+        keep &= df["phase"].fillna("").astype(str) == ""
+    if "discarded" in df.columns:
+        keep &= df["discarded"].fillna(False).astype(str).str.lower() != "true"
+    return df[keep]
+
 def aggregate(df: pd.DataFrame) -> pd.DataFrame:
     agg = df.groupby(['impl_name','scenario','N'], as_index=False)['ns_per_op'].median()
     agg = agg.sort_values(['scenario','N','impl_name'])
@@ -87,6 +102,23 @@ def plot_speedups(agg, baseline, repN, dpi):
     _annotate_bars(ax)
     plt.tight_layout(); plt.savefig(f"plots/speedup_vs_{baseline}_N{repN}.png", dpi=dpi); plt.close()
 
+def plot_amortization(path, dpi):
+    """First-touch curves from `run -amortization`: median cumulative ns over reps at each distinct-cell count."""
+    if not os.path.exists(path): return
+    df = pd.read_csv(path)
+    if df.empty: return
+    os.makedirs("plots", exist_ok=True)
+    curve = df.groupby(['impl_name','N','distinct_cells'], as_index=False)['cumulative_ns'].median()
+    for N in sorted(curve['N'].unique()):
+        sub = curve[curve['N']==N]
+        plt.figure(figsize=(10,6))
+        for impl in sub['impl_name'].unique():
+            s2 = sub[sub['impl_name']==impl].sort_values('distinct_cells')
+            plt.plot(s2['distinct_cells'], s2['cumulative_ns'] / 1e6, label=impl)
+        plt.title(f"First-touch amortization @ N={N}")
+        plt.xlabel("distinct cells written"); plt.ylabel("cumulative ms"); plt.legend(); plt.grid(True, linestyle='--', linewidth=0.5)
+        plt.tight_layout(); plt.savefig(f"plots/amortization_N{N}.png", dpi=dpi); plt.close()
+
 def write_summary(agg, dpi):
     lines = []
     lines.append(f"# Automatic Summary ({datetime.now(UTC).isoformat()})\n")
@@ -116,9 +148,10 @@ def main():
     ap.add_argument("--baseline", default="std_vector", help="baseline impl for speedup plots")
     ap.add_argument("--repN", default="auto", help="N for representative plots (auto picks middle)")
     ap.add_argument("--dpi", type=int, default=220)
+    ap.add_argument("--dispatch", default="per-op", help="Go dispatch to aggregate: per-op, batch or static")
     args = ap.parse_args()
 
-    df = load_all()
+    df = select_rows(load_all(), args.dispatch)
     agg = aggregate(df)
     agg.to_csv("aggregate.csv", index=False, encoding="utf-8")
 
@@ -131,6 +164,8 @@ def main():
     if args.baseline != "std_vector":
         plot_speedups(agg, "std_vector", repN, args.dpi)
 
+    plot_amortization(AMORTIZATION, args.dpi)
+
     write_summary(agg, args.dpi)
     print("Wrote aggregate.csv, plots/*.png, auto_summary.md")
 
@@ -0,0 +1,13 @@
+// Command inplacebench is the Go benchmark harness.
+//
+//	go run ./cmd/inplacebench -Ns 10000,100000,1000000 -reps 3 -seed 42 -outfile go-results.csv
+//	go run ./cmd/inplacebench {run|compare|verify|list} [flags]   (flags alone mean run)
+//
+// Everything, including flag parsing and the result writers, lives in
+// package inplacebench; see inplacebench.Main to build the same command with
+// implementations of your own.
+package main
+
+import "github.com/Dawit-Getachew/In-place-benchmark/inplacebench"
+
+func main() { inplacebench.Main() }
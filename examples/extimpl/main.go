@@ -0,0 +1,41 @@
+// Command extimpl shows how to benchmark an implementation that lives
+// outside this repository: register it in an init function and hand over to
+// inplacebench.Main. It then shows up in list, is selectable with -impls and
+// writes the same rows as the built-ins.
+//
+//	go run ./examples/extimpl -impls ext_offset_int64,go_slice_int64 -Ns 1k,100k
+package main
+
+import (
+	"time"
+
+	"github.com/Dawit-Getachew/In-place-benchmark/inplacebench"
+)
+
+// offsetArray stores each value relative to the last Init value.
+type offsetArray struct {
+	base int64
+	a    []int64
+}
+
+func (o *offsetArray) Name() string { return "ext_offset_int64" }
+func (o *offsetArray) Len() int     { return len(o.a) }
+func (o *offsetArray) Init(v int64) int64 {
+	start := time.Now()
+	for i := range o.a {
+		o.a[i] = 0
+	}
+	o.base = v
+	return time.Since(start).Nanoseconds()
+}
+func (o *offsetArray) Read(i int) int64     { return o.base + o.a[i] }
+func (o *offsetArray) Write(i int, v int64) { o.a[i] = v - o.base }
+
+func init() {
+	inplacebench.Register("ext_offset_int64",
+		inplacebench.ImplMeta{Description: "example external impl: values stored relative to Init", ElemBytes: 8, Overhead: 1, EstNsPerOp: 5,
+			Model: inplacebench.ImplModel{Init: inplacebench.ON, Op: inplacebench.O1, ExtraSpace: "0", Concurrency: inplacebench.ConcNone}},
+		func(n int) inplacebench.Array { return &offsetArray{a: make([]int64, n)} })
+}
+
+func main() { inplacebench.Main() }
@@ -0,0 +1,5 @@
+module github.com/Dawit-Getachew/In-place-benchmark
+
+go 1.21
+
+require github.com/google/btree v1.1.3
@@ -0,0 +1,114 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// adaptiveChunk is how many ops of one kind MIXED_ADAPTIVE times with one
+// clock read, so the clock costs each op a hundredth of a read.
+const adaptiveChunk = 100
+
+// runMixedAdaptive is MIXED_ADAPTIVE: min(1M, N) ops at random indices,
+// reads and writes in chunks of adaptiveChunk whose kind is drawn by the
+// current mix, starting at 50/50. After every window ops it compares the
+// window's mean ns per read and per write and moves step points of the mix
+// towards the faster kind, as a load balancer would, keeping at least step
+// percent of each so both stay measured. relocations_count is the read
+// percentage it ends at, conversions_count how many windows moved it.
+func runMixedAdaptive(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+	arr.Init(42)
+	M := min(1000000, N)
+	window := max(1, int(ctx.Params["window"]))
+	step := int(ctx.Params["step"])
+	idx := ctx.Buffers.Idx(rng, M, N)
+	readPct, moves := 50, int64(0)
+	var total, readNs, writeNs int64
+	var reads, writes int
+	var s int64
+	for done := 0; done < M; {
+		n := min(adaptiveChunk, M-done, window-done%window)
+		chunk := idx[done : done+n]
+		read := rng.Intn(100) < readPct
+		start := time.Now()
+		if read {
+			for _, j := range chunk {
+				s += arr.Read(j)
+			}
+		} else {
+			for k, j := range chunk {
+				arr.Write(j, int64(done+k))
+			}
+		}
+		el := time.Since(start).Nanoseconds()
+		total += el
+		if read {
+			readNs, reads = readNs+el, reads+n
+		} else {
+			writeNs, writes = writeNs+el, writes+n
+		}
+		if done += n; done%window == 0 {
+			if next := adaptReadPct(readPct, step, readNs, reads, writeNs, writes); next != readPct {
+				readPct, moves = next, moves+1
+			}
+			readNs, writeNs, reads, writes = 0, 0, 0, 0
+		}
+	}
+	consume(s)
+	res := perElem(M, total)
+	pct := int64(readPct)
+	res.Relocations, res.Conversions = &pct, &moves
+	return res
+}
+
+// adaptReadPct is the read percentage after a window whose reads took
+// readNs over reads ops and writes writeNs over writes: step points fewer
+// if reads were slower per op, step more if writes were, kept within
+// [step, 100-step]. A window that had only one kind leaves it alone.
+func adaptReadPct(readPct, step int, readNs int64, reads int, writeNs int64, writes int) int {
+	if reads == 0 || writes == 0 {
+		return readPct
+	}
+	switch r, w := float64(readNs)/float64(reads), float64(writeNs)/float64(writes); {
+	case r > w:
+		readPct -= step
+	case w > r:
+		readPct += step
+	}
+	return min(max(readPct, step), 100-step)
+}
+
+// slowReads is an Array whose reads spin first, so that they are the
+// slower kind on any machine.
+type slowReads struct{ Array }
+
+func (s slowReads) Read(i int) int64 {
+	v := s.Array.Read(i)
+	for k := 0; k < 200; k++ {
+		consume(int64(k))
+	}
+	return v
+}
+
+// checkMixedAdaptive checks the mix update, and that MIXED_ADAPTIVE
+// against an array with slow reads runs its ops and moves the mix to
+// step percent reads.
+func checkMixedAdaptive() error {
+	for _, c := range []struct {
+		pct             int
+		readNs, writeNs int64
+		reads, writes   int
+		want            int
+	}{{50, 300, 100, 100, 100, 45}, {50, 100, 300, 100, 100, 55}, {50, 100, 100, 100, 100, 50}, {5, 300, 100, 100, 100, 5}, {95, 100, 300, 100, 100, 95}, {50, 300, 100, 100, 0, 50}, {50, 300, 100, 300, 100, 50}} {
+		if got := adaptReadPct(c.pct, 5, c.readNs, c.reads, c.writeNs, c.writes); got != c.want {
+			return fmt.Errorf("mixed adaptive: from %d%% with %d ns/%d reads, %d ns/%d writes, got %d%%, want %d%%", c.pct, c.readNs, c.reads, c.writeNs, c.writes, got, c.want)
+		}
+	}
+	const N = 200000
+	res := runScenario(slowReads{NewSliceImpl(N)}, "MIXED_ADAPTIVE", N, 1, map[string]string{"window": "10000"})
+	if res.Ops != N || res.Relocations == nil || *res.Relocations != 5 || res.Conversions == nil || *res.Conversions < 9 {
+		return fmt.Errorf("mixed adaptive: slow reads gave %d ops, read pct %v after %v moves; want %d ops ending at 5%%", res.Ops, res.Relocations, res.Conversions, N)
+	}
+	return nil
+}
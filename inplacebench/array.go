@@ -0,0 +1,180 @@
+// Package inplacebench benchmarks initializable-array implementations behind
+// a common Array interface. Implementations are registered with Register,
+// scenarios are run one at a time with RunScenario, and a Runner executes a
+// whole (impl, N, scenario, seed, rep) matrix and returns typed Results.
+package inplacebench
+
+// Array is the interface every implementation provides. Len is the N the
+// array was built with. Init (re)constructs the array with every element v
+// and returns the time it took in ns; an impl may rebuild or reallocate its
+// structure here. Read and Write access element i, which is always in
+// [0, Len()).
+type Array interface {
+	Name() string
+	Len() int
+	Init(v int64) int64
+	Read(i int) int64
+	Write(i int, v int64)
+}
+
+// Filler is implemented by arrays with a bulk assignment that keeps their
+// structure: after Fill(v) every element reads v, as after Init(v), but
+// nothing is rebuilt or reallocated. Impls that have it declare CapFill.
+// Scenarios that need Fill fall back to Init without it.
+type Filler interface {
+	Fill(v int64)
+}
+
+// Deleter is implemented by arrays that can return one cell to the
+// default: after Delete(i), Read(i) returns the value of the most recent
+// Init or Fill until the next Write(i). The default is the current one, not
+// the one in force when i was written, so after Init(1), Write(i, 5), Init(2),
+// Write(i, 6), Delete(i), Read(i) is 2; an Init or Fill after the Delete
+// changes it again. Sparse arrays drop the cell's storage, dense ones write
+// the default back. Impls that have it declare CapDelete.
+type Deleter interface {
+	Delete(i int)
+}
+
+// Resetter is implemented by arrays that can drop all their contents back to
+// the state Init(0) leaves while keeping their allocations for reuse.
+// Scenarios that need Reset fall back to Init(0) without it.
+type Resetter interface {
+	Reset()
+}
+
+// Traverser is implemented by arrays with a cheaper in-order walk than
+// Read(0..N-1); TRAVERSE_FORWARD uses it when present.
+type Traverser interface {
+	Traverse(fn func(v int64))
+}
+
+// Iterator is implemented by arrays that can visit their elements without a
+// Read per index. ForEach calls fn for each index at most once with its
+// current value until fn returns false. Sparse arrays visit only the indices
+// written since the last Init or Fill and not deleted since (the others hold
+// the Init value); dense ones visit all N. Visits are in ascending index order only
+// for impls that declare CapSorted. ITERATE_WRITTEN uses it when present.
+type Iterator interface {
+	ForEach(fn func(i int, v int64) bool)
+}
+
+// Pusher is implemented by ring buffers; RING_OVERWRITE appends through Push
+// instead of writing slot indices.
+type Pusher interface {
+	Push(v int64)
+}
+
+// RangeReader and RangeWriter are implemented by arrays that can move a run
+// of consecutive elements in one call, typically a copy: ReadRange(start,
+// out) is out[k] = Read(start+k) and WriteRange(start, vals) is
+// Write(start+k, vals[k]) for every k, with start+len <= N. The sequential
+// and batch scenarios use them when present and record which path ran.
+type RangeReader interface {
+	ReadRange(start int, out []int64)
+}
+
+type RangeWriter interface {
+	WriteRange(start int, vals []int64)
+}
+
+// CheckedAccessor is implemented by arrays that reject an index outside
+// [0, N) with an error wrapping ErrOutOfRange instead of panicking, and
+// leave their contents unchanged when they do. In range, CheckedRead and
+// CheckedWrite are Read and Write. BOUNDS_MIX wraps arrays without it; see
+// Checked.
+type CheckedAccessor interface {
+	CheckedRead(i int) (int64, error)
+	CheckedWrite(i int, v int64) error
+}
+
+// CacheDropper is implemented by file-backed arrays that can write their
+// data back and evict it from the OS page cache, so that the next reads go
+// to the storage device.
+type CacheDropper interface {
+	DropCache() error
+}
+
+// NodeBinder is implemented by arrays that can place all their memory on
+// one NUMA node, moving pages that are already touched. Impls that have it
+// declare CapNUMA.
+type NodeBinder interface {
+	BindNode(node int) error
+}
+
+// MappedArray is implemented by arrays that keep their elements in a
+// mapping of their own, outside the Go heap: Mapping returns it, so that
+// Runner.NUMA can place it exactly with mbind.
+type MappedArray interface {
+	Mapping() []byte
+}
+
+// Snapshotter is implemented by arrays that can produce an independent
+// logical copy: after Snapshot, writes to either one leave the other
+// unchanged. Persistent and copy-on-write structures make it cheap; flat
+// ones copy every element. Impls that have it declare CapSnapshot; only
+// they run SNAPSHOT_CHURN.
+type Snapshotter interface {
+	Snapshot() Array
+}
+
+// DirtyPager is implemented by arrays that track which of their pages were
+// written since the last Init, and so how much the next Init must redo.
+type DirtyPager interface {
+	DirtyPages() int
+}
+
+// BatchRunner is implemented by arrays that can execute a whole batch of
+// reads and writes in one call: RunOps applies ops, which hold only OpRead
+// and OpWrite, in order and returns the xor of the values the reads
+// returned. The composite scenarios hand it each phase's ops in one call
+// in the cells Runner plans with dispatch=batch, so the per-op interface
+// call drops out of their ns/op. Impls that have it declare CapBatch.
+type BatchRunner interface {
+	RunOps(ops []Op) int64
+}
+
+// CompareAndSwapper is implemented by concurrent arrays with an atomic
+// conditional update: CompareAndSwap stores new at i and returns true only
+// if i holds old. Impls that have it declare CapCAS; only they run
+// COMPARE_EXCHANGE.
+type CompareAndSwapper interface {
+	CompareAndSwap(i int, old, new int64) bool
+}
+
+// ScenarioHook is implemented by arrays that need scenario-specific setup or
+// teardown. RunScenario calls BeforeScenario before the scenario's Init and
+// AfterScenario once the timer has stopped, so neither is measured.
+type ScenarioHook interface {
+	BeforeScenario(name string, N int)
+	AfterScenario(name string)
+}
+
+// StatsReporter is implemented by arrays that keep structural counters; they
+// are reported in the relocations_count and conversions_count columns.
+type StatsReporter interface {
+	Stats() (relocations, conversions int64)
+}
+
+// FootprintReporter is implemented by arrays that can tell how many bytes
+// they hold after a run (backing arrays, maps, nodes, buffers), reported in
+// the bytes_resident column. Sparse structures report their current size,
+// so it reflects what the scenario wrote. Other arrays are charged N times
+// ImplMeta.ElemBytes.
+type FootprintReporter interface {
+	MemoryFootprint() int64
+}
+
+// CapacityReporter is implemented by arrays that allocate room for more
+// elements than the N they were built for; Capacity is how many, reported
+// in the allocated_N column. Other arrays' is N.
+type CapacityReporter interface {
+	Capacity() int
+}
+
+// IndexMasker is implemented by arrays whose Read and Write take any
+// index and use i & Mask(), Mask()+1 being a power of two of at least
+// Len(). POWER_OF_TWO_MOD reduces its indices for them with the mask.
+type IndexMasker interface {
+	Mask() int
+}
@@ -0,0 +1,42 @@
+package inplacebench
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// TestMemoryFootprint cross-checks MemoryFootprint against the heap growth
+// runtime.MemStats sees for a dense and two sparse impls, within a factor
+// of 2.
+func TestMemoryFootprint(t *testing.T) {
+	const N = 1 << 20
+	for _, tc := range []struct {
+		impl   string
+		writes int
+	}{{"go_slice_int64", 0}, {"go_versioned_K1_int64", 1 << 16}, {"go_skiplist_int64", 1 << 16}} {
+		t.Run(tc.impl, func(t *testing.T) {
+			impl, ok := Lookup(tc.impl)
+			if !ok {
+				t.Fatalf("%s not registered", tc.impl)
+			}
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			arr := impl.New(N)
+			arr.Init(0)
+			rng := rand.New(rand.NewSource(1))
+			for k := 0; k < tc.writes; k++ {
+				arr.Write(rng.Intn(N), int64(k))
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			got := arr.(FootprintReporter).MemoryFootprint()
+			runtime.KeepAlive(arr)
+			heap := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+			if got < heap/2 || got > heap*2 {
+				t.Fatalf("reports %d bytes, heap grew by %d", got, heap)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// calibrationBytes is what EstimateMemoryBandwidth copies a pass, far past
+// any last-level cache.
+const calibrationBytes = 128 << 20
+
+// EstimateMemoryBandwidth copies 128 MiB from one buffer to another a few
+// times and returns the best pass in GB/s (10^9 bytes a second), counting,
+// as STREAM's Copy does, the bytes read and the bytes written. It is the
+// ceiling a sequential scenario can approach: a WRITE_SEQUENTIAL whose
+// N×8/ns·op is near it is bandwidth-bound, one far below it latency-bound.
+// It allocates 256 MiB for the duration and takes well under a second.
+func EstimateMemoryBandwidth() float64 {
+	return copyBandwidth(calibrationBytes, 3)
+}
+
+// copyBandwidth is EstimateMemoryBandwidth with size-byte buffers and the
+// given number of timed passes. Both buffers are written once first, so no
+// pass pays for page faults.
+func copyBandwidth(size, passes int) float64 {
+	src, dst := make([]byte, size), make([]byte, size)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	copy(dst, src)
+	best := time.Duration(math.MaxInt64)
+	for p := 0; p < passes; p++ {
+		start := time.Now()
+		copy(dst, src)
+		best = min(best, time.Since(start))
+	}
+	consume(int64(dst[size/2]))
+	return float64(2*size) / float64(max(best, 1))
+}
+
+// checkMemoryBandwidth checks that the copy calibration reports a finite,
+// positive rate on a small buffer.
+func checkMemoryBandwidth() error {
+	if gbs := copyBandwidth(1<<20, 2); !(gbs > 0) || math.IsInf(gbs, 0) {
+		return fmt.Errorf("bandwidth: a 1 MiB copy gave %v GB/s", gbs)
+	}
+	return nil
+}
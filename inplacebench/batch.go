@@ -0,0 +1,112 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// The dispatch column: how a run's scenario reached the array. Per-op
+// rows make one Array interface call per op.
+const (
+	DispatchPerOp  = "per-op"
+	DispatchBatch  = "batch"
+	DispatchStatic = "static"
+)
+
+func (c Cell) dispatch() string {
+	if c.Dispatch == "" {
+		return DispatchPerOp
+	}
+	return c.Dispatch
+}
+
+func (c Cell) dispatchSuffix() string {
+	if c.Dispatch == "" {
+		return ""
+	}
+	return "/" + c.Dispatch
+}
+
+// WithBatch adds after each per-op cell of plan whose impl declares
+// CapBatch and whose scenario is a composite its dispatch=batch twin, and
+// renumbers them. The twin draws the same indices and values from the
+// same seed, so the pair's rows differ only in how the ops reach the
+// array: one interface call per op, or one RunOps call per phase. A
+// composite with TimedDraws has no twin, as the batch would time the
+// writes without the draws the per-op row times.
+func WithBatch(plan []Cell) []Cell {
+	var out []Cell
+	for _, c := range plan {
+		c.Ordinal = len(out)
+		out = append(out, c)
+		if sc, _ := LookupScenario(c.Scenario); c.Impl.Meta.Has(CapBatch) && sc.Phases != nil && !hasTimedDraws(sc) && c.Dispatch == "" {
+			c.Ordinal, c.Dispatch = len(out), DispatchBatch
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// batchTrace is a traceArray whose RunOps makes the Reads and Writes of
+// its ops one by one, so a batch run hashes like the per-op run.
+type batchTrace struct{ *traceArray }
+
+func (t batchTrace) RunOps(ops []Op) int64 {
+	var x int64
+	for _, op := range ops {
+		if op.Kind == OpRead {
+			x ^= t.Read(op.I)
+		} else {
+			t.Write(op.I, op.V)
+		}
+	}
+	return x
+}
+
+// checkBatch checks that every composite makes the same accesses under
+// both dispatches: through RunOps its call sequence hashes to its golden
+// checksum, and go_slice_int64 ends with the same contents. It also checks
+// that a Runner plans the batch twin and writes its rows apart.
+func checkBatch() error {
+	for name := range scenarioGolden {
+		if sc, _ := LookupScenario(name); sc.Phases == nil {
+			continue
+		}
+		t := &traceArray{A: make([]int64, 1000), h: fnv.New64a()}
+		res := runScenarioIn(nil, DispatchBatch, batchTrace{t}, name, 1000, 42, nil)
+		t.op('O', res.Ops, 0)
+		if got := t.h.Sum64(); got != scenarioGolden[name] {
+			return fmt.Errorf("batch: %s through RunOps: call sequence checksum %#x, want %#x", name, got, scenarioGolden[name])
+		}
+		var sums [2]int64
+		for k, dispatch := range []string{"", DispatchBatch} {
+			arr := NewSliceImpl(1000)
+			runScenarioIn(NewBuffers(1), dispatch, arr, name, 1000, 42, nil)
+			for _, v := range arr.A {
+				sums[k] = sums[k]*31 + v
+			}
+		}
+		if sums[0] != sums[1] {
+			return fmt.Errorf("batch: %s left go_slice_int64 with other contents through RunOps", name)
+		}
+	}
+	slice, _ := Lookup("go_slice_int64")
+	atomic, _ := Lookup("go_atomic_int64")
+	for _, noBatch := range []bool{false, true} {
+		w := &memWriter{}
+		r := &Runner{Impls: []Impl{slice, atomic}, Scenarios: []string{"WRITE_RANDOM", "INIT_ONLY"}, Ns: []int{100}, Seeds: []int64{1}, Reps: 2, NoBatch: noBatch, Writers: []ResultWriter{w}}
+		res, err := r.Run(context.Background())
+		if err != nil {
+			return fmt.Errorf("batch: %v", err)
+		}
+		batched := res.Where(func(r Result) bool { return r.Dispatch == DispatchBatch })
+		if want := map[bool]int{false: 2, true: 0}[noBatch]; len(res) != 8+want || len(batched) != want || len(batched.Impl("go_slice_int64").Scenario("WRITE_RANDOM")) != want {
+			return fmt.Errorf("batch: NoBatch=%v: %d rows, %d with dispatch=batch, want %d", noBatch, len(res), len(batched), want)
+		}
+		if !noBatch && (len(res.Cells()) != 5 || batched[0].RunID != "go_slice_int64/WRITE_RANDOM/100/1/1/batch") {
+			return fmt.Errorf("batch: %d cells, batch run id %q", len(res.Cells()), batched[0].RunID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"unsafe"
+)
+
+// uncheckedSlice is a view of a SliceImpl whose Read and Write index its
+// backing array through unsafe.Add, with no bounds check: what a compiler
+// that could prove every index in range would emit for A[i]. Under
+// Runner.BoundsCheckFree the per-op composites run on it in place of the
+// slice, and the row's ns/op against a checked sweep's is what the
+// checks cost. An index outside [0, N) reads or corrupts whatever memory
+// lies there, so it is for the composites' indices alone, which are drawn
+// in range.
+type uncheckedSlice struct {
+	s *SliceImpl
+	p unsafe.Pointer
+}
+
+// uncheckedView returns the unchecked view of arr, or arr and false when
+// it has none; like hasStatic it matches the concrete type, so impls that
+// embed a SliceImpl keep their own Write.
+func uncheckedView(arr Array) (Array, bool) {
+	if s, ok := arr.(*SliceImpl); ok {
+		return uncheckedSlice{s, unsafe.Pointer(unsafe.SliceData(s.A))}, true
+	}
+	return arr, false
+}
+
+func (u uncheckedSlice) Name() string       { return u.s.Name() }
+func (u uncheckedSlice) Len() int           { return u.s.N }
+func (u uncheckedSlice) Init(v int64) int64 { return u.s.Init(v) }
+func (u uncheckedSlice) Read(i int) int64 {
+	return *(*int64)(unsafe.Add(u.p, uintptr(i)*8))
+}
+func (u uncheckedSlice) Write(i int, v int64) {
+	*(*int64)(unsafe.Add(u.p, uintptr(i)*8)) = v
+}
+
+// ReadRange and WriteRange copy a chunk as the slice does, so the bulk
+// phases take the same path either way.
+func (u uncheckedSlice) ReadRange(start int, out []int64)   { u.s.ReadRange(start, out) }
+func (u uncheckedSlice) WriteRange(start int, vals []int64) { u.s.WriteRange(start, vals) }
+
+// uncheckedPath is the op_path of a run on an unchecked view: path with
+// "_unchecked" after it, or "unchecked" for a path the scenario left
+// empty.
+func uncheckedPath(path string) string {
+	if path == "" {
+		return "unchecked"
+	}
+	return path + "_unchecked"
+}
+
+// checkBoundsCheckFree checks that every composite's phases read the same
+// values and leave the same contents on the unchecked view as on the
+// slice, and that a Runner under BoundsCheckFree puts the view only under
+// the per-op composites and marks their op_path.
+func checkBoundsCheckFree() error {
+	const N = 1000
+	for name := range scenarioGolden {
+		sc, _ := LookupScenario(name)
+		if sc.Phases == nil {
+			continue
+		}
+		var xs, sums [2]int64
+		for k, unchecked := range []bool{false, true} {
+			s := NewSliceImpl(N)
+			var arr Array = s
+			if unchecked {
+				arr, _ = uncheckedView(s)
+			}
+			rng := rand.New(rand.NewSource(42))
+			for _, ph := range sc.Phases(sc.Params) {
+				if ph.Init {
+					arr.Init(ph.InitValue)
+				}
+				runPhase(nil, nil, false, ph, arr, N, rng, &xs[k])
+			}
+			for _, v := range s.A {
+				sums[k] = sums[k]*31 + v
+			}
+		}
+		if xs[0] != xs[1] || sums[0] != sums[1] {
+			return fmt.Errorf("bounds-check-free: %s read %#x and left %#x unchecked, %#x and %#x checked", name, xs[1], sums[1], xs[0], sums[0])
+		}
+	}
+	if _, ok := uncheckedView(NewImmutableArrayImpl(1)); ok {
+		return fmt.Errorf("bounds-check-free: go_immutable_int64 has an unchecked view")
+	}
+	slice, _ := Lookup("go_slice_int64")
+	atomic, _ := Lookup("go_atomic_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice, atomic}, Scenarios: []string{"WRITE_RANDOM", "WRITE_SEQUENTIAL", "READBACK_VERIFY"}, Ns: []int{100}, Seeds: []int64{1}, Reps: 1,
+		BoundsCheckFree: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("bounds-check-free: %v", err)
+	}
+	want := map[string]string{
+		"go_slice_int64/WRITE_RANDOM/100/1/1": "unchecked", "go_slice_int64/WRITE_RANDOM/100/1/1/batch": "",
+		"go_slice_int64/WRITE_SEQUENTIAL/100/1/1": "range_unchecked", "go_slice_int64/WRITE_SEQUENTIAL/100/1/1/batch": "element",
+		"go_slice_int64/READBACK_VERIFY/100/1/1": "range", "go_atomic_int64/WRITE_RANDOM/100/1/1": "",
+	}
+	for _, row := range w.rows {
+		if p, ok := want[row.RunID]; ok && row.Path != p {
+			return fmt.Errorf("bounds-check-free: %s has op_path %q, want %q", row.RunID, row.Path, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package inplacebench
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/google/btree"
+)
+
+// btreeDegree is the google/btree degree: nodes hold between degree-1 and
+// 2*degree-1 items.
+const btreeDegree = 32
+
+type btreeItem struct {
+	k int
+	v int64
+}
+
+// BTreeImpl stores every index as a key in a github.com/google/btree B-tree.
+// Init rebuilds the tree by inserting all N keys in a fixed shuffled order,
+// exercising rebalancing; Read is Get and Write is ReplaceOrInsert. Delete
+// removes the key, and a missing key reads as the last Init or Fill value.
+type BTreeImpl struct {
+	N     int
+	T     *btree.BTreeG[btreeItem]
+	order []int
+	def   int64
+}
+
+func NewBTreeImpl(n int) *BTreeImpl {
+	order := rand.New(rand.NewSource(int64(n))).Perm(n)
+	return &BTreeImpl{N: n, order: order, T: btree.NewG(btreeDegree, btreeLess)}
+}
+
+func btreeLess(a, b btreeItem) bool { return a.k < b.k }
+
+func (s *BTreeImpl) Name() string { return "go_btree_int64" }
+func (s *BTreeImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.T = btree.NewG(btreeDegree, btreeLess)
+	s.def = v
+	for _, k := range s.order {
+		s.T.ReplaceOrInsert(btreeItem{k, v})
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *BTreeImpl) Len() int { return s.N }
+
+// Fill replaces the value of every key in place; the tree keeps its shape.
+// Before the first Init, or once keys are deleted, the tree is short of keys
+// and Fill rebuilds it like Init.
+func (s *BTreeImpl) Fill(v int64) {
+	if s.T.Len() < s.N {
+		s.Init(v)
+		return
+	}
+	s.def = v
+	for k := 0; k < s.N; k++ {
+		s.T.ReplaceOrInsert(btreeItem{k, v})
+	}
+}
+func (s *BTreeImpl) Read(i int) int64 {
+	if it, ok := s.T.Get(btreeItem{k: i}); ok {
+		return it.v
+	}
+	return s.def
+}
+func (s *BTreeImpl) Write(i int, v int64) { s.T.ReplaceOrInsert(btreeItem{i, v}) }
+func (s *BTreeImpl) Delete(i int)         { s.T.Delete(btreeItem{k: i}) }
+
+// CheckedRead and CheckedWrite bound the key; without them Read of a key
+// outside [0, N) returns the default and Write inserts it.
+func (s *BTreeImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *BTreeImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// ForEach is an in-order Ascend over every key: all N after Init, less the
+// deleted ones.
+func (s *BTreeImpl) ForEach(fn func(i int, v int64) bool) {
+	s.T.Ascend(func(it btreeItem) bool { return fn(it.k, it.v) })
+}
+
+// Snapshot is google/btree's lazy Clone: it shares every node, and both
+// trees copy a node the first time they modify it, so it costs O(1) and
+// each later write copies at most one root-to-leaf path.
+func (s *BTreeImpl) Snapshot() Array {
+	return &BTreeImpl{N: s.N, T: s.T.Clone(), order: s.order, def: s.def}
+}
+
+// Stats reports the tree height as relocations. google/btree does not expose
+// its depth, so this is the bound for minimally filled nodes,
+// ceil(log_degree(N+1)).
+func (s *BTreeImpl) Stats() (relocations, conversions int64) {
+	n := s.T.Len()
+	if n == 0 {
+		return 0, 0
+	}
+	return int64(math.Ceil(math.Log(float64(n+1)) / math.Log(btreeDegree))), 0
+}
+
+// MemoryFootprint estimates the tree from its item count: google/btree
+// does not expose its nodes, and random inserts leave them about 69% full.
+// The fixed insert order counts too.
+func (s *BTreeImpl) MemoryFootprint() int64 {
+	return int64(float64(s.T.Len())*float64(unsafe.Sizeof(btreeItem{}))/0.69) + int64(cap(s.order))*8
+}
+
+// BTreeOf is BTreeImpl for element type T, with the same degree, insert
+// order and Stats.
+type BTreeOf[T any] struct {
+	N     int
+	T     *btree.BTreeG[btreeItemOf[T]]
+	order []int
+}
+
+type btreeItemOf[T any] struct {
+	k int
+	v T
+}
+
+func NewBTreeOf[T any](n int) *BTreeOf[T] {
+	order := rand.New(rand.NewSource(int64(n))).Perm(n)
+	return &BTreeOf[T]{N: n, order: order, T: btree.NewG(btreeDegree, btreeLessOf[T])}
+}
+
+func btreeLessOf[T any](a, b btreeItemOf[T]) bool { return a.k < b.k }
+
+func (s *BTreeOf[T]) Name() string { return "go_btree_" + elemName[T]() }
+func (s *BTreeOf[T]) Len() int     { return s.N }
+func (s *BTreeOf[T]) Init(v T) int64 {
+	start := time.Now()
+	s.T = btree.NewG(btreeDegree, btreeLessOf[T])
+	for _, k := range s.order {
+		s.T.ReplaceOrInsert(btreeItemOf[T]{k, v})
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *BTreeOf[T]) Read(i int) T {
+	it, _ := s.T.Get(btreeItemOf[T]{k: i})
+	return it.v
+}
+func (s *BTreeOf[T]) Write(i int, v T) { s.T.ReplaceOrInsert(btreeItemOf[T]{i, v}) }
+func (s *BTreeOf[T]) Stats() (relocations, conversions int64) {
+	n := s.T.Len()
+	if n == 0 {
+		return 0, 0
+	}
+	return int64(math.Ceil(math.Log(float64(n+1)) / math.Log(btreeDegree))), 0
+}
+func (s *BTreeOf[T]) MemoryFootprint() int64 {
+	return int64(float64(s.T.Len())*float64(unsafe.Sizeof(btreeItemOf[T]{}))/0.69) + int64(cap(s.order))*8
+}
+
+// LockedBTreeImpl guards BTreeImpl with a sync.RWMutex so it can take part in
+// the concurrent scenarios.
+type LockedBTreeImpl struct {
+	BTreeImpl
+	mu sync.RWMutex
+}
+
+func NewLockedBTreeImpl(n int) *LockedBTreeImpl { return &LockedBTreeImpl{BTreeImpl: *NewBTreeImpl(n)} }
+func (s *LockedBTreeImpl) Name() string         { return "go_btree_locked_int64" }
+func (s *LockedBTreeImpl) Init(v int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.BTreeImpl.Init(v)
+}
+func (s *LockedBTreeImpl) Fill(v int64) {
+	s.mu.Lock()
+	s.BTreeImpl.Fill(v)
+	s.mu.Unlock()
+}
+func (s *LockedBTreeImpl) Read(i int) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.BTreeImpl.Read(i)
+}
+func (s *LockedBTreeImpl) Write(i int, v int64) {
+	s.mu.Lock()
+	s.BTreeImpl.Write(i, v)
+	s.mu.Unlock()
+}
+func (s *LockedBTreeImpl) Delete(i int) {
+	s.mu.Lock()
+	s.BTreeImpl.Delete(i)
+	s.mu.Unlock()
+}
+
+func (s *LockedBTreeImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *LockedBTreeImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// Snapshot takes the write lock: Clone hands the original a new
+// copy-on-write context too.
+func (s *LockedBTreeImpl) Snapshot() Array {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &LockedBTreeImpl{BTreeImpl: BTreeImpl{N: s.N, T: s.T.Clone(), order: s.order, def: s.def}}
+}
+
+// ForEach holds the read lock for the whole walk, so fn must not Write.
+func (s *LockedBTreeImpl) ForEach(fn func(i int, v int64) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.BTreeImpl.ForEach(fn)
+}
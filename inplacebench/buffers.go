@@ -0,0 +1,135 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Buffers hold the indices and op kinds scenarios draw before their timed
+// loops. A Runner keeps one per worker, sized up front to the largest M of
+// its plan, so each run refills memory the previous run already touched.
+// Allocating a fresh million-entry index slice (8 MB, and M more bytes of
+// op kinds for MIXED) per run churns the allocator and evicts much of the
+// L2 and L3 right before the loop that measures them. A nil *Buffers
+// allocates per call, as RunScenario and Runner.AllocPerRun do.
+//
+// What Idx, Ints, Reads and Ops return is only valid until the next call of the
+// same method; a scenario that needs two index sets at once allocates the
+// second itself.
+type Buffers struct {
+	idx   []int
+	reads []bool
+	ops   []Op
+}
+
+// NewBuffers returns Buffers holding m indices and m op kinds; they grow
+// when a scenario asks for more. The batch ops are allocated on first use,
+// as only dispatch=batch cells need them.
+func NewBuffers(m int) *Buffers {
+	return &Buffers{idx: make([]int, m), reads: make([]bool, m)}
+}
+
+// Ints returns m ints for the caller to fill.
+func (b *Buffers) Ints(m int) []int {
+	if b == nil {
+		return make([]int, m)
+	}
+	if cap(b.idx) < m {
+		b.idx = make([]int, m)
+	}
+	return b.idx[:m]
+}
+
+// Idx returns m indices drawn uniformly from [0, N).
+func (b *Buffers) Idx(rng *rand.Rand, m, N int) []int {
+	idx := b.Ints(m)
+	for i := range idx {
+		idx[i] = rng.Intn(N)
+	}
+	return idx
+}
+
+// Ops returns m Ops for the caller to fill.
+func (b *Buffers) Ops(m int) []Op {
+	if b == nil {
+		return make([]Op, m)
+	}
+	if cap(b.ops) < m {
+		b.ops = make([]Op, m)
+	}
+	return b.ops[:m]
+}
+
+// Reads returns m op kinds, each a read with probability pct percent.
+func (b *Buffers) Reads(rng *rand.Rand, m, pct int) []bool {
+	var reads []bool
+	if b == nil {
+		reads = make([]bool, m)
+	} else {
+		if cap(b.reads) < m {
+			b.reads = make([]bool, m)
+		}
+		reads = b.reads[:m]
+	}
+	for i := range reads {
+		reads[i] = rng.Intn(100) < pct
+	}
+	return reads
+}
+
+// planM is the largest M the cells' scenarios draw indices for: the
+// longest phase of a composite, the op count of the others, capped at the
+// million ops the random-access scenarios stop at. The scan scenarios'
+// larger counts are not index draws; a scenario that does draw more grows
+// the buffers once.
+func planM(cells []Cell) int {
+	m := 0
+	for _, c := range cells {
+		sc, _ := LookupScenario(c.Scenario)
+		if sc.Phases == nil {
+			m = max(m, scenarioOps(c.Scenario, c.N))
+			continue
+		}
+		for _, ph := range sc.Phases(sc.Params) {
+			m = max(m, ph.count(c.N))
+		}
+	}
+	return min(m, 1000000)
+}
+
+// checkBuffers checks that Buffers hand back the same memory run after run
+// and draw exactly what a per-run allocation does, so reusing them changes
+// no scenario's call sequence, and that planM sizes them for the plan.
+func checkBuffers() error {
+	buf := NewBuffers(8)
+	a := buf.Idx(rand.New(rand.NewSource(1)), 8, 100)
+	b := buf.Idx(rand.New(rand.NewSource(2)), 4, 100)
+	if &a[0] != &b[0] {
+		return fmt.Errorf("buffers: Idx allocated a new slice")
+	}
+	want := (*Buffers)(nil).Idx(rand.New(rand.NewSource(2)), 4, 100)
+	if fmt.Sprint(b) != fmt.Sprint(want) {
+		return fmt.Errorf("buffers: drew %v, a fresh slice %v", b, want)
+	}
+	if r, w := buf.Reads(rand.New(rand.NewSource(3)), 20, 50), (*Buffers)(nil).Reads(rand.New(rand.NewSource(3)), 20, 50); fmt.Sprint(r) != fmt.Sprint(w) {
+		return fmt.Errorf("buffers: op kinds %v, a fresh slice %v", r, w)
+	}
+	slice, _ := Lookup("go_slice_int64")
+	for _, sc := range []string{"WRITE_RANDOM", "MIXED_R50W50", "ADVERSARIAL_HOTSPOT"} {
+		var got [2]int64
+		for k, bp := range []*Buffers{nil, NewBuffers(1)} {
+			arr := slice.New(1000)
+			runScenarioIn(bp, "", arr, sc, 1000, 7, nil)
+			for i := 0; i < 1000; i++ {
+				got[k] = got[k]*31 + arr.Read(i)
+			}
+		}
+		if got[0] != got[1] {
+			return fmt.Errorf("buffers: %s left different contents with reused buffers", sc)
+		}
+	}
+	if m := planM(PlanCells([]Impl{slice}, []int{10, 1 << 22}, []string{"WRITE_RANDOM", "READ_UNWRITTEN"}, []int64{1}, 1, false)); m != 1000000 {
+		return fmt.Errorf("buffers: planM = %d, want 1000000", m)
+	}
+	return nil
+}
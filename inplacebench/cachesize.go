@@ -0,0 +1,130 @@
+package inplacebench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CacheSizeInfo is the size in bytes of each data cache level one core
+// sees; a level the machine does not report is 0.
+type CacheSizeInfo struct {
+	L1 int `json:"l1"`
+	L2 int `json:"l2"`
+	L3 int `json:"l3"`
+}
+
+// FallbackCacheSizes is what DetectCacheSizes returns where it cannot find
+// out: a typical x86 core of the last decade.
+var FallbackCacheSizes = CacheSizeInfo{L1: 32768, L2: 262144, L3: 8388608}
+
+// DetectCacheSizes reads the cache sizes from sysfs on Linux, sysctl on
+// macOS and GetLogicalProcessorInformationEx on Windows, and returns
+// FallbackCacheSizes elsewhere or when no L1 data cache is reported.
+func DetectCacheSizes() CacheSizeInfo {
+	info, _ := detectedCacheSizes()
+	return info
+}
+
+// detectedCacheSizes is DetectCacheSizes, and whether it found the sizes
+// rather than falling back.
+func detectedCacheSizes() (CacheSizeInfo, bool) {
+	if info, err := detectCacheSizes(); err == nil && info.L1 > 0 {
+		return info, true
+	}
+	return FallbackCacheSizes, false
+}
+
+// set records size as level's, if it is a level CacheSizeInfo has.
+func (c *CacheSizeInfo) set(level, size int) {
+	switch level {
+	case 1:
+		c.L1 = size
+	case 2:
+		c.L2 = size
+	case 3:
+		c.L3 = size
+	}
+}
+
+// sysfsCacheSizes reads the index*/ directories of a cpu's sysfs cache
+// directory, skipping instruction caches.
+func sysfsCacheSizes(dir string) (CacheSizeInfo, error) {
+	var info CacheSizeInfo
+	indexes, err := filepath.Glob(filepath.Join(dir, "index*"))
+	if err != nil || len(indexes) == 0 {
+		return info, fmt.Errorf("%s: no cache indexes", dir)
+	}
+	read := func(index, name string) string {
+		b, _ := os.ReadFile(filepath.Join(index, name))
+		return strings.TrimSpace(string(b))
+	}
+	for _, index := range indexes {
+		if read(index, "type") == "Instruction" {
+			continue
+		}
+		level, err := strconv.Atoi(read(index, "level"))
+		if err != nil {
+			continue
+		}
+		if size, err := parseCacheSize(read(index, "size")); err == nil {
+			info.set(level, size)
+		}
+	}
+	return info, nil
+}
+
+// parseCacheSize parses sysfs's sizes: bytes, or a count of K or M.
+func parseCacheSize(s string) (int, error) {
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "M")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("cache size %q", s)
+	}
+	return n * mult, nil
+}
+
+// checkCacheSizes checks the size parser and the sysfs reader on a fake
+// cache directory, and that DetectCacheSizes reports an L1.
+func checkCacheSizes() error {
+	for s, want := range map[string]int{"32K": 32768, "1024K": 1 << 20, "32M": 32 << 20, "512": 512} {
+		if got, err := parseCacheSize(s); err != nil || got != want {
+			return fmt.Errorf("cache sizes: parseCacheSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	dir, err := os.MkdirTemp("", "cachesizes")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for i, c := range []struct{ level, typ, size string }{
+		{"1", "Data", "48K"}, {"1", "Instruction", "32K"}, {"2", "Unified", "2048K"}, {"3", "Unified", "30M"},
+	} {
+		index := filepath.Join(dir, fmt.Sprintf("index%d", i))
+		os.Mkdir(index, 0o755)
+		for name, v := range map[string]string{"level": c.level, "type": c.typ, "size": c.size} {
+			if err := os.WriteFile(filepath.Join(index, name), []byte(v+"\n"), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	want := CacheSizeInfo{48 << 10, 2 << 20, 30 << 20}
+	if got, err := sysfsCacheSizes(dir); err != nil || got != want {
+		return fmt.Errorf("cache sizes: sysfs fixture gave %+v, %v; want %+v", got, err, want)
+	}
+	if _, err := sysfsCacheSizes(filepath.Join(dir, "missing")); err == nil {
+		return fmt.Errorf("cache sizes: a directory without indexes was read")
+	}
+	if info := DetectCacheSizes(); info.L1 <= 0 {
+		return fmt.Errorf("cache sizes: detected %+v", info)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package inplacebench
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+)
+
+// detectCacheSizes reads the hw.l*cachesize sysctls, which hold a 4- or
+// 8-byte integer in host (little-endian) order.
+func detectCacheSizes() (CacheSizeInfo, error) {
+	var info CacheSizeInfo
+	for level, name := range map[int]string{1: "hw.l1dcachesize", 2: "hw.l2cachesize", 3: "hw.l3cachesize"} {
+		v, err := syscall.Sysctl(name)
+		if err != nil {
+			continue
+		}
+		b := append([]byte(v), make([]byte, 8)...)
+		info.set(level, int(binary.LittleEndian.Uint64(b[:8])))
+	}
+	if info.L1 == 0 {
+		return info, fmt.Errorf("hw.l1dcachesize: not reported")
+	}
+	return info, nil
+}
@@ -0,0 +1,5 @@
+package inplacebench
+
+func detectCacheSizes() (CacheSizeInfo, error) {
+	return sysfsCacheSizes("/sys/devices/system/cpu/cpu0/cache")
+}
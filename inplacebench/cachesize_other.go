@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package inplacebench
+
+import "errors"
+
+func detectCacheSizes() (CacheSizeInfo, error) {
+	return CacheSizeInfo{}, errors.New("no cache size source on this platform")
+}
@@ -0,0 +1,48 @@
+package inplacebench
+
+import (
+	"encoding/binary"
+	"syscall"
+	"unsafe"
+)
+
+// relationCache is LOGICAL_PROCESSOR_RELATIONSHIP's RelationCache, and
+// cacheInstruction PROCESSOR_CACHE_TYPE's CacheInstruction.
+const (
+	relationCache    = 2
+	cacheInstruction = 1
+)
+
+var getLogicalProcessorInformationEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalProcessorInformationEx")
+
+// detectCacheSizes walks the SYSTEM_LOGICAL_PROCESSOR_INFORMATION_EX
+// records of RelationCache: Relationship and Size, then the
+// CACHE_RELATIONSHIP's Level, Associativity, LineSize, CacheSize and Type.
+// The first data or unified cache of each level is the one core's.
+func detectCacheSizes() (CacheSizeInfo, error) {
+	var info CacheSizeInfo
+	var n uint32
+	getLogicalProcessorInformationEx.Call(relationCache, 0, uintptr(unsafe.Pointer(&n)))
+	if n == 0 {
+		return info, syscall.EINVAL
+	}
+	buf := make([]byte, n)
+	if ok, _, err := getLogicalProcessorInformationEx.Call(relationCache, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&n))); ok == 0 {
+		return info, err
+	}
+	seen := map[int]bool{}
+	for off := 0; off+20 <= int(n); {
+		rec := buf[off:]
+		size := int(binary.LittleEndian.Uint32(rec[4:]))
+		if size <= 0 {
+			break
+		}
+		level := int(rec[8])
+		if binary.LittleEndian.Uint32(rec[0:]) == relationCache && binary.LittleEndian.Uint32(rec[16:]) != cacheInstruction && !seen[level] {
+			seen[level] = true
+			info.set(level, int(binary.LittleEndian.Uint32(rec[12:])))
+		}
+		off += size
+	}
+	return info, nil
+}
@@ -0,0 +1,106 @@
+package inplacebench
+
+import (
+	"sync"
+	"time"
+)
+
+// ChannelArrayImpl is the actor pattern: one goroutine owns the []int64 and
+// every call is a request sent to it over one inbound channel, answered on
+// the caller's own response channel. Callers never touch the slice, so it
+// is safe for concurrent use without a lock, and ns/op is a channel round
+// trip plus two goroutine handoffs, against a mutex's lock and unlock in
+// go_rwmutex_int64.
+type ChannelArrayImpl struct {
+	N    int
+	reqs chan chanRequest
+	done chan struct{}
+}
+
+type chanOp uint8
+
+const (
+	chanRead chanOp = iota
+	chanWrite
+	chanInit
+)
+
+type chanRequest struct {
+	Op    chanOp
+	Index int
+	V     int64
+	Reply chan int64
+}
+
+// chanReplies recycles response channels, so a call does not allocate.
+var chanReplies = sync.Pool{New: func() any { return make(chan int64, 1) }}
+
+func NewChannelArrayImpl(n int) *ChannelArrayImpl {
+	s := &ChannelArrayImpl{N: n, reqs: make(chan chanRequest), done: make(chan struct{})}
+	go s.serve(make([]int64, n))
+	return s
+}
+func (s *ChannelArrayImpl) Name() string { return "go_chan_int64" }
+func (s *ChannelArrayImpl) Len() int     { return s.N }
+
+// serve answers requests until the inbound channel is closed.
+func (s *ChannelArrayImpl) serve(a []int64) {
+	defer close(s.done)
+	for r := range s.reqs {
+		switch r.Op {
+		case chanRead:
+			r.Reply <- a[r.Index]
+		case chanWrite:
+			a[r.Index] = r.V
+			r.Reply <- 0
+		case chanInit:
+			for i := range a {
+				a[i] = r.V
+			}
+			r.Reply <- 0
+		}
+	}
+}
+
+// call sends one request and waits for its response.
+func (s *ChannelArrayImpl) call(op chanOp, i int, v int64) int64 {
+	reply := chanReplies.Get().(chan int64)
+	s.reqs <- chanRequest{op, i, v, reply}
+	v = <-reply
+	chanReplies.Put(reply)
+	return v
+}
+
+func (s *ChannelArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.call(chanInit, 0, v)
+	return time.Since(start).Nanoseconds()
+}
+
+// Read and Write check the index before sending, so an index out of range
+// panics in the caller, where it can be recovered, not in the server.
+func (s *ChannelArrayImpl) Read(i int) int64 {
+	if uint(i) >= uint(s.N) {
+		panic(outOfRange("Read", i, s.N))
+	}
+	return s.call(chanRead, i, 0)
+}
+func (s *ChannelArrayImpl) Write(i int, v int64) {
+	if uint(i) >= uint(s.N) {
+		panic(outOfRange("Write", i, s.N))
+	}
+	s.call(chanWrite, i, v)
+}
+
+// Fill is Init without the timing, one request for the whole array.
+func (s *ChannelArrayImpl) Fill(v int64) { s.call(chanInit, 0, v) }
+
+// MemoryFootprint is the server goroutine's slice.
+func (s *ChannelArrayImpl) MemoryFootprint() int64 { return int64(s.N) * 8 }
+
+// Close stops the server goroutine and waits for it to exit.
+func (s *ChannelArrayImpl) Close() error {
+	close(s.reqs)
+	<-s.done
+	return nil
+}
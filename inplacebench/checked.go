@@ -0,0 +1,48 @@
+package inplacebench
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOutOfRange is wrapped by the errors CheckedRead and CheckedWrite return
+// for an index outside [0, N).
+var ErrOutOfRange = errors.New("index out of range")
+
+// outOfRange is the error for op on index i outside [0, n).
+func outOfRange(op string, i, n int) error {
+	return fmt.Errorf("%s(%d) with N = %d: %w", op, i, n, ErrOutOfRange)
+}
+
+// Checked returns arr itself if it is a CheckedAccessor. Otherwise it wraps
+// arr so that a panic in Read or Write becomes ErrOutOfRange. The wrapper
+// defers a recover on every call, so its cost is part of what BOUNDS_MIX
+// measures, and it cannot tell an out-of-range access that did not panic
+// from a good one.
+func Checked(arr Array) CheckedAccessor {
+	if c, ok := arr.(CheckedAccessor); ok {
+		return c
+	}
+	return recoverChecked{arr}
+}
+
+type recoverChecked struct{ Array }
+
+func (c recoverChecked) CheckedRead(i int) (v int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Read(%d): %w: %v", i, ErrOutOfRange, r)
+		}
+	}()
+	return c.Read(i), nil
+}
+
+func (c recoverChecked) CheckedWrite(i int, v int64) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Write(%d): %w: %v", i, ErrOutOfRange, r)
+		}
+	}()
+	c.Write(i, v)
+	return nil
+}
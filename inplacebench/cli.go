@@ -0,0 +1,978 @@
+package inplacebench
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+func nowISO() string { return time.Now().UTC().Format(time.RFC3339) }
+
+// stringList is a repeatable flag that also accepts comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error {
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			*l = append(*l, p)
+		}
+	}
+	return nil
+}
+
+// toolVersion identifies the harness revision in the run metadata; bump it
+// when a change moves the numbers, and say why in CHANGELOG.md.
+const toolVersion = "3"
+
+// writeMeta records the run configuration next to the results file.
+func writeMeta(path string, meta map[string]any) {
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		panic(err)
+	}
+}
+
+// implRecord is one impl's entry in the .impls.json sidecar: its metadata
+// and model, for tables that put the theory next to the measurements.
+type implRecord struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Family       string            `json:"family,omitempty"`
+	Params       map[string]string `json:"params,omitempty"`
+	BytesPerElem float64           `json:"bytes_per_elem"`
+	Caps         string            `json:"caps"`
+	EstNsPerOp   float64           `json:"est_ns_per_op"`
+	Model        ImplModel         `json:"model"`
+}
+
+// writeImpls writes the .impls.json sidecar for impls.
+func writeImpls(path string, impls []Impl) {
+	recs := []implRecord{}
+	for _, impl := range impls {
+		m := impl.Meta
+		recs = append(recs, implRecord{impl.Name, m.Description, impl.Family, impl.Params, m.BytesPerElem(), m.Caps.String(), m.EstNsPerOp, m.Model})
+	}
+	b, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		panic(err)
+	}
+}
+
+// newUUID is a random (version 4) UUID, the default -benchmark-id.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// cmdRun is the benchmark sweep; it is also what a bare legacy invocation
+// (flags only, no subcommand) runs.
+func cmdRun(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	NsFlag := fs.String("Ns", "10000,100000,1000000", "comma-separated sizes; supports k/m/g suffix")
+	repsFlag := fs.Int("reps", 3, "repetitions")
+	identicalRepsFlag := fs.Bool("identical-reps", false, "run every rep on the same data (seed) instead of a per-rep derived seed, to measure the noise floor alone")
+	seedFlag := fs.Int64("seed", 42, "seed")
+	implWarmupFlag := fs.Bool("impl-warmup", false, "before the measured reps, run each (impl, scenario, N) once without recording it; rows say whether it completed in warmup_completed")
+	noBatchFlag := fs.Bool("no-batch", false, "run the composite scenarios per op only, without their dispatch=batch rows for impls with a BatchRunner")
+	strictVerifyFlag := fs.Bool("strict-verify", false, "hash every run's array contents afterwards into the checksum column and stop at the first that differs from what "+StrictBaseline+" leaves after the same scenario, N and seed")
+	keepGoingFlag := fs.Bool("keep-going", false, "with -strict-verify, mark mismatching rows verify=fail and go on instead of stopping")
+	amortizationFlag := fs.Bool("amortization", false, "also run FIRST_TOUCH_CURVE and write its curves (distinct_cells, cumulative_ns) to OUTFILE.amortization.csv; the sample interval is FIRST_TOUCH_CURVE:step in -scenario-params")
+	perfFlag := fs.Bool("perf", false, "read hardware counters (cache misses, LLC loads, dTLB misses, branch misses, instructions) around each run into their columns; Linux with perf_event_open allowed, else the columns stay empty")
+	numaNodeFlag := fs.Int("numa-node", -1, "Linux: bind each run's memory to this NUMA node, and its thread to the node's CPUs; mmap impls are mbind-bound exactly, Go-heap ones only best effort (see numa_placement)")
+	numaInterleaveFlag := fs.Bool("numa-interleave", false, "Linux: interleave each run's memory page by page over all online NUMA nodes, with the same mbind / best-effort split as -numa-node")
+	minRunFactorFlag := fs.Float64("min-run-factor", DefaultMinRunFactor, "repeat any run shorter than this many timer granularities (see CalibrateTimer) until the runs add up to it, and report their mean; rows say so in loops and short_run. 0 reports every run as it came")
+	throttleFlag := fs.Float64("throttle-threshold", DefaultThrottleThreshold, "mark a run throttled when its CPU clock, sampled at its start and end, is this fraction below the clock at the start of the sweep")
+	calibrateFlag := fs.Bool("calibrate", false, "before the sweep, measure the memory bandwidth ceiling with a 128 MiB copy (see EstimateMemoryBandwidth) into the metadata and a meta_memory_bandwidth_gbps column")
+	sinkFlag := fs.Bool("v", false, "at the end, print Sink, the value every in-process run's reads were folded into")
+	staticFlag := fs.Bool("static", false, "also run the composite scenarios against the concrete type of impls with a static loop (go_slice_int64, go_atomic_int64), as dispatch=static rows")
+	boundsCheckFreeFlag := fs.Bool("bounds-check-free", false, "run the per-op composite scenarios on go_slice_int64 through an unsafe view with no bounds checks; their op_path gets _unchecked. Compare with a sweep without it for what the checks cost")
+	allocPerRunFlag := fs.Bool("alloc-per-run", false, "allocate each run's index and op-kind buffers afresh instead of reusing one set per worker (the behaviour before tool version 2), for comparison")
+	gcBeforeFlag := fs.Bool("gc-before-run", false, "collect garbage and return it to the OS (runtime.GC, debug.FreeOSMemory) before each run's scenario")
+	gogcFlag := fs.String("gogc", "", "off-during-run turns the garbage collector off while each run's scenario runs and restores it afterwards")
+	var outfiles stringList
+	subtractOverheadFlag := fs.Bool("subtract-overhead", false, "also run go_noop and report its median ns/op, and each cell's median less it, in .txt summaries")
+	metadataFlag := fs.String("metadata", "", "comma-separated key=value annotations, e.g. machine=c5.4xlarge,kernel=5.15, written into every row as meta_KEY columns")
+	appendFlag := fs.Bool("append", false, "add rows to existing .csv outputs instead of truncating them; their header must match this build's")
+	benchmarkIDFlag := fs.String("benchmark-id", "", "identifier for this sweep in every row's benchmark_id column, e.g. ci-build-12345 (default: a random UUID)")
+	fs.Var(&outfiles, "outfile", "output file, repeatable or comma-separated; format from extension: .csv, .csv.gz, .json, .ndjson (default go-results.csv)")
+	implsFlag := fs.String("impls", "go_slice_int64", "comma-separated implementations, NAME{key=v,...} for a family variant, or \"all\"")
+	interleaveFlag := fs.Bool("interleave", false, "run rep 1 of every cell before rep 2 of any cell")
+	scenariosFlag := fs.String("scenarios", strings.Join(DefaultScenarios, ","), "comma-separated scenarios, or \"all\"")
+	goroutinesFlag := fs.Int("goroutines", runtime.GOMAXPROCS(0), "goroutines used by the concurrent scenarios")
+	burstSizeFlag := fs.Int("burst-size", 1000, "writes per burst in the WRITE_BURST_* scenarios")
+	temporalWindowFlag := fs.Int("temporal-window", 1000, "recently written indices TEMPORAL_LOCALITY revisits")
+	gossipDurationFlag := fs.Duration("gossip-duration", time.Second, "wall-clock length of a GOSSIP run")
+	branchDensityFlag := fs.Float64("branch-density", 0.5, "fraction of negative elements in the BRANCH_* scenarios")
+	dramRowFlag := fs.Int("dram-row-bytes", 8192, "DRAM row size used as the stride of BANK_CONFLICT and BANK_SPREAD")
+	cacheSizeFlag := fs.Int("cache-size-bytes", 0, "the cache CACHE_COLORING and CACHE_ASSOCIATIVITY target, in bytes; 0 gives CACHE_COLORING this machine's L1 data cache (see DetectCacheSizes) and leaves CACHE_ASSOCIATIVITY at 32768, to go with its assoc")
+	cacheColorFlag := fs.String("cache-color-offset", "0,64,128", "comma-separated byte offsets between the two arrays of CACHE_COLORING, one scenario each")
+	autoNsFlag := fs.String("auto-Ns", "", "derive sizes from a memory budget, e.g. budget=8g,points=6 (ignored when -Ns is given)")
+	telemetryFlag := fs.String("telemetry-ws", "", "also stream each result as JSON to this WebSocket URL (ws:// or wss://), best effort")
+	flushEveryFlag := fs.Int("flush-every", 1, "flush the output every k rows; 0 flushes only at exit (and on SIGINT)")
+	rateLimitFlag := fs.Float64("output-rate-limit", 0, "write at most this many rows per second to the outputs, for a slow downstream consumer; holds up the sweep between runs (0: no limit)")
+	selftestFlag := fs.Bool("selftest", false, "run scripted correctness checks over every implementation and exit")
+	dryRunFlag := fs.Bool("dry-run", false, "print the planned run count and estimated duration, then exit")
+	budgetFlag := fs.Duration("total-budget", 0, "trim reps, then the largest N, until the estimated sweep fits (e.g. 2h)")
+	strictBudgetFlag := fs.Bool("strict-budget", false, "with -total-budget, refuse to start instead of trimming")
+	parallelFlag := fs.Int("parallel", 1, "run up to k cells concurrently; >1 makes cells share memory bandwidth (rows get contended=true)")
+	scenarioParamsFlag := fs.String("scenario-params", "", "per-scenario parameters, e.g. ADVERSARIAL_HOTSPOT:hotspot_pct=5,hot_write_pct=80,MIXED_R50W50:read_pct=60")
+	implParamsFlag := fs.String("impl-params", "", "extra impls built from parameterised families, e.g. go_sharded:shards=4,go_sharded:shards=256,go_versioned:k=8")
+	isolateFlag := fs.Bool("isolate", false, "run every cell in a fresh child process (one runs at a time unless -parallel)")
+	syncIOFlag := fs.Bool("sync-io", false, "run go_file_int64 as go_file_sync_int64, with an fsync after every Write")
+	instrumentFlag := fs.String("instrument", "", "comma-separated impls to also run wrapped in InstrumentedImpl, as IMPL+instr, with their call counts in op_counts")
+	instrTraceFlag := fs.String("instrument-trace", "", "with -instrument, write each run's call trace to a file in this directory (see ReadTrace)")
+	redisAddrFlag := fs.String("redis-addr", RedisAddr, "Redis server for REDIS_BACKED (HOST:PORT); the scenario is skipped when it does not answer")
+	elemTypeFlag := fs.String("elem-type", "", "run the typed path with this element type: "+strings.Join(ElemTypes, ", ")+" (default: the int64 Array path)")
+	autoRerunFlag := fs.Bool("auto-rerun", false, "after a cell's reps, replace reps more than -outlier-mads MADs from its median, flagging the originals discarded=true; a cell still off after -max-reruns gets quality=unstable")
+	outlierMADsFlag := fs.Float64("outlier-mads", 3, "with -auto-rerun, the outlier threshold in median absolute deviations")
+	maxRerunsFlag := fs.Int("max-reruns", 3, "with -auto-rerun, the most replacement reps per cell")
+	stableFlag := fs.String("repeat-until-stable", "", "instead of -reps, repeat each cell until its ns/op is stable, e.g. cv=3%,max=15 (min=2 by default)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	RedisAddr = *redisAddrFlag
+
+	if *selftestFlag {
+		start := time.Now()
+		if err := Selftest(); err != nil {
+			fmt.Fprintln(stderr, "selftest FAIL:", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "selftest ok: %d impls x %d sizes in %v\n", len(Impls()), len(SelftestSizes), time.Since(start).Round(time.Millisecond))
+		return 0
+	}
+
+	selected, err := SelectImpls(*implsFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	variants, err := ParseImplParams(*implParamsFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *syncIOFlag {
+		for k, impl := range selected {
+			if impl.Family == "go_file" {
+				f, _ := LookupFamily("go_file")
+				selected[k], _ = f.Variant(map[string]string{"sync": "1"})
+			}
+		}
+	}
+	for _, name := range strings.Split(*instrumentFlag, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		v, err := instrFamily.Variant(map[string]string{"impl": name, "trace": *instrTraceFlag})
+		if err != nil {
+			fmt.Fprintln(stderr, "-instrument:", err)
+			return 2
+		}
+		variants = append(variants, v)
+	}
+	for _, v := range variants {
+		dup := false
+		for _, impl := range selected {
+			dup = dup || impl.Name == v.Name
+		}
+		if !dup {
+			selected = append(selected, v)
+		}
+	}
+
+	NsSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "Ns" {
+			NsSet = true
+		}
+	})
+	Nlist := ParseSizes(*NsFlag)
+	if *autoNsFlag != "" && !NsSet {
+		auto, err := AutoSizes(*autoNsFlag, selected)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		Nlist = auto
+		fmt.Fprintf(stdout, "auto-Ns: %v\n", Nlist)
+	}
+	if len(Nlist) == 0 {
+		Nlist = []int{10000, 100000, 1000000}
+	}
+	seeds := []int64{*seedFlag}
+	reps := *repsFlag
+	var stable StableSpec
+	if *stableFlag != "" {
+		if stable, err = ParseStableSpec(*stableFlag); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		reps = stable.Max
+	}
+	if *autoRerunFlag && (*stableFlag != "" || *outlierMADsFlag <= 0 || *maxRerunsFlag < 1) {
+		fmt.Fprintln(stderr, "-auto-rerun: needs -outlier-mads > 0 and -max-reruns >= 1, and cannot be combined with -repeat-until-stable")
+		return 2
+	}
+	// -cache-color-offset redefines the CACHE_COLORING group, registering
+	// a CACHE_COLORING_O<offset> scenario for each offset not built in.
+	var colorings []string
+	for _, f := range strings.Split(*cacheColorFlag, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		off, err := strconv.Atoi(f)
+		if err != nil || off < 0 {
+			fmt.Fprintf(stderr, "-cache-color-offset: want byte offsets >= 0, got %q\n", f)
+			return 2
+		}
+		colorings = append(colorings, cacheColoring(off))
+	}
+	scenarioGroups["CACHE_COLORING"] = colorings
+	scenarios, err := SelectScenarios(*scenariosFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *amortizationFlag && !slices.Contains(scenarios, "FIRST_TOUCH_CURVE") {
+		scenarios = append(scenarios, "FIRST_TOUCH_CURVE")
+	}
+	for _, name := range scenarios {
+		if sc, _ := LookupScenario(name); sc.Unavailable != nil {
+			if why := sc.Unavailable(); why != "" {
+				fmt.Fprintf(stderr, "skip %s: %s\n", name, why)
+			}
+		}
+	}
+	// REDIS_BACKED brings its own impl: go_redis_int64 at -redis-addr,
+	// unless the scenario was skipped.
+	for _, name := range scenarios {
+		if name != "REDIS_BACKED" || redisUnavailable() != "" {
+			continue
+		}
+		f, _ := LookupFamily("go_redis")
+		impl, err := f.Variant(map[string]string{"addr": RedisAddr})
+		if err != nil {
+			fmt.Fprintln(stderr, "-redis-addr:", err)
+			return 2
+		}
+		dup := false
+		for _, s := range selected {
+			dup = dup || s.Name == impl.Name
+		}
+		if !dup {
+			selected = append(selected, impl)
+		}
+	}
+	// So do BENCHMARK_BENCHMARK and NO_ESCAPE, go_noop being the only impl
+	// they run on, and -subtract-overhead, which needs go_noop's rows.
+	needNoop := *subtractOverheadFlag
+	for _, name := range scenarios {
+		needNoop = needNoop || name == "BENCHMARK_BENCHMARK" || name == "NO_ESCAPE"
+	}
+	if needNoop {
+		noop, _ := Lookup(OverheadImpl)
+		dup := false
+		for _, s := range selected {
+			dup = dup || s.Name == noop.Name
+		}
+		if !dup {
+			selected = append(selected, noop)
+		}
+	}
+	params, err := ParseScenarioParams(*scenarioParamsFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	// -goroutines is shorthand for goroutines= on the concurrent scenarios
+	// and GOSSIP, and -dram-row-bytes, -burst-size, -temporal-window,
+	// -gossip-duration, -branch-density and -cache-size-bytes for the bank,
+	// burst, temporal locality, gossip, branch and cache set scenarios; an
+	// explicit -scenario-params value wins.
+	params.setDefault("CONCURRENT_WRITE", "goroutines", strconv.Itoa(*goroutinesFlag))
+	params.setDefault("CONCURRENT_INIT", "goroutines", strconv.Itoa(*goroutinesFlag))
+	params.setDefault("COMPARE_EXCHANGE", "goroutines", strconv.Itoa(*goroutinesFlag))
+	params.setDefault("BANK_CONFLICT", "row_bytes", strconv.Itoa(*dramRowFlag))
+	params.setDefault("BANK_SPREAD", "row_bytes", strconv.Itoa(*dramRowFlag))
+	for _, sc := range scenarioGroups["WRITE_BURST"] {
+		params.setDefault(sc, "burst_size", strconv.Itoa(*burstSizeFlag))
+	}
+	params.setDefault("TEMPORAL_LOCALITY", "window", strconv.Itoa(*temporalWindowFlag))
+	// CACHE_COLORING's arrays only need to start a multiple of the L1
+	// apart, so it defaults to the detected size; CACHE_ASSOCIATIVITY
+	// divides it by assoc, so it keeps its 32 KiB, 8-way geometry unless
+	// -cache-size-bytes is given.
+	cacheSizes, cacheSizesDetected := detectedCacheSizes()
+	cacheBytes := *cacheSizeFlag
+	if cacheBytes <= 0 {
+		cacheBytes = cacheSizes.L1
+	} else {
+		params.setDefault("CACHE_ASSOCIATIVITY", "cache_bytes", strconv.Itoa(cacheBytes))
+	}
+	for _, sc := range scenarioGroups["CACHE_COLORING"] {
+		params.setDefault(sc, "cache_bytes", strconv.Itoa(cacheBytes))
+	}
+	params.setDefault("GOSSIP", "duration_ms", strconv.FormatFloat(float64(*gossipDurationFlag)/float64(time.Millisecond), 'g', -1, 64))
+	params.setDefault("GOSSIP", "goroutines", strconv.Itoa(*goroutinesFlag))
+	for _, sc := range []string{"BRANCH_PREDICTABLE", "BRANCH_UNPREDICTABLE"} {
+		params.setDefault(sc, "density", strconv.FormatFloat(*branchDensityFlag, 'g', -1, 64))
+	}
+
+	annotations, err := ParseAnnotations(*metadataFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	// The ceiling goes into every row, as an annotation would, so that a
+	// row's throughput can be read against it without the metadata.
+	var bandwidth *float64
+	if *calibrateFlag {
+		gbs := math.Round(EstimateMemoryBandwidth()*100) / 100
+		bandwidth = &gbs
+		fmt.Fprintf(stdout, "memory bandwidth: %.2f GB/s\n", gbs)
+		if _, ok := annotations["memory_bandwidth_gbps"]; !ok {
+			annotations["memory_bandwidth_gbps"] = strconv.FormatFloat(gbs, 'f', 2, 64)
+		}
+	}
+	if *minRunFactorFlag < 0 {
+		fmt.Fprintf(stderr, "-min-run-factor: want a factor >= 0, got %g\n", *minRunFactorFlag)
+		return 2
+	}
+	timer := CalibrateTimer()
+	minRunNs := int64(math.Ceil(timer.Granularity() * *minRunFactorFlag))
+	if *throttleFlag <= 0 || *throttleFlag >= 1 {
+		fmt.Fprintf(stderr, "-throttle-threshold: want a fraction between 0 and 1, got %g\n", *throttleFlag)
+		return 2
+	}
+	numa := NUMAPolicy{Bind: *numaNodeFlag >= 0, Node: max(*numaNodeFlag, 0), Interleave: *numaInterleaveFlag}
+	if why := numa.Unavailable(); why != "" {
+		fmt.Fprintf(stderr, "-numa-node/-numa-interleave: %s\n", why)
+		return 2
+	}
+	if *gogcFlag != "" && *gogcFlag != "off-during-run" {
+		fmt.Fprintf(stderr, "-gogc: unknown setting %q (want off-during-run)\n", *gogcFlag)
+		return 2
+	}
+	if _, ok := elemKinds[*elemTypeFlag]; *elemTypeFlag != "" && !ok {
+		fmt.Fprintf(stderr, "-elem-type: unknown element type %q (want one of %s)\n", *elemTypeFlag, strings.Join(ElemTypes, ", "))
+		return 2
+	}
+	plan := func(Nlist []int, reps int) []Cell {
+		cells := PlanCells(selected, Nlist, scenarios, seeds, reps, *interleaveFlag)
+		if *elemTypeFlag != "" {
+			cells = ForElemType(cells, *elemTypeFlag)
+		} else {
+			if !*noBatchFlag {
+				cells = WithBatch(cells)
+			}
+			if *staticFlag {
+				cells = WithStatic(cells)
+			}
+		}
+		return cells
+	}
+	// -impl-warmup's runs are in the estimate, but -total-budget only cuts
+	// the measured ones.
+	estimatePlan := func(cells []Cell) time.Duration {
+		if *implWarmupFlag && !*isolateFlag {
+			return EstimatePlan(cells) + EstimatePlan(WarmupCells(cells))
+		}
+		return EstimatePlan(cells)
+	}
+	cells := plan(Nlist, reps)
+	estimate := estimatePlan(cells)
+	var budgetCuts []string
+	if *budgetFlag > 0 && estimate > *budgetFlag {
+		if *strictBudgetFlag {
+			fmt.Fprintf(stderr, "estimated %v for %d runs exceeds -total-budget %v\n", estimate.Round(time.Second), len(cells), *budgetFlag)
+			return 1
+		}
+		Nlist, reps, budgetCuts = TrimToBudget(*budgetFlag, plan, Nlist, reps)
+		cells = plan(Nlist, reps)
+		estimate = estimatePlan(cells)
+		fmt.Fprintf(stdout, "total-budget %v: %s (estimated %v)\n", *budgetFlag, strings.Join(budgetCuts, ", "), estimate.Round(time.Second))
+		if estimate > *budgetFlag {
+			fmt.Fprintln(stderr, "warning: the trimmed matrix still exceeds the budget")
+		}
+	}
+	if *dryRunFlag {
+		if *stableFlag != "" {
+			// Every cell runs between min and max reps; the budget above
+			// is checked against the worst case.
+			low := estimatePlan(plan(Nlist, min(stable.Min, reps)))
+			fmt.Fprintf(stdout, "%d-%d runs, estimated %v-%v\n", len(plan(Nlist, min(stable.Min, reps))), len(cells), low.Round(time.Millisecond), estimate.Round(time.Millisecond))
+			return 0
+		}
+		fmt.Fprintf(stdout, "%d runs, estimated %v\n", len(cells), estimate.Round(time.Millisecond))
+		return 0
+	}
+	runner := &Runner{
+		Impls: selected, Scenarios: scenarios, Ns: Nlist, Seeds: seeds, Reps: reps,
+		Interleave: *interleaveFlag, Params: params, Parallel: *parallelFlag, Isolate: *isolateFlag,
+		ElemType: *elemTypeFlag, IdenticalReps: *identicalRepsFlag, BenchmarkID: *benchmarkIDFlag,
+		GC: GCControl{Before: *gcBeforeFlag, Off: *gogcFlag == "off-during-run"}, Annotations: annotations, AllocPerRun: *allocPerRunFlag,
+		BoundsCheckFree:   *boundsCheckFreeFlag,
+		Perf:              *perfFlag,
+		NUMA:              numa,
+		MinRunNs:          minRunNs,
+		ThrottleThreshold: *throttleFlag,
+		Warmup:            *implWarmupFlag, NoBatch: *noBatchFlag, Static: *staticFlag,
+		StrictVerify: *strictVerifyFlag, KeepGoing: *keepGoingFlag,
+	}
+	if runner.BenchmarkID == "" {
+		runner.BenchmarkID = newUUID()
+	}
+	if *autoRerunFlag {
+		runner.AutoRerun = &RerunSpec{MADs: *outlierMADsFlag, Max: *maxRerunsFlag}
+	}
+	if *stableFlag != "" {
+		runner.RepeatUntilStable = &StableSpec{CV: stable.CV, Min: min(stable.Min, reps), Max: reps}
+	}
+
+	if len(outfiles) == 0 {
+		outfiles = stringList{"go-results.csv"}
+	}
+	// Reject unknown formats, outputs -append cannot extend and telemetry
+	// URLs before creating (and truncating) any file.
+	for _, path := range outfiles {
+		kind, err := OutputFormat(path)
+		if err == nil && *appendFlag && kind != "csv" {
+			err = fmt.Errorf("%s: only .csv outputs can be appended to", path)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if *telemetryFlag != "" {
+		if _, _, err := wsTarget(*telemetryFlag); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	var outs []ResultWriter
+	for _, path := range outfiles {
+		open := OpenResultWriter
+		if *appendFlag {
+			open = AppendResultWriter
+		}
+		w, err := open(path, annotations.Keys()...)
+		if err != nil {
+			MultiWriter(outs...).Close()
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if s, ok := w.(*SummaryWriter); ok && *subtractOverheadFlag {
+			s.Baseline = OverheadImpl
+		}
+		outs = append(outs, FlushEvery(w, *flushEveryFlag))
+		if *amortizationFlag {
+			cw, err := OpenCurveWriter(path+".amortization.csv", *appendFlag)
+			if err != nil {
+				MultiWriter(outs...).Close()
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			outs = append(outs, cw)
+		}
+	}
+	if *telemetryFlag != "" {
+		t, err := NewTelemetryWriter(*telemetryFlag, stderr)
+		if err != nil {
+			MultiWriter(outs...).Close()
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		outs = append(outs, t)
+	}
+	indexBuffers := "reused"
+	if *allocPerRunFlag || *isolateFlag {
+		indexBuffers = "per-run"
+	}
+	repSeeds := "varying"
+	if *identicalRepsFlag {
+		repSeeds = "identical"
+	}
+	gogc := *gogcFlag
+	if gogc == "" {
+		gogc = "unchanged"
+	}
+	ordering := "sequential"
+	if *interleaveFlag {
+		ordering = "interleaved"
+	}
+
+	perfCounters := "off"
+	if *perfFlag {
+		perfCounters = "on"
+		if why := perfUnavailable(); why != "" {
+			perfCounters = "unavailable: " + why
+			fmt.Fprintf(stderr, "-perf: %s; the counter columns stay empty\n", why)
+		}
+	}
+
+	var implNames []string
+	for _, impl := range selected {
+		implNames = append(implNames, impl.Name)
+	}
+	meta := map[string]any{
+		"tool_version":          toolVersion,
+		"started":               nowISO(),
+		"go_version":            runtime.Version(),
+		"goos":                  runtime.GOOS,
+		"goarch":                runtime.GOARCH,
+		"num_cpu":               runtime.NumCPU(),
+		"Ns":                    Nlist,
+		"reps":                  reps,
+		"rep_seeds":             repSeeds,
+		"index_buffers":         indexBuffers,
+		"bounds_checks":         map[bool]string{false: "on", true: "off"}[*boundsCheckFreeFlag],
+		"batch_dispatch":        !*noBatchFlag && *elemTypeFlag == "",
+		"static_dispatch":       *staticFlag && *elemTypeFlag == "",
+		"strict_verify":         *strictVerifyFlag,
+		"amortization":          *amortizationFlag,
+		"perf_counters":         perfCounters,
+		"numa_policy":           numa.String(),
+		"numa_nodes":            len(numaNodes()),
+		"throttle_threshold":    *throttleFlag,
+		"timer_resolution_ns":   timer.ResolutionNs,
+		"timer_overhead_ns":     math.Round(timer.OverheadNs*100) / 100,
+		"min_run_ns":            minRunNs,
+		"impl_warmup":           *implWarmupFlag && !*isolateFlag,
+		"gc_before_run":         *gcBeforeFlag,
+		"gogc":                  gogc,
+		"seeds":                 seeds,
+		"impls":                 implNames,
+		"scenarios":             scenarios,
+		"parallel":              *parallelFlag,
+		"ordering":              ordering,
+		"auto_Ns":               *autoNsFlag,
+		"goroutines":            params["CONCURRENT_WRITE"]["goroutines"],
+		"dram_row_bytes":        params["BANK_CONFLICT"]["row_bytes"],
+		"branch_density":        params["BRANCH_PREDICTABLE"]["density"],
+		"burst_size":            params["WRITE_BURST_1US"]["burst_size"],
+		"temporal_window":       params["TEMPORAL_LOCALITY"]["window"],
+		"cache_color_offset":    *cacheColorFlag,
+		"cache_sizes":           cacheSizes,
+		"cache_sizes_source":    map[bool]string{false: "fallback", true: "detected"}[cacheSizesDetected],
+		"cache_bytes":           cacheBytes,
+		"memory_bandwidth_gbps": bandwidth,
+		"flush_every":           *flushEveryFlag,
+		"benchmark_id":          runner.BenchmarkID,
+		"metadata":              annotations,
+		"append":                *appendFlag,
+		"output_rate_limit":     *rateLimitFlag,
+		"telemetry_ws":          *telemetryFlag,
+		"total_budget":          budgetFlag.String(),
+		"budget_cuts":           budgetCuts,
+		"estimated":             estimate.String(),
+		"outfiles":              []string(outfiles),
+		"repeat_until_stable":   *stableFlag,
+		"auto_rerun":            runner.AutoRerun,
+		"isolate":               *isolateFlag,
+		"sync_io":               *syncIOFlag,
+		"redis_addr":            *redisAddrFlag,
+		"instrument":            *instrumentFlag,
+		"instrument_trace":      *instrTraceFlag,
+		"elem_type":             *elemTypeFlag,
+		"scenario_params":       params,
+	}
+	for _, path := range outfiles {
+		writeMeta(path+".meta.json", meta)
+		writeImpls(path+".impls.json", selected)
+	}
+
+	limited := newRateLimiter(MultiWriter(outs...), *rateLimitFlag)
+	out := ResultWriter(limited)
+	runner.Writers = []ResultWriter{out}
+	fmt.Fprintf(stdout, "benchmark-id: %s\n", runner.BenchmarkID)
+	rows := 0
+	runner.OnRunComplete = func(Result) { rows++ }
+	closeAll := func() {
+		if err := out.Close(); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+	// The first SIGINT/SIGTERM cancels the run: running cells finish and the
+	// outputs are flushed and closed. A second one exits at once.
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	var sig os.Signal
+	go func() {
+		sig = <-sigs
+		fmt.Fprintf(stderr, "%v: finishing running cells (again to quit now)\n", sig)
+		cancel()
+		<-sigs
+		os.Exit(130)
+	}()
+	results, err := runner.Run(ctx)
+	closeAll()
+	if ctx.Err() != nil {
+		fmt.Fprintf(stderr, "%v: wrote %d rows to %s\n", sig, rows, strings.Join(outfiles, ", "))
+		return 130
+	}
+	var verr *VerifyError
+	if errors.As(err, &verr) {
+		fmt.Fprintf(stderr, "%v; rows so far in %s\n", err, strings.Join(outfiles, ", "))
+		return 1
+	}
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(stdout, "Wrote %s\n", strings.Join(outfiles, ", "))
+	hot := 0
+	for _, res := range results {
+		if res.Throttled != nil && *res.Throttled {
+			hot++
+		}
+	}
+	if hot > 0 {
+		fmt.Fprintf(stderr, "%d of %d runs ran more than %g%% below the starting CPU clock (throttled=true); -interleave spreads that over the cells\n", hot, len(results), *throttleFlag*100)
+	}
+	if *sinkFlag {
+		fmt.Fprintf(stdout, "sink: %#x\n", Sink)
+	}
+	if *rateLimitFlag > 0 {
+		rows, rate := limited.throughput()
+		fmt.Fprintf(stdout, "output-rate-limit %g/s: wrote %d rows at %.1f/s\n", *rateLimitFlag, rows, rate)
+	}
+	return 0
+}
+
+// readMedians loads a results CSV and returns the median ns_per_op per
+// impl/scenario/N cell, pooling seeds, as Results.Cells computes it; with
+// subtract set, after Results.SubtractBaseline(subtract).
+func readMedians(path, subtract string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: empty file", path)
+	}
+	col := map[string]int{}
+	for i, h := range rows[0] {
+		col[h] = i
+	}
+	for _, h := range []string{"impl_name", "scenario", "N", "ns_per_op"} {
+		if _, ok := col[h]; !ok {
+			return nil, fmt.Errorf("%s: missing column %s", path, h)
+		}
+	}
+	var rs Results
+	for _, r := range rows[1:] {
+		v, err := strconv.ParseFloat(r[col["ns_per_op"]], 64)
+		if err != nil {
+			continue
+		}
+		n, _ := strconv.Atoi(r[col["N"]])
+		phase := ""
+		if i, ok := col["phase"]; ok {
+			phase = r[i]
+		}
+		if i, ok := col["discarded"]; ok && r[i] == "true" {
+			continue
+		}
+		dispatch := ""
+		if i, ok := col["dispatch"]; ok {
+			dispatch = r[i]
+		}
+		rs = append(rs, Result{Impl: r[col["impl_name"]], Scenario: r[col["scenario"]], Phase: phase, Dispatch: dispatch, N: n, NsPerOp: v, Status: "ok"})
+	}
+	if subtract != "" {
+		rs = rs.SubtractBaseline(subtract)
+	}
+	med := map[string]float64{}
+	for _, c := range rs.Cells() {
+		med[fmt.Sprintf("%s\t%s\t%d", c.Impl, c.label(), c.N)] = c.Median
+	}
+	return med, nil
+}
+
+// cmdCompare prints the per-cell median ns/op of two result files and their
+// ratio (b/a) for the cells present in both.
+func cmdCompare(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: compare [-subtract IMPL | -subtract-overhead] a.csv b.csv")
+		fs.PrintDefaults()
+	}
+	subtractFlag := fs.String("subtract", "", "take this impl's median ns/op (e.g. go_noop's) off every other impl's cells in both files first")
+	overheadFlag := fs.Bool("subtract-overhead", false, "-subtract "+OverheadImpl+": compare what is left after the harness's own cost")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	if *overheadFlag {
+		if *subtractFlag != "" && *subtractFlag != OverheadImpl {
+			fmt.Fprintln(stderr, "compare: -subtract-overhead subtracts "+OverheadImpl+", not "+*subtractFlag)
+			return 2
+		}
+		*subtractFlag = OverheadImpl
+	}
+	a, err := readMedians(fs.Arg(0), *subtractFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	b, err := readMedians(fs.Arg(1), *subtractFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var keys []string
+	for k := range a {
+		if _, ok := b[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "impl\tscenario\tN\ta_ns_per_op\tb_ns_per_op\tb/a")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%.4f\t%.4f\t%.3f\n", k, a[k], b[k], b[k]/a[k])
+	}
+	tw.Flush()
+	return 0
+}
+
+func cmdVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	implsFlag := fs.String("impls", "all", "comma-separated implementations, NAME{key=v,...} for a family variant, or \"all\"")
+	NsFlag := fs.String("Ns", "0,1,7,1000", "comma-separated sizes")
+	opsFlag := fs.Int("ops", 100000, "operations per implementation and size; every impl replays the same sequence")
+	seedFlag := fs.Int64("seed", 42, "seed")
+	scenariosFlag := fs.String("scenarios", "all", "scenarios to check for writes against go_immutable_int64, or \"all\"")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	selected, err := SelectImpls(*implsFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	scenarios, err := SelectScenarios(*scenariosFlag)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	failed := false
+	sizes := ParseSizes(*NsFlag)
+	for _, impl := range selected {
+		if impl.Meta.Has(CapReadOnly) {
+			fmt.Fprintf(stdout, "skip %s: read-only\n", impl.Name)
+			continue
+		}
+		if impl.Meta.Has(CapNoop) {
+			fmt.Fprintf(stdout, "skip %s: stores nothing\n", impl.Name)
+			continue
+		}
+		for _, N := range sizes {
+			ops := *opsFlag
+			if linearAccess[impl.Name] {
+				// Every op walks O(N) links; keep the total walk bounded.
+				ops = min(ops, max(1000, int(4e8)/(N+1)))
+			}
+			if d := VerifyImpl(impl, N, ops, *seedFlag); d != nil {
+				fmt.Fprintf(stdout, "FAIL %s N=%d: %v (reproduce: verify -impls %s -Ns %d -ops %d -seed %d)\n",
+					impl.Name, N, d, impl.Name, d.Repro.N, d.Repro.Ops, d.Repro.Seed)
+				failed = true
+			} else {
+				fmt.Fprintf(stdout, "ok   %s N=%d\n", impl.Name, N)
+			}
+		}
+	}
+	// Flag every scenario that writes after Init; that is only a failure for
+	// the ones declared read-only. Exclusive scenarios and those that need a
+	// capability cannot run on the immutable array.
+	N := 1
+	for _, n := range sizes {
+		N = max(N, n)
+	}
+	for _, sc := range scenarios {
+		if s, _ := LookupScenario(sc); ExclusiveScenarios[sc] || s.Requires != 0 {
+			continue
+		}
+		err := CheckReadOnly(sc, N, *seedFlag, nil)
+		switch {
+		case err == nil:
+			fmt.Fprintf(stdout, "ok   %s: no writes after Init\n", sc)
+		case ReadOnlyScenarios[sc]:
+			fmt.Fprintf(stdout, "FAIL %s: declared read-only but %v\n", sc, err)
+			failed = true
+		default:
+			fmt.Fprintf(stdout, "note %s writes: %v\n", sc, err)
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// cmdList prints the registered implementations and known scenarios.
+func cmdList(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	verbose := fs.Bool("v", false, "also print each impl's model: Init and op complexity, extra space and concurrency")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	model := ""
+	if *verbose {
+		model = "init\top\textra space\tconcurrency\t"
+	}
+	fmt.Fprintln(tw, "impl\tbytes/elem\tcaps\t"+model+"options (-impls NAME{key=v})\tdescription")
+	for _, impl := range Impls() {
+		m := impl.Meta
+		opts := implParams(impl)
+		if opts == "" {
+			opts = "-"
+		}
+		if *verbose {
+			model = ""
+			for _, f := range []string{string(m.Model.Init), string(m.Model.Op), m.Model.ExtraSpace, string(m.Model.Concurrency)} {
+				if f == "" {
+					f = "-"
+				}
+				model += f + "\t"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%g\t%v\t%s%s\t%s\n", impl.Name, m.BytesPerElem(), m.Caps, model, opts, m.Description)
+	}
+	tw.Flush()
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(tw, "family (-impl-params, -impls NAME{key=v})\tdefaults")
+	for _, f := range Families() {
+		var kv []string
+		for k, v := range f.Defaults {
+			kv = append(kv, k+"="+v)
+		}
+		sort.Strings(kv)
+		fmt.Fprintf(tw, "%s\t%s\n", f.Name, strings.Join(kv, ","))
+	}
+	tw.Flush()
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(tw, "scenario\tparams (-scenario-params)")
+	for _, sc := range Scenarios() {
+		mark := ""
+		if sc.OptIn {
+			mark = " (opt-in)"
+		}
+		params := FormatParams(sc.Params)
+		if params == "" {
+			params = "-"
+		}
+		fmt.Fprintf(tw, "%s%s\t%s\n", sc.Name, mark, params)
+	}
+	tw.Flush()
+	return 0
+}
+
+// parseFlags parses args into fs, which does not exit on errors, and gives
+// the exit code flag.ExitOnError would have used when it does not parse:
+// 0 after -h, 2 for a bad flag (fs has printed why).
+func parseFlags(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	err := fs.Parse(args)
+	switch {
+	case err == nil:
+		return 0, true
+	case errors.Is(err, flag.ErrHelp):
+		return 0, false
+	}
+	return 2, false
+}
+
+const usage = `usage: inplacebench <command> [flags]
+
+commands:
+  run      run the benchmark sweep (default when only flags are given)
+  compare  compare the per-cell medians of two result files
+  verify   check implementations against a reference model, without timing
+  list     list implementations and scenarios
+
+Run "inplacebench <command> -h" for the flags of a command.
+`
+
+// Main is the command-line front end: it runs the subcommand named in
+// os.Args and exits. A program that registers its own implementations or
+// scenarios in init functions and then calls Main gets the full harness,
+// with those registrations selectable, listed and benchmarked exactly like
+// the built-ins (the -isolate child re-executes that same program).
+func Main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches args to their subcommand, a bare list of flags being a
+// legacy invocation of run, and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "run":
+		return cmdRun(args, stdout, stderr)
+	case "compare":
+		return cmdCompare(args, stdout, stderr)
+	case "verify":
+		return cmdVerify(args, stdout, stderr)
+	case "list":
+		return cmdList(args, stdout, stderr)
+	case "cell":
+		// Internal: one cell of an -isolate run, see ServeCell.
+		if err := ServeCell(args); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", cmd, usage)
+	return 2
+}
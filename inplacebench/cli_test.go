@@ -0,0 +1,142 @@
+package inplacebench
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCLI runs the command line args as Main would and returns its exit code
+// and what it printed.
+func runCLI(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut strings.Builder
+	code = run(args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// sweepArgs is a one-run sweep of go_slice_int64 writing to path.
+func sweepArgs(path string) []string {
+	return []string{"-impls", "go_slice_int64", "-scenarios", "WRITE_RANDOM", "-Ns", "100", "-reps", "1", "-no-batch", "-outfile", path}
+}
+
+func TestCLIRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "r.csv")
+	code, stdout, stderr := runCLI(t, append([]string{"run"}, sweepArgs(path)...)...)
+	if code != 0 {
+		t.Fatalf("run exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "benchmark-id: ") || !strings.Contains(stdout, "Wrote "+path) {
+		t.Fatalf("run printed %q", stdout)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 2 {
+		t.Fatalf("%s has %d lines, want a header and one row", path, lines)
+	}
+	for _, side := range []string{".meta.json", ".impls.json"} {
+		if _, err := os.Stat(path + side); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestCLILegacy checks that flags without a subcommand are a run.
+func TestCLILegacy(t *testing.T) {
+	dry := []string{"-dry-run", "-impls", "go_slice_int64,go_atomic_int64", "-scenarios", "WRITE_RANDOM", "-Ns", "10,100", "-reps", "2", "-no-batch"}
+	_, want, _ := runCLI(t, append([]string{"run"}, dry...)...)
+	code, got, stderr := runCLI(t, dry...)
+	if code != 0 {
+		t.Fatalf("legacy dry run exited %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(got, "8 runs, estimated ") || got != want {
+		t.Fatalf("legacy dry run printed %q, run -dry-run %q", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "r.csv")
+	if code, stdout, stderr := runCLI(t, sweepArgs(path)...); code != 0 || !strings.Contains(stdout, "Wrote "+path) {
+		t.Fatalf("legacy run exited %d, printed %q: %s", code, stdout, stderr)
+	}
+}
+
+func TestCLICompare(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv")
+	for _, path := range []string{a, b} {
+		if code, _, stderr := runCLI(t, sweepArgs(path)...); code != 0 {
+			t.Fatalf("run exited %d: %s", code, stderr)
+		}
+	}
+	code, stdout, stderr := runCLI(t, "compare", a, b)
+	if code != 0 {
+		t.Fatalf("compare exited %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "impl") || !strings.HasPrefix(lines[1], "go_slice_int64") {
+		t.Fatalf("compare printed %q, want a header and the one cell", stdout)
+	}
+	if code, _, stderr := runCLI(t, "compare", a); code != 2 || !strings.Contains(stderr, "usage: compare") {
+		t.Fatalf("compare with one file exited %d: %q", code, stderr)
+	}
+	if code, _, _ := runCLI(t, "compare", a, filepath.Join(dir, "missing.csv")); code != 1 {
+		t.Fatalf("compare with a missing file exited %d, want 1", code)
+	}
+}
+
+func TestCLIVerify(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "verify", "-impls", "go_slice_int64,go_immutable_int64", "-Ns", "10", "-ops", "1000", "-scenarios", "READ_UNWRITTEN,WRITE_RANDOM")
+	if code != 0 {
+		t.Fatalf("verify exited %d: %s%s", code, stdout, stderr)
+	}
+	for _, want := range []string{"ok   go_slice_int64 N=10\n", "skip go_immutable_int64: read-only\n", "ok   READ_UNWRITTEN: no writes after Init\n", "note WRITE_RANDOM writes: "} {
+		if !strings.Contains(stdout, want) {
+			t.Fatalf("verify printed %q, want a line %q", stdout, want)
+		}
+	}
+}
+
+func TestCLIList(t *testing.T) {
+	code, stdout, _ := runCLI(t, "list")
+	if code != 0 {
+		t.Fatalf("list exited %d", code)
+	}
+	for _, impl := range Impls() {
+		if !strings.Contains(stdout, "\n"+impl.Name+" ") {
+			t.Fatalf("list leaves out %s", impl.Name)
+		}
+	}
+	for _, sc := range []string{"WRITE_RANDOM", "FIRST_TOUCH_CURVE (opt-in)"} {
+		if !strings.Contains(stdout, "\n"+sc+" ") {
+			t.Fatalf("list leaves out %s", sc)
+		}
+	}
+}
+
+func TestCLIHelp(t *testing.T) {
+	if code, stdout, _ := runCLI(t, "help"); code != 0 || stdout != usage {
+		t.Fatalf("help exited %d, printed %q", code, stdout)
+	}
+	// -h is not an error, for run and for a bare invocation alike.
+	for _, args := range [][]string{{"run", "-h"}, {"-h"}, {"list", "-h"}} {
+		if code, _, stderr := runCLI(t, args...); code != 0 || !strings.HasPrefix(stderr, "Usage of ") {
+			t.Fatalf("%v exited %d, printed %q", args, code, stderr)
+		}
+	}
+}
+
+func TestCLIUnknown(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "frobnicate", "-Ns", "10")
+	if code != 2 || stdout != "" || !strings.HasPrefix(stderr, `unknown command "frobnicate"`) || !strings.HasSuffix(stderr, usage) {
+		t.Fatalf("unknown command exited %d, printed %q and %q", code, stdout, stderr)
+	}
+	// A bad flag or a bad value is a usage error too.
+	for _, args := range [][]string{{"run", "-no-such-flag"}, {"-impls", "no_such_impl"}, {"verify", "-impls", "no_such_impl"}, {"list", "-x"},
+		{"-scenarios", "NO_SUCH_SCENARIO"}, {"-auto-Ns", "points=3"}, {"-repeat-until-stable", "cv=0"}} {
+		if code, _, stderr := runCLI(t, args...); code != 2 || stderr == "" {
+			t.Fatalf("%v exited %d, printed %q", args, code, stderr)
+		}
+	}
+}
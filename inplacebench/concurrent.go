@@ -0,0 +1,150 @@
+package inplacebench
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentSizes are the sizes CheckConcurrent runs at in Selftest: small,
+// so the goroutines keep colliding on the same indices.
+var ConcurrentSizes = []int{1, 7, 64}
+
+// concurrentDefault is the Init value; written values are never negative.
+const concurrentDefault = -1
+
+// Each written value packs the index (16 bits), the writer (8 bits) and the
+// writer's sequence number (24 bits, from 1) under a 15-bit check of all
+// three, so a reader can tell which Write stored what it read and a value
+// torn between two writes almost never decodes.
+func concurrentVal(i, g, seq int) int64 {
+	v := int64(i)<<32 | int64(g)<<24 | int64(seq)
+	return concurrentCheck(v)<<48 | v
+}
+
+func concurrentCheck(v int64) int64 { return int64(uint64(v)*0x9e3779b97f4a7c15>>49) & 0x7fff }
+
+// concurrentDecode undoes concurrentVal, with ok false for a value it
+// cannot have produced.
+func concurrentDecode(v int64) (i, g, seq int, ok bool) {
+	low := v & (1<<48 - 1)
+	i, g, seq = int(low>>32), int(low>>24&0xff), int(low&0xffffff)
+	return i, g, seq, v >= 0 && seq > 0 && concurrentCheck(low) == v>>48
+}
+
+// CheckConcurrent hammers impl.New(N) from goroutines goroutines, each making
+// ops random Reads and Writes (half each), and checks the guarantee the
+// concurrent scenarios assume of a CapConcurrent impl:
+//
+//   - a Read of i returns the Init value or a value some goroutine wrote to
+//     i, with a Write that had at least started;
+//   - reads of i by one goroutine never see one writer's values out of
+//     order, nor the Init value again once they saw a written one.
+//
+// Under the race detector (go test -race, go run -race) it also surfaces
+// data races. N must be below 1<<16, ops below 1<<24 and goroutines
+// below 256.
+func CheckConcurrent(impl Impl, N, goroutines, ops int) error {
+	if N == 0 {
+		return nil
+	}
+	arr := impl.New(N)
+	if c, ok := arr.(io.Closer); ok {
+		defer c.Close()
+	}
+	arr.Init(concurrentDefault)
+	G := goroutines
+	started := make([]atomic.Int64, G)
+	errs := make([]error, G)
+	var wg sync.WaitGroup
+	for g := 0; g < G; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(g)))
+			last := make([]int, N*G)
+			written := make([]bool, N)
+			seq := 1
+			for k := 0; k < ops; k++ {
+				i := rng.Intn(N)
+				if rng.Intn(2) == 0 {
+					started[g].Store(int64(seq))
+					arr.Write(i, concurrentVal(i, g, seq))
+					seq++
+					continue
+				}
+				v := arr.Read(i)
+				if v == concurrentDefault {
+					if written[i] {
+						errs[g] = fmt.Errorf("%s N=%d: Read(%d) returned the Init value after a written one", impl.Name, N, i)
+						return
+					}
+					continue
+				}
+				wi, wr, ws, ok := concurrentDecode(v)
+				switch {
+				case !ok || wi != i || wr >= G:
+					errs[g] = fmt.Errorf("%s N=%d: Read(%d) = %#x, which no goroutine wrote there", impl.Name, N, i, v)
+				case int64(ws) > started[wr].Load():
+					errs[g] = fmt.Errorf("%s N=%d: Read(%d) returned write %d of goroutine %d before it started", impl.Name, N, i, ws, wr)
+				case ws < last[i*G+wr]:
+					errs[g] = fmt.Errorf("%s N=%d: Read(%d) returned write %d of goroutine %d after its write %d", impl.Name, N, i, ws, wr, last[i*G+wr])
+				}
+				if errs[g] != nil {
+					return
+				}
+				last[i*G+wr], written[i] = ws, true
+			}
+		}(g)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConcurrentImpls runs CheckConcurrent over every CapConcurrent impl
+// at ConcurrentSizes, and COMPARE_EXCHANGE over the CapCAS ones.
+func checkConcurrentImpls(impls []Impl, goroutines, ops int) error {
+	for _, impl := range impls {
+		if !impl.Meta.Has(CapConcurrent) {
+			continue
+		}
+		for _, N := range ConcurrentSizes {
+			if err := CheckConcurrent(impl, N, goroutines, ops); err != nil {
+				return err
+			}
+			if !impl.Meta.Has(CapCAS) {
+				continue
+			}
+			if err := checkCompareExchange(impl, N, goroutines); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCompareExchange runs COMPARE_EXCHANGE on impl at N from goroutines
+// goroutines and from one, which has nothing to race and must never fail
+// a swap; the scenario itself checks the sum.
+func checkCompareExchange(impl Impl, N, goroutines int) error {
+	for _, G := range []int{goroutines, 1} {
+		var res RunResult
+		params := map[string]string{"goroutines": strconv.Itoa(G)}
+		if err := catchPanic(func() { res = runScenario(impl.New(N), "COMPARE_EXCHANGE", N, 1, params) }); err != nil {
+			return fmt.Errorf("%s N=%d: COMPARE_EXCHANGE: %v", impl.Name, N, err)
+		}
+		s, f := *res.Conversions, *res.Relocations
+		if s+f != int64(G*min(1000000, N)) || G == 1 && f != 0 {
+			return fmt.Errorf("%s N=%d: COMPARE_EXCHANGE from %d goroutines: %d swaps and %d failures", impl.Name, N, G, s, f)
+		}
+	}
+	return nil
+}
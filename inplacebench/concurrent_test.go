@@ -0,0 +1,16 @@
+package inplacebench
+
+import "testing"
+
+// TestRaceArrays runs CheckConcurrent for every selected CapConcurrent
+// impl. Run it with go test -race, so the race detector sees the same
+// traffic; INPLACEBENCH_IMPLS (default "all") narrows the set.
+func TestRaceArrays(t *testing.T) {
+	selected, err := SelectImpls(envOr("INPLACEBENCH_IMPLS", "all"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConcurrentImpls(selected, 8, 20000); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,214 @@
+package inplacebench
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CurvePoint is one sample of a FIRST_TOUCH_CURVE run: the time since its
+// clock started when the Distinct-th index was first written.
+type CurvePoint struct {
+	Distinct     int
+	CumulativeNs int64
+}
+
+// CurveHeader is the header of the amortization sidecar, one row per
+// CurvePoint of every run that has a curve.
+var CurveHeader = []string{"run_id", "impl_name", "N", "seed", "rep_id", "distinct_cells", "cumulative_ns"}
+
+// touchSet records which of N indices have been written: one bit each, in
+// the harness, so the impl under test does not count its own first touches.
+type touchSet []uint64
+
+func newTouchSet(N int) touchSet { return make(touchSet, (N+63)/64) }
+
+// add marks i and reports whether it was new.
+func (t touchSet) add(i int) bool {
+	w, b := i>>6, uint64(1)<<(i&63)
+	if t[w]&b != 0 {
+		return false
+	}
+	t[w] |= b
+	return true
+}
+
+func (t touchSet) count() int {
+	n := 0
+	for _, w := range t {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// runFirstTouchCurve is FIRST_TOUCH_CURVE: WRITE_RANDOM's min(1M, N)
+// writes, with the indices drawn before the clock starts, sampling the
+// elapsed time at every step-th distinct index first written and once more
+// at the end. The harness cost is a bit test per write, a bit set per first
+// touch and a clock read per sample, at most M/step+1 of them; touchSet
+// adds N/8 bytes to the working set. relocations_count is the number of
+// distinct indices written.
+func runFirstTouchCurve(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+	arr.Init(0)
+	M := min(1000000, N)
+	step := int(ctx.Params["step"])
+	if step < 1 {
+		step = max(1, N/1000)
+	}
+	idx := ctx.Buffers.Ints(M)
+	for k := range idx {
+		idx[k] = rng.Intn(N)
+	}
+	seen := newTouchSet(N)
+	curve := make([]CurvePoint, 0, M/step+1)
+	distinct := 0
+	start := time.Now()
+	for _, j := range idx {
+		arr.Write(j, randVal(rng))
+		if seen.add(j) {
+			if distinct++; distinct%step == 0 {
+				curve = append(curve, CurvePoint{distinct, time.Since(start).Nanoseconds()})
+			}
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	if distinct%step != 0 {
+		curve = append(curve, CurvePoint{distinct, el})
+	}
+	res := timedOps(M, el)
+	d := int64(distinct)
+	res.Relocations, res.Curve = &d, curve
+	return res
+}
+
+// CurveWriter writes the Curve of every result that has one to a CSV with
+// CurveHeader, the amortization sidecar; other results are skipped.
+type CurveWriter struct {
+	dst io.Writer
+	w   *csv.Writer
+}
+
+// NewCurveWriter writes CurveHeader to w unless header is false, as when
+// appending to a sidecar that has it.
+func NewCurveWriter(w io.Writer, header bool) (*CurveWriter, error) {
+	c := &CurveWriter{dst: w, w: csv.NewWriter(w)}
+	if header {
+		if err := c.w.Write(CurveHeader); err != nil {
+			return nil, err
+		}
+	}
+	return c, c.Flush()
+}
+
+// OpenCurveWriter creates the sidecar at path, or with appending extends
+// it, writing the header only into an empty file.
+func OpenCurveWriter(path string, appending bool) (*CurveWriter, error) {
+	mode := os.O_TRUNC
+	if appending {
+		mode = os.O_APPEND
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|mode, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err == nil {
+		var c *CurveWriter
+		if c, err = NewCurveWriter(f, st.Size() == 0); err == nil {
+			return c, nil
+		}
+	}
+	f.Close()
+	return nil, err
+}
+
+func (c *CurveWriter) Write(r Result) error {
+	for _, p := range r.Curve {
+		rec := []string{r.RunID, r.Impl, strconv.Itoa(r.N), strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Rep),
+			strconv.Itoa(p.Distinct), strconv.FormatInt(p.CumulativeNs, 10)}
+		if err := c.w.Write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *CurveWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+func (c *CurveWriter) Close() error {
+	err := c.Flush()
+	if cerr := closeUnderlying(c.dst); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// checkFirstTouchCurve checks FIRST_TOUCH_CURVE's samples against a replay
+// of its indices, that the curve survives a child's JSON result, and that
+// a Runner hands it to a CurveWriter, one row per sample of that run only.
+func checkFirstTouchCurve() error {
+	const N, step = 1000, 50
+	arr := NewSliceImpl(N)
+	res := runScenario(arr, "FIRST_TOUCH_CURVE", N, 7, map[string]string{"step": strconv.Itoa(step)})
+	rng := rand.New(rand.NewSource(7))
+	seen := newTouchSet(N)
+	want := make([]int64, N)
+	idx := make([]int, N)
+	for k := range idx {
+		idx[k] = rng.Intn(N)
+	}
+	for _, j := range idx {
+		seen.add(j)
+		want[j] = randVal(rng)
+	}
+	distinct := seen.count()
+	if res.Relocations == nil || *res.Relocations != int64(distinct) || len(res.Curve) != (distinct+step-1)/step {
+		return fmt.Errorf("first-touch: %d samples, relocations %v; want %d distinct indices every %d", len(res.Curve), res.Relocations, distinct, step)
+	}
+	for k, p := range res.Curve {
+		wantD := min((k+1)*step, distinct)
+		if p.Distinct != wantD || p.CumulativeNs < 0 || p.CumulativeNs > res.TotalNs || k > 0 && p.CumulativeNs < res.Curve[k-1].CumulativeNs {
+			return fmt.Errorf("first-touch: sample %d is %+v, want %d distinct within %d ns and after the last", k, p, wantD, res.TotalNs)
+		}
+	}
+	if last := res.Curve[len(res.Curve)-1]; distinct%step != 0 && last.CumulativeNs != res.TotalNs {
+		return fmt.Errorf("first-touch: last sample at %d ns, run took %d", last.CumulativeNs, res.TotalNs)
+	}
+	for i, v := range want {
+		if arr.Read(i) != v {
+			return fmt.Errorf("first-touch: %d at %d, want %d", arr.Read(i), i, v)
+		}
+	}
+	var back Result
+	if b, err := json.Marshal(Result{Curve: res.Curve}); err != nil || json.Unmarshal(b, &back) != nil || fmt.Sprint(back.Curve) != fmt.Sprint(res.Curve) {
+		return fmt.Errorf("first-touch: the curve does not survive a child's JSON result")
+	}
+	slice, _ := Lookup("go_slice_int64")
+	var b strings.Builder
+	cw, _ := NewCurveWriter(&b, true)
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"FIRST_TOUCH_CURVE", "WRITE_RANDOM"}, Ns: []int{N}, Seeds: []int64{1}, Reps: 1,
+		NoBatch: true, Params: ScenarioParams{"FIRST_TOUCH_CURVE": {"step": strconv.Itoa(step)}}, Writers: []ResultWriter{cw}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("first-touch: %v", err)
+	}
+	cw.Flush()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) < 2 || lines[0] != strings.Join(CurveHeader, ",") || !strings.HasPrefix(lines[1], "go_slice_int64/FIRST_TOUCH_CURVE/1000/1/1,go_slice_int64,1000,1,1,50,") {
+		return fmt.Errorf("first-touch: sidecar %q", lines)
+	}
+	for _, l := range lines[1:] {
+		if !strings.HasPrefix(l, "go_slice_int64/FIRST_TOUCH_CURVE/") {
+			return fmt.Errorf("first-touch: sidecar row %q is not FIRST_TOUCH_CURVE's", l)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package inplacebench
+
+import (
+	"os"
+	"syscall"
+)
+
+// dropPageCache syncs and writes 1 to /proc/sys/vm/drop_caches, which
+// drops clean page-cache pages but not dentries and inodes.
+func dropPageCache() error {
+	syscall.Sync()
+	return os.WriteFile("/proc/sys/vm/drop_caches", []byte("1\n"), 0)
+}
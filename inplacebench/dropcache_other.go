@@ -0,0 +1,8 @@
+//go:build !linux
+
+package inplacebench
+
+import "errors"
+
+// dropPageCache has no portable equivalent off Linux.
+func dropPageCache() error { return errors.New("needs Linux") }
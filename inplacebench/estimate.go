@@ -0,0 +1,201 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSizes parses a comma-separated size list with optional k/m/g
+// (decimal) suffixes, skipping entries that do not parse.
+func ParseSizes(s string) []int {
+	parts := strings.Split(s, ",")
+	var out []int
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		mult := 1.0
+		if strings.HasSuffix(p, "k") || strings.HasSuffix(p, "K") {
+			p = p[:len(p)-1]
+			mult = 1e3
+		}
+		if strings.HasSuffix(p, "m") || strings.HasSuffix(p, "M") {
+			p = p[:len(p)-1]
+			mult = 1e6
+		}
+		if strings.HasSuffix(p, "g") || strings.HasSuffix(p, "G") {
+			p = p[:len(p)-1]
+			mult = 1e9
+		}
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, int(f*mult))
+	}
+	return out
+}
+
+// estSetupNsPerOp covers the untimed per-op setup (RNG draws, index buffers).
+const estSetupNsPerOp = 10
+
+// scenarioOps is the op count the scenario declares for N.
+func scenarioOps(scenario string, N int) int {
+	if sc, ok := LookupScenario(scenario); ok && sc.Ops != nil {
+		return sc.Ops(N)
+	}
+	return min(1000000, N)
+}
+
+// EstimateCell is the dry-run estimate for one cell: Init over N elements
+// plus the scenario's ops, at the impl's estimated cost.
+func EstimateCell(c Cell) time.Duration {
+	ops := float64(scenarioOps(c.Scenario, c.N))
+	perOp := c.Impl.Meta.EstNsPerOp
+	if linearAccess[c.Impl.Name] && c.Scenario != "TRAVERSE_FORWARD" {
+		perOp *= math.Max(1, float64(c.N)/2)
+	}
+	ns := float64(c.N)*c.Impl.Meta.EstNsPerOp + ops*(perOp+estSetupNsPerOp)
+	return time.Duration(ns)
+}
+
+// EstimatePlan sums EstimateCell over cells.
+func EstimatePlan(cells []Cell) time.Duration {
+	var d time.Duration
+	for _, c := range cells {
+		d += EstimateCell(c)
+	}
+	return d
+}
+
+// TrimToBudget cuts the matrix until its estimate fits the budget: first reps
+// one at a time down to 1, then the largest N one at a time while more than
+// one size remains. It returns the reduced reps and sizes and a description of
+// each cut.
+func TrimToBudget(budget time.Duration, plan func(Nlist []int, reps int) []Cell, Nlist []int, reps int) ([]int, int, []string) {
+	var cuts []string
+	for reps > 1 && EstimatePlan(plan(Nlist, reps)) > budget {
+		reps--
+		cuts = append(cuts, fmt.Sprintf("reps -> %d", reps))
+	}
+	Nlist = append([]int(nil), Nlist...)
+	sort.Ints(Nlist)
+	for len(Nlist) > 1 && EstimatePlan(plan(Nlist, reps)) > budget {
+		cuts = append(cuts, fmt.Sprintf("drop N=%d", Nlist[len(Nlist)-1]))
+		Nlist = Nlist[:len(Nlist)-1]
+	}
+	return Nlist, reps, cuts
+}
+
+// parseBytes parses a byte count with an optional k/m/g/t (binary) suffix.
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(s, "B"), "b")))
+	mult := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		case 't':
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad byte size %q", s)
+	}
+	return int64(f * mult), nil
+}
+
+// footprintBytes estimates peak memory of one run: the array itself plus the
+// index and op-kind buffers the scenarios allocate.
+func footprintBytes(impl Impl, N int) float64 {
+	return float64(N)*impl.Meta.BytesPerElem() + 2*8*float64(min(1000000, 10*N))
+}
+
+// AutoSizes parses "budget=8g,points=6" and returns up to `points` sizes from
+// 1000 up to the largest 1/2/5 x 10^k size whose footprint fits the budget
+// for the hungriest selected implementation, spaced geometrically and snapped
+// to the 1/2/5 grid.
+func AutoSizes(spec string, selected []Impl) ([]int, error) {
+	var budget int64
+	points := 6
+	for _, kv := range strings.Split(spec, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("-auto-Ns: expected key=value, got %q", kv)
+		}
+		switch k {
+		case "budget":
+			b, err := parseBytes(v)
+			if err != nil {
+				return nil, fmt.Errorf("-auto-Ns: %v", err)
+			}
+			budget = b
+		case "points":
+			p, err := strconv.Atoi(v)
+			if err != nil || p < 1 {
+				return nil, fmt.Errorf("-auto-Ns: bad points %q", v)
+			}
+			points = p
+		default:
+			return nil, fmt.Errorf("-auto-Ns: unknown key %q", k)
+		}
+	}
+	if budget <= 0 {
+		return nil, fmt.Errorf("-auto-Ns: budget is required")
+	}
+
+	fits := func(N int) bool {
+		for _, impl := range selected {
+			if footprintBytes(impl, N) > float64(budget) {
+				return false
+			}
+		}
+		return true
+	}
+	var grid []int
+	for dec := 1000; dec <= math.MaxInt/10; dec *= 10 {
+		for _, m := range []int{1, 2, 5} {
+			if fits(m * dec) {
+				grid = append(grid, m*dec)
+			}
+		}
+	}
+	if len(grid) == 0 {
+		return nil, fmt.Errorf("-auto-Ns: budget %d bytes is too small for N=1000", budget)
+	}
+	if points == 1 {
+		return grid[len(grid)-1:], nil
+	}
+	if len(grid) <= points {
+		return grid, nil
+	}
+
+	lo, hi := math.Log(float64(grid[0])), math.Log(float64(grid[len(grid)-1]))
+	var out []int
+	for p := 0; p < points; p++ {
+		target := lo + (hi-lo)*float64(p)/float64(points-1)
+		best := grid[0]
+		for _, g := range grid {
+			if math.Abs(math.Log(float64(g))-target) < math.Abs(math.Log(float64(best))-target) {
+				best = g
+			}
+		}
+		if len(out) == 0 || out[len(out)-1] != best {
+			out = append(out, best)
+		}
+	}
+	return out, nil
+}
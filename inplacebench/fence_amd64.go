@@ -0,0 +1,10 @@
+//go:build amd64
+
+package inplacebench
+
+// fenceKind names the barrier storeFence issues, for op_path.
+const fenceKind = "mfence"
+
+// storeFence is a full memory barrier, MFENCE (fence_amd64.s). It is not
+// inlined, so MEMORY_FENCE's cost per write includes a call.
+func storeFence()
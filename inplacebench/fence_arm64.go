@@ -0,0 +1,10 @@
+//go:build arm64
+
+package inplacebench
+
+// fenceKind names the barrier storeFence issues, for op_path.
+const fenceKind = "dmb"
+
+// storeFence is a full memory barrier, DMB ISH (fence_arm64.s). It is not
+// inlined, so MEMORY_FENCE's cost per write includes a call.
+func storeFence()
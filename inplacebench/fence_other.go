@@ -0,0 +1,14 @@
+//go:build !amd64 && !arm64
+
+package inplacebench
+
+import "sync/atomic"
+
+// fenceKind names the barrier storeFence issues, for op_path.
+const fenceKind = "atomic"
+
+var fenceWord atomic.Uint32
+
+// storeFence has no assembly here: a sequentially consistent atomic add is
+// the portable full barrier.
+func storeFence() { fenceWord.Add(1) }
@@ -0,0 +1,197 @@
+//go:build linux && (amd64 || arm64)
+
+package inplacebench
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fadvDontNeed is POSIX_FADV_DONTNEED.
+const fadvDontNeed = 4
+
+// FileArrayImpl stores element i as 8 little-endian bytes at offset 8i of a
+// temp file and reaches it with one syscall.Pread or syscall.Pwrite per
+// access, so every operation pays a syscall and a page-cache copy. With Sync
+// set every Write (and each Init and WriteRange) is followed by an fsync.
+type FileArrayImpl struct {
+	N    int
+	Sync bool
+	f    *os.File
+	fd   int
+	buf  []byte
+}
+
+func NewFileArrayImpl(n int, sync bool) *FileArrayImpl {
+	f, err := os.CreateTemp("", "go_file_*.bin")
+	if err != nil {
+		panic(err)
+	}
+	return &FileArrayImpl{N: n, Sync: sync, f: f, fd: int(f.Fd()), buf: make([]byte, rangeChunk*8)}
+}
+func (s *FileArrayImpl) Name() string {
+	if s.Sync {
+		return "go_file_sync_int64"
+	}
+	return "go_file_int64"
+}
+func (s *FileArrayImpl) Len() int { return s.N }
+
+func (s *FileArrayImpl) pwrite(b []byte, off int64) {
+	for len(b) > 0 {
+		n, err := syscall.Pwrite(s.fd, b, off)
+		if err != nil {
+			panic(err)
+		}
+		b, off = b[n:], off+int64(n)
+	}
+}
+func (s *FileArrayImpl) pread(b []byte, off int64) {
+	for len(b) > 0 {
+		n, err := syscall.Pread(s.fd, b, off)
+		if err != nil {
+			panic(err)
+		}
+		if n == 0 {
+			panic("pread: short file")
+		}
+		b, off = b[n:], off+int64(n)
+	}
+}
+func (s *FileArrayImpl) sync() {
+	if s.Sync {
+		if err := s.f.Sync(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Init truncates the file and rewrites it in 32 KiB pwrites.
+func (s *FileArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	if err := s.f.Truncate(0); err != nil {
+		panic(err)
+	}
+	for k := 0; k < len(s.buf); k += 8 {
+		binary.LittleEndian.PutUint64(s.buf[k:], uint64(v))
+	}
+	for lo := 0; lo < s.N; lo += rangeChunk {
+		s.pwrite(s.buf[:min(rangeChunk, s.N-lo)*8], int64(lo)*8)
+	}
+	s.sync()
+	return time.Since(start).Nanoseconds()
+}
+func (s *FileArrayImpl) Read(i int) int64 {
+	var b [8]byte
+	s.pread(b[:], int64(i)*8)
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+func (s *FileArrayImpl) Write(i int, v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	s.pwrite(b[:], int64(i)*8)
+	s.sync()
+}
+
+// ReadRange and WriteRange move up to 4096 elements per syscall.
+func (s *FileArrayImpl) ReadRange(start int, out []int64) {
+	for len(out) > 0 {
+		n := min(len(out), rangeChunk)
+		s.pread(s.buf[:n*8], int64(start)*8)
+		for k := range out[:n] {
+			out[k] = int64(binary.LittleEndian.Uint64(s.buf[k*8:]))
+		}
+		start, out = start+n, out[n:]
+	}
+}
+func (s *FileArrayImpl) WriteRange(start int, vals []int64) {
+	for len(vals) > 0 {
+		n := min(len(vals), rangeChunk)
+		for k, v := range vals[:n] {
+			binary.LittleEndian.PutUint64(s.buf[k*8:], uint64(v))
+		}
+		s.pwrite(s.buf[:n*8], int64(start)*8)
+		start, vals = start+n, vals[n:]
+	}
+	s.sync()
+}
+
+// CheckedRead and CheckedWrite check the index first: pwrite past the end
+// would silently grow the file.
+func (s *FileArrayImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *FileArrayImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// DropCache writes the file back and asks the kernel to evict its pages
+// with posix_fadvise(POSIX_FADV_DONTNEED).
+func (s *FileArrayImpl) DropCache() error {
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	if _, _, e := syscall.Syscall6(syscall.SYS_FADVISE64, uintptr(s.fd), 0, 0, fadvDontNeed, 0, 0); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Close removes the backing file.
+func (s *FileArrayImpl) Close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
+
+// MemoryFootprint is the size of the backing file, as for go_jsonfile_int64.
+func (s *FileArrayImpl) MemoryFootprint() int64 {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+func registerFileImpls() {
+	f := Family{
+		Name:     "go_file",
+		Defaults: map[string]string{"sync": "0"},
+		New: func(n int, p map[string]string) (Array, error) {
+			sync, err := boolParam(p, "sync")
+			if err != nil {
+				return nil, err
+			}
+			return NewFileArrayImpl(n, sync), nil
+		},
+		// An fsync per Write costs a device flush, not a syscall.
+		Meta: func(p map[string]string) ImplMeta {
+			if p["sync"] == "1" {
+				return ImplMeta{
+					Description: "pread/pwrite on a temp file, fsync after every Write",
+					ElemBytes:   8,
+					Overhead:    1,
+					EstNsPerOp:  50000,
+					Model:       ImplModel{Init: ONB, Op: O1, ExtraSpace: "O(B)", Concurrency: ConcNone},
+				}
+			}
+			return ImplMeta{
+				Description: "pread/pwrite on a temp file",
+				ElemBytes:   8,
+				Overhead:    1,
+				EstNsPerOp:  400,
+				Model:       ImplModel{Init: ONB, Op: O1, ExtraSpace: "O(B)", Concurrency: ConcNone},
+			}
+		},
+	}
+	RegisterFamily(f)
+	register(f.mustVariant())
+}
@@ -0,0 +1,7 @@
+//go:build !linux || !(amd64 || arm64)
+
+package inplacebench
+
+// go_file_int64 drops pages with a raw fadvise64 syscall, wired up on 64-bit
+// Linux only.
+func registerFileImpls() {}
@@ -0,0 +1,113 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultThrottleThreshold is Runner.ThrottleThreshold's default: a run
+// whose clock fell more than 10% below the sweep's is throttled.
+const DefaultThrottleThreshold = 0.1
+
+// cpuFreq returns the mean current frequency of the online CPUs in MHz,
+// false where this machine does not report it; see cpuFreqSource.
+var cpuFreq = cpuFreqSource
+
+// freqSpan is what the samples of cpuFreq at the start and end of a run
+// say: their minimum and mean, both 0 when either was unreadable.
+type freqSpan struct{ min, avg float64 }
+
+// withFreq runs fn between two samples of cpuFreq. The samples read sysfs
+// or /proc, so they stay outside the scenario's clock and any counters.
+func withFreq(fn func()) freqSpan {
+	start, ok := cpuFreq()
+	fn()
+	end, ok2 := cpuFreq()
+	if !ok || !ok2 {
+		return freqSpan{}
+	}
+	return freqSpan{min(start, end), (start + end) / 2}
+}
+
+// setFreq fills res's frequency columns from s.
+func (s freqSpan) setFreq(res *Result) {
+	res.CPUMHzMin, res.CPUMHzAvg = s.min, s.avg
+}
+
+// throttled is the throttled column of a row whose run's clock fell to
+// minMHz, against the base the sweep started at: nil when either is
+// unknown.
+func throttled(minMHz, base, threshold float64) *bool {
+	if minMHz <= 0 || base <= 0 {
+		return nil
+	}
+	t := minMHz < base*(1-threshold)
+	return &t
+}
+
+// formatMHz is a frequency column, empty when unknown.
+func formatMHz(mhz float64) string {
+	if mhz <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.0f", mhz)
+}
+
+// checkFreq checks the throttle verdict, and that a Runner fills the
+// frequency columns from cpuFreq and flags a run whose clock fell, with
+// cpuFreq replaced by a clock that drops after the first sample.
+func checkFreq() error {
+	for _, c := range []struct {
+		min, base float64
+		want      string
+	}{{0, 3000, ""}, {2900, 0, ""}, {2800, 3000, "false"}, {2600, 3000, "true"}} {
+		if got := throttled(c.min, c.base, DefaultThrottleThreshold); fmtBoolPtr(got) != c.want {
+			return fmt.Errorf("freq: throttled(%v, %v) = %s, want %s", c.min, c.base, fmtBoolPtr(got), c.want)
+		}
+	}
+	defer func(f func() (float64, bool)) { cpuFreq = f }(cpuFreq)
+	samples := 0
+	cpuFreq = func() (float64, bool) {
+		samples++
+		return math.Max(1000, 3200-float64(samples)*400), true
+	}
+	slice, _ := Lookup("go_slice_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{1000}, Seeds: []int64{1}, Reps: 2, NoBatch: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("freq: %v", err)
+	}
+	// The sweep's base is 2800; the runs see 2400 and 2000, then 1600 and
+	// 1200.
+	want := [][2]float64{{2000, 2200}, {1200, 1400}}
+	if len(w.rows) != len(want) {
+		return fmt.Errorf("freq: %d rows, want %d", len(w.rows), len(want))
+	}
+	for i, row := range w.rows {
+		if row.CPUMHzMin != want[i][0] || row.CPUMHzAvg != want[i][1] || row.Throttled == nil || !*row.Throttled {
+			return fmt.Errorf("freq: row %d has %v/%v MHz, throttled %s; want %v, throttled", i, row.CPUMHzMin, row.CPUMHzAvg, fmtBoolPtr(row.Throttled), want[i])
+		}
+	}
+	cpuFreq = func() (float64, bool) { return 0, false }
+	w.rows = nil
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("freq: unreadable: %v", err)
+	}
+	rec := w.rows[0].Record()
+	for _, col := range []string{"cpu_mhz_min", "cpu_mhz_avg", "throttled"} {
+		if v := rec[slices.Index(Header, col)]; v != "" {
+			return fmt.Errorf("freq: unreadable clock gave %s %q", col, v)
+		}
+	}
+	return nil
+}
+
+// fmtBoolPtr is a *bool column, empty when nil.
+func fmtBoolPtr(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return fmt.Sprint(*b)
+}
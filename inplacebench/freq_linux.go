@@ -0,0 +1,58 @@
+package inplacebench
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var cpufreqFiles = sync.OnceValue(func() []string {
+	files, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	return files
+})
+
+// cpuFreqSource averages the CPUs' cpufreq scaling_cur_freq (kHz), or,
+// where there is no cpufreq, as in most VMs, /proc/cpuinfo's "cpu MHz"
+// lines.
+func cpuFreqSource() (float64, bool) {
+	if files := cpufreqFiles(); len(files) > 0 {
+		sum, n := 0.0, 0
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if khz, perr := strconv.ParseFloat(strings.TrimSpace(string(b)), 64); err == nil && perr == nil && khz > 0 {
+				sum, n = sum+khz/1000, n+1
+			}
+		}
+		if n > 0 {
+			return sum / float64(n), true
+		}
+	}
+	return cpuinfoMHz("/proc/cpuinfo")
+}
+
+// cpuinfoMHz averages the "cpu MHz" lines of a /proc/cpuinfo file.
+func cpuinfoMHz(path string) (float64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	sum, n := 0.0, 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok || strings.TrimSpace(k) != "cpu MHz" {
+			continue
+		}
+		if mhz, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && mhz > 0 {
+			sum, n = sum+mhz, n+1
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
@@ -0,0 +1,5 @@
+//go:build !linux
+
+package inplacebench
+
+func cpuFreqSource() (float64, bool) { return 0, false }
@@ -0,0 +1,96 @@
+package inplacebench
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// FuzzMaxN bounds the array size DecodeOps produces; it is just past the
+// 4096-element page boundary so every boundary selftest covers is reachable.
+const FuzzMaxN = 4097
+
+// An encoded op sequence is a 2-byte little-endian N (taken mod FuzzMaxN+1)
+// followed by 4-byte ops: kind (mod 5, in OpKind order), a 2-byte index and
+// an int8 value. A trailing partial op is ignored.
+const fuzzOpBytes = 4
+
+// DecodeOps turns fuzzer input into an array size and an op sequence that
+// starts with Init(0). Indices are reduced mod N, since the Array contract
+// only allows them in [0, N); with N = 0 only Init and Fill remain.
+func DecodeOps(data []byte) (N int, seq []Op) {
+	if len(data) < 2 {
+		return 0, []Op{{Kind: OpInit}}
+	}
+	N = int(binary.LittleEndian.Uint16(data)) % (FuzzMaxN + 1)
+	data = data[2:]
+	seq = make([]Op, 1, 1+len(data)/fuzzOpBytes)
+	for ; len(data) >= fuzzOpBytes; data = data[fuzzOpBytes:] {
+		op := Op{
+			Kind: OpKind(data[0] % 5),
+			I:    int(binary.LittleEndian.Uint16(data[1:])),
+			V:    int64(int8(data[3])),
+		}
+		switch op.Kind {
+		case OpRead, OpWrite, OpDelete:
+			if N == 0 {
+				continue
+			}
+			op.I %= N
+		default:
+			op.I = 0
+		}
+		seq = append(seq, op)
+	}
+	return N, seq
+}
+
+// EncodeOps is the inverse of DecodeOps for seq without its leading Init(0),
+// for building seed inputs; values must fit in an int8 and N in FuzzMaxN.
+func EncodeOps(N int, seq []Op) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, uint16(N))
+	for _, op := range seq {
+		b = append(b, byte(op.Kind))
+		b = binary.LittleEndian.AppendUint16(b, uint16(op.I))
+		b = append(b, byte(int8(op.V)))
+	}
+	return b
+}
+
+// FuzzSeeds are the seed corpus: a write to index 0 followed by Init, reads
+// of N-1 before any write, alternating Init values, and writes and reads on
+// both sides of the word, block and page boundaries.
+func FuzzSeeds() [][]byte {
+	r := func(i int) Op { return Op{Kind: OpRead, I: i} }
+	w := func(i int, v int64) Op { return Op{Kind: OpWrite, I: i, V: v} }
+	in := func(v int64) Op { return Op{Kind: OpInit, V: v} }
+	seeds := [][]byte{
+		EncodeOps(1, []Op{w(0, 5), in(7), r(0)}),
+		EncodeOps(7, []Op{w(0, 5), r(0), in(7), r(0), r(6)}),
+		EncodeOps(7, []Op{r(6), w(6, 1), r(6)}),
+		EncodeOps(1000, []Op{r(999), w(0, -1), r(999)}),
+		EncodeOps(7, []Op{in(1), r(3), in(-1), r(3), w(3, 2), in(1), r(3), in(-1), r(3)}),
+		EncodeOps(7, []Op{w(2, 9), {Kind: OpFill, V: 4}, r(2), w(2, 3), {Kind: OpDelete, I: 2}, r(2)}),
+	}
+	var edges []Op
+	for _, i := range []int{63, 64, 511, 512, 4095, 4096} {
+		edges = append(edges, w(i, int64(i%100)), r(i-1), r(i))
+	}
+	edges = append(edges, in(-3))
+	for _, i := range []int{63, 64, 511, 512, 4095, 4096} {
+		edges = append(edges, r(i))
+	}
+	return append(seeds, EncodeOps(FuzzMaxN, edges))
+}
+
+// fuzzImpl replays seq on a fresh impl.New(N); read-only and no-op impls
+// are skipped.
+func fuzzImpl(impl Impl, N int, seq []Op) *Divergence {
+	if impl.Meta.Has(CapReadOnly) || impl.Meta.Has(CapNoop) {
+		return nil
+	}
+	arr := impl.New(N)
+	if c, ok := arr.(io.Closer); ok {
+		defer c.Close()
+	}
+	return Replay(arr, N, seq)
+}
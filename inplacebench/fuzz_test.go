@@ -0,0 +1,57 @@
+package inplacebench
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fuzzCorpus is where go test keeps FuzzArrays' seed corpus.
+const fuzzCorpus = "testdata/fuzz/FuzzArrays"
+
+// FuzzArrays replays decoded op sequences against every registered
+// implementation except the read-only ones and fails on the first
+// divergence from the reference model or panic. A new implementation is
+// fuzzed as soon as it is registered; INPLACEBENCH_IMPLS (default "all")
+// narrows the set. The seeds are the files under fuzzCorpus.
+func FuzzArrays(f *testing.F) {
+	selected, err := SelectImpls(envOr("INPLACEBENCH_IMPLS", "all"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		N, seq := DecodeOps(data)
+		for _, impl := range selected {
+			if d := fuzzImpl(impl, N, seq); d != nil {
+				t.Fatalf("%s N=%d: %v", impl.Name, N, d)
+			}
+		}
+	})
+}
+
+// TestFuzzCorpus checks that the checked-in corpus is FuzzSeeds, one file
+// per seed in order.
+func TestFuzzCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fuzzCorpus, "seed*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := FuzzSeeds()
+	if len(files) != len(seeds) {
+		t.Fatalf("%d files in %s, want %d seeds", len(files), fuzzCorpus, len(seeds))
+	}
+	for i, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, lit, _ := strings.Cut(strings.TrimSpace(string(b)), "\n")
+		got, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(lit, "[]byte("), ")"))
+		if header != "go test fuzz v1" || err != nil || !bytes.Equal([]byte(got), seeds[i]) {
+			t.Errorf("%s is not FuzzSeeds()[%d] (%v)", name, i, err)
+		}
+	}
+}
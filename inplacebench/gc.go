@@ -0,0 +1,99 @@
+package inplacebench
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// GCControl is what a Runner does about the garbage collector around each
+// run, so a collection set off by setup allocations (the array, the index
+// buffers) does not land in the middle of a timed loop. The zero value
+// leaves the collector alone. Either way the row's gc_count says how many
+// collections ended while the scenario ran, setup included; under Parallel
+// that counts the other cells' collections too.
+type GCControl struct {
+	// Before collects and returns freed memory to the OS (runtime.GC, then
+	// debug.FreeOSMemory) after the array is built, before the scenario
+	// starts.
+	Before bool
+	// Off turns the collector off (debug.SetGCPercent(-1)) while the
+	// scenario runs and restores the previous setting afterwards. A
+	// scenario's garbage then stays on the heap until the run ends.
+	Off bool
+}
+
+var (
+	gcMu    sync.Mutex
+	gcOffs  int
+	gcSaved int
+)
+
+// gcOff and gcOn bracket a run under GCControl.Off. The setting is the
+// process's, so with parallel cells the first run in turns the collector
+// off and the last one out restores it.
+func gcOff() {
+	gcMu.Lock()
+	defer gcMu.Unlock()
+	if gcOffs++; gcOffs == 1 {
+		gcSaved = debug.SetGCPercent(-1)
+	}
+}
+
+func gcOn() {
+	gcMu.Lock()
+	defer gcMu.Unlock()
+	if gcOffs--; gcOffs == 0 {
+		debug.SetGCPercent(gcSaved)
+	}
+}
+
+// around runs run under g and returns the number of collections that
+// ended during it.
+func (g GCControl) around(run func()) int64 {
+	if g.Before {
+		runtime.GC()
+		debug.FreeOSMemory()
+	}
+	if g.Off {
+		gcOff()
+		defer gcOn()
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.NumGC
+	run()
+	runtime.ReadMemStats(&ms)
+	return int64(ms.NumGC - before)
+}
+
+// checkGC checks that Off holds off the collections a burst of garbage
+// sets off without it, that it restores the GC percentage, and that Before
+// collects.
+func checkGC() error {
+	garbage := func() {
+		for k := 0; k < 64; k++ {
+			consume(int64(len(make([]byte, 1<<20))))
+		}
+	}
+	if n := (GCControl{}).around(garbage); n == 0 {
+		return fmt.Errorf("gc: 64 MiB of garbage set off no collection")
+	}
+	if n := (GCControl{Off: true}).around(garbage); n != 0 {
+		return fmt.Errorf("gc: %d collections with the collector off", n)
+	}
+	if p := debug.SetGCPercent(100); p != 100 {
+		debug.SetGCPercent(p)
+		return fmt.Errorf("gc: GOGC is %d after a run with the collector off, want 100", p)
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.NumGC
+	(GCControl{Before: true}).around(func() {})
+	runtime.ReadMemStats(&ms)
+	if ms.NumGC == before {
+		return fmt.Errorf("gc: Before did not collect")
+	}
+	return nil
+}
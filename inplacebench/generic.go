@@ -0,0 +1,335 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// ArrayOf is the Array interface for element type T. Go cannot also call it
+// Array, which stays the int64 interface every impl and scenario is written
+// against; an Array is an ArrayOf[int64] as it stands, so the int64 path
+// needs no adapter. The typed path (Runner.ElemType) runs the legacy sweep
+// against the ArrayOf ports registered with RegisterOf, with values of type
+// T from the first call to the last.
+type ArrayOf[T any] interface {
+	Name() string
+	Len() int
+	Init(v T) int64
+	Read(i int) T
+	Write(i int, v T)
+}
+
+var _ ArrayOf[int64] = Array(nil)
+
+// Payload is the struct element type: 16 bytes, the width of two int64s,
+// so each access moves more than a word.
+type Payload struct {
+	Key    int32
+	Weight float32
+	Val    int64
+}
+
+// Bridge adapts a typed array to Array: from turns each int64 going in into
+// a T and to turns each T coming out back. They must be inverse on the
+// values written, which selftest keeps small. Each access pays the
+// conversions, so Bridge is for reusing the int64 checks on a port, not for
+// timing it.
+func Bridge[T any](a ArrayOf[T], from func(int64) T, to func(T) int64) Array {
+	return bridge[T]{a, from, to}
+}
+
+type bridge[T any] struct {
+	a    ArrayOf[T]
+	from func(int64) T
+	to   func(T) int64
+}
+
+func (b bridge[T]) Name() string         { return b.a.Name() }
+func (b bridge[T]) Len() int             { return b.a.Len() }
+func (b bridge[T]) Init(v int64) int64   { return b.a.Init(b.from(v)) }
+func (b bridge[T]) Read(i int) int64     { return b.to(b.a.Read(i)) }
+func (b bridge[T]) Write(i int, v int64) { b.a.Write(i, b.from(v)) }
+
+// ElemTypes are the element types of the typed path, as recorded in the
+// elem_type column; rows from the Array path record int64.
+var ElemTypes = []string{"int64", "int32", "float64", "payload"}
+
+// elemName is T's entry in ElemTypes, or "" for any other type.
+func elemName[T any]() string {
+	switch any(*new(T)).(type) {
+	case int64:
+		return "int64"
+	case int32:
+		return "int32"
+	case float64:
+		return "float64"
+	case Payload:
+		return "payload"
+	}
+	return ""
+}
+
+// elemType holds one element type's ports, keyed by the name of the int64
+// impl they port, and its conversions from and to the int64 values the
+// scenarios are written in.
+type elemType[T any] struct {
+	from  func(int64) T
+	to    func(T) int64
+	ports map[string]func(n int) ArrayOf[T]
+	order []string
+}
+
+// elemKind is an elemType with T erased, for the runner.
+type elemKind interface {
+	ported(impl string) bool
+	measure(c Cell, params map[string]string, buf *Buffers) (name string, run RunResult, reloc, conv, resident int64)
+	check() error
+}
+
+var elemKinds = map[string]elemKind{
+	"int64":   &elemType[int64]{from: func(v int64) int64 { return v }, to: func(v int64) int64 { return v }},
+	"int32":   &elemType[int32]{from: func(v int64) int32 { return int32(v) }, to: func(v int32) int64 { return int64(v) }},
+	"float64": &elemType[float64]{from: func(v int64) float64 { return float64(v) }, to: func(v float64) int64 { return int64(v) }},
+	"payload": &elemType[Payload]{
+		from: func(v int64) Payload { return Payload{Key: int32(v), Weight: float32(v), Val: v} },
+		to:   func(p Payload) int64 { return p.Val },
+	},
+}
+
+// RegisterOf adds a port of the int64 impl named impl to element type T,
+// which must be one of ElemTypes. The port's Name should be impl's with
+// its _int64 suffix replaced by the element type's (see ElemImplName).
+// Like Register it panics on a duplicate and should be called from an init
+// function.
+func RegisterOf[T any](impl string, newFn func(n int) ArrayOf[T]) {
+	e, ok := elemKinds[elemName[T]()].(*elemType[T])
+	if !ok {
+		panic(fmt.Sprintf("inplacebench: RegisterOf %s for %T, which is not in ElemTypes", impl, *new(T)))
+	}
+	if e.ports == nil {
+		e.ports = map[string]func(int) ArrayOf[T]{}
+	}
+	if _, dup := e.ports[impl]; dup {
+		panic("inplacebench: RegisterOf called twice for " + impl + " of " + elemName[T]())
+	}
+	e.ports[impl] = newFn
+	e.order = append(e.order, impl)
+}
+
+// ElemImplName is the name the port of impl to elem runs under:
+// go_slice_int64 becomes go_slice_int32.
+func ElemImplName(impl, elem string) string {
+	return strings.TrimSuffix(impl, "_int64") + "_" + elem
+}
+
+func (e *elemType[T]) ported(impl string) bool { return e.ports[impl] != nil }
+
+func (e *elemType[T]) measure(c Cell, params map[string]string, buf *Buffers) (string, RunResult, int64, int64, int64) {
+	arr := e.ports[c.Impl.Name](c.N)
+	run := runScenarioOf(buf, arr, c.Scenario, c.N, c.EffectiveSeed(), params, e.from)
+	var reloc, conv int64
+	if sr, ok := arr.(StatsReporter); ok {
+		reloc, conv = sr.Stats()
+	}
+	resident := int64(float64(c.N) * c.Impl.Meta.ElemBytes * float64(unsafe.Sizeof(*new(T))) / 8)
+	if f, ok := arr.(FootprintReporter); ok {
+		resident = f.MemoryFootprint()
+	}
+	return arr.Name(), run, reloc, conv, resident
+}
+
+// check replays the property generators against every port through Bridge
+// and runs each typed scenario once at a few sizes.
+func (e *elemType[T]) check() error {
+	for _, impl := range e.order {
+		newFn := e.ports[impl]
+		base, ok := Lookup(impl)
+		if !ok {
+			return fmt.Errorf("%s: port of unregistered impl %s", elemName[T](), impl)
+		}
+		bridged := Impl{Name: ElemImplName(impl, elemName[T]()), Meta: base.Meta,
+			New: func(n int) Array { return Bridge(newFn(n), e.from, e.to) }}
+		if f := CheckProperties(bridged, []int{1, 7, 64}, 3); f != nil {
+			return fmt.Errorf("%s port: %w", elemName[T](), f)
+		}
+		for _, N := range []int{1, 100} {
+			arr := newFn(N)
+			if arr.Name() != bridged.Name {
+				return fmt.Errorf("%s: Name() = %q, want %q", bridged.Name, arr.Name(), bridged.Name)
+			}
+			for _, sc := range DefaultScenarios {
+				if !TypedScenario(sc) {
+					continue
+				}
+				if err := catchPanic(func() { runScenarioOf(nil, arr, sc, N, 1, nil, e.from) }); err != nil {
+					return fmt.Errorf("%s %s N=%d: %v", bridged.Name, sc, N, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func catchPanic(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// checkTyped runs every element type's check.
+func checkTyped() error {
+	for _, elem := range ElemTypes {
+		if err := elemKinds[elem].check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TypedScenario reports whether the typed path runs the scenario: the
+// legacy sweep does.
+func TypedScenario(name string) bool {
+	sc, _ := LookupScenario(name)
+	switch sc.Spec().Kind {
+	case "INIT_ONLY", "READ_UNWRITTEN", "WRITE_SEQUENTIAL", "WRITE_RANDOM", "ADVERSARIAL_HOTSPOT", "MIXED":
+		return true
+	}
+	return false
+}
+
+// ForElemType keeps the cells of plan the typed path can run with elem, a
+// port of the cell's impl and a TypedScenario, and renumbers them.
+func ForElemType(plan []Cell, elem string) []Cell {
+	e := elemKinds[elem]
+	var out []Cell
+	for _, c := range plan {
+		if e != nil && e.ported(c.Impl.Name) && TypedScenario(c.Scenario) {
+			c.Ordinal, c.Elem = len(out), elem
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// RunScenarioOf is RunScenario for a typed array; from makes the T for each
+// int64 value the scenario writes, so a typed run makes the same Reads and
+// Writes in the same order as the int64 scenario's element path with the
+// same seed.
+func RunScenarioOf[T any](arr ArrayOf[T], scenario string, N int, seed int64, params map[string]string, from func(int64) T) (ops int, totalNs int64, nsPerOp float64, initNs int64) {
+	r := runScenarioOf(nil, arr, scenario, N, seed, params, from)
+	return r.Ops, r.TotalNs, r.NsPerOp, r.InitNs
+}
+
+// runScenarioOf runs the typed twin of a TypedScenario. Each mirrors the
+// int64 scenario of the same name, element path only; conversions happen
+// in from, one call per value written. The last value read is kept
+// instead of an xor of all of them. Indices and op kinds are drawn into buf.
+func runScenarioOf[T any](buf *Buffers, arr ArrayOf[T], scenario string, N int, seed int64, params map[string]string, from func(int64) T) RunResult {
+	sc, ok := LookupScenario(scenario)
+	if !ok || !TypedScenario(scenario) {
+		panic("no typed scenario " + scenario)
+	}
+	p, err := sc.Resolve(params)
+	if err != nil {
+		panic(err)
+	}
+	if arr.Len() != N {
+		panic(fmt.Sprintf("%s: Len() = %d, but the scenario was asked for N = %d", arr.Name(), arr.Len(), N))
+	}
+	rng := rand.New(rand.NewSource(seed))
+	var last T
+	defer func() { keep(last) }()
+	switch kind := sc.Spec().Kind; {
+	case kind == "INIT_ONLY":
+		start := time.Now()
+		arr.Init(from(42))
+		el := time.Since(start).Nanoseconds()
+		return RunResult{Ops: 1, TotalNs: el, InitNs: el}
+	case kind == "READ_UNWRITTEN":
+		arr.Init(from(123))
+		M := min(1000000, 10*N)
+		idx := buf.Idx(rng, M, N)
+		start := time.Now()
+		for _, j := range idx {
+			last = arr.Read(j)
+		}
+		return timedOps(M, time.Since(start).Nanoseconds())
+	case kind == "WRITE_SEQUENTIAL":
+		arr.Init(from(0))
+		start := time.Now()
+		for i := 0; i < N; i++ {
+			arr.Write(i, from(int64(i)))
+		}
+		res := timedOps(N, time.Since(start).Nanoseconds())
+		res.Path = "element"
+		return res
+	case kind == "WRITE_RANDOM":
+		arr.Init(from(0))
+		M := min(1000000, N)
+		idx := buf.Idx(rng, M, N)
+		start := time.Now()
+		for _, j := range idx {
+			arr.Write(j, from(randVal(rng)))
+		}
+		return timedOps(M, time.Since(start).Nanoseconds())
+	case kind == "ADVERSARIAL_HOTSPOT":
+		arr.Init(from(0))
+		M := min(1000000, N)
+		hot := int(math.Max(1, float64(N)*p["hotspot_pct"]/100))
+		hotWritePct := p["hot_write_pct"]
+		start := time.Now()
+		for i := 0; i < M; i++ {
+			var j int
+			if hotDraw(rng, hotWritePct) {
+				j = rng.Intn(hot)
+			} else {
+				j = rng.Intn(N)
+			}
+			arr.Write(j, from(randVal(rng)))
+		}
+		return timedOps(M, time.Since(start).Nanoseconds())
+	}
+	// MIXED, as mixedPhases.
+	readPct := int(p["read_pct"])
+	arr.Init(from(42))
+	M := min(1000000, N)
+	idx := buf.Idx(rng, M, N)
+	isRead := buf.Reads(rng, M, readPct)
+	start := time.Now()
+	for i := 0; i < M; i++ {
+		if isRead[i] {
+			last = arr.Read(idx[i])
+		} else {
+			arr.Write(idx[i], from(randVal(rng)))
+		}
+	}
+	return timedOps(M, time.Since(start).Nanoseconds())
+}
+
+// registerPorts ports go_slice_int64, go_rwmutex_int64 and go_btree_int64
+// to T.
+func registerPorts[T any]() {
+	RegisterOf("go_slice_int64", func(n int) ArrayOf[T] { return NewSliceOf[T](n) })
+	RegisterOf("go_rwmutex_int64", func(n int) ArrayOf[T] { return NewThreadSafeSliceOf[T](n) })
+	RegisterOf("go_btree_int64", func(n int) ArrayOf[T] { return NewBTreeOf[T](n) })
+}
+
+func init() {
+	registerPorts[int64]()
+	registerPorts[int32]()
+	registerPorts[float64]()
+	registerPorts[Payload]()
+}
+
+var sinkAny any
+
+// keep is consume for a typed value.
+func keep[T any](v T) { sinkMu.Lock(); sinkAny = v; sinkMu.Unlock() }
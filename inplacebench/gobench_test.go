@@ -0,0 +1,71 @@
+package inplacebench
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// BenchmarkInplace runs every registered implementation × scenario × size
+// as a sub-benchmark named impl/scenario/N=n, for go test -bench,
+// -benchmem, -cpuprofile and benchstat.
+//
+// Each iteration of b.N is one scenario op: cells are run through the same
+// RunScenario as the CSV harness, on a fresh array each time, until at least
+// b.N ops have been measured. The reported ns/op is the scenario's own timed
+// part, so it is comparable with the ns_per_op column; relocations and
+// conversions of the last run are reported as extra metrics.
+//
+// INPLACEBENCH_NS (default "1000,100000"), INPLACEBENCH_IMPLS (default
+// "all") and INPLACEBENCH_SCENARIOS (default the legacy sweep, or "all")
+// narrow the matrix; cells are planned by PlanCells with seed 42.
+func BenchmarkInplace(b *testing.B) {
+	impls, err := SelectImpls(envOr("INPLACEBENCH_IMPLS", "all"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	scenarios := DefaultScenarios
+	if s := os.Getenv("INPLACEBENCH_SCENARIOS"); s != "" {
+		if scenarios, err = SelectScenarios(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+	Ns := ParseSizes(envOr("INPLACEBENCH_NS", "1000,100000"))
+	for _, c := range PlanCells(impls, Ns, scenarios, []int64{42}, 1, false) {
+		c := c
+		b.Run(fmt.Sprintf("%s/%s/N=%d", c.Impl.Name, c.Scenario, c.N), func(b *testing.B) {
+			benchmarkCell(b, c)
+		})
+	}
+}
+
+func benchmarkCell(b *testing.B, c Cell) {
+	var done int
+	var totalNs, reloc, conv int64
+	for done < b.N {
+		arr := c.Impl.New(c.N)
+		ops, tot, _, _ := RunScenario(arr, c.Scenario, c.N, c.EffectiveSeed(), nil)
+		if sr, ok := arr.(StatsReporter); ok {
+			reloc, conv = sr.Stats()
+		}
+		if cl, ok := arr.(io.Closer); ok {
+			cl.Close()
+		}
+		done += max(ops, 1)
+		totalNs += tot
+	}
+	b.ReportMetric(float64(totalNs)/float64(done), "ns/op")
+	if c.Impl.Meta.Has(CapStats) {
+		b.ReportMetric(float64(reloc), "relocations")
+		b.ReportMetric(float64(conv), "conversions")
+	}
+}
+
+// envOr is the environment variable key, or def when it is unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
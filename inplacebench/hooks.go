@@ -0,0 +1,208 @@
+package inplacebench
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hooks are optional callbacks a Runner makes around each run (one Cell)
+// and each cell (every rep of one impl, scenario, N and seed), for behavior
+// the loop does not have: dropping the page cache, pinning the CPU
+// frequency, pushing partial results somewhere, attaching a profiler to one
+// run. They are called outside the timed region by construction, before
+// the array is built and after it is closed; an isolated run's hooks run in
+// the parent. Under Parallel they are called from the worker goroutines,
+// so hooks that share state must lock it.
+//
+// BeforeCell runs before the first rep of a cell starts and BeforeRun
+// before every rep. An error from either vetoes the run, or with
+// BeforeCell every rep of the cell: the run is not measured and its result
+// has Status "skipped" for ErrSkipRun (with the error's text when it wraps
+// the sentinel) and "failed: <err>" for any other error. AfterRun receives
+// every result, vetoed ones included, before the writers do. AfterCell
+// receives the cell's statistics once its last planned rep is done; reps
+// adaptive repetition skips count as done, and a cell a cancellation cut
+// short gets none.
+type Hooks struct {
+	BeforeCell func(Cell) error
+	BeforeRun  func(RunInfo) error
+	AfterRun   func(Result)
+	AfterCell  func(CellStats)
+}
+
+// RunInfo is what BeforeRun gets: the cell, whether it shares the machine
+// with other runs, and how many runs the plan has.
+type RunInfo struct {
+	Cell      Cell
+	Contended bool
+	Planned   int
+}
+
+// ErrSkipRun is the error a Before hook returns to skip a run without
+// failing it; wrap it to say why, as in fmt.Errorf("%w: governor busy",
+// ErrSkipRun).
+var ErrSkipRun = errors.New("skip run")
+
+// hookStatus is the Status of a run a Before hook vetoed with err.
+func hookStatus(err error) string {
+	if !errors.Is(err, ErrSkipRun) {
+		return "failed: " + err.Error()
+	}
+	if err == ErrSkipRun {
+		return "skipped"
+	}
+	return "skipped: " + strings.TrimPrefix(err.Error(), ErrSkipRun.Error()+": ")
+}
+
+// hookState tracks one Run's cells for the hooks. The map is built from
+// the plan before any cell starts and only read afterwards, so workers
+// share it without a lock.
+type hookState struct {
+	hooks   []Hooks
+	planned int
+	cells   map[string]*hookCell
+	ns      atomic.Int64
+}
+
+type hookCell struct {
+	once sync.Once
+	veto error
+
+	mu   sync.Mutex
+	left int
+	reps Results
+}
+
+// newHookState returns nil when there are no hooks, and the hookState
+// methods do nothing on nil.
+func newHookState(hooks []Hooks, cells []Cell) *hookState {
+	if len(hooks) == 0 {
+		return nil
+	}
+	h := &hookState{hooks: hooks, planned: len(cells), cells: map[string]*hookCell{}}
+	for _, c := range cells {
+		k := stableKey(c)
+		if h.cells[k] == nil {
+			h.cells[k] = &hookCell{}
+		}
+		h.cells[k].left++
+	}
+	return h
+}
+
+// timed adds the time fn takes to the hook time.
+func (h *hookState) timed(fn func()) {
+	start := time.Now()
+	fn()
+	h.ns.Add(time.Since(start).Nanoseconds())
+}
+
+// before runs BeforeCell once per cell and BeforeRun for every run, and
+// returns the first error.
+func (h *hookState) before(c Cell, contended bool) (err error) {
+	if h == nil {
+		return nil
+	}
+	hc := h.cells[stableKey(c)]
+	h.timed(func() {
+		hc.once.Do(func() {
+			for _, hk := range h.hooks {
+				if hk.BeforeCell != nil {
+					if hc.veto = hk.BeforeCell(c); hc.veto != nil {
+						return
+					}
+				}
+			}
+		})
+		if err = hc.veto; err != nil {
+			return
+		}
+		info := RunInfo{c, contended, h.planned}
+		for _, hk := range h.hooks {
+			if hk.BeforeRun != nil {
+				if err = hk.BeforeRun(info); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return err
+}
+
+// after runs AfterRun for res, unless the run was skipped as stable (res is
+// nil), and AfterCell once the cell has no reps left.
+func (h *hookState) after(c Cell, res *Result) {
+	if h == nil {
+		return
+	}
+	hc := h.cells[stableKey(c)]
+	h.timed(func() {
+		if res != nil {
+			for _, hk := range h.hooks {
+				if hk.AfterRun != nil {
+					hk.AfterRun(*res)
+				}
+			}
+		}
+		hc.mu.Lock()
+		if res != nil {
+			hc.reps = append(hc.reps, *res)
+		}
+		hc.left--
+		last := hc.left == 0 && len(hc.reps) > 0
+		hc.mu.Unlock()
+		if !last {
+			return
+		}
+		stats := hc.reps.Cells()[0]
+		for _, hk := range h.hooks {
+			if hk.AfterCell != nil {
+				hk.AfterCell(stats)
+			}
+		}
+	})
+}
+
+// LogRuns is a Hooks that writes one line to w as each run starts and one
+// as it ends, e.g. to follow a long sweep on stderr:
+//
+//	runner.Hooks = append(runner.Hooks, inplacebench.LogRuns(os.Stderr))
+func LogRuns(w io.Writer) Hooks {
+	var mu sync.Mutex
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, format, args...)
+	}
+	return Hooks{
+		BeforeRun: func(info RunInfo) error {
+			logf("run %d/%d %s\n", info.Cell.Ordinal+1, info.Planned, info.Cell.RunID())
+			return nil
+		},
+		AfterRun: func(res Result) {
+			if res.OK() {
+				logf("done %s: %.4f ns/op\n", res.RunID, res.NsPerOp)
+			} else {
+				logf("done %s: %s\n", res.RunID, res.Status)
+			}
+		},
+	}
+}
+
+// DropPageCache is a Hooks that writes dirty pages back and drops the
+// kernel's page cache before each cell, so a file-backed impl's first rep
+// starts cold; unlike DISK_BACKED's CacheDropper it works for any impl but
+// needs Linux and root. Where it cannot drop the cache the cell fails.
+func DropPageCache() Hooks {
+	return Hooks{BeforeCell: func(Cell) error {
+		if err := dropPageCache(); err != nil {
+			return fmt.Errorf("drop page cache: %v", err)
+		}
+		return nil
+	}}
+}
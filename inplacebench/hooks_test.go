@@ -0,0 +1,132 @@
+package inplacebench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestHooks runs a small matrix with recording hooks, sequentially and
+// interleaved on two workers, and checks the call order and counts, both
+// kinds of veto, the AfterCell statistics and LogRuns' output.
+func TestHooks(t *testing.T) {
+	slice, _ := Lookup("go_slice_int64")
+	atomicImpl, _ := Lookup("go_atomic_int64")
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel=%d", parallel), func(t *testing.T) { testHooks(t, slice, atomicImpl, parallel) })
+	}
+}
+
+func testHooks(t *testing.T, slice, atomicImpl Impl, parallel int) {
+	var mu sync.Mutex
+	var events []string
+	event := func(format string, args ...any) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+	cells := map[string]CellStats{}
+	var log strings.Builder
+	r := &Runner{
+		Impls: []Impl{slice, atomicImpl}, Scenarios: []string{"WRITE_RANDOM", "READ_UNWRITTEN"},
+		Ns: []int{10}, Seeds: []int64{1}, Reps: 2, Interleave: parallel > 1, Parallel: parallel, NoBatch: true,
+		Hooks: []Hooks{{
+			BeforeCell: func(c Cell) error {
+				event("before cell %s/%s", c.Impl.Name, c.Scenario)
+				if c.Impl.Name == atomicImpl.Name && c.Scenario == "READ_UNWRITTEN" {
+					return errors.New("no governor")
+				}
+				return nil
+			},
+			BeforeRun: func(info RunInfo) error {
+				event("before run %s", info.Cell.RunID())
+				if info.Cell.Impl.Name == atomicImpl.Name && info.Cell.Rep == 2 {
+					return fmt.Errorf("%w: busy", ErrSkipRun)
+				}
+				return nil
+			},
+			AfterRun: func(res Result) { event("after run %s %s", res.RunID, res.Status) },
+			AfterCell: func(s CellStats) {
+				event("after cell %s/%s", s.Impl, s.Scenario)
+				mu.Lock()
+				cells[s.Impl+"/"+s.Scenario] = s
+				mu.Unlock()
+			},
+		}, LogRuns(&log)},
+	}
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, res := range results {
+		status[res.RunID] = res.Status
+	}
+	for id, want := range map[string]string{
+		"go_slice_int64/WRITE_RANDOM/10/1/2":    "ok",
+		"go_atomic_int64/WRITE_RANDOM/10/1/1":   "ok",
+		"go_atomic_int64/WRITE_RANDOM/10/1/2":   "skipped: busy",
+		"go_atomic_int64/READ_UNWRITTEN/10/1/1": "failed: no governor",
+		"go_atomic_int64/READ_UNWRITTEN/10/1/2": "failed: no governor",
+	} {
+		if status[id] != want {
+			t.Fatalf("%s has status %q, want %q", id, status[id], want)
+		}
+	}
+	if len(results) != 8 || len(cells) != 4 {
+		t.Fatalf("%d results and %d cells, want 8 and 4", len(results), len(cells))
+	}
+	if s := cells["go_atomic_int64/WRITE_RANDOM"]; s.Reps != 2 || s.Failed != 1 || math.IsNaN(s.Median) {
+		t.Fatalf("AfterCell got %+v for a cell with one skipped rep", s)
+	}
+	// Per cell: BeforeCell first and AfterCell last, each exactly once.
+	for key := range cells {
+		first, last, n := -1, -1, 0
+		for i, e := range events {
+			switch e {
+			case "before cell " + key:
+				first, n = i, n+1
+			case "after cell " + key:
+				last, n = i, n+1
+			}
+		}
+		for i, e := range events {
+			if strings.Contains(e, " "+key+"/") && (i < first || i > last) {
+				t.Fatalf("%q outside %s's BeforeCell and AfterCell", e, key)
+			}
+		}
+		if n != 2 || first > last {
+			t.Fatalf("%s's cell hooks ran out of order: %q", key, events)
+		}
+	}
+	// BeforeRun is not called for the reps BeforeCell vetoed; every other
+	// run's AfterRun follows its BeforeRun.
+	counts := map[string]int{}
+	for i, e := range events {
+		kind, id, _ := strings.Cut(e, " go_")
+		counts[kind]++
+		if kind == "after run" {
+			id, _, _ = strings.Cut(id, " ")
+			vetoed := strings.HasPrefix(id, "atomic_int64/READ_UNWRITTEN/")
+			if before := slices.Index(events, "before run go_"+id); !vetoed && (before < 0 || before > i) {
+				t.Fatalf("AfterRun of go_%s without a BeforeRun before it: %q", id, events)
+			}
+		}
+	}
+	for kind, want := range map[string]int{"before cell": 4, "before run": 6, "after run": 8, "after cell": 4} {
+		if counts[kind] != want {
+			t.Fatalf("%d %s calls, want %d: %q", counts[kind], kind, want, events)
+		}
+	}
+	if r.HookTime() <= 0 {
+		t.Fatalf("no hook time measured")
+	}
+	if n := strings.Count(log.String(), "\n"); n != 13 {
+		t.Fatalf("LogRuns wrote %d lines, want 13:\n%s", n, log.String())
+	}
+}
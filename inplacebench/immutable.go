@@ -0,0 +1,101 @@
+package inplacebench
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrImmutableWrite is the panic value (wrapped with the index) of a Write to
+// an ImmutableArrayImpl after its first Init.
+var ErrImmutableWrite = errors.New("write to immutable array after Init")
+
+// ImmutableArrayImpl is a SliceImpl that becomes read-only once initialized:
+// Init may be repeated, but Write after the first Init panics. It models a
+// read-only cache region and catches scenarios that claim not to write.
+type ImmutableArrayImpl struct {
+	SliceImpl
+	inited bool
+}
+
+func NewImmutableArrayImpl(n int) *ImmutableArrayImpl {
+	return &ImmutableArrayImpl{SliceImpl: *NewSliceImpl(n)}
+}
+func (s *ImmutableArrayImpl) Name() string { return "go_immutable_int64" }
+func (s *ImmutableArrayImpl) Init(v int64) int64 {
+	s.inited = true
+	return s.SliceImpl.Init(v)
+}
+
+// Snapshot is an initialized, so read-only, copy.
+func (s *ImmutableArrayImpl) Snapshot() Array {
+	return &ImmutableArrayImpl{SliceImpl: SliceImpl{N: s.N, A: append([]int64(nil), s.A...)}, inited: true}
+}
+
+// RunOps is SliceImpl's batch path, except that after the first Init a
+// batch with a write in it panics as Write does, before running any op.
+func (s *ImmutableArrayImpl) RunOps(ops []Op) int64 {
+	for _, op := range ops {
+		if s.inited && op.Kind == OpWrite {
+			s.Write(op.I, op.V)
+		}
+	}
+	return s.SliceImpl.RunOps(ops)
+}
+
+// TryWrite is Write returning ErrImmutableWrite instead of panicking.
+func (s *ImmutableArrayImpl) TryWrite(i int, v int64) error {
+	if s.inited {
+		return fmt.Errorf("Write(%d): %w", i, ErrImmutableWrite)
+	}
+	s.SliceImpl.Write(i, v)
+	return nil
+}
+
+func (s *ImmutableArrayImpl) Write(i int, v int64) {
+	if err := s.TryWrite(i, v); err != nil {
+		panic(err)
+	}
+}
+
+// Fill is a write to every element, so like Write it is only allowed before
+// the first Init.
+func (s *ImmutableArrayImpl) Fill(v int64) {
+	if s.inited {
+		panic(fmt.Errorf("Fill(%d): %w", v, ErrImmutableWrite))
+	}
+	s.SliceImpl.Fill(v)
+}
+
+// Delete writes the default back, so like Write it is only allowed before
+// the first Init.
+func (s *ImmutableArrayImpl) Delete(i int) {
+	if s.inited {
+		panic(fmt.Errorf("Delete(%d): %w", i, ErrImmutableWrite))
+	}
+	s.SliceImpl.Delete(i)
+}
+
+// WriteRange would otherwise be SliceImpl's, bypassing the check.
+func (s *ImmutableArrayImpl) WriteRange(start int, vals []int64) {
+	for k, v := range vals {
+		s.Write(start+k, v)
+	}
+}
+
+// CheckReadOnly runs scenario against an ImmutableArrayImpl and returns the
+// ErrImmutableWrite the scenario triggered, or nil if it never wrote after
+// Init. The panic is only recovered on the calling goroutine, so scenarios in
+// ExclusiveScenarios cannot be checked this way.
+func CheckReadOnly(scenario string, N int, seed int64, params map[string]string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok || !errors.Is(e, ErrImmutableWrite) {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	RunScenario(NewImmutableArrayImpl(N), scenario, N, seed, params)
+	return nil
+}
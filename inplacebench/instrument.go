@@ -0,0 +1,344 @@
+package inplacebench
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// OpCounter is implemented by arrays that count the calls made on them;
+// the counts are reported in the op_counts column.
+type OpCounter interface {
+	OpCounts() OpCounts
+}
+
+// OpCounts are the calls a run made. Fill counts as an Init; MaxIndex is
+// the largest index read or written, or -1.
+type OpCounts struct {
+	Inits, Reads, Writes, MaxIndex int64
+}
+
+// String renders c in the scenario_params syntax, e.g.
+// "inits=1,max_index=999,reads=0,writes=1000".
+func (c OpCounts) String() string {
+	return fmt.Sprintf("inits=%d,max_index=%d,reads=%d,writes=%d", c.Inits, c.MaxIndex, c.Reads, c.Writes)
+}
+
+// InstrumentedImpl wraps another array, forwarding every call after
+// counting it and, when a trace directory is set, appending it to a trace
+// file (see ReadTrace). Its name is the wrapped array's plus "+instr", so
+// its rows sit next to the plain impl's and the difference in ns/op is the
+// cost of the instrumentation. Filler is forwarded; the other optional
+// interfaces are not, so scenarios take their element path and every access
+// is counted. Counters are atomic and the trace is written under a mutex,
+// so a CapConcurrent array stays one.
+type InstrumentedImpl struct {
+	Inner    Array
+	traceDir string
+	inits    atomic.Int64
+	reads    atomic.Int64
+	writes   atomic.Int64
+	maxIndex atomic.Int64
+
+	mu    sync.Mutex
+	f     *os.File
+	trace *bufio.Writer
+	err   error
+}
+
+// instrumentedFiller is an InstrumentedImpl around a Filler, so the
+// wrapper has a Fill exactly when the wrapped array does.
+type instrumentedFiller struct{ *InstrumentedImpl }
+
+// Instrument wraps inner; traceDir "" records no trace.
+func Instrument(inner Array, traceDir string) Array {
+	s := &InstrumentedImpl{Inner: inner, traceDir: traceDir}
+	s.maxIndex.Store(-1)
+	if _, ok := inner.(Filler); ok {
+		return instrumentedFiller{s}
+	}
+	return s
+}
+
+func (s *InstrumentedImpl) Name() string { return s.Inner.Name() + "+instr" }
+func (s *InstrumentedImpl) Len() int     { return s.Inner.Len() }
+
+func (s *InstrumentedImpl) Init(v int64) int64 {
+	s.inits.Add(1)
+	s.record('I', v)
+	return s.Inner.Init(v)
+}
+func (s *InstrumentedImpl) Read(i int) int64 {
+	s.reads.Add(1)
+	s.touch(i)
+	s.record('R', int64(i))
+	return s.Inner.Read(i)
+}
+func (s *InstrumentedImpl) Write(i int, v int64) {
+	s.writes.Add(1)
+	s.touch(i)
+	s.record('W', int64(i), v)
+	s.Inner.Write(i, v)
+}
+func (s instrumentedFiller) Fill(v int64) {
+	s.inits.Add(1)
+	s.record('F', v)
+	s.Inner.(Filler).Fill(v)
+}
+
+func (s *InstrumentedImpl) touch(i int) {
+	for m := s.maxIndex.Load(); int64(i) > m; m = s.maxIndex.Load() {
+		if s.maxIndex.CompareAndSwap(m, int64(i)) {
+			return
+		}
+	}
+}
+
+// record appends one trace line; the file is created on the first call, so
+// an array that is never used leaves none behind. A write error stops the
+// trace and is returned by Close.
+func (s *InstrumentedImpl) record(kind byte, args ...int64) {
+	if s.traceDir == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if s.trace == nil {
+		s.f, s.err = os.CreateTemp(s.traceDir, fmt.Sprintf("%s_N%d_*.trace", s.Name(), s.Len()))
+		if s.err != nil {
+			return
+		}
+		s.trace = bufio.NewWriter(s.f)
+		fmt.Fprintf(s.trace, "N %d\n", s.Len())
+	}
+	b := append(make([]byte, 0, 48), kind)
+	for _, a := range args {
+		b = strconv.AppendInt(append(b, ' '), a, 10)
+	}
+	if _, err := s.trace.Write(append(b, '\n')); err != nil {
+		s.err = err
+	}
+}
+
+func (s *InstrumentedImpl) OpCounts() OpCounts {
+	return OpCounts{s.inits.Load(), s.reads.Load(), s.writes.Load(), s.maxIndex.Load()}
+}
+
+// Stats forwards the wrapped array's counters, so relocations_count and
+// conversions_count match the plain impl's rows.
+func (s *InstrumentedImpl) Stats() (relocations, conversions int64) {
+	if sr, ok := s.Inner.(StatsReporter); ok {
+		return sr.Stats()
+	}
+	return 0, 0
+}
+
+// TracePath is the trace file, or "" while none has been written.
+func (s *InstrumentedImpl) TracePath() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ""
+	}
+	return s.f.Name()
+}
+
+// Close flushes the trace and closes the wrapped array if it is an
+// io.Closer.
+func (s *InstrumentedImpl) Close() error {
+	s.mu.Lock()
+	err := s.err
+	if s.trace != nil {
+		if ferr := s.trace.Flush(); err == nil {
+			err = ferr
+		}
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+		s.trace = nil
+	}
+	s.mu.Unlock()
+	if c, ok := s.Inner.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReadTrace parses a trace written by InstrumentedImpl: a line "N n", then
+// one line per call, "I v" (Init), "F v" (Fill), "R i" (Read) or "W i v"
+// (Write). The result is the op sequence Replay takes, so
+//
+//	N, seq, err := inplacebench.ReadTrace(f)
+//	d := inplacebench.Replay(impl.New(N), N, seq)
+//
+// re-runs a recorded scenario against any impl and checks it against the
+// reference model.
+func ReadTrace(r io.Reader) (N int, seq []Op, err error) {
+	sc := bufio.NewScanner(r)
+	line := 0
+	bad := func(why string) (int, []Op, error) {
+		return 0, nil, fmt.Errorf("trace line %d: %s", line, why)
+	}
+	N = -1
+	for sc.Scan() {
+		line++
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		var args []int64
+		for _, s := range f[1:] {
+			a, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return bad(err.Error())
+			}
+			args = append(args, a)
+		}
+		want, op := 1, Op{}
+		switch f[0] {
+		case "N":
+			if N >= 0 || len(args) != 1 || args[0] < 0 {
+				return bad("want one N line with N >= 0 first")
+			}
+			N = int(args[0])
+			continue
+		case "I":
+			op.Kind, op.V = OpInit, traceArg(args, 0)
+		case "F":
+			op.Kind, op.V = OpFill, traceArg(args, 0)
+		case "R":
+			op.Kind, op.I = OpRead, int(traceArg(args, 0))
+		case "W":
+			want = 2
+			op.Kind, op.I, op.V = OpWrite, int(traceArg(args, 0)), traceArg(args, 1)
+		default:
+			return bad(fmt.Sprintf("unknown op %q", f[0]))
+		}
+		if N < 0 {
+			return bad("op before the N line")
+		}
+		if len(args) != want {
+			return bad(fmt.Sprintf("%s takes %d values, got %d", f[0], want, len(args)))
+		}
+		if (op.Kind == OpRead || op.Kind == OpWrite) && uint(op.I) >= uint(N) {
+			return bad(fmt.Sprintf("index %d out of range [0, %d)", op.I, N))
+		}
+		seq = append(seq, op)
+	}
+	if err := sc.Err(); err != nil {
+		return 0, nil, err
+	}
+	if N < 0 {
+		return bad("no N line")
+	}
+	return N, seq, nil
+}
+
+func traceArg(args []int64, k int) int64 {
+	if k < len(args) {
+		return args[k]
+	}
+	return 0
+}
+
+// instrFamily builds instrumented variants: impl names the registered impl
+// to wrap and trace the directory for trace files ("" for none). -instrument
+// adds one per named impl.
+var instrFamily = Family{
+	Name:     "instr",
+	Defaults: map[string]string{"impl": "go_slice_int64", "trace": ""},
+	New: func(n int, p map[string]string) (Array, error) {
+		inner, ok := Lookup(p["impl"])
+		if !ok {
+			return nil, fmt.Errorf("unknown impl %q", p["impl"])
+		}
+		return Instrument(inner.New(n), p["trace"]), nil
+	},
+	// Capabilities that need a method the wrapper does not forward are
+	// dropped; a counted call costs a few atomic adds.
+	Meta: func(p map[string]string) ImplMeta {
+		inner, _ := Lookup(p["impl"])
+		m := inner.Meta
+		m.Description = "counting wrapper around " + inner.Name
+		m.Caps = m.Caps&^(CapDelete|CapSorted|CapSnapshot|CapNUMA|CapCAS|CapBatch|CapStatic) | CapStats
+		m.EstNsPerOp += 10
+		return m
+	},
+}
+
+// checkInstrument runs a few scenarios on instrumented go_slice_int64 and
+// go_btree_int64 with a trace, and checks that the counts match the ops the
+// scenario reports, that the trace is the call sequence scenarioChecksum
+// sees, and that it replays cleanly.
+func checkInstrument() error {
+	dir, err := os.MkdirTemp("", "inplacebench-trace")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"go_slice_int64", "go_btree_int64"} {
+		impl, err := instrFamily.Variant(map[string]string{"impl": name, "trace": dir})
+		if err != nil {
+			return err
+		}
+		inner, _ := Lookup(name)
+		for _, sc := range []string{"WRITE_RANDOM", "MIXED_R50W50", "READ_UNWRITTEN"} {
+			const N = 100
+			arr := impl.New(N)
+			if _, fill := arr.(Filler); fill != inner.Meta.Has(CapFill) {
+				return fmt.Errorf("%s: Filler=%v, %s has fill=%v", impl.Name, fill, name, inner.Meta.Has(CapFill))
+			}
+			ops, _, _, _ := RunScenario(arr, sc, N, 1, nil)
+			oc := arr.(OpCounter).OpCounts()
+			path := arr.(interface{ TracePath() string }).TracePath()
+			if err := arr.(io.Closer).Close(); err != nil {
+				return fmt.Errorf("%s %s: %v", impl.Name, sc, err)
+			}
+			if oc.Reads+oc.Writes != int64(ops) || oc.MaxIndex >= N {
+				return fmt.Errorf("%s %s: counts %v for %d ops at N=%d", impl.Name, sc, oc, ops, N)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("%s %s: %v", impl.Name, sc, err)
+			}
+			tn, seq, err := ReadTrace(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s %s: %v", impl.Name, sc, err)
+			}
+			if tn != N || int64(len(seq)) != oc.Inits+oc.Reads+oc.Writes {
+				return fmt.Errorf("%s %s: trace has N=%d and %d ops, counts %v", impl.Name, sc, tn, len(seq), oc)
+			}
+			t := &traceArray{A: make([]int64, N), h: fnv.New64a()}
+			for _, op := range seq {
+				switch op.Kind {
+				case OpInit:
+					t.Init(op.V)
+				case OpRead:
+					t.Read(op.I)
+				case OpWrite:
+					t.Write(op.I, op.V)
+				}
+			}
+			t.op('O', ops, 0)
+			if t.h.Sum64() != scenarioChecksum(sc, N, 1) {
+				return fmt.Errorf("%s %s: trace is not the scenario's call sequence", impl.Name, sc)
+			}
+			if d := Replay(inner.New(N), N, seq); d != nil {
+				return fmt.Errorf("%s %s: trace replay: %v", impl.Name, sc, d)
+			}
+		}
+	}
+	return nil
+}
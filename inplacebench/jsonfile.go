@@ -0,0 +1,112 @@
+package inplacebench
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// jsonLineWidth fits any int64 in JSON ("-9223372036854775808") plus '\n'.
+const jsonLineWidth = 21
+
+// MemoryMappedJSONImpl stores element i as a JSON number on line i of a temp
+// file. Lines are space-padded to a fixed width so Read/Write can seek straight
+// to i*jsonLineWidth with ReadAt/WriteAt instead of scanning. Every access is a
+// syscall plus JSON encoding, hundreds of times slower than SliceImpl; it
+// exists to exercise the harness on microsecond-scale operations.
+type MemoryMappedJSONImpl struct {
+	N   int
+	f   *os.File
+	buf [jsonLineWidth]byte
+}
+
+func NewMemoryMappedJSONImpl(n int) *MemoryMappedJSONImpl {
+	f, err := os.CreateTemp("", "go_jsonfile_*.ndjson")
+	if err != nil {
+		panic(err)
+	}
+	return &MemoryMappedJSONImpl{N: n, f: f}
+}
+func (s *MemoryMappedJSONImpl) Name() string { return "go_jsonfile_int64" }
+func (s *MemoryMappedJSONImpl) Len() int     { return s.N }
+
+func (s *MemoryMappedJSONImpl) line(v int64) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	copy(s.buf[:], b)
+	for k := len(b); k < jsonLineWidth-1; k++ {
+		s.buf[k] = ' '
+	}
+	s.buf[jsonLineWidth-1] = '\n'
+	return s.buf[:]
+}
+
+// Init truncates the file and writes N lines holding v.
+func (s *MemoryMappedJSONImpl) Init(v int64) int64 {
+	start := time.Now()
+	if err := s.f.Truncate(0); err != nil {
+		panic(err)
+	}
+	if _, err := s.f.Seek(0, 0); err != nil {
+		panic(err)
+	}
+	bw := bufio.NewWriter(s.f)
+	line := s.line(v)
+	for i := 0; i < s.N; i++ {
+		bw.Write(line)
+	}
+	if err := bw.Flush(); err != nil {
+		panic(err)
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *MemoryMappedJSONImpl) Read(i int) int64 {
+	if _, err := s.f.ReadAt(s.buf[:], int64(i)*jsonLineWidth); err != nil {
+		panic(err)
+	}
+	var v int64
+	if err := json.Unmarshal(s.buf[:], &v); err != nil {
+		panic(err)
+	}
+	return v
+}
+func (s *MemoryMappedJSONImpl) Write(i int, v int64) {
+	if _, err := s.f.WriteAt(s.line(v), int64(i)*jsonLineWidth); err != nil {
+		panic(err)
+	}
+}
+
+// CheckedRead and CheckedWrite check the index first: WriteAt past the end
+// would silently grow the file.
+func (s *MemoryMappedJSONImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *MemoryMappedJSONImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// Close removes the backing file.
+func (s *MemoryMappedJSONImpl) Close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
+
+// MemoryFootprint is the size of the backing file; the data lives there (and
+// in the page cache) rather than on the heap.
+func (s *MemoryMappedJSONImpl) MemoryFootprint() int64 {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
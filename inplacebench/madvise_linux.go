@@ -0,0 +1,72 @@
+package inplacebench
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// MadviseSequentialImpl keeps its elements in a MAP_SHARED mapping of
+// /dev/zero advised MADV_SEQUENTIAL. Real write-combining needs an MTRR or
+// PAT write-combining memory type, which only /dev/mem or a driver can set
+// up; this is the software proxy WRITE_COMBINE_TEST compares with
+// go_slice_int64. The mapping is shmem, so pages fault in through the page
+// cache rather than as private anonymous memory, and the advice makes the
+// kernel read ahead and drop behind aggressively.
+type MadviseSequentialImpl struct {
+	N   int
+	mem []byte
+	A   []int64
+}
+
+func NewMadviseSequentialImpl(n int) *MadviseSequentialImpl {
+	s := &MadviseSequentialImpl{N: n}
+	if n == 0 {
+		return s
+	}
+	f, err := os.OpenFile("/dev/zero", os.O_RDWR, 0)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	mem, err := syscall.Mmap(int(f.Fd()), 0, n*8, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		panic(err)
+	}
+	if err := syscall.Madvise(mem, syscall.MADV_SEQUENTIAL); err != nil {
+		syscall.Munmap(mem)
+		panic(err)
+	}
+	s.mem = mem
+	s.A = unsafe.Slice((*int64)(unsafe.Pointer(&mem[0])), n)
+	return s
+}
+func (s *MadviseSequentialImpl) Name() string { return "go_madvise_seq_int64" }
+func (s *MadviseSequentialImpl) Len() int     { return s.N }
+func (s *MadviseSequentialImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Fill(v)
+	return time.Since(start).Nanoseconds()
+}
+func (s *MadviseSequentialImpl) Fill(v int64) {
+	for i := range s.A {
+		s.A[i] = v
+	}
+}
+func (s *MadviseSequentialImpl) Read(i int) int64     { return s.A[i] }
+func (s *MadviseSequentialImpl) Write(i int, v int64) { s.A[i] = v }
+
+// MemoryFootprint is the size of the mapping.
+func (s *MadviseSequentialImpl) MemoryFootprint() int64 { return int64(len(s.mem)) }
+
+func (s *MadviseSequentialImpl) Mapping() []byte { return s.mem }
+
+func (s *MadviseSequentialImpl) Close() error {
+	if s.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.mem)
+	s.mem, s.A = nil, nil
+	return err
+}
@@ -0,0 +1,107 @@
+package inplacebench
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// MprotectImpl keeps its elements in an anonymous mmap that Init fills and
+// then mprotects to PROT_READ, so the OS enforces read-only access: a Write
+// after Init faults (a panic under debug.SetPanicOnFault, otherwise a crash).
+// Stats reports the mprotect calls as relocations and the ns they took as
+// conversions, which shows what the protection costs next to the reads.
+type MprotectImpl struct {
+	N         int
+	mem       []byte
+	A         []int64
+	protects  int64
+	protectNs int64
+}
+
+func NewMprotectImpl(n int) *MprotectImpl {
+	s := &MprotectImpl{N: n}
+	if n == 0 {
+		return s
+	}
+	mem, err := syscall.Mmap(-1, 0, n*8, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic(err)
+	}
+	s.mem = mem
+	s.A = unsafe.Slice((*int64)(unsafe.Pointer(&mem[0])), n)
+	return s
+}
+func (s *MprotectImpl) Name() string { return "go_mprotect_int64" }
+func (s *MprotectImpl) Len() int     { return s.N }
+
+func (s *MprotectImpl) protect(prot int) {
+	if s.mem == nil {
+		return
+	}
+	start := time.Now()
+	if err := syscall.Mprotect(s.mem, prot); err != nil {
+		panic(err)
+	}
+	s.protectNs += time.Since(start).Nanoseconds()
+	s.protects++
+}
+
+// Init makes the mapping writable, fills it and protects it again; both
+// mprotect calls are part of the returned time.
+func (s *MprotectImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.protect(syscall.PROT_READ | syscall.PROT_WRITE)
+	for i := range s.A {
+		s.A[i] = v
+	}
+	s.protect(syscall.PROT_READ)
+	return time.Since(start).Nanoseconds()
+}
+func (s *MprotectImpl) Read(i int) int64     { return s.A[i] }
+func (s *MprotectImpl) Write(i int, v int64) { s.A[i] = v }
+
+// WriteRange faults on protected memory just as Write does.
+func (s *MprotectImpl) ReadRange(start int, out []int64) { copy(out, s.A[start:start+len(out)]) }
+func (s *MprotectImpl) WriteRange(start int, vals []int64) {
+	copy(s.A[start:start+len(vals)], vals)
+}
+
+// Protected reports whether writes currently fault.
+func (s *MprotectImpl) Protected() bool { return s.protects > 0 && s.protects%2 == 0 }
+
+func (s *MprotectImpl) Stats() (relocations, conversions int64) { return s.protects, s.protectNs }
+
+func (s *MprotectImpl) Mapping() []byte { return s.mem }
+
+func (s *MprotectImpl) Close() error {
+	if s.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.mem)
+	s.mem, s.A = nil, nil
+	return err
+}
+
+func registerPlatformImpls() {
+	Register("go_mprotect_int64", ImplMeta{
+		Description: "anonymous mmap set to PROT_READ by mprotect after Init",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapReadOnly | CapStats,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "< 1 page", Concurrency: ConcNone},
+	}, func(n int) Array { return NewMprotectImpl(n) })
+	Register("go_madvise_seq_int64", ImplMeta{
+		Description: "MAP_SHARED mmap of /dev/zero with MADV_SEQUENTIAL, a write-combining proxy",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "< 1 page", Concurrency: ConcNone},
+	}, func(n int) Array { return NewMadviseSequentialImpl(n) })
+	registerNUMAImpls()
+}
+
+// MemoryFootprint is the size of the mapping.
+func (s *MprotectImpl) MemoryFootprint() int64 { return int64(len(s.mem)) }
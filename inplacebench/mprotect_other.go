@@ -0,0 +1,7 @@
+//go:build !linux
+
+package inplacebench
+
+// go_mprotect_int64 and go_madvise_seq_int64 need mmap, mprotect and
+// madvise, wired up on Linux only.
+func registerPlatformImpls() {}
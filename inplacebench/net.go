@@ -0,0 +1,226 @@
+package inplacebench
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetworkArrayImpl keeps the elements in a server goroutine behind a
+// loopback TCP connection and turns every access into one request–reply
+// round trip of a line protocol:
+//
+//	GET i          -> v
+//	MGET i j ...   -> v w ...
+//	SET i v        -> OK
+//	INIT v         -> OK
+//
+// with "ERR reason" for a malformed request or an index outside [0, N).
+// ns/op is therefore the loopback round-trip time, a baseline for remote
+// array services such as Redis; the work per request is a slice access.
+type NetworkArrayImpl struct {
+	N    int
+	ln   net.Listener
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	done chan struct{}
+}
+
+func NewNetworkArrayImpl(n int) *NetworkArrayImpl {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &NetworkArrayImpl{N: n, ln: ln, done: make(chan struct{})}
+	go s.serve(n)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		panic(err)
+	}
+	s.conn = conn
+	s.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return s
+}
+func (s *NetworkArrayImpl) Name() string { return "go_tcp_int64" }
+func (s *NetworkArrayImpl) Len() int     { return s.N }
+
+// serve accepts the one client connection and answers it until it closes.
+func (s *NetworkArrayImpl) serve(n int) {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	a := make([]int64, n)
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		rw.WriteString(serveLine(a, strings.Fields(line)))
+		rw.WriteByte('\n')
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// serveLine executes one request against a and returns the reply.
+func serveLine(a []int64, f []string) string {
+	arg := func(k int) (int64, bool) {
+		if k >= len(f) {
+			return 0, false
+		}
+		v, err := strconv.ParseInt(f[k], 10, 64)
+		return v, err == nil
+	}
+	index := func(k int) (int, string) {
+		i, ok := arg(k)
+		if !ok {
+			return 0, "ERR bad index"
+		}
+		if uint64(i) >= uint64(len(a)) {
+			return 0, fmt.Sprintf("ERR index %d out of range [0, %d)", i, len(a))
+		}
+		return int(i), ""
+	}
+	switch {
+	case len(f) == 2 && f[0] == "GET":
+		i, bad := index(1)
+		if bad != "" {
+			return bad
+		}
+		return strconv.FormatInt(a[i], 10)
+	case len(f) >= 2 && f[0] == "MGET":
+		b := make([]byte, 0, 8*(len(f)-1))
+		for k := 1; k < len(f); k++ {
+			i, bad := index(k)
+			if bad != "" {
+				return bad
+			}
+			if k > 1 {
+				b = append(b, ' ')
+			}
+			b = strconv.AppendInt(b, a[i], 10)
+		}
+		return string(b)
+	case len(f) == 3 && f[0] == "SET":
+		i, bad := index(1)
+		if bad != "" {
+			return bad
+		}
+		v, ok := arg(2)
+		if !ok {
+			return "ERR bad value"
+		}
+		a[i] = v
+		return "OK"
+	case len(f) == 2 && f[0] == "INIT":
+		v, ok := arg(1)
+		if !ok {
+			return "ERR bad value"
+		}
+		for i := range a {
+			a[i] = v
+		}
+		return "OK"
+	}
+	return "ERR bad request"
+}
+
+// call sends one request and waits for its reply; an ERR reply is
+// returned as an error, a broken connection panics.
+func (s *NetworkArrayImpl) call(req string) (string, error) {
+	s.rw.WriteString(req)
+	s.rw.WriteByte('\n')
+	if err := s.rw.Flush(); err != nil {
+		panic(err)
+	}
+	reply, err := s.rw.ReadString('\n')
+	if err != nil {
+		panic(err)
+	}
+	reply = strings.TrimSuffix(reply, "\n")
+	if strings.HasPrefix(reply, "ERR ") {
+		return "", fmt.Errorf("%s: %s", req, reply[4:])
+	}
+	return reply, nil
+}
+func (s *NetworkArrayImpl) mustCall(req string) string {
+	reply, err := s.call(req)
+	if err != nil {
+		panic(err)
+	}
+	return reply
+}
+
+func (s *NetworkArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.mustCall("INIT " + strconv.FormatInt(v, 10))
+	return time.Since(start).Nanoseconds()
+}
+func (s *NetworkArrayImpl) Read(i int) int64 {
+	v, err := strconv.ParseInt(s.mustCall("GET "+strconv.Itoa(i)), 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// ReadBatch reads out[k] = Read(idx[k]) for every k in one MGET round trip;
+// NETWORK_BACKED:batch uses it.
+func (s *NetworkArrayImpl) ReadBatch(idx []int, out []int64) {
+	if len(idx) == 0 {
+		return
+	}
+	b := []byte("MGET")
+	for _, i := range idx {
+		b = strconv.AppendInt(append(b, ' '), int64(i), 10)
+	}
+	f := strings.Fields(s.mustCall(string(b)))
+	if len(f) != len(idx) {
+		panic(fmt.Sprintf("MGET: %d values for %d indices", len(f), len(idx)))
+	}
+	for k := range f {
+		v, err := strconv.ParseInt(f[k], 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		out[k] = v
+	}
+}
+func (s *NetworkArrayImpl) Write(i int, v int64) {
+	s.mustCall("SET " + strconv.Itoa(i) + " " + strconv.FormatInt(v, 10))
+}
+
+// CheckedRead and CheckedWrite check the index before the round trip; the
+// server would only send back an ERR reply.
+func (s *NetworkArrayImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *NetworkArrayImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// MemoryFootprint is the server goroutine's slice.
+func (s *NetworkArrayImpl) MemoryFootprint() int64 { return int64(s.N) * 8 }
+
+// Close hangs up and waits for the server goroutine to exit.
+func (s *NetworkArrayImpl) Close() error {
+	err := s.conn.Close()
+	s.ln.Close()
+	<-s.done
+	return err
+}
@@ -0,0 +1,70 @@
+package inplacebench
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NoopImpl stores nothing: Read returns 0 and Write discards its value, so
+// a scenario's ns/op on it is the cost of the scenario's own loop (index
+// and value generation, the interface call, the timer) with no array
+// behind it. Results.SubtractBaseline takes it off other impls' results.
+type NoopImpl struct {
+	N int
+}
+
+func NewNoopImpl(n int) *NoopImpl          { return &NoopImpl{N: n} }
+func (s *NoopImpl) Name() string           { return "go_noop" }
+func (s *NoopImpl) Len() int               { return s.N }
+func (s *NoopImpl) Init(int64) int64       { return 0 }
+func (s *NoopImpl) Read(int) int64         { return 0 }
+func (s *NoopImpl) Write(_ int, v int64)   { _ = v }
+func (s *NoopImpl) MemoryFootprint() int64 { return 0 }
+
+// OverheadImpl is the impl -subtract-overhead takes off the others: on
+// go_noop only the harness is left to time.
+const OverheadImpl = "go_noop"
+
+// checkNoop checks that go_noop is planned only for composite scenarios
+// and BENCHMARK_BENCHMARK, and only it for the latter, and that
+// BENCHMARK_BENCHMARK measures the loop and both harness costs, and that
+// a net summary takes go_noop's median off the cells it has.
+func checkNoop() error {
+	noop, _ := Lookup(OverheadImpl)
+	slice, _ := Lookup("go_slice_int64")
+	planned := map[string]bool{}
+	for _, c := range PlanCells([]Impl{noop, slice}, []int{10}, []string{"WRITE_RANDOM", "READBACK_VERIFY", "BENCHMARK_BENCHMARK"}, []int64{1}, 1, false) {
+		planned[c.Impl.Name+"/"+c.Scenario] = true
+	}
+	for key, want := range map[string]bool{
+		"go_noop/WRITE_RANDOM":               true,
+		"go_noop/READBACK_VERIFY":            false,
+		"go_noop/BENCHMARK_BENCHMARK":        true,
+		"go_slice_int64/BENCHMARK_BENCHMARK": false,
+	} {
+		if planned[key] != want {
+			return fmt.Errorf("noop: %s planned=%v, want %v", key, planned[key], want)
+		}
+	}
+	res := runScenario(noop.New(1000), "BENCHMARK_BENCHMARK", 1000, 1, nil)
+	if res.Ops != 1000 || res.TotalNs <= 0 || res.Relocations == nil || *res.Relocations < 0 || res.Conversions == nil || *res.Conversions <= 0 {
+		return fmt.Errorf("noop: BENCHMARK_BENCHMARK gave %d ops in %d ns, counters %v and %v", res.Ops, res.TotalNs, res.Relocations, res.Conversions)
+	}
+	row := func(impl string, N int, ns float64) Result {
+		return Result{Impl: impl, Scenario: "WRITE_RANDOM", N: N, Seed: 1, NsPerOp: ns, Status: "ok"}
+	}
+	var b strings.Builder
+	rs := Results{row("a", 10, 5), row("a", 10, 7), row(OverheadImpl, 10, 2), row("a", 20, 9)}
+	if err := rs.WriteNetSummary(&b, OverheadImpl); err != nil {
+		return err
+	}
+	var net []string
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n")[1:] {
+		f := strings.Fields(line)
+		net = append(net, f[len(f)-2]+" "+f[len(f)-1])
+	}
+	if want := "[2.0000 4.0000 2.0000 0.0000 - -]"; fmt.Sprint(net) != want {
+		return fmt.Errorf("noop: net summary columns %v, want %s", net, want)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// NUMAPolicy is Runner.NUMA, where the runs' memory goes: with Bind, on
+// Node, from a thread pinned to Node's CPUs; with Interleave, spread page
+// by page over every online node. The zero value leaves placement to the
+// kernel.
+type NUMAPolicy struct {
+	Bind       bool
+	Node       int
+	Interleave bool
+}
+
+// The numa_placement column: how a row's array was placed under a
+// NUMAPolicy. A MappedArray's own mapping is mbind-bound, exactly; any
+// other array is allocated and initialized on a thread with the policy
+// set, which places the pages that thread faults in, but not heap memory
+// the Go runtime had already touched, nor pages other threads fault.
+const (
+	NUMAMbind     = "mbind"
+	NUMAMempolicy = "mempolicy"
+)
+
+func (p NUMAPolicy) set() bool { return p.Bind || p.Interleave }
+
+func (p NUMAPolicy) String() string {
+	switch {
+	case p.Interleave:
+		return "interleave"
+	case p.Bind:
+		return fmt.Sprintf("bind:%d", p.Node)
+	}
+	return "default"
+}
+
+// Unavailable says why p cannot be applied on this machine, or "".
+func (p NUMAPolicy) Unavailable() string {
+	if !p.set() {
+		return ""
+	}
+	nodes := numaNodes()
+	switch {
+	case p.Bind && p.Interleave:
+		return "bind and interleave exclude each other"
+	case len(nodes) == 0:
+		return "no NUMA nodes found (needs linux/amd64 and /sys/devices/system/node)"
+	case p.Bind && !slices.Contains(nodes, p.Node):
+		return fmt.Sprintf("NUMA node %d is not online (online: %v)", p.Node, nodes)
+	}
+	return ""
+}
+
+// numaNodeArg is p as the cell command's -numa-node: its Node under Bind,
+// otherwise -1.
+func numaNodeArg(p NUMAPolicy) int {
+	if p.Bind {
+		return p.Node
+	}
+	return -1
+}
+
+// placeArray applies the cell's policy to arr's mapping if it has one and
+// returns the numa_placement column.
+func placeArray(p NUMAPolicy, arr Array) (string, error) {
+	if !p.set() {
+		return "", nil
+	}
+	if m, ok := arr.(MappedArray); ok && len(m.Mapping()) > 0 {
+		return NUMAMbind, p.bindMapping(m.Mapping())
+	}
+	return NUMAMempolicy, nil
+}
+
+// checkNUMAPolicy checks the policy names and, where node 0 is online,
+// that a Runner under each policy places a mapped impl with mbind and a
+// heap one by mempolicy.
+func checkNUMAPolicy() error {
+	for p, want := range map[NUMAPolicy]string{{}: "default", {Bind: true}: "bind:0", {Bind: true, Node: 3}: "bind:3", {Interleave: true}: "interleave"} {
+		if p.String() != want {
+			return fmt.Errorf("numa: %+v is %q, want %q", p, p.String(), want)
+		}
+	}
+	if (NUMAPolicy{Bind: true, Interleave: true}).Unavailable() == "" || (NUMAPolicy{Bind: true, Node: 1 << 20}).Unavailable() == "" {
+		return fmt.Errorf("numa: an impossible policy is available")
+	}
+	if (NUMAPolicy{Bind: true}).Unavailable() != "" {
+		return nil
+	}
+	impls := []Impl{}
+	want := map[string]string{"go_slice_int64": NUMAMempolicy, "go_mbind_int64": NUMAMbind}
+	for name := range want {
+		if impl, ok := Lookup(name); ok {
+			impls = append(impls, impl)
+		}
+	}
+	for _, p := range []NUMAPolicy{{Bind: true}, {Interleave: true}} {
+		w := &memWriter{}
+		r := &Runner{Impls: impls, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{1 << 16}, Seeds: []int64{1}, Reps: 1, NoBatch: true, NUMA: p, Writers: []ResultWriter{w}}
+		if _, err := r.Run(context.Background()); err != nil {
+			return fmt.Errorf("numa %s: %v", p, err)
+		}
+		for _, row := range w.rows {
+			if !row.OK() || row.NUMAPlacement != want[row.Impl] {
+				return fmt.Errorf("numa %s: %s %s, placement %q, want %q", p, row.Impl, row.Status, row.NUMAPlacement, want[row.Impl])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+//go:build linux && amd64
+
+package inplacebench
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	mpolDefault    = 0 // MPOL_DEFAULT
+	mpolBind       = 2 // MPOL_BIND
+	mpolInterleave = 3 // MPOL_INTERLEAVE
+	mpolMFStrict   = 1 // MPOL_MF_STRICT: fail if a page cannot be placed
+	mpolMFMove     = 2 // MPOL_MF_MOVE: migrate pages already faulted in
+)
+
+// MbindImpl keeps its elements in an anonymous mmap whose pages BindNode
+// restricts to one NUMA node with mbind(MPOL_BIND), migrating any that are
+// already touched. Until then the kernel's first-touch policy places them,
+// and it behaves like go_slice_int64.
+type MbindImpl struct {
+	N    int
+	Node int
+	mem  []byte
+	A    []int64
+}
+
+func NewMbindImpl(n int) *MbindImpl {
+	s := &MbindImpl{N: n, Node: -1}
+	if n == 0 {
+		return s
+	}
+	mem, err := syscall.Mmap(-1, 0, n*8, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic(err)
+	}
+	s.mem = mem
+	s.A = unsafe.Slice((*int64)(unsafe.Pointer(&mem[0])), n)
+	return s
+}
+func (s *MbindImpl) Name() string { return "go_mbind_int64" }
+func (s *MbindImpl) Len() int     { return s.N }
+func (s *MbindImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := range s.A {
+		s.A[i] = v
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *MbindImpl) Read(i int) int64     { return s.A[i] }
+func (s *MbindImpl) Write(i int, v int64) { s.A[i] = v }
+
+// BindNode binds the mapping to node; nodes above 63 are not supported.
+func (s *MbindImpl) BindNode(node int) error {
+	if node < 0 || node > 63 {
+		return fmt.Errorf("mbind: node %d outside 0..63", node)
+	}
+	s.Node = node
+	if s.mem == nil {
+		return nil
+	}
+	mask := uint64(1) << node
+	// maxnode counts one bit past the mask, as the kernel expects.
+	_, _, e := syscall.Syscall6(syscall.SYS_MBIND, uintptr(unsafe.Pointer(&s.mem[0])), uintptr(len(s.mem)),
+		mpolBind, uintptr(unsafe.Pointer(&mask)), 65, mpolMFStrict|mpolMFMove)
+	if e != 0 {
+		return fmt.Errorf("mbind to node %d: %v", node, e)
+	}
+	return nil
+}
+
+func (s *MbindImpl) Close() error {
+	if s.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(s.mem)
+	s.mem, s.A = nil, nil
+	return err
+}
+
+// MemoryFootprint is the size of the mapping.
+func (s *MbindImpl) MemoryFootprint() int64 { return int64(len(s.mem)) }
+
+func (s *MbindImpl) Mapping() []byte { return s.mem }
+
+func registerNUMAImpls() {
+	Register("go_mbind_int64", ImplMeta{
+		Description: "anonymous mmap bound to one NUMA node by mbind",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapNUMA,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "< 1 page", Concurrency: ConcNone},
+	}, func(n int) Array { return NewMbindImpl(n) })
+}
+
+// parseList parses a sysfs list such as "0-3,8,10-11".
+func parseList(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(strings.TrimSpace(s), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, err
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(hi); err != nil {
+				return nil, err
+			}
+		}
+		for i := a; i <= b; i++ {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+func readList(path string) ([]int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseList(string(b))
+}
+
+// numaNodes lists the online NUMA nodes, or nil if sysfs does not say.
+func numaNodes() []int {
+	nodes, err := readList("/sys/devices/system/node/online")
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// cpuMask is a sched_setaffinity mask for up to 1024 CPUs.
+type cpuMask [16]uint64
+
+func setAffinity(m *cpuMask) error {
+	if _, _, e := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(*m), uintptr(unsafe.Pointer(m))); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// pinToNode restricts the calling thread to node's CPUs and returns a
+// function that restores its previous affinity. The caller must hold
+// runtime.LockOSThread.
+func pinToNode(node int) (restore func(), err error) {
+	cpus, err := readList(fmt.Sprintf("/sys/devices/system/node/node%d/cpulist", node))
+	if err != nil {
+		return nil, fmt.Errorf("NUMA node %d: %v", node, err)
+	}
+	var old, mask cpuMask
+	if _, _, e := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(old), uintptr(unsafe.Pointer(&old))); e != 0 {
+		return nil, fmt.Errorf("sched_getaffinity: %v", e)
+	}
+	for _, c := range cpus {
+		if c < len(mask)*64 {
+			mask[c/64] |= 1 << (c % 64)
+		}
+	}
+	if err := setAffinity(&mask); err != nil {
+		return nil, fmt.Errorf("pin to NUMA node %d: %v", node, err)
+	}
+	return func() { setAffinity(&old) }, nil
+}
+
+// mempolicy is p's mode and node mask: MPOL_BIND to its node, or
+// MPOL_INTERLEAVE over every online node.
+func (p NUMAPolicy) mempolicy() (mode uintptr, mask uint64, err error) {
+	if p.Interleave {
+		for _, n := range numaNodes() {
+			if n < 64 {
+				mask |= 1 << n
+			}
+		}
+		return mpolInterleave, mask, nil
+	}
+	if p.Node < 0 || p.Node > 63 {
+		return 0, 0, fmt.Errorf("NUMA node %d outside 0..63", p.Node)
+	}
+	return mpolBind, 1 << p.Node, nil
+}
+
+// applyThread sets p as the calling thread's memory policy, and under
+// Bind pins the thread to the node's CPUs, returning a function that puts
+// both back. The caller must hold runtime.LockOSThread.
+func (p NUMAPolicy) applyThread() (restore func(), err error) {
+	mode, mask, err := p.mempolicy()
+	if err != nil {
+		return nil, err
+	}
+	unpin := func() {}
+	if p.Bind {
+		if unpin, err = pinToNode(p.Node); err != nil {
+			return nil, err
+		}
+	}
+	if _, _, e := syscall.Syscall(syscall.SYS_SET_MEMPOLICY, mode, uintptr(unsafe.Pointer(&mask)), 65); e != 0 {
+		unpin()
+		return nil, fmt.Errorf("set_mempolicy %s: %v", p, e)
+	}
+	return func() {
+		syscall.Syscall(syscall.SYS_SET_MEMPOLICY, mpolDefault, 0, 0)
+		unpin()
+	}, nil
+}
+
+// bindMapping applies p to mem with mbind, moving the pages already
+// touched.
+func (p NUMAPolicy) bindMapping(mem []byte) error {
+	mode, mask, err := p.mempolicy()
+	if err != nil {
+		return err
+	}
+	if _, _, e := syscall.Syscall6(syscall.SYS_MBIND, uintptr(unsafe.Pointer(&mem[0])), uintptr(len(mem)),
+		mode, uintptr(unsafe.Pointer(&mask)), 65, mpolMFMove); e != 0 {
+		return fmt.Errorf("mbind %s: %v", p, e)
+	}
+	return nil
+}
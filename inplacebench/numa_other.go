@@ -0,0 +1,24 @@
+//go:build !(linux && amd64)
+
+package inplacebench
+
+import "errors"
+
+// go_mbind_int64 and the NUMA scenarios' thread pinning use raw linux/amd64
+// syscalls; elsewhere no NUMA nodes are reported, so those scenarios are
+// never planned.
+func registerNUMAImpls() {}
+
+func numaNodes() []int { return nil }
+
+func pinToNode(int) (func(), error) {
+	return nil, errors.New("NUMA pinning is only supported on linux/amd64")
+}
+
+func (p NUMAPolicy) applyThread() (func(), error) {
+	return nil, errors.New("NUMA placement is only supported on linux/amd64")
+}
+
+func (p NUMAPolicy) bindMapping([]byte) error {
+	return errors.New("NUMA placement is only supported on linux/amd64")
+}
@@ -0,0 +1,177 @@
+package inplacebench
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Option sets one parameter of a Family variant; see New.
+type Option func(params map[string]string)
+
+// WithParam sets any family parameter by its key, as in -impl-params.
+func WithParam(key, value string) Option {
+	return func(p map[string]string) { p[key] = value }
+}
+
+// WithShards sets go_sharded's shard count.
+func WithShards(n int) Option { return WithParam("shards", strconv.Itoa(n)) }
+
+// WithVersions sets how many writes per index go_versioned keeps.
+func WithVersions(k int) Option { return WithParam("k", strconv.Itoa(k)) }
+
+// WithSync makes go_file fsync after every Write.
+func WithSync(on bool) Option {
+	v := "0"
+	if on {
+		v = "1"
+	}
+	return WithParam("sync", v)
+}
+
+// WithAddr sets go_redis's server address.
+func WithAddr(addr string) Option { return WithParam("addr", addr) }
+
+// Configure resolves name, a registered impl or a family, with opts applied
+// to its parameters. A registered family variant starts from its own
+// parameters, so Configure("go_sharded_S8_int64", WithShards(16)) and
+// Configure("go_sharded", WithShards(16)) are the same impl. Options an impl
+// does not take, and any option for an impl that is not from a family, are
+// errors.
+func Configure(name string, opts ...Option) (Impl, error) {
+	params := map[string]string{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	family := name
+	if impl, ok := Lookup(name); ok {
+		if len(params) == 0 {
+			return impl, nil
+		}
+		if impl.Family == "" {
+			return Impl{}, fmt.Errorf("%s takes no options", name)
+		}
+		for k, v := range impl.Params {
+			if _, set := params[k]; !set {
+				params[k] = v
+			}
+		}
+		family = impl.Family
+	}
+	f, ok := LookupFamily(family)
+	if !ok {
+		return Impl{}, fmt.Errorf("unknown impl: %s", name)
+	}
+	return f.Variant(params)
+}
+
+// New constructs an n-element array of the impl Configure resolves.
+func New(name string, n int, opts ...Option) (Array, error) {
+	impl, err := Configure(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return impl.New(n), nil
+}
+
+// parseImplSpec parses one -impls entry, NAME or NAME{key=v,key=v}.
+func parseImplSpec(s string) (Impl, error) {
+	name, rest, braced := strings.Cut(s, "{")
+	name = strings.TrimSpace(name)
+	if !braced {
+		impl, ok := Lookup(name)
+		if !ok {
+			return Impl{}, fmt.Errorf("unknown impl: %s", name)
+		}
+		return impl, nil
+	}
+	body, ok := strings.CutSuffix(strings.TrimSpace(rest), "}")
+	if !ok || strings.ContainsAny(body, "{}") {
+		return Impl{}, fmt.Errorf("impls: want NAME{key=v,...}, got %q", s)
+	}
+	var opts []Option
+	for _, kv := range strings.Split(body, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return Impl{}, fmt.Errorf("impls: %s: want key=value, got %q", name, kv)
+		}
+		opts = append(opts, WithParam(strings.TrimSpace(k), strings.TrimSpace(v)))
+	}
+	impl, err := Configure(name, opts...)
+	if err != nil {
+		return Impl{}, fmt.Errorf("impls: %v", err)
+	}
+	return impl, nil
+}
+
+// splitImpls splits a -impls list at the commas outside braces.
+func splitImpls(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
+
+// implParams renders impl's parameters for the impl_params column and
+// list, "key=v,key=v"; it is empty for an impl not built from a family.
+func implParams(impl Impl) string {
+	var parts []string
+	for k, v := range impl.Params {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// checkOptions checks that Configure and the NAME{...} syntax of
+// SelectImpls resolve family names and registered variants to the same
+// impls, and reject options an impl does not take.
+func checkOptions() error {
+	for _, tc := range []struct {
+		spec, want string
+	}{
+		{"go_sharded{shards=16}", "go_sharded_S16_int64"},
+		{"go_sharded_S8_int64{shards=4}", "go_sharded_S4_int64"},
+		{"go_versioned{ k = 2 }", "go_versioned_K2_int64"},
+		{"go_sharded_S8_int64{}", "go_sharded_S8_int64"},
+		{"go_slice_int64", "go_slice_int64"},
+	} {
+		impl, err := parseImplSpec(tc.spec)
+		if err != nil || impl.Name != tc.want {
+			return fmt.Errorf("options: %s is %q (%v), want %s", tc.spec, impl.Name, err, tc.want)
+		}
+	}
+	sel, err := SelectImpls("go_sharded{shards=2},go_slice_int64,go_versioned{k=3}")
+	if err != nil || len(sel) != 3 || sel[2].Name != "go_versioned_K3_int64" {
+		return fmt.Errorf("options: SelectImpls split the list into %d impls (%v)", len(sel), err)
+	}
+	for _, bad := range []string{"go_sharded{bogus=1}", "go_slice_int64{shards=2}", "go_sharded{shards=0}", "go_sharded{shards=2", "nope{k=1}"} {
+		if _, err := parseImplSpec(bad); err == nil {
+			return fmt.Errorf("options: %s accepted", bad)
+		}
+	}
+	arr, err := New("go_sharded", 100, WithShards(3))
+	if err != nil {
+		return fmt.Errorf("options: New: %v", err)
+	}
+	if arr.Name() != "go_sharded_S3_int64" {
+		return fmt.Errorf("options: New(go_sharded, WithShards(3)) is %s", arr.Name())
+	}
+	return selftestImpl(arr, 100)
+}
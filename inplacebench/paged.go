@@ -0,0 +1,98 @@
+package inplacebench
+
+import "time"
+
+// pageElems is the elements per page of PagedImpl: 4 KiB of int64.
+const pageElems = 512
+
+// PagedImpl keeps its elements in 4 KiB pages allocated on first write;
+// Read of a page never written returns the Init value. Write marks its page
+// dirty, so Init only refills the pages written since the last Init (every
+// allocated page when the value changes) instead of all N elements.
+type PagedImpl struct {
+	N     int
+	pages [][]int64
+	dirty []bool
+	// written lists the dirty pages, allocated the pages ever allocated.
+	written, allocated []int
+	def                int64
+}
+
+func NewPagedImpl(n int) *PagedImpl {
+	np := (n + pageElems - 1) / pageElems
+	return &PagedImpl{N: n, pages: make([][]int64, np), dirty: make([]bool, np)}
+}
+func (p *PagedImpl) Name() string { return "go_paged_int64" }
+func (p *PagedImpl) Len() int     { return p.N }
+
+// Init refills the dirty pages with v, or every allocated one when v is a
+// new value, and leaves the rest unallocated.
+func (p *PagedImpl) Init(v int64) int64 {
+	start := time.Now()
+	refill := p.written
+	if v != p.def {
+		refill = p.allocated
+	}
+	for _, pg := range refill {
+		page := p.pages[pg]
+		for k := range page {
+			page[k] = v
+		}
+	}
+	for _, pg := range p.written {
+		p.dirty[pg] = false
+	}
+	p.written, p.def = p.written[:0], v
+	return time.Since(start).Nanoseconds()
+}
+func (p *PagedImpl) Read(i int) int64 {
+	if page := p.pages[i/pageElems]; page != nil {
+		return page[i%pageElems]
+	}
+	return p.def
+}
+func (p *PagedImpl) Write(i int, v int64) {
+	pg := i / pageElems
+	if !p.dirty[pg] {
+		if p.pages[pg] == nil {
+			page := make([]int64, pageElems)
+			for k := range page {
+				page[k] = p.def
+			}
+			p.pages[pg] = page
+			p.allocated = append(p.allocated, pg)
+		}
+		p.dirty[pg] = true
+		p.written = append(p.written, pg)
+	}
+	p.pages[pg][i%pageElems] = v
+}
+
+// DirtyPages is the number of pages written since the last Init.
+func (p *PagedImpl) DirtyPages() int { return len(p.written) }
+
+// Stats reports the pages allocated as relocations and the dirty pages as
+// conversions.
+func (p *PagedImpl) Stats() (relocations, conversions int64) {
+	return int64(len(p.allocated)), int64(len(p.written))
+}
+
+func (p *PagedImpl) MemoryFootprint() int64 {
+	return int64(len(p.allocated))*pageElems*8 + int64(len(p.pages))*(24+1) + int64(cap(p.written)+cap(p.allocated))*8
+}
+
+// dirtyCounter counts the pageElems-element pages of the index range its
+// Writes touch, which is what they dirty in a flat []int64.
+type dirtyCounter struct {
+	Array
+	seen  []bool
+	pages int64
+}
+
+func (d *dirtyCounter) Write(i int, v int64) {
+	if pg := i / pageElems; !d.seen[pg] {
+		d.seen[pg] = true
+		d.pages++
+	}
+	d.Array.Write(i, v)
+}
@@ -0,0 +1,17 @@
+package inplacebench
+
+// PerfColumns are the hardware counter columns Runner.Perf fills, last in
+// Header: perf(1)'s cache-misses, LLC-loads, dTLB-load-misses,
+// branch-misses and instructions, user space only.
+var PerfColumns = [...]string{"cache_misses", "llc_loads", "dtlb_misses", "branch_misses", "instructions"}
+
+// withPerf runs fn, with the counters around it when on and this machine
+// has them; a counter it cannot read is absent from the map, and without
+// perf the map is nil.
+func withPerf(on bool, fn func()) map[string]int64 {
+	if !on || perfUnavailable() != "" {
+		fn()
+		return nil
+	}
+	return perfAround(fn)
+}
@@ -0,0 +1,142 @@
+package inplacebench
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// perfAttr is perf_event_attr up to config1, PERF_ATTR_SIZE_VER0: the
+// kernel takes the size it is given and zeroes the rest.
+type perfAttr struct {
+	Type, Size   uint32
+	Config       uint64
+	SamplePeriod uint64
+	SampleType   uint64
+	ReadFormat   uint64
+	Flags        uint64
+	WakeupEvents uint32
+	BpType       uint32
+	Config1      uint64
+}
+
+const (
+	perfTypeHardware = 0
+	perfTypeHWCache  = 3
+
+	perfFlagDisabled      = 1 << 0
+	perfFlagExcludeKernel = 1 << 5
+	perfFlagExcludeHV     = 1 << 6
+
+	perfFormatTotalTimeEnabled = 1 << 0
+	perfFormatTotalTimeRunning = 1 << 1
+
+	perfFlagFDCloexec = 1 << 3
+
+	perfIocEnable  = 0x2400
+	perfIocDisable = 0x2401
+	perfIocReset   = 0x2403
+)
+
+// perfConfigs are PerfColumns' events: PERF_COUNT_HW_CACHE_MISSES, the
+// last-level cache's read accesses, the data TLB's read misses,
+// PERF_COUNT_HW_BRANCH_MISSES and PERF_COUNT_HW_INSTRUCTIONS.
+var perfConfigs = [len(PerfColumns)]struct {
+	typ    uint32
+	config uint64
+}{
+	{perfTypeHardware, 3},
+	{perfTypeHWCache, 2},
+	{perfTypeHWCache, 3 | 1<<16},
+	{perfTypeHardware, 5},
+	{perfTypeHardware, 1},
+}
+
+// perfOpen opens a counter of user-space events on the calling thread,
+// disabled.
+func perfOpen(typ uint32, config uint64) (int, error) {
+	attr := perfAttr{Type: typ, Size: uint32(unsafe.Sizeof(perfAttr{})), Config: config,
+		ReadFormat: perfFormatTotalTimeEnabled | perfFormatTotalTimeRunning,
+		Flags:      perfFlagDisabled | perfFlagExcludeKernel | perfFlagExcludeHV}
+	cpu, group := -1, -1
+	fd, _, errno := syscall.Syscall6(syscall.SYS_PERF_EVENT_OPEN, uintptr(unsafe.Pointer(&attr)), 0,
+		uintptr(cpu), uintptr(group), perfFlagFDCloexec, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func perfIoctl(fd int, req uintptr) {
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, 0)
+}
+
+var perfProbe struct {
+	once sync.Once
+	why  string
+}
+
+// probePerf opens and closes an instruction counter, once, and says why it
+// could not.
+func probePerf() string {
+	perfProbe.once.Do(func() {
+		fd, err := perfOpen(perfTypeHardware, 1)
+		if err == nil {
+			syscall.Close(fd)
+			return
+		}
+		switch {
+		case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+			paranoid, _ := os.ReadFile("/proc/sys/kernel/perf_event_paranoid")
+			perfProbe.why = fmt.Sprintf("perf_event_open: %v (kernel.perf_event_paranoid = %s)", err, strings.TrimSpace(string(paranoid)))
+		case errors.Is(err, syscall.ENOENT), errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENODEV):
+			perfProbe.why = fmt.Sprintf("perf_event_open: %v: no hardware counters here (a VM without a virtual PMU?)", err)
+		default:
+			perfProbe.why = "perf_event_open: " + err.Error()
+		}
+	})
+	return perfProbe.why
+}
+
+var perfUnavailable = probePerf
+
+// perfAround runs fn on a locked thread with PerfColumns' counters enabled
+// around it, and returns what each that opened counted, scaled up when the
+// kernel multiplexed it. Opening, enabling, reading and closing them all
+// happen outside fn.
+func perfAround(fn func()) map[string]int64 {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	fds := map[string]int{}
+	for i, col := range PerfColumns {
+		if fd, err := perfOpen(perfConfigs[i].typ, perfConfigs[i].config); err == nil {
+			fds[col] = fd
+		}
+	}
+	for _, fd := range fds {
+		perfIoctl(fd, perfIocReset)
+	}
+	for _, fd := range fds {
+		perfIoctl(fd, perfIocEnable)
+	}
+	fn()
+	for _, fd := range fds {
+		perfIoctl(fd, perfIocDisable)
+	}
+	counts := map[string]int64{}
+	for col, fd := range fds {
+		var v [3]uint64 // value, time enabled, time running
+		n, err := syscall.Read(fd, (*[24]byte)(unsafe.Pointer(&v))[:])
+		syscall.Close(fd)
+		if err != nil || n != 24 || v[2] == 0 {
+			continue
+		}
+		counts[col] = int64(float64(v[0]) * float64(v[1]) / float64(v[2]))
+	}
+	return counts
+}
@@ -0,0 +1,14 @@
+package inplacebench
+
+import "testing"
+
+// TestPerfCounters checks that a Runner under Perf fills in the
+// instruction count, where perf_event_open is allowed.
+func TestPerfCounters(t *testing.T) {
+	if why := perfUnavailable(); why != "" {
+		t.Skip(why)
+	}
+	if rows := runPerf(t); !rows[0].OK() || rows[0].Perf["instructions"] <= 0 {
+		t.Fatalf("status %q, counters %v with perf available", rows[0].Status, rows[0].Perf)
+	}
+}
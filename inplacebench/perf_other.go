@@ -0,0 +1,10 @@
+//go:build !linux
+
+package inplacebench
+
+var perfUnavailable = func() string { return "perf_event_open needs Linux" }
+
+func perfAround(fn func()) map[string]int64 {
+	fn()
+	return nil
+}
@@ -0,0 +1,38 @@
+package inplacebench
+
+import (
+	"context"
+	"testing"
+)
+
+// runPerf runs one WRITE_RANDOM run of go_slice_int64 under Perf.
+func runPerf(t *testing.T) Results {
+	t.Helper()
+	slice, _ := Lookup("go_slice_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{1000}, Seeds: []int64{1}, Reps: 1, NoBatch: true, Perf: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.rows) != 1 {
+		t.Fatalf("%d rows, want 1", len(w.rows))
+	}
+	return w.rows
+}
+
+// TestPerfUnavailable checks that a Runner under Perf completes with the
+// counter columns empty when perf is unavailable.
+func TestPerfUnavailable(t *testing.T) {
+	defer func(f func() string) { perfUnavailable = f }(perfUnavailable)
+	perfUnavailable = func() string { return "disabled by the test" }
+	rows := runPerf(t)
+	if !rows[0].OK() || rows[0].Perf != nil {
+		t.Fatalf("status %q, counters %v", rows[0].Status, rows[0].Perf)
+	}
+	rec := rows[0].Record()
+	for i, col := range PerfColumns {
+		if v := rec[len(Header)-len(PerfColumns)+i]; v != "" {
+			t.Fatalf("column %s is %q", col, v)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Phase is one step of a composite scenario: Count ops of one mix over one
+// index distribution, timed on its own. A composite runs its phases in
+// order on the same array, so it can capture transitions the
+// single-pattern scenarios cannot, such as a sparse impl converting to
+// dense halfway through.
+//
+// The RNG is drawn in a fixed order per phase: every index first, then
+// every op kind of a PhaseMixed phase, then a value per write inside the
+// timed loop. Writes store randVal, except under DistSequential, where
+// element i gets i. A TimedDraws phase draws each index inside the timed
+// loop instead, right before its value.
+type Phase struct {
+	// Name is the phase column of the phase's rows; Phases numbers the
+	// unnamed ones.
+	Name string
+	// Init, when set, re-initialises the array to InitValue, untimed,
+	// before the phase.
+	Init      bool
+	InitValue int64
+	Op        PhaseOp
+	// ReadPct is the share of reads of a PhaseMixed phase, in percent.
+	ReadPct int
+	Dist    PhaseDist
+	// HotspotPct and HotPct shape DistHotspot: HotPct percent of the ops go
+	// to the first HotspotPct percent of the indices, the rest to any (see
+	// hotDraw).
+	HotspotPct, HotPct float64
+	// Count is the phase's op count at N; nil means min(1e6, N). A
+	// DistSequential phase longer than N wraps around.
+	Count func(N int) int
+	// Bulk lets a DistSequential phase that only reads or only writes move
+	// rangeChunk elements per ReadRange or WriteRange call when the array
+	// has them.
+	Bulk bool
+	// TimedDraws has a PhaseWrite phase off DistSequential draw each
+	// write's index, then its value, inside the timed loop, so the RNG is
+	// timed with the writes. ADVERSARIAL_HOTSPOT sets it to keep both the
+	// call sequence and the timing of its original loop.
+	TimedDraws bool
+}
+
+// PhaseOp is what a phase's ops do.
+type PhaseOp uint8
+
+const (
+	PhaseWrite PhaseOp = iota
+	PhaseRead
+	// PhaseMixed draws each op's kind, ReadPct percent of them reads.
+	PhaseMixed
+)
+
+// PhaseDist is how a phase picks indices.
+type PhaseDist uint8
+
+const (
+	DistUniform PhaseDist = iota
+	// DistSequential visits 0, 1, ... N-1, 0, ...
+	DistSequential
+	DistHotspot
+)
+
+// PhaseResult is one phase's share of a composite run. Path is "range" or
+// "element" for a DistSequential phase and empty for the others.
+type PhaseResult struct {
+	Name    string
+	Ops     int
+	TotalNs int64
+	NsPerOp float64
+	Path    string
+}
+
+// Phases returns an opt-in composite scenario named name that runs phases
+// in order, e.g. fill sequentially, then ten rounds of hotspot writes and
+// uniform reads, then a full scan:
+//
+//	phases := []inplacebench.Phase{{Name: "fill", Init: true, Dist: inplacebench.DistSequential, Count: func(N int) int { return N }}}
+//	phases = append(phases, inplacebench.Rounds(10,
+//		inplacebench.Phase{Name: "hot", Dist: inplacebench.DistHotspot, HotspotPct: 5, HotPct: 90},
+//		inplacebench.Phase{Name: "read", Op: inplacebench.PhaseRead})...)
+//	phases = append(phases, inplacebench.Phase{Name: "scan", Op: inplacebench.PhaseRead, Dist: inplacebench.DistSequential, Count: func(N int) int { return N }})
+//	inplacebench.RegisterScenario(inplacebench.Phases("FILL_HOT_SCAN", phases...))
+//
+// A run with more than one phase gives a row for the whole run and one per
+// phase, all with the scenario's name and the phase in the phase column;
+// a single-phase composite gives one row, like any other scenario.
+func Phases(name string, phases ...Phase) Scenario {
+	phases = append([]Phase(nil), phases...)
+	for i := range phases {
+		if phases[i].Name == "" {
+			phases[i].Name = strconv.Itoa(i + 1)
+		}
+	}
+	return Scenario{Name: name, OptIn: true, Phases: func(map[string]float64) []Phase { return phases }}
+}
+
+// Rounds repeats phases n times, naming the copies NAME_r1, NAME_r2, ...
+func Rounds(n int, phases ...Phase) []Phase {
+	var out []Phase
+	for r := 1; r <= n; r++ {
+		for _, ph := range phases {
+			ph.Name = fmt.Sprintf("%s_r%d", ph.Name, r)
+			out = append(out, ph)
+		}
+	}
+	return out
+}
+
+func (ph Phase) count(N int) int {
+	if N == 0 {
+		return 0
+	}
+	if ph.Count == nil {
+		return min(1000000, N)
+	}
+	return ph.Count(N)
+}
+
+// hasTimedDraws reports whether a phase of composite sc, at its default
+// parameters, draws in its timed loop.
+func hasTimedDraws(sc Scenario) bool {
+	for _, ph := range sc.Phases(sc.Params) {
+		if ph.TimedDraws {
+			return true
+		}
+	}
+	return false
+}
+
+// phaseOps is the Ops of a composite: the phases' counts at the default
+// parameters.
+func phaseOps(sc Scenario) func(N int) int {
+	return func(N int) int {
+		m := 0
+		for _, ph := range sc.Phases(sc.Params) {
+			m += ph.count(N)
+		}
+		return m
+	}
+}
+
+// runPhases is the Run of a composite scenario. Its ns/op is over every
+// phase's ops; Init calls between phases are not timed. Each phase draws
+// into buf and, with br set, runs as one br.RunOps call; with static set,
+// its per-op loop runs through arr's static loop, if it has one.
+func runPhases(buf *Buffers, br BatchRunner, static bool, arr Array, N int, rng *rand.Rand, phases []Phase) RunResult {
+	var res RunResult
+	var s int64
+	for _, ph := range phases {
+		if ph.Init {
+			arr.Init(ph.InitValue)
+		}
+		pr := runPhase(buf, br, static, ph, arr, N, rng, &s)
+		res.Ops += pr.Ops
+		res.TotalNs += pr.TotalNs
+		res.Phases = append(res.Phases, pr)
+	}
+	consume(s)
+	res.NsPerOp = float64(res.TotalNs) / float64(res.Ops)
+	if len(res.Phases) == 1 {
+		res.Path, res.Phases = res.Phases[0].Path, nil
+	}
+	return res
+}
+
+// runPhase runs one phase's timed loop, xoring what it reads into *s.
+func runPhase(buf *Buffers, br BatchRunner, static bool, ph Phase, arr Array, N int, rng *rand.Rand, s *int64) PhaseResult {
+	M := ph.count(N)
+	seq := ph.Dist == DistSequential
+	timed := ph.TimedDraws && ph.Op == PhaseWrite && !seq
+	// hot is the hot region of a DistHotspot phase; 0 makes the timed
+	// loops draw uniformly.
+	hot := 0
+	if ph.Dist == DistHotspot {
+		hot = int(math.Max(1, float64(N)*ph.HotspotPct/100))
+	}
+	var idx []int
+	switch {
+	case timed:
+		// Drawn in the timed loop, or with the batch ops below.
+	case ph.Dist == DistUniform:
+		idx = buf.Idx(rng, M, N)
+	case ph.Dist == DistHotspot:
+		idx = buf.Ints(M)
+		for k := range idx {
+			idx[k] = drawIndex(rng, N, hot, ph.HotPct)
+		}
+	}
+	var reads []bool
+	if ph.Op == PhaseMixed {
+		reads = buf.Reads(rng, M, ph.ReadPct)
+	}
+	path := ""
+	if seq {
+		path = "element"
+	}
+	if br != nil {
+		// The ops are built before the timer, drawing each write's value in
+		// the order the per-op loops below do, so both dispatches make the
+		// same accesses.
+		ops := buf.Ops(M)
+		for k := range ops {
+			read := ph.Op == PhaseRead || ph.Op == PhaseMixed && reads[k]
+			switch {
+			case timed:
+				i := drawIndex(rng, N, hot, ph.HotPct)
+				ops[k] = Op{Kind: OpWrite, I: i, V: randVal(rng)}
+			case seq && read:
+				ops[k] = Op{Kind: OpRead, I: k % N}
+			case seq:
+				ops[k] = Op{Kind: OpWrite, I: k % N, V: int64(k % N)}
+			case read:
+				ops[k] = Op{Kind: OpRead, I: idx[k]}
+			default:
+				ops[k] = Op{Kind: OpWrite, I: idx[k], V: randVal(rng)}
+			}
+		}
+		start := time.Now()
+		x := br.RunOps(ops)
+		el := time.Since(start).Nanoseconds()
+		*s ^= x
+		return PhaseResult{ph.Name, M, el, timedOps(M, el).NsPerOp, path}
+	}
+	var x, el int64
+	rr, canRead := arr.(RangeReader)
+	rw, canWrite := arr.(RangeWriter)
+	switch {
+	case seq && ph.Bulk && ph.Op == PhaseWrite && canWrite:
+		path = "range"
+		var buf [rangeChunk]int64
+		start := time.Now()
+		for p := 0; p < M; {
+			lo := p % N
+			vals := buf[:min(rangeChunk, N-lo, M-p)]
+			for k := range vals {
+				vals[k] = int64(lo + k)
+			}
+			rw.WriteRange(lo, vals)
+			p += len(vals)
+		}
+		el = time.Since(start).Nanoseconds()
+	case seq && ph.Bulk && ph.Op == PhaseRead && canRead:
+		path = "range"
+		var buf [rangeChunk]int64
+		start := time.Now()
+		for p := 0; p < M; {
+			lo := p % N
+			out := buf[:min(rangeChunk, N-lo, M-p)]
+			rr.ReadRange(lo, out)
+			for _, v := range out {
+				x ^= v
+			}
+			p += len(out)
+		}
+		el = time.Since(start).Nanoseconds()
+	default:
+		l := elementLoop{ph.Op, seq, timed, M, N, hot, ph.HotPct, idx, reads, rng}
+		ok := false
+		if static {
+			x, el, ok = l.runStatic(arr)
+		}
+		if !ok {
+			x, el = l.run(arr)
+		}
+	}
+	*s ^= x
+	r := timedOps(M, el)
+	return PhaseResult{ph.Name, M, el, r.NsPerOp, path}
+}
+
+// elementLoop is a phase's per-op loop: M ops of kind op, over indices idx
+// or, when seq, whole passes over 0..N-1, read or written as reads says
+// for a PhaseMixed phase. A timed loop draws each index itself, by
+// drawIndex over hot and hotPct.
+type elementLoop struct {
+	op         PhaseOp
+	seq, timed bool
+	M, N, hot  int
+	hotPct     float64
+	idx        []int
+	reads      []bool
+	rng        *rand.Rand
+}
+
+// drawIndex draws one index in [0, N): from the first hot when hotDraw
+// says so, else uniformly; hot 0 is always uniform.
+func drawIndex(rng *rand.Rand, N, hot int, hotPct float64) int {
+	if hot > 0 && hotDraw(rng, hotPct) {
+		return rng.Intn(hot)
+	}
+	return rng.Intn(N)
+}
+
+// run times l on arr through the Array interface and returns the xor of
+// what it read and the elapsed nanoseconds.
+func (l elementLoop) run(arr Array) (x, el int64) {
+	var start time.Time
+	switch {
+	case l.seq:
+		// Whole passes over 0..N-1, so the inner loops are the plain ones.
+		start = time.Now()
+		for p := 0; p < l.M; p += l.N {
+			n := min(l.N, l.M-p)
+			switch l.op {
+			case PhaseWrite:
+				for i := 0; i < n; i++ {
+					arr.Write(i, int64(i))
+				}
+			case PhaseRead:
+				for i := 0; i < n; i++ {
+					x ^= arr.Read(i)
+				}
+			default:
+				for i := 0; i < n; i++ {
+					if l.reads[p+i] {
+						x ^= arr.Read(i)
+					} else {
+						arr.Write(i, int64(i))
+					}
+				}
+			}
+		}
+	case l.timed:
+		start = time.Now()
+		for k := 0; k < l.M; k++ {
+			var j int
+			if l.hot > 0 && hotDraw(l.rng, l.hotPct) {
+				j = l.rng.Intn(l.hot)
+			} else {
+				j = l.rng.Intn(l.N)
+			}
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseWrite:
+		start = time.Now()
+		for _, j := range l.idx {
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseRead:
+		start = time.Now()
+		for _, j := range l.idx {
+			x ^= arr.Read(j)
+		}
+	default:
+		start = time.Now()
+		for k, j := range l.idx {
+			if l.reads[k] {
+				x ^= arr.Read(j)
+			} else {
+				arr.Write(j, randVal(l.rng))
+			}
+		}
+	}
+	return x, time.Since(start).Nanoseconds()
+}
+
+// phaseRows expands a composite run's result into the rows written for
+// it: res itself for the whole run, then one per phase with the phase's
+// ops, timing and path and a RunID suffixed with #phase. The whole-run row
+// keeps the counters and footprint, which the phases do not split.
+func phaseRows(res Result) []Result {
+	rows := []Result{res}
+	for _, ph := range res.Phases {
+		r := res
+		r.Phases = nil
+		r.Phase, r.RunID = ph.Name, res.RunID+"#"+ph.Name
+		r.Ops, r.TotalNs, r.NsPerOp, r.Path = ph.Ops, ph.TotalNs, ph.NsPerOp, ph.Path
+		r.InitNs, r.Relocations, r.Conversions, r.BytesResident = 0, 0, 0, 0
+		rows = append(rows, r)
+	}
+	rows[0].Phases = nil
+	return rows
+}
+
+// checkPhases runs a three-phase composite on go_slice_int64 through a
+// Runner and checks its rows, and that the scenarios expressed as
+// single-phase composites keep their golden call sequences (checkScenarios
+// compares those) while reporting the path their range branch takes.
+func checkPhases() error {
+	const N = 100
+	sc := Phases("PHASES_SELFTEST",
+		Phase{Name: "fill", Init: true, Dist: DistSequential, Bulk: true, Count: func(N int) int { return N }},
+		Phase{Name: "hot", Dist: DistHotspot, HotspotPct: 10, HotPct: 90, Count: func(N int) int { return 3 * N }},
+		Phase{Op: PhaseMixed, ReadPct: 50})
+	if _, dup := scenarios[sc.Name]; !dup {
+		RegisterScenario(sc)
+	}
+	slice, _ := Lookup("go_slice_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{sc.Name}, Ns: []int{N}, Seeds: []int64{1}, Reps: 1, NoBatch: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("phases: %v", err)
+	}
+	want := []struct {
+		phase, path string
+		ops         int
+	}{{"", "", 5 * N}, {"fill", "range", N}, {"hot", "", 3 * N}, {"3", "", N}}
+	if len(w.rows) != len(want) {
+		return fmt.Errorf("phases: %d rows, want %d", len(w.rows), len(want))
+	}
+	var sum int64
+	for i, row := range w.rows {
+		if row.Phase != want[i].phase || row.Path != want[i].path || row.Ops != want[i].ops || row.Scenario != sc.Name || !row.OK() {
+			return fmt.Errorf("phases: row %d is phase %q, path %q, %d ops, want %+v", i, row.Phase, row.Path, row.Ops, want[i])
+		}
+		if i > 0 {
+			sum += row.TotalNs
+		}
+	}
+	if sum != w.rows[0].TotalNs || w.rows[1].RunID != w.rows[0].RunID+"#fill" {
+		return fmt.Errorf("phases: phase rows add up to %d ns of %d, RunID %s", sum, w.rows[0].TotalNs, w.rows[1].RunID)
+	}
+	if cells := Results(w.rows).Cells(); len(cells) != 4 {
+		return fmt.Errorf("phases: %d cells, want one per row", len(cells))
+	}
+	if res := runScenario(slice.New(N), "WRITE_SEQUENTIAL", N, 1, nil); res.Path != "range" || res.Phases != nil {
+		return fmt.Errorf("phases: WRITE_SEQUENTIAL on go_slice_int64 took path %q with %d phases", res.Path, len(res.Phases))
+	}
+	return nil
+}
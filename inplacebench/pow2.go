@@ -0,0 +1,56 @@
+package inplacebench
+
+import (
+	"math/bits"
+	"time"
+)
+
+// PowerOfTwoPaddedSliceImpl is a []int64 rounded up to the next power of
+// two, so every access is A[i&(len(A)-1)]: any index lands in the
+// backing array, an index past it wrapping around instead of panicking.
+// Elements N and up are padding, and they are what lets POWER_OF_TWO_MOD
+// reduce a raw index with the mask instead of modulo N, a division when N
+// is only known at run time. The bounds check stays: the compiler cannot
+// prove len(A) > 0, and i&-1 is i.
+type PowerOfTwoPaddedSliceImpl struct {
+	N int
+	A []int64
+}
+
+func NewPowerOfTwoPaddedSliceImpl(n int) *PowerOfTwoPaddedSliceImpl {
+	c := 1
+	if n > 1 {
+		c <<= bits.Len(uint(n - 1))
+	}
+	return &PowerOfTwoPaddedSliceImpl{N: n, A: make([]int64, c)}
+}
+func (s *PowerOfTwoPaddedSliceImpl) Name() string { return "go_pow2_int64" }
+func (s *PowerOfTwoPaddedSliceImpl) Len() int     { return s.N }
+func (s *PowerOfTwoPaddedSliceImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := range s.A {
+		s.A[i] = v
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *PowerOfTwoPaddedSliceImpl) Read(i int) int64     { return s.A[i&(len(s.A)-1)] }
+func (s *PowerOfTwoPaddedSliceImpl) Write(i int, v int64) { s.A[i&(len(s.A)-1)] = v }
+
+// CheckedRead and CheckedWrite reject what Read and Write would wrap, so
+// BOUNDS_MIX still sees an index outside [0, N) refused.
+func (s *PowerOfTwoPaddedSliceImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.A[i], nil
+}
+func (s *PowerOfTwoPaddedSliceImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.A[i] = v
+	return nil
+}
+func (s *PowerOfTwoPaddedSliceImpl) Mask() int              { return len(s.A) - 1 }
+func (s *PowerOfTwoPaddedSliceImpl) Capacity() int          { return len(s.A) }
+func (s *PowerOfTwoPaddedSliceImpl) MemoryFootprint() int64 { return int64(len(s.A)) * 8 }
@@ -0,0 +1,193 @@
+package inplacebench
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+// OpGenerator produces a structured op sequence for N elements. Every
+// sequence starts with an Init and reads back what it wrote and its
+// neighbours, so replaying it against the reference model checks that a Read
+// returns the last Write to that index or else the last Init value, that
+// Init discards every earlier Write, and that an op on index i leaves every
+// other index alone.
+type OpGenerator struct {
+	Name string
+	Gen  func(N int, rng *rand.Rand) []Op
+}
+
+// OpGenerators are the generators CheckProperties runs.
+var OpGenerators = []OpGenerator{
+	{"sequential_bursts", genSequentialBursts},
+	{"sparse_scatter", genSparseScatter},
+	{"init_churn", genInitChurn},
+}
+
+func propVal(rng *rand.Rand) int64 { return int64(rng.Intn(201) - 100) }
+
+// genSequentialBursts writes runs of consecutive indices, then reads each run
+// back together with the index on either side of it.
+func genSequentialBursts(N int, rng *rand.Rand) []Op {
+	seq := []Op{{Kind: OpInit, V: propVal(rng)}}
+	if N == 0 {
+		return seq
+	}
+	for b := 0; b < 8; b++ {
+		start, n := rng.Intn(N), 1+rng.Intn(min(N, 64))
+		for k := 0; k < n; k++ {
+			seq = append(seq, Op{Kind: OpWrite, I: (start + k) % N, V: propVal(rng)})
+		}
+		for k := -1; k <= n; k++ {
+			seq = append(seq, Op{Kind: OpRead, I: ((start+k)%N + N) % N})
+		}
+		if rng.Intn(3) == 0 {
+			seq = append(seq, Op{Kind: OpInit, V: propVal(rng)}, Op{Kind: OpRead, I: start})
+		}
+	}
+	return seq
+}
+
+// genSparseScatter writes a few indices far apart, reads every one of them
+// and as many never-written indices, and then rewrites some of them.
+func genSparseScatter(N int, rng *rand.Rand) []Op {
+	seq := []Op{{Kind: OpInit, V: propVal(rng)}}
+	if N == 0 {
+		return seq
+	}
+	for round := 0; round < 4; round++ {
+		idx := rng.Perm(N)[:min(N, 16)]
+		for _, i := range idx {
+			seq = append(seq, Op{Kind: OpWrite, I: i, V: propVal(rng)})
+		}
+		for _, i := range idx {
+			seq = append(seq, Op{Kind: OpRead, I: i}, Op{Kind: OpRead, I: rng.Intn(N)})
+		}
+		if round%2 == 1 {
+			seq = append(seq, Op{Kind: OpDelete, I: idx[0]}, Op{Kind: OpRead, I: idx[0]})
+		}
+	}
+	return seq
+}
+
+// genInitChurn re-initializes often, alternating Init and Fill with a couple
+// of writes and reads in between.
+func genInitChurn(N int, rng *rand.Rand) []Op {
+	seq := []Op{{Kind: OpInit, V: propVal(rng)}}
+	for round := 0; round < 32; round++ {
+		kind := OpInit
+		if round%4 == 3 {
+			kind = OpFill
+		}
+		seq = append(seq, Op{Kind: kind, V: propVal(rng)})
+		if N == 0 {
+			continue
+		}
+		i := rng.Intn(N)
+		seq = append(seq, Op{Kind: OpRead, I: i}, Op{Kind: OpWrite, I: i, V: propVal(rng)},
+			Op{Kind: OpRead, I: i}, Op{Kind: OpRead, I: rng.Intn(N)})
+	}
+	return seq
+}
+
+// PropertyFailure is a shrunk counterexample: Seq is a minimal sequence (no
+// single op or run of ops can be removed, and N cannot be reduced) on which
+// Impl still diverges from the reference model.
+type PropertyFailure struct {
+	Impl      string
+	Generator string
+	Seed      int64
+	N         int
+	Seq       []Op
+	Div       *Divergence
+}
+
+func (f *PropertyFailure) Error() string {
+	return fmt.Sprintf("%s: %s seed %d: %v; minimal counterexample:\n%s", f.Impl, f.Generator, f.Seed, f.Div, f.GoSnippet())
+}
+
+// GoSnippet renders the counterexample as Go statements that reproduce it.
+func (f *PropertyFailure) GoSnippet() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "impl, _ := inplacebench.Lookup(%q)\narr := impl.New(%d)\n", f.Impl, f.N)
+	for k, op := range f.Seq {
+		last := k == len(f.Seq)-1
+		switch op.Kind {
+		case OpInit:
+			fmt.Fprintf(&b, "arr.Init(%d)\n", op.V)
+		case OpWrite:
+			fmt.Fprintf(&b, "arr.Write(%d, %d)\n", op.I, op.V)
+		case OpFill:
+			fmt.Fprintf(&b, "arr.(inplacebench.Filler).Fill(%d)\n", op.V)
+		case OpDelete:
+			fmt.Fprintf(&b, "arr.(inplacebench.Deleter).Delete(%d)\n", op.I)
+		case OpRead:
+			if !last || f.Div.Panic != "" {
+				fmt.Fprintf(&b, "arr.Read(%d)\n", op.I)
+				break
+			}
+			fmt.Fprintf(&b, "if got := arr.Read(%d); got != %d {\n\tpanic(fmt.Sprintf(\"Read(%d) = %%d, want %d\", got))\n}\n", op.I, f.Div.Want, op.I, f.Div.Want)
+		}
+		if last && f.Div.Panic != "" {
+			fmt.Fprintf(&b, "// panics: %s\n", f.Div.Panic)
+		}
+	}
+	return b.String()
+}
+
+// CheckProperties replays every OpGenerators sequence for each of Ns and
+// seeds 0..seeds-1 against impl and returns the first failure, shrunk, or
+// nil.
+func CheckProperties(impl Impl, Ns []int, seeds int) *PropertyFailure {
+	for _, g := range OpGenerators {
+		for _, N := range Ns {
+			for s := int64(0); s < int64(seeds); s++ {
+				seq := g.Gen(N, rand.New(rand.NewSource(s)))
+				if d := replayFresh(impl, N, seq); d != nil {
+					f := &PropertyFailure{Impl: impl.Name, Generator: g.Name, Seed: s}
+					f.N, f.Seq, f.Div = shrinkOps(impl, N, seq, d)
+					return f
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func replayFresh(impl Impl, N int, seq []Op) *Divergence {
+	arr := impl.New(N)
+	if c, ok := arr.(io.Closer); ok {
+		defer c.Close()
+	}
+	return Replay(arr, N, seq)
+}
+
+// shrinkOps cuts seq after the failing op, then removes runs of ops (halving
+// the run length down to single ops) while it still fails, keeping the
+// leading Init, and finally lowers N to the smallest size that holds every
+// index and still fails.
+func shrinkOps(impl Impl, N int, seq []Op, d *Divergence) (int, []Op, *Divergence) {
+	seq = seq[:d.Op+1]
+	for size := len(seq) / 2; size >= 1; size /= 2 {
+		for start := 1; start < len(seq); {
+			end := min(start+size, len(seq))
+			try := append(append([]Op(nil), seq[:start]...), seq[end:]...)
+			if td := replayFresh(impl, N, try); td != nil {
+				seq, d = try[:td.Op+1], td
+				continue
+			}
+			start = end
+		}
+	}
+	need := 1
+	for _, op := range seq {
+		need = max(need, op.I+1)
+	}
+	for n := need; n < N; n++ {
+		if td := replayFresh(impl, n, seq); td != nil {
+			return n, seq[:td.Op+1], td
+		}
+	}
+	return N, seq, d
+}
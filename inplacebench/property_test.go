@@ -0,0 +1,45 @@
+package inplacebench
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestProperties runs CheckProperties over every registered impl that
+// accepts writes, and reports a failure with its shrunk Go repro.
+func TestProperties(t *testing.T) {
+	for _, impl := range Impls() {
+		if impl.Meta.Has(CapReadOnly) || impl.Meta.Has(CapNoop) {
+			continue
+		}
+		t.Run(impl.Name, func(t *testing.T) {
+			if f := CheckProperties(impl, PropertySizes, 4); f != nil {
+				t.Fatalf("%s: %s seed %d: %v; repro:\n%s", f.Impl, f.Generator, f.Seed, f.Div, f.GoSnippet())
+			}
+		})
+	}
+}
+
+// dropLastWrite loses every write to the last index.
+type dropLastWrite struct{ *SliceImpl }
+
+func (d dropLastWrite) Write(i int, v int64) {
+	if i == d.N-1 {
+		return
+	}
+	d.SliceImpl.Write(i, v)
+}
+
+// TestPropertyShrink checks that a planted bug is found and shrunk to a
+// repro that writes and then reads the last index.
+func TestPropertyShrink(t *testing.T) {
+	impl := Impl{Name: "drop_last_write", New: func(n int) Array { return dropLastWrite{NewSliceImpl(n)} }}
+	f := CheckProperties(impl, PropertySizes, 4)
+	if f == nil {
+		t.Fatal("CheckProperties missed a dropped write")
+	}
+	snippet := f.GoSnippet()
+	if len(f.Seq) > 3 || !strings.Contains(snippet, "arr.Write(") || !strings.Contains(snippet, "want") {
+		t.Fatalf("counterexample not shrunk (%d ops):\n%s", len(f.Seq), snippet)
+	}
+}
@@ -0,0 +1,110 @@
+package inplacebench
+
+import (
+	"fmt"
+	"testing"
+)
+
+// rangeCases are partial ranges that start and end mid-shard, cross one
+// or many shard boundaries, the 512-element page and the rangeChunk
+// boundary, and cover the empty and the whole range.
+var rangeCases = []struct {
+	name      string
+	N, shards int
+	lo, n     int
+}{
+	{"empty", 100, 8, 40, 0},
+	{"whole", 100, 8, 0, 100},
+	{"inside one shard", 100, 8, 14, 9},
+	{"up to a shard end", 100, 8, 3, 10},
+	{"from a shard start", 100, 8, 13, 20},
+	{"across one shard boundary", 100, 8, 10, 6},
+	{"across every shard", 100, 8, 1, 98},
+	{"last element", 100, 8, 99, 1},
+	{"uneven last shard", 10, 4, 7, 3},
+	{"more shards than elements", 5, 8, 1, 4},
+	{"across a page", 4097, 8, 500, 30},
+	{"across rangeChunk", 3 * rangeChunk, 2, rangeChunk - 5, 10},
+	{"shard and rangeChunk at once", 2 * rangeChunk, 2, rangeChunk - 100, 200},
+	{"across a shard and a page", 4097, 3, 1360, 300},
+	{"to the end", 4097, 7, 3000, 1097},
+}
+
+// TestShardedRanges checks WriteRange and ReadRange on ShardedSliceImpl
+// against Read and Write, for each rangeCases range.
+func TestShardedRanges(t *testing.T) {
+	for _, tc := range rangeCases {
+		t.Run(fmt.Sprintf("%s/N=%d/S=%d", tc.name, tc.N, tc.shards), func(t *testing.T) {
+			s := NewShardedSliceImpl(tc.N, tc.shards)
+			s.Init(-1)
+			vals := make([]int64, tc.n)
+			for j := range vals {
+				vals[j] = int64(j + 1)
+			}
+			s.WriteRange(tc.lo, vals)
+			for i := 0; i < tc.N; i++ {
+				want := int64(-1)
+				if i >= tc.lo && i < tc.lo+tc.n {
+					want = int64(i - tc.lo + 1)
+				}
+				if got := s.Read(i); got != want {
+					t.Fatalf("after WriteRange(%d, [%d]): Read(%d) = %d, want %d", tc.lo, tc.n, i, got, want)
+				}
+			}
+			for i := 0; i < tc.N; i++ {
+				s.Write(i, int64(-i))
+			}
+			out := make([]int64, tc.n)
+			s.ReadRange(tc.lo, out)
+			for j, got := range out {
+				if want := int64(-(tc.lo + j)); got != want {
+					t.Fatalf("ReadRange(%d, [%d])[%d] = %d, want %d", tc.lo, tc.n, j, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestRanges checks every registered impl with ReadRange or WriteRange
+// against Read and Write over the rangeCases ranges.
+func TestRanges(t *testing.T) {
+	for _, impl := range Impls() {
+		if impl.Meta.Has(CapNoop) {
+			continue
+		}
+		t.Run(impl.Name, func(t *testing.T) {
+			for _, tc := range rangeCases {
+				arr := impl.New(tc.N)
+				rw, canWrite := arr.(RangeWriter)
+				rr, canRead := arr.(RangeReader)
+				if !canWrite && !canRead {
+					closeArray(arr)
+					t.Skip("no ReadRange or WriteRange")
+				}
+				arr.Init(-1)
+				if canWrite && !impl.Meta.Has(CapReadOnly) {
+					vals := make([]int64, tc.n)
+					for j := range vals {
+						vals[j] = int64(j + 1)
+					}
+					rw.WriteRange(tc.lo, vals)
+					for j := range vals {
+						if got := arr.Read(tc.lo + j); got != vals[j] {
+							t.Fatalf("%s: after WriteRange(%d, [%d]): Read(%d) = %d, want %d", tc.name, tc.lo, tc.n, tc.lo+j, got, vals[j])
+						}
+					}
+				}
+				if canRead {
+					out := make([]int64, tc.n)
+					rr.ReadRange(tc.lo, out)
+					for j, got := range out {
+						if want := arr.Read(tc.lo + j); got != want {
+							t.Fatalf("%s: ReadRange(%d, [%d])[%d] = %d, Read(%d) = %d", tc.name, tc.lo, tc.n, j, got, tc.lo+j, want)
+						}
+					}
+				}
+				closeArray(arr)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package inplacebench
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RedisAddr is the Redis server REDIS_BACKED and go_redis_int64 talk to;
+// -redis-addr sets it.
+var RedisAddr = "localhost:6379"
+
+// RedisArrayImpl keeps element i under the string key PREFIX:i in a Redis
+// server and turns every Read into a GET and every Write into a SET, so
+// ns/op is the Redis round-trip time. Init pipelines MSET commands of
+// redisChunk keys each; Close deletes the keys. The client speaks RESP
+// directly, as this module has no Redis dependency. The connection is
+// dialled on first use, so the registry can build a probe without a server.
+type RedisArrayImpl struct {
+	N      int
+	Addr   string
+	prefix string
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+}
+
+// redisChunk is the number of keys per MSET or DEL, and redisWindow the
+// number of pipelined commands sent before their replies are read.
+const (
+	redisChunk  = 1000
+	redisWindow = 64
+)
+
+var redisArrays atomic.Int64
+
+func NewRedisArrayImpl(n int, addr string) *RedisArrayImpl {
+	prefix := fmt.Sprintf("inplacebench:%d:%d", time.Now().UnixNano(), redisArrays.Add(1))
+	return &RedisArrayImpl{N: n, Addr: addr, prefix: prefix}
+}
+func (s *RedisArrayImpl) Name() string { return "go_redis_int64" }
+func (s *RedisArrayImpl) Len() int     { return s.N }
+
+func (s *RedisArrayImpl) key(i int) string { return s.prefix + ":" + strconv.Itoa(i) }
+
+// dial connects on first use; a server that cannot be reached panics.
+func (s *RedisArrayImpl) dial() {
+	if s.rw != nil {
+		return
+	}
+	conn, err := net.DialTimeout("tcp", s.Addr, 2*time.Second)
+	if err != nil {
+		panic(fmt.Sprintf("go_redis_int64: %v", err))
+	}
+	s.conn = conn
+	s.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+}
+
+// send buffers one command as a RESP array of bulk strings.
+func (s *RedisArrayImpl) send(args ...string) {
+	s.dial()
+	b := strconv.AppendInt([]byte{'*'}, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, a := range args {
+		b = append(strconv.AppendInt(append(b, '$'), int64(len(a)), 10), '\r', '\n')
+		b = append(append(b, a...), '\r', '\n')
+	}
+	if _, err := s.rw.Write(b); err != nil {
+		panic(err)
+	}
+}
+
+// reply reads one reply; the bool reports a nil bulk string, and an array
+// comes back as its elements. An -ERR reply is returned as an error, a broken
+// connection panics.
+func (s *RedisArrayImpl) reply() (string, []string, bool, error) {
+	line := s.line()
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil, false, nil
+	case '-':
+		return "", nil, false, errors.New(line[1:])
+	case '$':
+		v, isNil := s.bulk(line)
+		return v, nil, isNil, nil
+	case '*':
+		n, perr := strconv.Atoi(line[1:])
+		if perr != nil {
+			panic(fmt.Sprintf("go_redis_int64: bad reply %q", line))
+		}
+		var elems []string
+		for k := 0; k < n; k++ {
+			v, _ := s.bulk(s.line())
+			elems = append(elems, v)
+		}
+		return "", elems, n < 0, nil
+	}
+	panic(fmt.Sprintf("go_redis_int64: bad reply %q", line))
+}
+
+func (s *RedisArrayImpl) line() string {
+	line, err := s.rw.ReadString('\n')
+	if err != nil {
+		panic(err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		panic(fmt.Sprintf("go_redis_int64: bad reply %q", line))
+	}
+	return line[:len(line)-2]
+}
+
+// bulk reads the body of the bulk string whose header is line; "$-1" is
+// nil and reads as "".
+func (s *RedisArrayImpl) bulk(line string) (string, bool) {
+	n, err := strconv.Atoi(line[1:])
+	if line[0] != '$' || err != nil {
+		panic(fmt.Sprintf("go_redis_int64: bad reply %q", line))
+	}
+	if n < 0 {
+		return "", true
+	}
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(s.rw, b); err != nil {
+		panic(err)
+	}
+	return string(b[:n]), false
+}
+
+// do sends one command and waits for its reply.
+func (s *RedisArrayImpl) do(args ...string) (string, []string, bool) {
+	s.send(args...)
+	if err := s.rw.Flush(); err != nil {
+		panic(err)
+	}
+	v, elems, isNil, err := s.reply()
+	if err != nil {
+		panic(fmt.Sprintf("go_redis_int64: %s: %v", args[0], err))
+	}
+	return v, elems, isNil
+}
+
+// pipeline sends cmd(lo, hi) for every chunk of redisChunk indices,
+// reading the replies back redisWindow commands at a time.
+func (s *RedisArrayImpl) pipeline(cmd func(lo, hi int) []string) {
+	s.dial()
+	pending := 0
+	drain := func() {
+		if err := s.rw.Flush(); err != nil {
+			panic(err)
+		}
+		for ; pending > 0; pending-- {
+			if _, _, _, err := s.reply(); err != nil {
+				panic(fmt.Sprintf("go_redis_int64: %v", err))
+			}
+		}
+	}
+	for lo := 0; lo < s.N; lo += redisChunk {
+		s.send(cmd(lo, min(lo+redisChunk, s.N))...)
+		if pending++; pending == redisWindow {
+			drain()
+		}
+	}
+	drain()
+}
+
+func (s *RedisArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	val := strconv.FormatInt(v, 10)
+	s.pipeline(func(lo, hi int) []string {
+		args := make([]string, 0, 1+2*(hi-lo))
+		args = append(args, "MSET")
+		for i := lo; i < hi; i++ {
+			args = append(args, s.key(i), val)
+		}
+		return args
+	})
+	return time.Since(start).Nanoseconds()
+}
+
+// parseRedisInt parses a stored value; a key that was never set reads as 0.
+func parseRedisInt(v string, isNil bool) int64 {
+	if isNil {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("go_redis_int64: value %q: %v", v, err))
+	}
+	return n
+}
+
+func (s *RedisArrayImpl) Read(i int) int64 {
+	v, _, isNil := s.do("GET", s.key(i))
+	return parseRedisInt(v, isNil)
+}
+func (s *RedisArrayImpl) Write(i int, v int64) {
+	s.do("SET", s.key(i), strconv.FormatInt(v, 10))
+}
+
+// ReadBatch reads every index in idx with one MGET, as
+// NetworkArrayImpl.ReadBatch does.
+func (s *RedisArrayImpl) ReadBatch(idx []int, out []int64) {
+	if len(idx) == 0 {
+		return
+	}
+	args := make([]string, 0, 1+len(idx))
+	args = append(args, "MGET")
+	for _, i := range idx {
+		args = append(args, s.key(i))
+	}
+	s.send(args...)
+	if err := s.rw.Flush(); err != nil {
+		panic(err)
+	}
+	line := s.line()
+	n, err := strconv.Atoi(line[1:])
+	if line[0] != '*' || err != nil || n != len(idx) {
+		panic(fmt.Sprintf("go_redis_int64: MGET of %d keys: reply %q", len(idx), line))
+	}
+	for k := range idx {
+		out[k] = parseRedisInt(s.bulk(s.line()))
+	}
+}
+
+// Close deletes the keys and hangs up; an array never used has nothing to
+// delete.
+func (s *RedisArrayImpl) Close() error {
+	if s.rw == nil {
+		return nil
+	}
+	s.pipeline(func(lo, hi int) []string {
+		args := make([]string, 0, 1+hi-lo)
+		args = append(args, "DEL")
+		for i := lo; i < hi; i++ {
+			args = append(args, s.key(i))
+		}
+		return args
+	})
+	s.rw = nil
+	return s.conn.Close()
+}
+
+// redisUnavailable is REDIS_BACKED's Unavailable: it sends a PING to
+// RedisAddr, once per address.
+func redisUnavailable() string {
+	redisProbe.Lock()
+	defer redisProbe.Unlock()
+	addr := RedisAddr
+	if why, ok := redisProbe.why[addr]; ok {
+		return why
+	}
+	why := ""
+	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+	if err == nil {
+		conn.SetDeadline(time.Now().Add(500 * time.Millisecond))
+		var b [7]byte
+		if _, err = conn.Write([]byte("*1\r\n$4\r\nPING\r\n")); err == nil {
+			if _, err = io.ReadFull(conn, b[:]); err == nil && string(b[:]) != "+PONG\r\n" {
+				err = fmt.Errorf("PING: reply %q", b[:])
+			}
+		}
+		conn.Close()
+	}
+	if err != nil {
+		why = fmt.Sprintf("no Redis at %s: %v", addr, err)
+	}
+	redisProbe.why[addr] = why
+	return why
+}
+
+var redisProbe = struct {
+	sync.Mutex
+	why map[string]string
+}{why: map[string]string{}}
+
+// registerRedisImpls registers the go_redis family but no variant, so
+// "-impls all" and the selftest never need a server; REDIS_BACKED adds
+// go_redis_int64 for RedisAddr, and -impl-params go_redis:addr=HOST:PORT
+// adds it for any scenario.
+func registerRedisImpls() {
+	RegisterFamily(Family{
+		Name:     "go_redis",
+		Defaults: map[string]string{"addr": RedisAddr},
+		New: func(n int, p map[string]string) (Array, error) {
+			if p["addr"] == "" {
+				return nil, fmt.Errorf("addr must be HOST:PORT")
+			}
+			return NewRedisArrayImpl(n, p["addr"]), nil
+		},
+		// The memory is the server's, roughly 64 bytes per small string key.
+		Meta: func(p map[string]string) ImplMeta {
+			return ImplMeta{
+				Description: "keys in Redis at " + p["addr"] + ", GET/SET per access, pipelined MSET for Init",
+				ElemBytes:   8,
+				Overhead:    8,
+				EstNsPerOp:  30000,
+				Model:       ImplModel{Init: ONB, Op: O1, ExtraSpace: "O(N) in Redis", Concurrency: ConcNone},
+			}
+		},
+	})
+}
+
+// checkRedis runs the property and selftest checks on go_redis_int64 when
+// RedisAddr answers, and passes without a server.
+func checkRedis() error {
+	if redisUnavailable() != "" {
+		return nil
+	}
+	f, _ := LookupFamily("go_redis")
+	impl, err := f.Variant(map[string]string{"addr": RedisAddr})
+	if err != nil {
+		return err
+	}
+	if pf := CheckProperties(impl, PropertySizes, 1); pf != nil {
+		return pf
+	}
+	for _, N := range SelftestSizes {
+		arr := impl.New(N)
+		err := selftestImpl(arr, N)
+		arr.(io.Closer).Close()
+		if err != nil {
+			return fmt.Errorf("%s N=%d: %v", impl.Name, N, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,548 @@
+package inplacebench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Capability flags what an implementation supports beyond Array.
+type Capability uint
+
+const (
+	// CapFill: the impl has a bulk Fill operation.
+	CapFill Capability = 1 << iota
+	// CapDelete: the impl is a Deleter.
+	CapDelete
+	// CapConcurrent: Read and Write are safe from concurrent goroutines,
+	// and a Read of i returns the Init value or a value written to i, never
+	// a torn one; CheckConcurrent tests this. Init may not overlap them.
+	// Only these impls run the Exclusive scenarios.
+	CapConcurrent
+	// CapStats: the impl implements StatsReporter.
+	CapStats
+	// CapReadOnly: Write panics after Init, so only ReadOnlyScenarios run.
+	CapReadOnly
+	// CapSorted: the impl is an Iterator that visits in index order.
+	CapSorted
+	// CapNUMA: the impl is a NodeBinder; only it runs NUMA_LOCAL and
+	// NUMA_REMOTE.
+	CapNUMA
+	// CapSnapshot: the impl is a Snapshotter.
+	CapSnapshot
+	// CapNoop: the impl stores nothing, so it only runs the composite
+	// scenarios, whose loops do not look at what they read, and the ones
+	// requiring CapNoop; selftest, verify and fuzz skip it.
+	CapNoop
+	// CapCAS: the impl is a CompareAndSwapper; only it runs
+	// COMPARE_EXCHANGE.
+	CapCAS
+	// CapBatch: the impl is a BatchRunner; the composite scenarios also run
+	// on it with dispatch=batch.
+	CapBatch
+	// CapStatic: runStatic has a loop for the impl's concrete type; the
+	// composite scenarios also run on it with dispatch=static under
+	// Runner.Static.
+	CapStatic
+)
+
+var capNames = []string{"fill", "delete", "concurrent", "stats", "readonly", "sorted", "numa", "snapshot", "noop", "cas", "batch", "static"}
+
+func (c Capability) String() string {
+	var names []string
+	for i, name := range capNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+// ImplMeta describes a registered implementation. ElemBytes is the size of
+// one element and Overhead the factor of memory actually used per element
+// (every index written) relative to it; their product sizes sweeps.
+// EstNsPerOp is a coarse per-operation cost used by the run-time estimator.
+// Model is what the impl claims about itself in theory.
+type ImplMeta struct {
+	Description string
+	ElemBytes   float64
+	Overhead    float64
+	Caps        Capability
+	EstNsPerOp  float64
+	Model       ImplModel
+}
+
+// ImplModel is an impl's self-description for the theoretical columns of a
+// comparison table: the complexity of Init and of one Read or Write, the
+// memory it needs beyond the N elements as a formula, and how it serves
+// concurrent callers. The formula is in N, W (indices written since Init)
+// and the impl's parameters. The zero value claims nothing; TestModels
+// checks the claims it can measure. impls.json records every selected
+// impl's model next to the results.
+type ImplModel struct {
+	Init        Complexity  `json:"init"`
+	Op          Complexity  `json:"op"`
+	ExtraSpace  string      `json:"extra_space"`
+	Concurrency Concurrency `json:"concurrency"`
+}
+
+// Complexity is an asymptotic cost class.
+type Complexity string
+
+const (
+	O1    Complexity = "O(1)"
+	OLogN Complexity = "O(log N)"
+	// ONB is N/B block operations, B elements each: chunked syscalls or
+	// pipelined batches.
+	ONB    Complexity = "O(N/B)"
+	ON     Complexity = "O(N)"
+	ONLogN Complexity = "O(N log N)"
+)
+
+// Concurrency is how an impl serves concurrent callers. Every impl but
+// ConcNone ones declares CapConcurrent.
+type Concurrency string
+
+const (
+	// ConcNone: one goroutine at a time.
+	ConcNone Concurrency = "none"
+	// ConcLocked: one lock around the whole array.
+	ConcLocked Concurrency = "locked"
+	// ConcSharded: a lock per shard of indices.
+	ConcSharded Concurrency = "sharded"
+	// ConcLockFree: atomic operations, no locks.
+	ConcLockFree Concurrency = "lock-free"
+	// ConcSerialized: one owner goroutine serves every access in turn.
+	ConcSerialized Concurrency = "serialized"
+)
+
+// BytesPerElem is the estimated memory per element, ElemBytes * Overhead.
+func (m ImplMeta) BytesPerElem() float64 { return m.ElemBytes * math.Max(m.Overhead, 1) }
+
+// Has reports whether every capability in c is set.
+func (m ImplMeta) Has(c Capability) bool { return m.Caps&c == c }
+
+// Impl is a registered implementation. Impls built from a Family record it
+// and the parameters they were built with.
+type Impl struct {
+	Name   string
+	Meta   ImplMeta
+	New    func(n int) Array
+	Family string
+	Params map[string]string
+}
+
+// Family is a constructor that takes parameters, so one implementation can be
+// benchmarked at several settings without a registered name for each.
+// Defaults lists every accepted key with its default value; Meta may depend
+// on the parameters.
+type Family struct {
+	Name     string
+	Defaults map[string]string
+	New      func(n int, params map[string]string) (Array, error)
+	Meta     func(params map[string]string) ImplMeta
+}
+
+// Variant builds the Impl for one parameter setting; keys missing from
+// params take their defaults. The Impl is named after what the constructed
+// array reports, e.g. go_sharded_S64_int64.
+func (f Family) Variant(params map[string]string) (Impl, error) {
+	merged := map[string]string{}
+	for k, v := range f.Defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		if _, ok := f.Defaults[k]; !ok {
+			var keys []string
+			for k := range f.Defaults {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return Impl{}, fmt.Errorf("%s does not take %q (takes %v)", f.Name, k, keys)
+		}
+		merged[k] = v
+	}
+	probe, err := f.New(0, merged)
+	if err != nil {
+		return Impl{}, fmt.Errorf("%s: %v", f.Name, err)
+	}
+	if c, ok := probe.(io.Closer); ok {
+		defer c.Close()
+	}
+	return Impl{
+		Name: probe.Name(),
+		Meta: f.Meta(merged),
+		New: func(n int) Array {
+			arr, err := f.New(n, merged)
+			if err != nil {
+				panic(err)
+			}
+			return arr
+		},
+		Family: f.Name,
+		Params: merged,
+	}, nil
+}
+
+func (f Family) mustVariant(kv ...string) Impl {
+	params := map[string]string{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		params[kv[i]] = kv[i+1]
+	}
+	impl, err := f.Variant(params)
+	if err != nil {
+		panic(err)
+	}
+	return impl
+}
+
+// intParam parses a positive integer parameter.
+func intParam(params map[string]string, key string) (int, error) {
+	v, err := strconv.Atoi(params[key])
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("%s=%q: want a positive integer", key, params[key])
+	}
+	return v, nil
+}
+
+// boolParam parses a 0/1 (or true/false) parameter.
+func boolParam(params map[string]string, key string) (bool, error) {
+	v, err := strconv.ParseBool(params[key])
+	if err != nil {
+		return false, fmt.Errorf("%s=%q: want 0 or 1", key, params[key])
+	}
+	return v, nil
+}
+
+var families = []Family{
+	{
+		Name:     "go_sharded",
+		Defaults: map[string]string{"shards": "8"},
+		New: func(n int, p map[string]string) (Array, error) {
+			shards, err := intParam(p, "shards")
+			if err != nil {
+				return nil, err
+			}
+			return NewShardedSliceImpl(n, shards), nil
+		},
+		Meta: func(map[string]string) ImplMeta {
+			return ImplMeta{
+				Description: "slice split into mutex-guarded shards",
+				ElemBytes:   8,
+				Overhead:    1,
+				Caps:        CapFill | CapDelete | CapConcurrent | CapSorted | CapSnapshot,
+				EstNsPerOp:  25,
+				Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(S)", Concurrency: ConcSharded},
+			}
+		},
+	},
+	{
+		Name:     "go_versioned",
+		Defaults: map[string]string{"k": "1"},
+		New: func(n int, p map[string]string) (Array, error) {
+			k, err := intParam(p, "k")
+			if err != nil {
+				return nil, err
+			}
+			return NewVersionedArrayImpl(n, k), nil
+		},
+		// Roughly 56 bytes per element at K=1, 80 at K=4.
+		Meta: func(p map[string]string) ImplMeta {
+			k, _ := strconv.Atoi(p["k"])
+			return ImplMeta{
+				Description: "keeps the K most recent writes per index",
+				ElemBytes:   8,
+				Overhead:    6 + float64(k),
+				Caps:        CapFill | CapDelete | CapStats | CapSnapshot,
+				EstNsPerOp:  150,
+				Model:       ImplModel{Init: O1, Op: O1, ExtraSpace: "O(W*K)", Concurrency: ConcNone},
+			}
+		},
+	},
+}
+
+// LookupFamily returns the registered family with the given name.
+func LookupFamily(name string) (Family, bool) {
+	for _, f := range families {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// RegisterFamily adds a parameterised implementation family.
+func RegisterFamily(f Family) { families = append(families, f) }
+
+// Families returns every registered family in registration order.
+func Families() []Family { return append([]Family(nil), families...) }
+
+// impls is the registry, in registration order; byName indexes it.
+var (
+	impls  []Impl
+	byName = map[string]int{}
+)
+
+// Register adds an implementation to the registry. It panics if name is
+// empty, ctor is nil or name is already registered, so conflicts surface at
+// init time. Call it from an init function, before running anything.
+func Register(name string, meta ImplMeta, ctor func(n int) Array) {
+	register(Impl{Name: name, Meta: meta, New: ctor})
+}
+
+func register(impl Impl) {
+	if impl.Name == "" {
+		panic("inplacebench: Register with an empty name")
+	}
+	if impl.New == nil {
+		panic("inplacebench: Register " + impl.Name + " with a nil constructor")
+	}
+	if _, dup := byName[impl.Name]; dup {
+		panic("inplacebench: Register called twice for " + impl.Name)
+	}
+	byName[impl.Name] = len(impls)
+	impls = append(impls, impl)
+}
+
+func init() {
+	Register("go_slice_int64", ImplMeta{
+		Description: "plain []int64; Init rewrites every element",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill | CapDelete | CapSorted | CapSnapshot | CapBatch | CapStatic,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "0", Concurrency: ConcNone},
+	}, func(n int) Array { return NewSliceImpl(n) })
+	Register("go_atomic_int64", ImplMeta{
+		Description: "[]int64 accessed with sync/atomic loads and stores",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill | CapDelete | CapConcurrent | CapSorted | CapSnapshot | CapCAS | CapStatic,
+		EstNsPerOp:  6,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "0", Concurrency: ConcLockFree},
+	}, func(n int) Array { return NewAtomicSliceImpl(n) })
+	registerRelaxedImpls()
+	Register("go_rwmutex_int64", ImplMeta{
+		Description: "[]int64 behind one sync.RWMutex",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill | CapDelete | CapConcurrent | CapSorted | CapSnapshot | CapCAS,
+		EstNsPerOp:  20,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(1)", Concurrency: ConcLocked},
+	}, func(n int) Array { return NewThreadSafeSliceImpl(n) })
+	Register("go_seqlock_int64", ImplMeta{
+		Description: "[]int64 with per-element sequence counters (seqlock)",
+		ElemBytes:   8,
+		Overhead:    1.5,
+		Caps:        CapConcurrent,
+		EstNsPerOp:  15,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "4N", Concurrency: ConcLockFree},
+	}, func(n int) Array { return NewReadWriteLockFreeImpl(n) })
+	register(families[0].mustVariant("shards", "1"))
+	register(families[0].mustVariant("shards", "8"))
+	register(families[0].mustVariant("shards", "64"))
+	register(families[1].mustVariant("k", "1"))
+	register(families[1].mustVariant("k", "4"))
+	Register("go_delta_int64", ImplMeta{
+		Description: "first write in full, later writes as int8 delta chains",
+		ElemBytes:   8,
+		Overhead:    12,
+		Caps:        CapFill | CapDelete | CapStats,
+		EstNsPerOp:  150,
+		Model:       ImplModel{Init: O1, Op: O1, ExtraSpace: "O(W)", Concurrency: ConcNone},
+	}, func(n int) Array { return NewDeltaArrayImpl(n) })
+	Register("go_btree_int64", ImplMeta{
+		Description: "github.com/google/btree keyed by index",
+		ElemBytes:   8,
+		Overhead:    4,
+		Caps:        CapFill | CapDelete | CapStats | CapSorted | CapSnapshot,
+		EstNsPerOp:  120,
+		Model:       ImplModel{Init: ONLogN, Op: OLogN, ExtraSpace: "O(N)", Concurrency: ConcNone},
+	}, func(n int) Array { return NewBTreeImpl(n) })
+	Register("go_btree_locked_int64", ImplMeta{
+		Description: "go_btree_int64 behind a sync.RWMutex",
+		ElemBytes:   8,
+		Overhead:    4,
+		Caps:        CapFill | CapDelete | CapConcurrent | CapStats | CapSorted | CapSnapshot,
+		EstNsPerOp:  140,
+		Model:       ImplModel{Init: ONLogN, Op: OLogN, ExtraSpace: "O(N)", Concurrency: ConcLocked},
+	}, func(n int) Array { return NewLockedBTreeImpl(n) })
+	Register("go_skiplist_int64", ImplMeta{
+		Description: "lock-free skip list of written indices",
+		ElemBytes:   8,
+		Overhead:    9,
+		Caps:        CapConcurrent | CapStats | CapSorted,
+		EstNsPerOp:  200,
+		Model:       ImplModel{Init: O1, Op: OLogN, ExtraSpace: "O(W)", Concurrency: ConcLockFree},
+	}, func(n int) Array { return NewSkipListImpl(n) })
+	// EstNsPerOp is per link followed; see linearAccess.
+	Register("go_xorlist_int64", ImplMeta{
+		Description: "XOR doubly linked list; O(i) Read and Write",
+		ElemBytes:   8,
+		Overhead:    2,
+		Caps:        CapSorted,
+		EstNsPerOp:  10,
+		Model:       ImplModel{Init: ON, Op: ON, ExtraSpace: "8N", Concurrency: ConcNone},
+	}, func(n int) Array { return NewXORLinkedListImpl(n) })
+	// The overhead counts the file size so -auto-Ns stays bounded.
+	Register("go_jsonfile_int64", ImplMeta{
+		Description: "fixed-width JSON lines in a temp file",
+		ElemBytes:   8,
+		Overhead:    jsonLineWidth / 8.0,
+		EstNsPerOp:  1000,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "13N on disk", Concurrency: ConcNone},
+	}, func(n int) Array { return NewMemoryMappedJSONImpl(n) })
+	registerFileImpls()
+	// EstNsPerOp is a loopback round trip.
+	Register("go_tcp_int64", ImplMeta{
+		Description: "[]int64 in a server goroutine behind loopback TCP, GET/SET per access",
+		ElemBytes:   8,
+		Overhead:    1,
+		EstNsPerOp:  10000,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(1)", Concurrency: ConcNone},
+	}, func(n int) Array { return NewNetworkArrayImpl(n) })
+	registerRedisImpls()
+	Register("go_chan_int64", ImplMeta{
+		Description: "[]int64 owned by one goroutine; every access is a request over a channel",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill | CapConcurrent,
+		EstNsPerOp:  300,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(1)", Concurrency: ConcSerialized},
+	}, func(n int) Array { return NewChannelArrayImpl(n) })
+	Register("go_immutable_int64", ImplMeta{
+		Description: "go_slice_int64 that panics on Write after Init",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapReadOnly | CapSorted | CapSnapshot | CapBatch,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "0", Concurrency: ConcNone},
+	}, func(n int) Array { return NewImmutableArrayImpl(n) })
+	Register("go_ring_int64", ImplMeta{
+		Description: "ring buffer with head/tail; Push overwrites the oldest entry",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapFill | CapStats,
+		EstNsPerOp:  6,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "0", Concurrency: ConcNone},
+	}, func(n int) Array { return NewRingBufferImpl(n) })
+	Register("go_pow2_int64", ImplMeta{
+		Description: "[]int64 padded to the next power of two, indexed i & (cap-1)",
+		ElemBytes:   8,
+		Overhead:    2,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(N)", Concurrency: ConcNone},
+	}, func(n int) Array { return NewPowerOfTwoPaddedSliceImpl(n) })
+	Register("go_paged_int64", ImplMeta{
+		Description: "[]int64 in 4 KiB pages allocated on first write; Init refills only the pages written since the last one",
+		ElemBytes:   8,
+		Overhead:    1,
+		Caps:        CapStats,
+		EstNsPerOp:  7,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "O(N/B)", Concurrency: ConcNone},
+	}, func(n int) Array { return NewPagedImpl(n) })
+	// EstNsPerOp is the scenario loop alone.
+	Register("go_noop", ImplMeta{
+		Description: "stores nothing; Read returns 0, Write discards: the scenario loop's own cost",
+		ElemBytes:   8,
+		Overhead:    0,
+		Caps:        CapNoop,
+		EstNsPerOp:  1,
+		Model:       ImplModel{Init: O1, Op: O1, ExtraSpace: "0", Concurrency: ConcNone},
+	}, func(n int) Array { return NewNoopImpl(n) })
+	registerPlatformImpls()
+	RegisterFamily(instrFamily)
+}
+
+// ParseImplParams parses "FAMILY:key=v,key=v,FAMILY:key=v" into one Impl per
+// FAMILY: prefix; a pair without a prefix belongs to the variant before it,
+// so the same family may appear several times with different settings.
+func ParseImplParams(s string) ([]Impl, error) {
+	type spec struct {
+		family string
+		params map[string]string
+	}
+	var specs []*spec
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if fam, kv, ok := strings.Cut(part, ":"); ok {
+			specs = append(specs, &spec{fam, map[string]string{}})
+			part = kv
+		}
+		if len(specs) == 0 {
+			return nil, fmt.Errorf("impl-params: %q has no FAMILY: prefix", part)
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("impl-params: want key=value, got %q", part)
+		}
+		specs[len(specs)-1].params[k] = v
+	}
+	var out []Impl
+	for _, sp := range specs {
+		f, ok := LookupFamily(sp.family)
+		if !ok {
+			var names []string
+			for _, f := range families {
+				names = append(names, f.Name)
+			}
+			return nil, fmt.Errorf("impl-params: unknown family %q (have %v)", sp.family, names)
+		}
+		impl, err := f.Variant(sp.params)
+		if err != nil {
+			return nil, fmt.Errorf("impl-params: %v", err)
+		}
+		out = append(out, impl)
+	}
+	return out, nil
+}
+
+// formatImplParams renders impl's parameters in ParseImplParams syntax.
+func formatImplParams(impl Impl) string { return impl.Family + ":" + implParams(impl) }
+
+// linearAccess marks impls whose Read and Write walk O(i) links, so the
+// estimator charges N/2 hops per indexed op.
+var linearAccess = map[string]bool{"go_xorlist_int64": true}
+
+// Impls returns every registered implementation in registration order.
+func Impls() []Impl { return append([]Impl(nil), impls...) }
+
+// Lookup returns the implementation registered under exactly name; the
+// match is case-sensitive.
+func Lookup(name string) (Impl, bool) {
+	i, ok := byName[name]
+	if !ok {
+		return Impl{}, false
+	}
+	return impls[i], true
+}
+
+// SelectImpls resolves a comma-separated list of impl names; "all" selects
+// every registered implementation. NAME{key=v,key=v} is the impl Configure
+// builds from NAME with those parameters.
+func SelectImpls(s string) ([]Impl, error) {
+	if strings.TrimSpace(s) == "all" {
+		return Impls(), nil
+	}
+	var out []Impl
+	for _, spec := range splitImpls(s) {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		impl, err := parseImplSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, impl)
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package inplacebench
+
+import (
+	"io"
+	"math"
+	"testing"
+)
+
+// TestModels checks what every registered impl claims in its ImplModel
+// against what can be observed: a Concurrency other than none exactly when
+// the impl declares CapConcurrent, and an O(1) Init that takes roughly as
+// long at 1<<20 elements as at 1<<10. Fields left empty are not checked.
+func TestModels(t *testing.T) {
+	for _, impl := range Impls() {
+		t.Run(impl.Name, func(t *testing.T) {
+			m := impl.Meta.Model
+			if m.Concurrency != "" && (m.Concurrency != ConcNone) != impl.Meta.Has(CapConcurrent) {
+				t.Fatalf("claims concurrency %q but declares concurrent=%v", m.Concurrency, impl.Meta.Has(CapConcurrent))
+			}
+			if m.Init != O1 {
+				return
+			}
+			// The fastest of five Inits at each size; an O(N) one grows
+			// 1024x, 64x leaves room for a cold map or allocator.
+			small, large := fastestInit(impl, 1<<10), fastestInit(impl, 1<<20)
+			if large > 64*small+10000 {
+				t.Fatalf("claims an O(1) Init but it took %d ns at N=%d and %d ns at N=%d", small, 1<<10, large, 1<<20)
+			}
+		})
+	}
+}
+
+// fastestInit is the least ns of five Inits of one impl.New(N).
+func fastestInit(impl Impl, N int) int64 {
+	arr := impl.New(N)
+	if c, ok := arr.(io.Closer); ok {
+		defer c.Close()
+	}
+	best := int64(math.MaxInt64)
+	for k := 0; k < 5; k++ {
+		best = min(best, arr.Init(int64(k)))
+	}
+	return best
+}
@@ -0,0 +1,48 @@
+//go:build amd64
+
+package inplacebench
+
+import (
+	"time"
+	"unsafe"
+)
+
+// RelaxedAtomicImpl is AtomicSliceImpl with the atomics taken out: every
+// access is a plain load or store through (*int64)(unsafe.Pointer(&A[i])).
+// On amd64 that is what a relaxed atomic compiles to, since an aligned
+// 8-byte MOV never tears and TSO keeps stores in program order, while
+// Go's atomic.StoreInt64 is an XCHG, a full barrier. It is a benchmarking
+// construct only: Go's memory model gives plain accesses no ordering at
+// all, the race detector rejects them, and on a weakly ordered machine
+// such as arm64 other cores could see its stores out of order, which is
+// why it is built for amd64 alone. It does not declare CapConcurrent.
+type RelaxedAtomicImpl struct {
+	N int
+	A []int64
+}
+
+func NewRelaxedAtomicImpl(n int) *RelaxedAtomicImpl {
+	return &RelaxedAtomicImpl{N: n, A: make([]int64, n)}
+}
+func (s *RelaxedAtomicImpl) Name() string { return "go_relaxed_int64" }
+func (s *RelaxedAtomicImpl) Len() int     { return s.N }
+func (s *RelaxedAtomicImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := range s.A {
+		s.Write(i, v)
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *RelaxedAtomicImpl) Read(i int) int64       { return *(*int64)(unsafe.Pointer(&s.A[i])) }
+func (s *RelaxedAtomicImpl) Write(i int, v int64)   { *(*int64)(unsafe.Pointer(&s.A[i])) = v }
+func (s *RelaxedAtomicImpl) MemoryFootprint() int64 { return int64(cap(s.A)) * 8 }
+
+func registerRelaxedImpls() {
+	Register("go_relaxed_int64", ImplMeta{
+		Description: "go_atomic_int64 with plain loads and stores: relaxed ordering, sound on TSO (amd64) only",
+		ElemBytes:   8,
+		Overhead:    1,
+		EstNsPerOp:  5,
+		Model:       ImplModel{Init: ON, Op: O1, ExtraSpace: "0", Concurrency: ConcNone},
+	}, func(n int) Array { return NewRelaxedAtomicImpl(n) })
+}
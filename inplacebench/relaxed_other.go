@@ -0,0 +1,8 @@
+//go:build !amd64
+
+package inplacebench
+
+// go_relaxed_int64's plain stores only stand in for relaxed atomics under
+// amd64's TSO; a weakly ordered machine could reorder them, so it is not
+// registered elsewhere.
+func registerRelaxedImpls() {}
@@ -0,0 +1,227 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// RerunSpec configures Runner.AutoRerun. A rep is an outlier when its
+// ns/op lies more than MADs median absolute deviations from its cell's
+// median; each outlier, worst first, is discarded and replaced by a new
+// rep, at most Max times per cell.
+type RerunSpec struct {
+	MADs float64 `json:"mads"`
+	Max  int     `json:"max"`
+}
+
+// The quality column of rows under Runner.AutoRerun: the cell had no
+// outlier, had its outliers replaced, or still had one after Max
+// replacements, as a bimodal cell does. It is empty without AutoRerun,
+// and for a cell the sweep was cancelled before judging.
+const (
+	QualityStable   = "stable"
+	QualityRerun    = "rerun"
+	QualityUnstable = "unstable"
+)
+
+// reruns holds each cell's results until its last planned rep, then
+// passes on the cells without outliers and keeps the others for finish.
+// It runs on the goroutine that emits results.
+type reruns struct {
+	spec    RerunSpec
+	emit    func(Result)
+	planned map[string]int
+	cells   map[int]Cell
+	first   map[string]Cell
+	order   []string
+	nextRep map[string]int
+	held    map[string]Results
+	pending []string
+	next    int
+}
+
+func newReruns(spec RerunSpec, cells []Cell, emit func(Result)) *reruns {
+	rr := &reruns{spec: spec, emit: emit, planned: map[string]int{}, cells: map[int]Cell{}, first: map[string]Cell{},
+		nextRep: map[string]int{}, held: map[string]Results{}, next: len(cells)}
+	for _, c := range cells {
+		k := stableKey(c)
+		if _, ok := rr.first[k]; !ok {
+			rr.first[k] = c
+			rr.order = append(rr.order, k)
+		}
+		rr.planned[k]++
+		rr.cells[c.Ordinal] = c
+		rr.nextRep[k] = max(rr.nextRep[k], c.Rep+1)
+	}
+	return rr
+}
+
+// add holds res, the result of the planned cell with its Ordinal, until
+// the cell is complete.
+func (rr *reruns) add(res Result) {
+	k := stableKey(rr.cells[res.Ordinal])
+	rr.held[k] = append(rr.held[k], res)
+	if len(rr.held[k]) < rr.planned[k] {
+		return
+	}
+	if worstOutlier(rr.held[k], rr.spec.MADs) < 0 {
+		rr.release(k, QualityStable)
+		return
+	}
+	rr.pending = append(rr.pending, k)
+}
+
+// release emits k's held results with quality.
+func (rr *reruns) release(k, quality string) {
+	for _, res := range rr.held[k] {
+		res.Quality = quality
+		rr.emit(res)
+	}
+	delete(rr.held, k)
+}
+
+// finish replaces the outliers of the pending cells, one rep at a time
+// through run, then emits every result still held, the incomplete cells'
+// unjudged. Replacement reps take the next rep numbers and ordinals after
+// the plan's.
+func (rr *reruns) finish(ctx context.Context, run func(Cell) (Result, bool)) {
+	for _, k := range rr.pending {
+		quality := ""
+		for reruns := 0; ctx.Err() == nil; reruns++ {
+			w := worstOutlier(rr.held[k], rr.spec.MADs)
+			if w < 0 {
+				quality = QualityRerun
+				break
+			}
+			if reruns == rr.spec.Max {
+				quality = QualityUnstable
+				break
+			}
+			rr.held[k][w].Discarded = true
+			c := rr.first[k]
+			c.Rep, c.Ordinal = rr.nextRep[k], rr.next
+			rr.nextRep[k]++
+			rr.next++
+			if res, ok := run(c); ok {
+				rr.held[k] = append(rr.held[k], res)
+			}
+		}
+		rr.release(k, quality)
+	}
+	for _, k := range rr.order {
+		if _, ok := rr.held[k]; ok {
+			rr.release(k, "")
+		}
+	}
+}
+
+// worstOutlier returns the index of the successful, undiscarded rep of rs
+// farthest from their median, if it is more than mads MADs from it, or -1.
+// Fewer than three such reps have no outliers.
+func worstOutlier(rs Results, mads float64) int {
+	var at []int
+	var ns []float64
+	for i, r := range rs {
+		if r.OK() && !r.Discarded {
+			at, ns = append(at, i), append(ns, r.NsPerOp)
+		}
+	}
+	if len(ns) < 3 {
+		return -1
+	}
+	m := Median(ns)
+	dev := make([]float64, len(ns))
+	for i, x := range ns {
+		dev[i] = math.Abs(x - m)
+	}
+	limit := mads * Median(dev)
+	worst := -1
+	for i, d := range dev {
+		if d > limit && (worst < 0 || d > dev[worst]) {
+			worst = i
+		}
+	}
+	if worst < 0 {
+		return -1
+	}
+	return at[worst]
+}
+
+// checkReruns checks worstOutlier on fixtures, replays a cell with one
+// slow rep and a bimodal cell through reruns with scripted timings, and
+// checks that a Runner under AutoRerun stamps its rows.
+func checkReruns() error {
+	rs := func(ns ...float64) Results {
+		var out Results
+		for _, x := range ns {
+			out = append(out, Result{NsPerOp: x, Status: "ok"})
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		rs   Results
+		want int
+	}{
+		{rs(10, 11, 10.5, 50), 3},
+		{rs(10, 11, 10.5, 10.2), -1},
+		{rs(10, 50), -1},
+		{append(rs(10, 11, 10.5), Result{NsPerOp: 50, Status: "failed: x"}), -1},
+	} {
+		if got := worstOutlier(tc.rs, 3); got != tc.want {
+			return fmt.Errorf("reruns: worstOutlier(%v) = %d, want %d", tc.rs, got, tc.want)
+		}
+	}
+	slice, _ := Lookup("go_slice_int64")
+	cells := PlanCells([]Impl{slice}, []int{10}, []string{"WRITE_RANDOM"}, []int64{1}, 5, false)
+	for _, tc := range []struct {
+		ns        []float64
+		quality   string
+		discarded []int
+	}{
+		{[]float64{10, 11, 50, 10.5, 10.2, 10.4}, QualityRerun, []int{3}},
+		{[]float64{10, 40, 11, 41, 10.5, 42, 10.2}, QualityUnstable, []int{4, 6}},
+	} {
+		var rows Results
+		rr := newReruns(RerunSpec{MADs: 3, Max: 2}, cells, func(res Result) { rows = append(rows, res) })
+		result := func(c Cell) Result {
+			return Result{Rep: c.Rep, Ordinal: c.Ordinal, NsPerOp: tc.ns[c.Rep-1], Status: "ok"}
+		}
+		for _, c := range cells {
+			rr.add(result(c))
+		}
+		if len(rows) != 0 {
+			return fmt.Errorf("reruns: a cell with an outlier was emitted before its reruns")
+		}
+		rr.finish(context.Background(), func(c Cell) (Result, bool) { return result(c), true })
+		var discarded []int
+		for _, row := range rows {
+			if row.Quality != tc.quality {
+				return fmt.Errorf("reruns: rep %d has quality %q, want %q", row.Rep, row.Quality, tc.quality)
+			}
+			if row.Discarded {
+				discarded = append(discarded, row.Rep)
+			}
+		}
+		if len(rows) != len(tc.ns) || fmt.Sprint(discarded) != fmt.Sprint(tc.discarded) || rows[len(rows)-1].Ordinal != len(tc.ns)-1 {
+			return fmt.Errorf("reruns: %s: %d rows, discarded reps %v, want %d and %v", tc.quality, len(rows), discarded, len(tc.ns), tc.discarded)
+		}
+		if c := rows.Cells()[0]; c.Reps != len(tc.ns)-len(tc.discarded) {
+			return fmt.Errorf("reruns: cell stats count %d reps, want the %d kept", c.Reps, len(tc.ns)-len(tc.discarded))
+		}
+	}
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 3, NoBatch: true,
+		AutoRerun: &RerunSpec{MADs: math.Inf(1), Max: 2}, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("reruns: %v", err)
+	}
+	if len(w.rows) != 3 || w.rows[0].Quality != QualityStable {
+		return fmt.Errorf("reruns: %d rows, quality %q", len(w.rows), w.rows[0].Quality)
+	}
+	r.RepeatUntilStable = &StableSpec{CV: 0.03, Min: 2, Max: 3}
+	if _, err := r.Run(context.Background()); err == nil {
+		return fmt.Errorf("reruns: AutoRerun ran with RepeatUntilStable")
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header is the column layout of Result.Record for a row without
+// Annotations.
+var Header = []string{
+	"timestamp_iso", "impl_name", "scenario", "N", "seed", "rep_id",
+	"ops_in_run", "total_time_ns", "ns_per_op", "init_time_ns_if_recorded",
+	"relocations_count", "conversions_count",
+	"run_ordinal", "run_id", "contended", "rep_cv", "status",
+	"scenario_params", "op_path", "bytes_resident", "elem_type",
+	"op_counts", "impl_params", "scenario_kind", "phase", "effective_seed",
+	"benchmark_id", "gc_count", "warmup_completed", "discarded", "quality",
+	"dispatch", "allocated_N", "checksum", "verify", "numa_placement",
+	"cpu_mhz_min", "cpu_mhz_avg", "throttled", "loops", "short_run",
+	"cache_misses", "llc_loads", "dtlb_misses", "branch_misses", "instructions",
+}
+
+// Annotations are free-form key=value pairs describing a sweep (the
+// machine, the kernel, a ticket), written into every row as meta_KEY
+// columns after Header's, in key order.
+type Annotations map[string]string
+
+// ParseAnnotations parses "key=value,key=value". Keys are letters, digits,
+// '_', '-' and '.'; values may be empty but cannot contain a comma.
+func ParseAnnotations(s string) (Annotations, error) {
+	out := Annotations{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("metadata: want key=value, got %q", part)
+		}
+		k = strings.TrimSpace(k)
+		if k == "" || strings.IndexFunc(k, func(c rune) bool {
+			return !(c == '_' || c == '-' || c == '.' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z')
+		}) >= 0 {
+			return nil, fmt.Errorf("metadata: bad key %q", k)
+		}
+		if _, dup := out[k]; dup {
+			return nil, fmt.Errorf("metadata: %s given twice", k)
+		}
+		out[k] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+// Keys returns a's keys in column order.
+func (a Annotations) Keys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Columns returns the columns of a row annotated with keys: Header, then
+// meta_KEY for each key. Readers that look columns up by name, as compare
+// does, read such a file like any other.
+func Columns(keys []string) []string {
+	cols := append([]string(nil), Header...)
+	for _, k := range keys {
+		cols = append(cols, "meta_"+k)
+	}
+	return cols
+}
+
+// Result is one measured run. Status is "ok", "failed: <reason>" for an
+// isolated run whose child process failed or a run a hook vetoed, "error:
+// panic: <value>" for an in-process run that panicked, or "skipped" for a
+// run a hook skipped (see Hooks); the measurements are then zero.
+type Result struct {
+	Timestamp time.Time
+	Impl      string
+	Scenario  string
+	N         int
+	// Seed is the cell's base seed; EffectiveSeed is the one the run drew
+	// from (see Cell.EffectiveSeed), which replays the row on its own.
+	Seed          int64
+	Rep           int
+	EffectiveSeed int64
+	Ops           int
+	TotalNs       int64
+	NsPerOp       float64
+	InitNs        int64
+	Relocations   int64
+	Conversions   int64
+	Ordinal       int
+	RunID         string
+	Contended     bool
+	// RepCV is the robust CV of ns/op over the cell's reps so far, set only
+	// under Runner.RepeatUntilStable.
+	RepCV  *float64
+	Status string
+	// Params are the scenario's resolved parameters.
+	Params map[string]float64
+	// Path is RunResult.Path.
+	Path string
+	// BytesResident is what the array held after the run; see
+	// FootprintReporter.
+	BytesResident int64
+	// ElemType is the element type the run used, int64 for the Array path.
+	ElemType string
+	// OpCounts is the OpCounter's counts, for an instrumented impl.
+	OpCounts string
+	// ImplParams are the family parameters the impl was built with.
+	ImplParams string
+	// ScenarioKind is the Kind of the scenario's ScenarioSpec; with Params
+	// it identifies the workload without parsing the scenario name.
+	ScenarioKind string
+	// Phase is the phase a row of a multi-phase composite scenario times,
+	// empty for the row of the whole run.
+	Phase string
+	// Phases carries a composite run's phase timing until the Runner
+	// expands it into the phase rows.
+	Phases []PhaseResult
+	// Curve is RunResult.Curve, written by a CurveWriter rather than as a
+	// column.
+	Curve []CurvePoint
+	// BenchmarkID is Runner.BenchmarkID, the sweep the row belongs to.
+	BenchmarkID string
+	// GCs is the number of garbage collections that ended while the
+	// scenario ran (see GCControl), so a timing a collection landed in can be
+	// told apart.
+	GCs int64
+	// WarmupCompleted is whether a Runner.Warmup run of the row's impl,
+	// scenario and N completed before it; false without Warmup.
+	WarmupCompleted bool
+	// Discarded marks a rep Runner.AutoRerun replaced as an outlier; it
+	// stays in the output but not in the statistics. Quality is the cell's
+	// verdict, one of the Quality constants, empty without AutoRerun.
+	Discarded bool
+	Quality   string
+	// Dispatch is how the scenario reached the array: the cell's Dispatch,
+	// or DispatchPerOp.
+	Dispatch string
+	// AllocatedN is how many elements the array allocated for its N; see
+	// CapacityReporter. It is 0 in a failed row.
+	AllocatedN int
+	// Checksum hashes the array's contents after the run, and Verify is
+	// VerifyOK or VerifyFail against StrictBaseline's; both are empty
+	// unless Runner.StrictVerify compared the cell.
+	Checksum string
+	Verify   string
+	// NUMAPlacement is NUMAMbind or NUMAMempolicy, how Runner.NUMA placed
+	// the array, and empty without it.
+	NUMAPlacement string
+	// CPUMHzMin and CPUMHzAvg are the minimum and mean of the CPU clock
+	// sampled at the start and end of the run, 0 where it is unreadable.
+	// Throttled is whether CPUMHzMin fell below the clock the sweep
+	// started at by more than Runner.ThrottleThreshold, nil when either is
+	// unknown.
+	CPUMHzMin float64
+	CPUMHzAvg float64
+	Throttled *bool
+	// Loops is how many runs of the scenario the timing columns are the
+	// mean of, more than 1 when Runner.MinRunNs repeated a short one;
+	// ShortRun marks those rows, whose first run took less than MinRunNs.
+	Loops    int
+	ShortRun bool
+	// Perf is what the hardware counters counted around the run, by
+	// PerfColumns name, under Runner.Perf; a counter the machine lacks is
+	// absent, and without perf there is none.
+	Perf map[string]int64
+	// Annotations are Runner.Annotations.
+	Annotations Annotations
+}
+
+// OK reports whether the run completed.
+func (r Result) OK() bool { return r.Status == "ok" }
+
+// Record formats r in the order of Columns(r.Annotations.Keys()). The
+// measurement columns of a failed run are left empty.
+func (r Result) Record() []string {
+	cv := ""
+	if r.RepCV != nil {
+		cv = fmt.Sprintf("%.4f", *r.RepCV)
+	}
+	rec := []string{
+		r.Timestamp.UTC().Format(time.RFC3339), r.Impl, r.Scenario,
+		strconv.Itoa(r.N), strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Rep),
+		strconv.Itoa(r.Ops), strconv.FormatInt(r.TotalNs, 10), fmt.Sprintf("%.4f", r.NsPerOp),
+		strconv.FormatInt(r.InitNs, 10), strconv.FormatInt(r.Relocations, 10), strconv.FormatInt(r.Conversions, 10),
+		strconv.Itoa(r.Ordinal), r.RunID, strconv.FormatBool(r.Contended), cv, r.Status,
+		FormatParams(r.Params), r.Path, strconv.FormatInt(r.BytesResident, 10),
+		r.ElemType, r.OpCounts, r.ImplParams, r.ScenarioKind, r.Phase,
+		strconv.FormatInt(r.EffectiveSeed, 10), r.BenchmarkID,
+		strconv.FormatInt(r.GCs, 10), strconv.FormatBool(r.WarmupCompleted),
+		strconv.FormatBool(r.Discarded), r.Quality, r.Dispatch, strconv.Itoa(r.AllocatedN), r.Checksum, r.Verify, r.NUMAPlacement,
+		formatMHz(r.CPUMHzMin), formatMHz(r.CPUMHzAvg), fmtBoolPtr(r.Throttled),
+		strconv.Itoa(r.Loops), strconv.FormatBool(r.ShortRun),
+	}
+	for _, col := range PerfColumns {
+		v := ""
+		if n, ok := r.Perf[col]; ok {
+			v = strconv.FormatInt(n, 10)
+		}
+		rec = append(rec, v)
+	}
+	if !r.OK() {
+		for i := 6; i <= 11; i++ {
+			rec[i] = ""
+		}
+		rec[19] = ""
+		rec[27] = ""
+	}
+	for _, k := range r.Annotations.Keys() {
+		rec = append(rec, r.Annotations[k])
+	}
+	return rec
+}
+
+// Median returns the median of v without modifying it, or NaN when v is
+// empty.
+func Median(v []float64) float64 {
+	c := append([]float64(nil), v...)
+	sort.Float64s(c)
+	n := len(c)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return c[n/2]
+	}
+	return (c[n/2-1] + c[n/2]) / 2
+}
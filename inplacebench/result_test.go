@@ -0,0 +1,78 @@
+package inplacebench
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	for _, bad := range []string{"machine", "=x", "a b=1", "k=1,k=2"} {
+		if _, err := ParseAnnotations(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+	ann, err := ParseAnnotations(" machine=c5.4xlarge, kernel=5.15,note=")
+	if err != nil || len(ann) != 3 || ann["kernel"] != "5.15" || ann["note"] != "" {
+		t.Fatalf("parsed %v, %v", ann, err)
+	}
+}
+
+// TestAnnotations runs a sweep annotated with -metadata into a CSV and an
+// NDJSON output, and checks the meta_ columns, that compare still reads the
+// CSV by name, and that appending with other annotations is refused.
+func TestAnnotations(t *testing.T) {
+	ann, err := ParseAnnotations("machine=c5.4xlarge,kernel=5.15,note=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path, ndjson := filepath.Join(dir, "results.csv"), filepath.Join(dir, "results.ndjson")
+	var outs []ResultWriter
+	for _, p := range []string{path, ndjson} {
+		w, err := OpenResultWriter(p, ann.Keys()...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs = append(outs, w)
+	}
+	slice, _ := Lookup("go_slice_int64")
+	r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 1, Annotations: ann, NoBatch: true, Writers: outs}
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := MultiWriter(outs...).Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(Header)
+	if len(rows) != 2 || strings.Join(rows[0][n:], ",") != "meta_kernel,meta_machine,meta_note" || strings.Join(rows[1][n:], ",") != "5.15,c5.4xlarge," {
+		t.Fatalf("read back %q", rows)
+	}
+	if med, err := readMedians(path, ""); err != nil || len(med) != 1 {
+		t.Fatalf("compare read %v, %v", med, err)
+	}
+	b, err = os.ReadFile(ndjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]string
+	if err := json.Unmarshal(b, &obj); err != nil || obj["meta_machine"] != "c5.4xlarge" || obj["gc_count"] == "" {
+		t.Fatalf("NDJSON row %s: %v", b, err)
+	}
+	if _, err := AppendResultWriter(path, "machine"); err == nil || !strings.Contains(err.Error(), "extra meta_kernel, meta_note") {
+		t.Fatalf("appending with other keys: %v", err)
+	}
+}
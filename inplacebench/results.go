@@ -0,0 +1,257 @@
+package inplacebench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+// Results is what Runner.Run returns: every result it produced, in
+// completion order, with helpers to query them from Go code instead of
+// re-reading an output file. Filters return a new Results; the statistics
+// are the ones the summary output and compare print.
+type Results []Result
+
+// Where returns the results keep accepts.
+func (rs Results) Where(keep func(Result) bool) Results {
+	var out Results
+	for _, r := range rs {
+		if keep(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Impl, Scenario and N return the results for one impl name, scenario or
+// size; they chain, as in rs.Impl("go_slice_int64").N(1000000).
+func (rs Results) Impl(name string) Results {
+	return rs.Where(func(r Result) bool { return r.Impl == name })
+}
+func (rs Results) Scenario(name string) Results {
+	return rs.Where(func(r Result) bool { return r.Scenario == name })
+}
+func (rs Results) N(n int) Results {
+	return rs.Where(func(r Result) bool { return r.N == n })
+}
+
+// Phase returns the rows of one phase of composite scenarios; Phase("")
+// is the rows of whole runs, one per run.
+func (rs Results) Phase(name string) Results {
+	return rs.Where(func(r Result) bool { return r.Phase == name })
+}
+
+// OK returns the results of the runs that succeeded.
+func (rs Results) OK() Results { return rs.Where(Result.OK) }
+
+// Kept returns the results Runner.AutoRerun did not discard.
+func (rs Results) Kept() Results {
+	return rs.Where(func(r Result) bool { return !r.Discarded })
+}
+
+// CellStats aggregates the reps of one (impl, scenario, N, seed) cell, or
+// of one phase of it for a multi-phase composite scenario. Mean,
+// Median and Stddev are over the ns/op of the reps that succeeded, Stddev
+// the sample standard deviation (0 for one rep); all three are NaN when
+// none did. Each rep draws its own data unless Runner.IdenticalReps, so
+// the spread includes the data pattern's variance as well as the
+// machine's.
+type CellStats struct {
+	Impl     string
+	Scenario string
+	Phase    string
+	N        int
+	Seed     int64
+	Reps     int
+	Failed   int
+	Mean     float64
+	Median   float64
+	Stddev   float64
+	// Dispatch is the cell's dispatch column; see WithBatch.
+	Dispatch string
+}
+
+// Cells aggregates rs per cell, in first-seen order, leaving out the reps
+// Runner.AutoRerun discarded.
+func (rs Results) Cells() []CellStats {
+	var out []CellStats
+	for _, g := range groupByCell(rs.Kept()) {
+		var ns []float64
+		for _, r := range g {
+			if r.OK() {
+				ns = append(ns, r.NsPerOp)
+			}
+		}
+		r := g[0]
+		mean, stddev := meanStddev(ns)
+		out = append(out, CellStats{
+			Impl: r.Impl, Scenario: r.Scenario, Phase: r.Phase, Dispatch: r.Dispatch, N: r.N, Seed: r.Seed,
+			Reps: len(g), Failed: len(g) - len(ns),
+			Mean: mean, Median: Median(ns), Stddev: stddev,
+		})
+	}
+	return out
+}
+
+// meanStddev returns the mean and sample standard deviation of v, NaN for
+// both when v is empty.
+func meanStddev(v []float64) (mean, stddev float64) {
+	if len(v) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	for _, x := range v {
+		mean += x
+	}
+	mean /= float64(len(v))
+	if len(v) == 1 {
+		return mean, 0
+	}
+	var ss float64
+	for _, x := range v {
+		ss += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(v)-1))
+}
+
+// Ratio is one cell's median ns/op over the median of the same scenario, N
+// and seed for a baseline impl; above 1 is slower than the baseline.
+type Ratio struct {
+	Cell, Baseline CellStats
+	Ratio          float64
+}
+
+// Ratios compares every cell of an impl other than baseline with
+// baseline's matching cell; cells baseline has no match for are left out.
+func (rs Results) Ratios(baseline string) []Ratio {
+	cells := rs.Cells()
+	base := map[string]CellStats{}
+	for _, c := range cells {
+		if c.Impl == baseline {
+			base[ratioKey(c)] = c
+		}
+	}
+	var out []Ratio
+	for _, c := range cells {
+		b, ok := base[ratioKey(c)]
+		if c.Impl == baseline || !ok {
+			continue
+		}
+		out = append(out, Ratio{c, b, c.Median / b.Median})
+	}
+	return out
+}
+
+// SubtractBaseline returns rs without baseline's rows and with baseline's
+// median ns/op for the same scenario, phase, N and seed taken off every
+// other successful row, TotalNs following; rows baseline has no successful
+// match for are left out. With go_noop as the baseline, run over the same
+// scenarios, what remains is the array's share of each op. A result faster
+// than the baseline's median comes out negative.
+func (rs Results) SubtractBaseline(baseline string) Results {
+	base := map[string]float64{}
+	for _, c := range rs.Impl(baseline).Cells() {
+		if !math.IsNaN(c.Median) {
+			base[ratioKey(c)] = c.Median
+		}
+	}
+	var out Results
+	for _, r := range rs.OK().Kept() {
+		b, ok := base[ratioKey(CellStats{Scenario: r.Scenario, Phase: r.Phase, Dispatch: r.Dispatch, N: r.N, Seed: r.Seed})]
+		if r.Impl == baseline || !ok {
+			continue
+		}
+		r.NsPerOp -= b
+		r.TotalNs = int64(math.Round(r.NsPerOp * float64(r.Ops)))
+		out = append(out, r)
+	}
+	return out
+}
+
+func ratioKey(c CellStats) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%d\t%d", c.Scenario, c.Phase, c.Dispatch, c.N, c.Seed)
+}
+
+// label is the cell's scenario as the summary and compare print it, with
+// the phase after a slash and "@batch" or "@static" after a dispatch=batch
+// or dispatch=static cell's.
+func (c CellStats) label() string {
+	l := c.Scenario
+	if c.Phase != "" {
+		l += "/" + c.Phase
+	}
+	if c.Dispatch == DispatchBatch || c.Dispatch == DispatchStatic {
+		l += "@" + c.Dispatch
+	}
+	return l
+}
+
+// RatioTo is impl's median ns/op over baseline's for one scenario and N,
+// pooling seeds and over whole runs of a composite; ok is false unless both have a successful run there.
+//
+//	if r, ok := res.RatioTo("my_array", "go_slice_int64", "MIXED_R50W50", 1000000); !ok || r > 2 {
+//		t.Errorf("my_array is %.2fx go_slice_int64", r)
+//	}
+func (rs Results) RatioTo(impl, baseline, scenario string, N int) (ratio float64, ok bool) {
+	at := rs.Scenario(scenario).N(N).Phase("").OK().Kept().Where(func(r Result) bool { return r.Dispatch != DispatchBatch && r.Dispatch != DispatchStatic })
+	a, b := medianNs(at.Impl(impl)), medianNs(at.Impl(baseline))
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN(), false
+	}
+	return a / b, true
+}
+
+func medianNs(rs Results) float64 {
+	ns := make([]float64, len(rs))
+	for i, r := range rs {
+		ns[i] = r.NsPerOp
+	}
+	return Median(ns)
+}
+
+// WriteSummary writes the table SummaryWriter writes (.txt outputs).
+func (rs Results) WriteSummary(w io.Writer) error { return rs.WriteNetSummary(w, "") }
+
+// WriteNetSummary is WriteSummary with two more columns: baseline's median
+// ns/op for the cell's scenario, phase, N and seed, and the cell's median
+// less it, as SubtractBaseline would leave it. Both are "-" for a cell
+// baseline has no successful run for. An empty baseline adds no columns.
+func (rs Results) WriteNetSummary(w io.Writer, baseline string) error {
+	base := map[string]float64{}
+	for _, c := range rs.Impl(baseline).Cells() {
+		if !math.IsNaN(c.Median) {
+			base[ratioKey(c)] = c.Median
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "impl\tscenario\tN\tseed\treps\tfailed\tmedian_ns_per_op")
+	if baseline != "" {
+		fmt.Fprint(tw, "\toverhead_ns_per_op\tnet_ns_per_op")
+	}
+	fmt.Fprintln(tw)
+	for _, c := range rs.Cells() {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%.4f", c.Impl, c.label(), c.N, c.Seed, c.Reps, c.Failed, c.Median)
+		if b, ok := base[ratioKey(c)]; ok {
+			fmt.Fprintf(tw, "\t%.4f\t%.4f", b, c.Median-b)
+		} else if baseline != "" {
+			fmt.Fprint(tw, "\t-\t-")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// Export writes every result to w and closes it, as if w had been one of
+// the Runner's Writers.
+func (rs Results) Export(w ResultWriter) error {
+	var err error
+	for _, r := range rs {
+		if err = w.Write(r); err != nil {
+			break
+		}
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
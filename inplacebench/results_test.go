@@ -0,0 +1,75 @@
+package inplacebench
+
+import (
+	"math"
+	"testing"
+)
+
+// resultsFixture has three ok reps and a failed one of a/10, two ok reps
+// of b/10, one of a/20 and a failed b/20 under another seed.
+func resultsFixture() Results {
+	row := func(impl string, N int, seed int64, ns float64, status string) Result {
+		return Result{Impl: impl, Scenario: "WRITE_RANDOM", N: N, Seed: seed, NsPerOp: ns, Status: status}
+	}
+	return Results{
+		row("a", 10, 1, 2, "ok"), row("a", 10, 1, 4, "ok"), row("a", 10, 1, 9, "ok"),
+		row("a", 10, 1, 100, "failed: boom"),
+		row("b", 10, 1, 1, "ok"), row("b", 10, 1, 3, "ok"),
+		row("a", 20, 1, 5, "ok"),
+		row("b", 20, 2, 7, "failed: boom"),
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-12 || math.IsNaN(a) && math.IsNaN(b) }
+
+func TestResultsFilters(t *testing.T) {
+	rs := resultsFixture()
+	if got := len(rs.Impl("a").N(10)); got != 4 {
+		t.Fatalf("Impl(a).N(10) has %d rows, want 4", got)
+	}
+	if got := len(rs.Scenario("WRITE_RANDOM").OK()); got != 6 {
+		t.Fatalf("OK has %d rows, want 6", got)
+	}
+}
+
+func TestResultsCells(t *testing.T) {
+	// a/10: ok ns 2, 4, 9: mean 5, median 4, sample variance (9+1+16)/2 = 13.
+	// b/10: mean 2, median 2, variance (1+1)/1 = 2. a/20: one rep, stddev 0.
+	nan := math.NaN()
+	want := []CellStats{
+		{"a", "WRITE_RANDOM", "", 10, 1, 4, 1, 5, 4, math.Sqrt(13), ""},
+		{"b", "WRITE_RANDOM", "", 10, 1, 2, 0, 2, 2, math.Sqrt(2), ""},
+		{"a", "WRITE_RANDOM", "", 20, 1, 1, 0, 5, 5, 0, ""},
+		{"b", "WRITE_RANDOM", "", 20, 2, 1, 1, nan, nan, nan, ""},
+	}
+	cells := resultsFixture().Cells()
+	if len(cells) != len(want) {
+		t.Fatalf("%d cells, want %d", len(cells), len(want))
+	}
+	for i, c := range cells {
+		w := want[i]
+		if c.Impl != w.Impl || c.N != w.N || c.Seed != w.Seed || c.Reps != w.Reps || c.Failed != w.Failed ||
+			!near(c.Mean, w.Mean) || !near(c.Median, w.Median) || !near(c.Stddev, w.Stddev) {
+			t.Errorf("cell %d is %+v, want %+v", i, c, w)
+		}
+	}
+}
+
+func TestResultsRatios(t *testing.T) {
+	rs := resultsFixture()
+	ratios := rs.Ratios("b")
+	if len(ratios) != 1 || ratios[0].Cell.N != 10 || !near(ratios[0].Ratio, 2) {
+		t.Fatalf("Ratios(b) = %+v, want one a/10 at 2", ratios)
+	}
+	if r, ok := rs.RatioTo("a", "b", "WRITE_RANDOM", 10); !ok || !near(r, 2) {
+		t.Fatalf("RatioTo(a, b, N=10) = %v, %v, want 2", r, ok)
+	}
+	if _, ok := rs.RatioTo("a", "b", "WRITE_RANDOM", 20); ok {
+		t.Fatalf("RatioTo(a, b, N=20) ok without a successful b run")
+	}
+	// b/10's median is 2: a/10's ok rows lose 2 ns/op, a/20 has no match.
+	sub := rs.SubtractBaseline("b")
+	if len(sub) != 3 || sub[0].Impl != "a" || !near(sub[0].NsPerOp, 0) || !near(sub[2].NsPerOp, 7) {
+		t.Fatalf("SubtractBaseline(b) = %+v, want a/10 at 0, 2 and 7", sub)
+	}
+}
@@ -0,0 +1,70 @@
+package inplacebench
+
+import "time"
+
+// RingBufferImpl is a fixed ring of N slots with head (oldest pushed entry)
+// and tail (next slot to fill) pointers, as in a message queue. Read and
+// Write address slots directly; Push appends at tail and, once N entries are
+// live, overwrites the oldest and advances head. Init empties the ring.
+type RingBufferImpl struct {
+	N                int
+	A                []int64
+	head, tail, size int
+	overwrites       int64
+}
+
+func NewRingBufferImpl(n int) *RingBufferImpl { return &RingBufferImpl{N: n, A: make([]int64, n)} }
+func (s *RingBufferImpl) Name() string        { return "go_ring_int64" }
+func (s *RingBufferImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+	s.head, s.tail, s.size, s.overwrites = 0, 0, 0, 0
+	return time.Since(start).Nanoseconds()
+}
+func (s *RingBufferImpl) Len() int { return s.N }
+
+// Fill rewrites every slot but keeps head, tail and the overwrite count.
+func (s *RingBufferImpl) Fill(v int64) {
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+}
+
+// Reset empties the ring like Init(0).
+func (s *RingBufferImpl) Reset() {
+	clear(s.A)
+	s.head, s.tail, s.size, s.overwrites = 0, 0, 0, 0
+}
+func (s *RingBufferImpl) Read(i int) int64     { return s.A[i] }
+func (s *RingBufferImpl) Write(i int, v int64) { s.A[i] = v }
+func (s *RingBufferImpl) ReadRange(start int, out []int64) {
+	copy(out, s.A[start:start+len(out)])
+}
+func (s *RingBufferImpl) WriteRange(start int, vals []int64) {
+	copy(s.A[start:start+len(vals)], vals)
+}
+
+// Push appends v, overwriting the oldest entry when the ring is full.
+func (s *RingBufferImpl) Push(v int64) {
+	if s.N == 0 {
+		return
+	}
+	s.A[s.tail] = v
+	s.tail++
+	if s.tail == s.N {
+		s.tail = 0
+	}
+	if s.size == s.N {
+		s.head = s.tail
+		s.overwrites++
+	} else {
+		s.size++
+	}
+}
+
+// Stats reports the overwrites since Init as conversions.
+func (s *RingBufferImpl) Stats() (relocations, conversions int64) { return 0, s.overwrites }
+
+func (s *RingBufferImpl) MemoryFootprint() int64 { return int64(cap(s.A)) * 8 }
@@ -0,0 +1,938 @@
+package inplacebench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cell is one (impl, scenario, N, seed, rep) run of the matrix. Ordinal is
+// its position in the sequential plan, which stays meaningful when parallel
+// runs finish out of order. Elem, when set, runs the cell on the typed path
+// with Impl's port to that element type (see ForElemType). IdenticalReps
+// makes every rep draw its data from Seed itself; see EffectiveSeed. GC is
+// what the run does about the garbage collector. Dispatch, when set, runs a
+// composite scenario through the array's BatchRunner or its static loop,
+// the dispatch=batch or dispatch=static twin of the per-op cell (see
+// WithBatch and WithStatic). BoundsCheckFree runs a per-op composite on
+// the array's unchecked view, if it has one; see Runner.BoundsCheckFree.
+// Verify hashes the array's contents after the
+// run, untimed, into the checksum column; see Runner.StrictVerify. Perf
+// reads the hardware counters around the run; see Runner.Perf. NUMA is
+// Runner.NUMA, MinRunNs Runner.MinRunNs.
+type Cell struct {
+	Ordinal         int
+	Impl            Impl
+	Scenario        string
+	N               int
+	Seed            int64
+	Rep             int
+	Elem            string
+	IdenticalReps   bool
+	GC              GCControl
+	Dispatch        string
+	BoundsCheckFree bool
+	Verify          bool
+	Perf            bool
+	NUMA            NUMAPolicy
+	MinRunNs        int64
+}
+
+// EffectiveSeed is the seed the cell's scenario draws its indices and
+// values from: RepSeed(Seed, Rep), so each rep measures a different data
+// pattern, or Seed for every rep under IdenticalReps.
+func (c Cell) EffectiveSeed() int64 {
+	if c.IdenticalReps {
+		return c.Seed
+	}
+	return RepSeed(c.Seed, c.Rep)
+}
+
+// RepSeed derives rep's seed from the base seed. Rep 1 keeps seed, so a
+// single-rep sweep draws what it always did; later reps get a splitmix64
+// of the pair.
+func RepSeed(seed int64, rep int) int64 {
+	if rep <= 1 {
+		return seed
+	}
+	z := uint64(seed) + uint64(rep)*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return int64(z ^ z>>31)
+}
+
+// RunID identifies the cell as "impl/scenario/N/seed/rep", with "/batch"
+// or "/static" after it for a cell with that Dispatch.
+func (c Cell) RunID() string {
+	return fmt.Sprintf("%s/%s/%d/%d/%d", c.implName(), c.Scenario, c.N, c.Seed, c.Rep) + c.dispatchSuffix()
+}
+
+// implName is the impl_name the cell's row gets: its port's on the typed
+// path.
+func (c Cell) implName() string {
+	if c.Elem != "" {
+		return ElemImplName(c.Impl.Name, c.Elem)
+	}
+	return c.Impl.Name
+}
+
+// elemType is the cell's elem_type column.
+func (c Cell) elemType() string {
+	if c.Elem != "" {
+		return c.Elem
+	}
+	return "int64"
+}
+
+// PlanCells lays out the matrix in execution order. By default all reps of a
+// cell run back to back; interleaved puts reps outermost so a cell's reps are
+// spread over the whole sweep instead of sharing warm caches and thermal state.
+// A scenario is only planned for impls it suits (see Scenario's flags) and
+// not at all while it is Unavailable.
+func PlanCells(impls []Impl, Nlist []int, scenarios []string, seeds []int64, reps int, interleaved bool) []Cell {
+	var cells []Cell
+	requires, unavailable, composite := map[string]Capability{}, map[string]bool{}, map[string]bool{}
+	for _, name := range scenarios {
+		sc, _ := LookupScenario(name)
+		requires[name] = sc.Requires
+		composite[name] = sc.Phases != nil
+		unavailable[name] = sc.Unavailable != nil && sc.Unavailable() != ""
+	}
+	add := func(impl Impl, N int, scenario string, seed int64, rep int) {
+		cells = append(cells, Cell{Ordinal: len(cells), Impl: impl, Scenario: scenario, N: N, Seed: seed, Rep: rep})
+	}
+	each := func(fn func(impl Impl, N int, scenario string, seed int64)) {
+		for _, impl := range impls {
+			for _, N := range Nlist {
+				for _, scenario := range scenarios {
+					if ExclusiveScenarios[scenario] && !impl.Meta.Has(CapConcurrent) {
+						continue
+					}
+					if impl.Meta.Has(CapReadOnly) && !ReadOnlyScenarios[scenario] {
+						continue
+					}
+					if impl.Meta.Has(CapNoop) && !composite[scenario] && requires[scenario]&CapNoop == 0 {
+						continue
+					}
+					if unavailable[scenario] || !impl.Meta.Has(requires[scenario]) {
+						continue
+					}
+					for _, seed := range seeds {
+						fn(impl, N, scenario, seed)
+					}
+				}
+			}
+		}
+	}
+	if interleaved {
+		for rep := 1; rep <= reps; rep++ {
+			each(func(impl Impl, N int, scenario string, seed int64) { add(impl, N, scenario, seed, rep) })
+		}
+	} else {
+		each(func(impl Impl, N int, scenario string, seed int64) {
+			for rep := 1; rep <= reps; rep++ {
+				add(impl, N, scenario, seed, rep)
+			}
+		})
+	}
+	return cells
+}
+
+// StableSpec configures adaptive repetition: reps of a cell continue until at
+// least Min have run and the robust CV of ns/op is at most CV, or Max is
+// reached.
+type StableSpec struct {
+	CV       float64
+	Min, Max int
+}
+
+// ParseStableSpec parses "cv=3%,max=15,min=2"; omitted keys keep those
+// defaults.
+func ParseStableSpec(s string) (StableSpec, error) {
+	spec := StableSpec{CV: 0.03, Min: 2, Max: 15}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return spec, fmt.Errorf("repeat-until-stable: want key=value, got %q", kv)
+		}
+		var err error
+		switch k {
+		case "cv":
+			pct := strings.HasSuffix(v, "%")
+			spec.CV, err = strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			if pct {
+				spec.CV /= 100
+			}
+		case "min":
+			spec.Min, err = strconv.Atoi(v)
+		case "max":
+			spec.Max, err = strconv.Atoi(v)
+		default:
+			return spec, fmt.Errorf("repeat-until-stable: unknown key %q", k)
+		}
+		if err != nil {
+			return spec, fmt.Errorf("repeat-until-stable: %s: %v", k, err)
+		}
+	}
+	if spec.CV <= 0 || spec.Min < 1 || spec.Max < spec.Min {
+		return spec, fmt.Errorf("repeat-until-stable: need cv > 0 and 1 <= min <= max")
+	}
+	return spec, nil
+}
+
+// robustCV is 1.4826*MAD/median, the median-based analogue of stddev/mean:
+// one outlying rep moves neither the median nor the MAD much.
+func robustCV(v []float64) float64 {
+	m := Median(v)
+	if m == 0 {
+		return 0
+	}
+	dev := make([]float64, len(v))
+	for i, x := range v {
+		dev[i] = math.Abs(x - m)
+	}
+	return 1.4826 * Median(dev) / m
+}
+
+// stabilizer tracks the reps of each cell under adaptive repetition. Cells
+// are planned at Max reps; once a cell is stable its remaining reps are
+// skipped.
+type stabilizer struct {
+	spec   StableSpec
+	mu     sync.Mutex
+	reps   map[string][]float64
+	stable map[string]bool
+}
+
+func newStabilizer(spec StableSpec) *stabilizer {
+	return &stabilizer{spec: spec, reps: map[string][]float64{}, stable: map[string]bool{}}
+}
+
+func stableKey(c Cell) string {
+	return fmt.Sprintf("%s/%s/%d/%d", c.Impl.Name, c.Scenario, c.N, c.Seed) + c.dispatchSuffix()
+}
+
+func (s *stabilizer) done(c Cell) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stable[stableKey(c)]
+}
+
+// record adds one rep's ns/op and returns the CV over the reps so far.
+func (s *stabilizer) record(c Cell, nsPerOp float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := stableKey(c)
+	s.reps[k] = append(s.reps[k], nsPerOp)
+	cv := robustCV(s.reps[k])
+	if len(s.reps[k]) >= s.spec.Min && cv <= s.spec.CV {
+		s.stable[k] = true
+	}
+	return cv
+}
+
+// Runner executes a benchmark matrix.
+type Runner struct {
+	Impls      []Impl
+	Scenarios  []string
+	Ns         []int
+	Seeds      []int64
+	Reps       int
+	Interleave bool
+	Params     ScenarioParams
+	// Parallel runs up to that many cells at once; values below 2 run them
+	// one by one. Concurrent cells share caches and memory bandwidth, and
+	// their results carry Contended. Exclusive scenarios always run alone.
+	Parallel int
+	// Isolate runs every cell in a child process: the running binary is
+	// re-executed with the "cell" command, which must dispatch to ServeCell.
+	Isolate bool
+	// ElemType, when set to one of ElemTypes, runs the typed path instead
+	// of the int64 Array path: only the cells ForElemType keeps.
+	ElemType string
+	// RepeatUntilStable, when set, replaces Reps with adaptive repetition.
+	RepeatUntilStable *StableSpec
+	// IdenticalReps runs every rep of a cell on the same data, for studies
+	// of the noise floor; by default each rep draws from its own seed (see
+	// Cell.EffectiveSeed).
+	IdenticalReps bool
+	// Warmup runs each (impl, scenario, N) once, unrecorded and without
+	// hooks, before the measured runs start, so first-run costs (page
+	// faults on the first Init, cold dispatch and allocator caches) land
+	// outside them; each row's WarmupCompleted says whether its warmup ran
+	// without panicking. Under Isolate every run is a fresh process, so
+	// there is nothing to warm and Warmup is ignored.
+	Warmup bool
+	// AutoRerun, when set, holds each cell's rows until its last rep, then
+	// discards and replaces its outlying reps (see RerunSpec) after the
+	// sweep, one replacement at a time on an otherwise idle machine. The
+	// discarded rows are still written, flagged, and every row of the cell
+	// gets its Quality. It cannot be combined with RepeatUntilStable.
+	AutoRerun *RerunSpec
+	// NoBatch plans only the per-op cells; by default the composites also
+	// run with dispatch=batch on CapBatch impls (see WithBatch). The typed
+	// path has no batch dispatch.
+	NoBatch bool
+	// StrictVerify hashes each run's array contents afterwards, untimed,
+	// into the checksum column and compares them with what StrictBaseline
+	// leaves after the same scenario, N and seed. The first mismatch ends
+	// the sweep with a *VerifyError, after its rows (verify=fail) are
+	// written; with KeepGoing the sweep goes on. See verifiable for the
+	// cells it skips.
+	StrictVerify bool
+	KeepGoing    bool
+	// Static also runs the composites with dispatch=static on CapStatic
+	// impls (see WithStatic), against the array's concrete type. Like
+	// NoBatch it does not apply to the typed path.
+	Static bool
+	// BoundsCheckFree runs the per-op composites on go_slice_int64 through
+	// an unsafe view of its elements with no bounds checks, instead of the
+	// slice itself; their op_path gets "_unchecked" (or is "unchecked").
+	// Against a sweep without it, that is what the checks cost. The batch
+	// and static twins and every other scenario run as before.
+	BoundsCheckFree bool
+	// Perf reads the hardware counters in PerfColumns around every run,
+	// on Linux with perf_event_open allowed (see perfUnavailable); the
+	// columns stay empty where it is not. The counts cover the scenario's
+	// whole Run, its untimed setup included, on the thread that runs it.
+	Perf bool
+	// NUMA places every run's memory by a NUMA policy, reported in the
+	// numa_placement column; see NUMAPolicy. Run fails at once if it is
+	// Unavailable.
+	NUMA NUMAPolicy
+	// ThrottleThreshold is the fraction the CPU clock may fall during a
+	// sweep before a run is marked throttled: each row's cpu_mhz_min
+	// against the clock sampled when Run starts. Zero means
+	// DefaultThrottleThreshold.
+	ThrottleThreshold float64
+	// MinRunNs is the shortest timed run worth reporting: a run shorter
+	// than it is repeated, each time on a fresh array, until the runs add
+	// up to it, and its row reports their mean timing, with the loops and
+	// short_run columns saying so. Contents, counters and checksums stay
+	// the first run's. Zero reports every run as it came; the CLI sets it to
+	// -min-run-factor times CalibrateTimer's granularity.
+	MinRunNs int64
+	// AllocPerRun gives every run freshly allocated index and op-kind
+	// buffers, as before Buffers, for comparison; by default each worker
+	// reuses one set (see Buffers).
+	AllocPerRun bool
+	// GC is what each run does about the garbage collector before and
+	// during its scenario; the zero value leaves it alone.
+	GC GCControl
+	// Writers receive every result, then are flushed (if they implement
+	// Flusher) when Run returns, including on cancellation; closing them is
+	// left to the caller.
+	Writers []ResultWriter
+	// OnRunComplete, if set, receives each result after the writers, from a
+	// single goroutine and in completion order.
+	OnRunComplete func(Result)
+	// Hooks are called around every run and cell, in order; see Hooks.
+	Hooks []Hooks
+	// Now stamps each result; nil means time.Now.
+	Now func() time.Time
+	// BenchmarkID, if set, goes into every result's benchmark_id column,
+	// so rows of several sweeps collected in one place stay apart.
+	BenchmarkID string
+	// Annotations go into every result, as meta_KEY columns; the CSV
+	// writers must be opened with their keys (see OpenResultWriter).
+	Annotations Annotations
+
+	stable *stabilizer
+	hooks  *hookState
+	warm   map[warmKey]bool
+	strict *strictRefs
+	// baseMHz is the CPU clock when Run started, 0 if unreadable.
+	baseMHz float64
+}
+
+// ResultWriter is an output for results.
+type ResultWriter interface {
+	Write(Result) error
+	Close() error
+}
+
+// Flusher is implemented by ResultWriters that buffer.
+type Flusher interface {
+	Flush() error
+}
+
+// Plan returns the cells Run would execute, at the maximum rep count under
+// RepeatUntilStable.
+func (r *Runner) Plan() []Cell {
+	reps := r.Reps
+	if r.RepeatUntilStable != nil {
+		reps = r.RepeatUntilStable.Max
+	}
+	cells := PlanCells(r.Impls, r.Ns, r.Scenarios, r.Seeds, reps, r.Interleave)
+	if r.ElemType != "" {
+		cells = ForElemType(cells, r.ElemType)
+	} else {
+		if !r.NoBatch {
+			cells = WithBatch(cells)
+		}
+		if r.Static {
+			cells = WithStatic(cells)
+		}
+	}
+	for i := range cells {
+		cells[i].IdenticalReps = r.IdenticalReps
+		cells[i].GC = r.GC
+		cells[i].BoundsCheckFree = r.BoundsCheckFree
+		cells[i].Perf = r.Perf
+		cells[i].NUMA = r.NUMA
+		cells[i].MinRunNs = r.MinRunNs
+		cells[i].Verify = r.StrictVerify && verifiable(cells[i])
+	}
+	return cells
+}
+
+// Run executes the plan, handing each result to the writers and then to
+// OnRunComplete, and returns them all as Results; a multi-phase composite
+// run adds a row per phase after its own (see Phases). ctx is checked before
+// every cell; a cell already running finishes (an isolated child is killed)
+// and its result is dropped, since polling ctx inside a timed loop would
+// perturb it. On cancellation the writers are flushed and Run returns the
+// results so far and ctx.Err().
+func (r *Runner) Run(ctx context.Context) (results Results, err error) {
+	for _, sc := range r.Scenarios {
+		if !knownScenario(sc) {
+			return nil, fmt.Errorf("unknown scenario: %s", sc)
+		}
+	}
+	if why := r.NUMA.Unavailable(); why != "" {
+		return nil, fmt.Errorf("numa: %s", why)
+	}
+	if _, ok := elemKinds[r.ElemType]; r.ElemType != "" && !ok {
+		return nil, fmt.Errorf("unknown element type %q (want one of %s)", r.ElemType, strings.Join(ElemTypes, ", "))
+	}
+	if r.AutoRerun != nil && r.RepeatUntilStable != nil {
+		return nil, errors.New("AutoRerun and RepeatUntilStable cannot be combined")
+	}
+	if r.RepeatUntilStable == nil && r.Reps < 1 {
+		return nil, errors.New("reps must be at least 1")
+	}
+	r.stable = nil
+	if r.RepeatUntilStable != nil {
+		r.stable = newStabilizer(*r.RepeatUntilStable)
+	}
+	r.baseMHz, _ = cpuFreq()
+	r.strict = nil
+	if r.StrictVerify {
+		r.strict = newStrictRefs(r.Params)
+	}
+	defer func() {
+		for _, w := range r.Writers {
+			if f, ok := w.(Flusher); ok {
+				if ferr := f.Flush(); ferr != nil && err == nil {
+					err = ferr
+				}
+			}
+		}
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var werr, verr error
+	emit := func(res Result) {
+		for _, row := range phaseRows(res) {
+			if werr != nil {
+				return
+			}
+			for _, w := range r.Writers {
+				if werr = w.Write(row); werr != nil {
+					// A failed write stops the sweep like a cancellation.
+					cancel()
+					return
+				}
+			}
+			results = append(results, row)
+			if r.OnRunComplete != nil {
+				r.OnRunComplete(row)
+			}
+		}
+		if res.Verify == VerifyFail && !r.KeepGoing && verr == nil {
+			verr = &VerifyError{res}
+			cancel()
+		}
+	}
+	cells := r.Plan()
+	r.hooks = newHookState(r.Hooks, cells)
+	r.warm = nil
+	if r.Warmup && !r.Isolate {
+		r.warm = r.warmup(ctx, cells)
+	}
+	var rr *reruns
+	if r.AutoRerun != nil {
+		rr = newReruns(*r.AutoRerun, cells, emit)
+		emit = rr.add
+	}
+	if r.Parallel <= 1 {
+		buf := r.buffers(cells)
+		for _, c := range cells {
+			if ctx.Err() != nil {
+				break
+			}
+			if res, ok := r.runCell(ctx, c, false, buf); ok {
+				emit(res)
+			}
+		}
+	} else {
+		r.runParallel(ctx, cells, emit)
+	}
+	if rr != nil {
+		buf := r.buffers(cells)
+		rr.finish(ctx, func(c Cell) (Result, bool) { return r.runCell(ctx, c, false, buf) })
+	}
+	if werr != nil {
+		return results, werr
+	}
+	if verr != nil {
+		return results, verr
+	}
+	return results, ctx.Err()
+}
+
+// buffers returns a worker's Buffers for cells, or nil to allocate per
+// run under AllocPerRun or Isolate (each child allocates its own).
+func (r *Runner) buffers(cells []Cell) *Buffers {
+	if r.AllocPerRun || r.Isolate {
+		return nil
+	}
+	return NewBuffers(planM(cells))
+}
+
+// HookTime is how long the last Run spent in Hooks. EstimatePlan does
+// not count it, so it is what a sweep with slow hooks overran the estimate
+// by.
+func (r *Runner) HookTime() time.Duration {
+	if r.hooks == nil {
+		return 0
+	}
+	return time.Duration(r.hooks.ns.Load())
+}
+
+// runCell runs one cell with the calling worker's buf; ok is false when
+// the cell was skipped because adaptive repetition already considers it
+// stable, or ctx ended during it.
+func (r *Runner) runCell(ctx context.Context, c Cell, contended bool, buf *Buffers) (res Result, ok bool) {
+	if r.stable != nil && r.stable.done(c) {
+		r.hooks.after(c, nil)
+		return Result{}, false
+	}
+	if err := r.hooks.before(c, contended); err != nil {
+		res = failedResult(c, contended, r.Params, hookStatus(err))
+	} else if r.Isolate {
+		res = runCellChild(ctx, c, contended, r.Params)
+	} else if err := catchPanic(func() { res = measureCell(c, contended, r.Params, buf) }); err != nil {
+		// Without a child process to contain it, a panicking run ends as
+		// an error row and the sweep goes on.
+		res = failedResult(c, contended, r.Params, "error: "+err.Error())
+	}
+	if ctx.Err() != nil {
+		return Result{}, false
+	}
+	if r.Now != nil {
+		res.Timestamp = r.Now()
+	}
+	res.BenchmarkID, res.Annotations = r.BenchmarkID, r.Annotations
+	res.WarmupCompleted = r.warm[warmKeyOf(c)]
+	threshold := r.ThrottleThreshold
+	if threshold == 0 {
+		threshold = DefaultThrottleThreshold
+	}
+	res.Throttled = throttled(res.CPUMHzMin, r.baseMHz, threshold)
+	if r.strict != nil && c.Verify && res.OK() {
+		r.strict.check(c, &res)
+	}
+	if r.stable != nil && res.OK() {
+		cv := r.stable.record(c, res.NsPerOp)
+		res.RepCV = &cv
+	}
+	r.hooks.after(c, &res)
+	return res, true
+}
+
+// measureCell runs one cell in this process, drawing its indices into buf.
+func measureCell(c Cell, contended bool, params ScenarioParams, buf *Buffers) Result {
+	// The policy is a thread's, so the run keeps to the one it is set on.
+	if c.NUMA.set() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		restore, err := c.NUMA.applyThread()
+		if err != nil {
+			return failedResult(c, contended, params, "failed: numa: "+err.Error())
+		}
+		defer restore()
+	}
+	if c.Elem != "" {
+		var name string
+		var run RunResult
+		var reloc, conv, resident int64
+		var perf map[string]int64
+		var freq freqSpan
+		gcs := c.GC.around(func() {
+			freq = withFreq(func() {
+				perf = withPerf(c.Perf, func() { name, run, reloc, conv, resident = elemKinds[c.Elem].measure(c, params[c.Scenario], buf) })
+			})
+		})
+		loops := 1
+		if tooShort(run, c.MinRunNs) {
+			gcs += c.GC.around(func() {
+				run, loops, _ = loopToMin(run, c.MinRunNs, func() (RunResult, error) {
+					_, r, _, _, _ := elemKinds[c.Elem].measure(c, params[c.Scenario], buf)
+					return r, nil
+				})
+			})
+		}
+		res := cellResult(c, contended, params, name, run, reloc, conv, resident)
+		res.GCs, res.Perf = gcs, perf
+		res.Loops, res.ShortRun = loops, loops > 1
+		freq.setFreq(&res)
+		if c.NUMA.set() {
+			res.NUMAPlacement = NUMAMempolicy
+		}
+		return res
+	}
+	arr, target, unchecked, placement, err := buildArray(c)
+	if err != nil {
+		return failedResult(c, contended, params, "failed: numa: "+err.Error())
+	}
+	var run RunResult
+	var perf map[string]int64
+	var freq freqSpan
+	gcs := c.GC.around(func() {
+		freq = withFreq(func() {
+			perf = withPerf(c.Perf, func() {
+				run = runScenarioIn(buf, c.Dispatch, target, c.Scenario, c.N, c.EffectiveSeed(), params[c.Scenario])
+			})
+		})
+	})
+	if unchecked {
+		run.Path = uncheckedPath(run.Path)
+		for i := range run.Phases {
+			run.Phases[i].Path = uncheckedPath(run.Phases[i].Path)
+		}
+	}
+	var reloc, conv int64
+	if sr, ok := arr.(StatsReporter); ok {
+		reloc, conv = sr.Stats()
+	}
+	if run.Relocations != nil {
+		reloc = *run.Relocations
+	}
+	if run.Conversions != nil {
+		conv = *run.Conversions
+	}
+	resident := footprint(arr, c.Impl.Meta)
+	// An instrumented impl records every store anyway, and a probe would
+	// show in its counts and trace.
+	counts := ""
+	if oc, ok := arr.(OpCounter); ok {
+		counts = oc.OpCounts().String()
+	} else {
+		probe(arr, c.EffectiveSeed())
+	}
+	allocated := 0
+	if cr, ok := arr.(CapacityReporter); ok {
+		allocated = cr.Capacity()
+	}
+	checksum := ""
+	if c.Verify {
+		checksum = contentsChecksum(arr)
+	}
+	closeArray(arr)
+	// Only the timing is looped, each loop on a fresh array as each rep
+	// is: what the row says of the array was read after the first run.
+	loops := 1
+	if tooShort(run, c.MinRunNs) {
+		gcs += c.GC.around(func() {
+			run, loops, err = loopToMin(run, c.MinRunNs, func() (RunResult, error) {
+				arr, target, _, _, err := buildArray(c)
+				if err != nil {
+					return RunResult{}, err
+				}
+				defer closeArray(arr)
+				return runScenarioIn(buf, c.Dispatch, target, c.Scenario, c.N, c.EffectiveSeed(), params[c.Scenario]), nil
+			})
+		})
+		if err != nil {
+			return failedResult(c, contended, params, "failed: numa: "+err.Error())
+		}
+	}
+	res := cellResult(c, contended, params, arr.Name(), run, reloc, conv, resident)
+	res.OpCounts, res.GCs, res.Perf = counts, gcs, perf
+	if allocated > 0 {
+		res.AllocatedN = allocated
+	}
+	res.Checksum, res.NUMAPlacement = checksum, placement
+	res.Loops, res.ShortRun = loops, loops > 1
+	freq.setFreq(&res)
+	return res
+}
+
+// buildArray is a fresh array for c, placed by its NUMA policy, and the
+// view its scenario runs on: the array itself, or under BoundsCheckFree
+// an unchecked view of it.
+func buildArray(c Cell) (arr, target Array, unchecked bool, placement string, err error) {
+	arr = c.Impl.New(c.N)
+	if placement, err = placeArray(c.NUMA, arr); err != nil {
+		closeArray(arr)
+		return nil, nil, false, "", err
+	}
+	target = arr
+	if sc, _ := LookupScenario(c.Scenario); c.BoundsCheckFree && c.Dispatch == "" && sc.Phases != nil {
+		target, unchecked = uncheckedView(arr)
+	}
+	return arr, target, unchecked, placement, nil
+}
+
+// closeArray closes arr if it holds a resource.
+func closeArray(arr Array) {
+	if c, ok := arr.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// cellResult is the Result of a completed run of c.
+func cellResult(c Cell, contended bool, params ScenarioParams, name string, run RunResult, reloc, conv, resident int64) Result {
+	return Result{
+		Timestamp: time.Now(), Impl: name, Scenario: c.Scenario,
+		N: c.N, Seed: c.Seed, Rep: c.Rep, EffectiveSeed: c.EffectiveSeed(),
+		Ops: run.Ops, TotalNs: run.TotalNs, NsPerOp: run.NsPerOp, InitNs: run.InitNs,
+		Relocations: reloc, Conversions: conv,
+		Ordinal: c.Ordinal, RunID: c.RunID(), Contended: contended, Status: "ok",
+		Params: cellParams(c, params), Path: run.Path, BytesResident: resident,
+		ElemType: c.elemType(), ImplParams: implParams(c.Impl), ScenarioKind: cellKind(c),
+		Phases: run.Phases, Curve: run.Curve, Dispatch: c.dispatch(), AllocatedN: c.N,
+	}
+}
+
+// cellKind is the scenario_kind column: the Kind of c's scenario.
+func cellKind(c Cell) string {
+	sc, _ := LookupScenario(c.Scenario)
+	return sc.Spec().Kind
+}
+
+// footprint is arr's MemoryFootprint, or N × ElemBytes when it has none.
+func footprint(arr Array, meta ImplMeta) int64 {
+	if f, ok := arr.(FootprintReporter); ok {
+		return f.MemoryFootprint()
+	}
+	return int64(float64(arr.Len()) * meta.ElemBytes)
+}
+
+// cellParams resolves the parameters c's scenario runs with.
+func cellParams(c Cell, params ScenarioParams) map[string]float64 {
+	sc, _ := LookupScenario(c.Scenario)
+	p, _ := sc.Resolve(params[c.Scenario])
+	return p
+}
+
+// failedResult is the Result of a run of c that did not complete, with
+// the measurement columns zero.
+func failedResult(c Cell, contended bool, params ScenarioParams, status string) Result {
+	return Result{
+		Timestamp: time.Now(), Impl: c.implName(), Scenario: c.Scenario,
+		N: c.N, Seed: c.Seed, Rep: c.Rep, EffectiveSeed: c.EffectiveSeed(),
+		Ordinal: c.Ordinal, RunID: c.RunID(), Contended: contended,
+		Status: status, Params: cellParams(c, params), ElemType: c.elemType(),
+		ImplParams: implParams(c.Impl), ScenarioKind: cellKind(c), Dispatch: c.dispatch(),
+	}
+}
+
+// formatScenarioParams is the inverse of ParseScenarioParams.
+func formatScenarioParams(p ScenarioParams) string {
+	var parts []string
+	for sc, kv := range p {
+		for k, v := range kv {
+			parts = append(parts, sc+":"+k+"="+v)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// runCellChild re-executes this binary with the "cell" command, which runs
+// exactly one cell and prints its Result as one JSON line. A child that fails
+// or prints no result yields a failed Result instead, so one crash does not
+// end the sweep. Timing happens inside the child, so process startup is not
+// measured.
+func runCellChild(ctx context.Context, c Cell, contended bool, params ScenarioParams) Result {
+	fail := func(reason string) Result {
+		return failedResult(c, contended, params, "failed: "+reason)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fail(err.Error())
+	}
+	implArgs := []string{"-impl", c.Impl.Name}
+	if c.Impl.Family != "" {
+		implArgs = []string{"-impl-params", formatImplParams(c.Impl)}
+	}
+	sc, _ := LookupScenario(c.Scenario)
+	cmd := exec.CommandContext(ctx, exe, append([]string{"cell"}, append(implArgs,
+		"-scenario", c.Scenario, "-spec", sc.Spec().String(),
+		"-N", strconv.Itoa(c.N), "-seed", strconv.FormatInt(c.Seed, 10),
+		"-rep", strconv.Itoa(c.Rep), "-identical-reps="+strconv.FormatBool(c.IdenticalReps),
+		"-gc-before-run="+strconv.FormatBool(c.GC.Before), "-gogc-off="+strconv.FormatBool(c.GC.Off), "-ordinal", strconv.Itoa(c.Ordinal),
+		"-contended="+strconv.FormatBool(contended), "-elem-type", c.Elem, "-dispatch", c.Dispatch,
+		"-bounds-check-free="+strconv.FormatBool(c.BoundsCheckFree), "-verify="+strconv.FormatBool(c.Verify),
+		"-perf="+strconv.FormatBool(c.Perf), "-numa-node", strconv.Itoa(numaNodeArg(c.NUMA)),
+		"-numa-interleave="+strconv.FormatBool(c.NUMA.Interleave), "-min-run-ns", strconv.FormatInt(c.MinRunNs, 10),
+		"-scenario-params", formatScenarioParams(params))...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		reason := err.Error()
+		if line, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); line != "" {
+			reason += ": " + line
+		}
+		return fail(reason)
+	}
+	var res Result
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return fail("bad child result: " + err.Error())
+	}
+	return res
+}
+
+// ServeCell is the child side of Runner.Isolate: it parses the arguments
+// runCellChild passes after "cell", runs that one cell and writes its Result
+// to stdout as JSON.
+func ServeCell(args []string) error {
+	fs := flag.NewFlagSet("cell", flag.ContinueOnError)
+	implFlag := fs.String("impl", "", "implementation")
+	implParamsFlag := fs.String("impl-params", "", "family variant, instead of -impl")
+	scenarioFlag := fs.String("scenario", "", "scenario")
+	specFlag := fs.String("spec", "", "the scenario's ScenarioSpec, for scenarios built on demand")
+	NFlag := fs.Int("N", 0, "size")
+	seedFlag := fs.Int64("seed", 42, "seed")
+	repFlag := fs.Int("rep", 1, "rep id")
+	identicalFlag := fs.Bool("identical-reps", false, "draw from -seed itself rather than the rep's derived seed")
+	gcBeforeFlag := fs.Bool("gc-before-run", false, "collect before the scenario runs")
+	gcOffFlag := fs.Bool("gogc-off", false, "turn the collector off while the scenario runs")
+	ordinalFlag := fs.Int("ordinal", 0, "position in the parent's plan")
+	contendedFlag := fs.Bool("contended", false, "value of the contended column")
+	paramsFlag := fs.String("scenario-params", "", "per-scenario parameters")
+	elemFlag := fs.String("elem-type", "", "element type of the typed path, empty for the Array path")
+	dispatchFlag := fs.String("dispatch", "", "dispatch of a composite, batch or static; empty for per-op")
+	boundsFlag := fs.Bool("bounds-check-free", false, "run a per-op composite on the array's unchecked view")
+	perfFlag := fs.Bool("perf", false, "read the hardware counters around the run")
+	numaNodeFlag := fs.Int("numa-node", -1, "bind the run's memory and thread to this NUMA node")
+	numaInterleaveFlag := fs.Bool("numa-interleave", false, "interleave the run's memory over the NUMA nodes")
+	minRunFlag := fs.Int64("min-run-ns", 0, "repeat a run shorter than this until the runs add up to it, reporting their mean")
+	verifyFlag := fs.Bool("verify", false, "hash the array's contents after the run into the checksum column")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *specFlag != "" {
+		spec, err := ParseScenarioSpec(*specFlag)
+		if err != nil {
+			return err
+		}
+		registerSpec(spec)
+	}
+	params, err := ParseScenarioParams(*paramsFlag)
+	if err != nil {
+		return err
+	}
+	var sel []Impl
+	if *implParamsFlag != "" {
+		sel, err = ParseImplParams(*implParamsFlag)
+	} else {
+		sel, err = SelectImpls(*implFlag)
+	}
+	if err != nil {
+		return err
+	}
+	if len(sel) != 1 || !knownScenario(*scenarioFlag) {
+		return fmt.Errorf("cell: need exactly one known impl and scenario")
+	}
+	if _, ok := elemKinds[*elemFlag]; *elemFlag != "" && !ok {
+		return fmt.Errorf("cell: unknown element type %q", *elemFlag)
+	}
+	c := Cell{
+		Ordinal:         *ordinalFlag,
+		Impl:            sel[0],
+		Scenario:        *scenarioFlag,
+		N:               *NFlag,
+		Seed:            *seedFlag,
+		Rep:             *repFlag,
+		Elem:            *elemFlag,
+		IdenticalReps:   *identicalFlag,
+		GC:              GCControl{Before: *gcBeforeFlag, Off: *gcOffFlag},
+		Dispatch:        *dispatchFlag,
+		BoundsCheckFree: *boundsFlag,
+		Verify:          *verifyFlag,
+		Perf:            *perfFlag,
+		NUMA:            NUMAPolicy{Bind: *numaNodeFlag >= 0, Node: max(*numaNodeFlag, 0), Interleave: *numaInterleaveFlag},
+		MinRunNs:        *minRunFlag,
+	}
+	b, err := json.Marshal(measureCell(c, *contendedFlag, params, nil))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(b, '\n'))
+	return err
+}
+
+// runParallel runs cells on r.Parallel worker goroutines, each building its
+// own array and RNG, and hands finished results to emit from a single
+// goroutine. No new cells start once ctx is done. Exclusive scenarios take the gate for writing so they run alone.
+func (r *Runner) runParallel(ctx context.Context, cells []Cell, emit func(Result)) {
+	var gate sync.RWMutex
+	jobs := make(chan Cell)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for i := 0; i < r.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := r.buffers(cells)
+			for c := range jobs {
+				var res Result
+				var ok bool
+				if ExclusiveScenarios[c.Scenario] {
+					gate.Lock()
+					res, ok = r.runCell(ctx, c, false, buf)
+					gate.Unlock()
+				} else {
+					gate.RLock()
+					res, ok = r.runCell(ctx, c, true, buf)
+					gate.RUnlock()
+				}
+				if ok {
+					results <- res
+				}
+			}
+		}()
+	}
+	go func() {
+	feed:
+		for _, c := range cells {
+			select {
+			case jobs <- c:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	for res := range results {
+		emit(res)
+	}
+}
@@ -0,0 +1,124 @@
+package inplacebench
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// runFourCells runs go_slice_int64 and go_atomic_int64 × 2 reps of
+// WRITE_RANDOM at N=10 with a fake clock that ticks a second a call,
+// cancelling from OnRunComplete after stopAfter callbacks (0: never). It
+// checks that each callback runs after its row was written and that Run
+// returns the rows the writer got.
+func runFourCells(t *testing.T, stopAfter int) (*memWriter, []int, error) {
+	t.Helper()
+	slice, _ := Lookup("go_slice_int64")
+	atomic, _ := Lookup("go_atomic_int64")
+	tick := time.Unix(0, 0)
+	w := &memWriter{}
+	var seen []int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &Runner{
+		Impls: []Impl{slice, atomic}, Scenarios: []string{"WRITE_RANDOM"},
+		Ns: []int{10}, Seeds: []int64{1}, Reps: 2, NoBatch: true,
+		Writers: []ResultWriter{w},
+		Now:     func() time.Time { tick = tick.Add(time.Second); return tick },
+		OnRunComplete: func(res Result) {
+			if len(w.rows) != len(seen)+1 {
+				t.Errorf("callback for ordinal %d ran before its write", res.Ordinal)
+			}
+			if seen = append(seen, res.Ordinal); len(seen) == stopAfter {
+				cancel()
+			}
+		},
+	}
+	results, err := r.Run(ctx)
+	if len(results) != len(w.rows) {
+		t.Fatalf("Run returned %d results, the writer got %d", len(results), len(w.rows))
+	}
+	for i := range results {
+		if results[i].RunID != w.rows[i].RunID {
+			t.Fatalf("result %d is %s, the writer got %s", i, results[i].RunID, w.rows[i].RunID)
+		}
+	}
+	return w, seen, err
+}
+
+// checkRunOrder checks that want rows were written, called back and
+// flushed once, in plan order, each stamped by the fake clock.
+func checkRunOrder(t *testing.T, w *memWriter, seen []int, want int) {
+	t.Helper()
+	if len(w.rows) != want || len(seen) != want || w.flushes != 1 {
+		t.Fatalf("%d rows, %d callbacks, %d flushes, want %d, %d and 1", len(w.rows), len(seen), w.flushes, want, want)
+	}
+	for i, res := range w.rows {
+		if seen[i] != i || res.Ordinal != i {
+			t.Fatalf("callback %d saw ordinal %d (row ordinal %d)", i, seen[i], res.Ordinal)
+		}
+		if want := time.Unix(int64(i+1), 0); !res.Timestamp.Equal(want) {
+			t.Fatalf("row %d stamped %v, want %v from the clock", i, res.Timestamp, want)
+		}
+	}
+}
+
+// TestRunnerFakeClock runs the matrix to the end and checks the order of
+// rows and callbacks and the fake clock's timestamps.
+func TestRunnerFakeClock(t *testing.T) {
+	w, seen, err := runFourCells(t, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRunOrder(t, w, seen, 4)
+}
+
+// TestRunnerCancel cancels from the progress callback after two cells and
+// checks that Run stops there, flushes what it wrote and returns
+// context.Canceled.
+func TestRunnerCancel(t *testing.T) {
+	w, seen, err := runFourCells(t, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Run returned %v, want context.Canceled", err)
+	}
+	checkRunOrder(t, w, seen, 2)
+}
+
+// TestRunnerRepSeeds checks that rep 1 draws from the base seed and rep 2
+// from its own, unless the reps are identical, and that the seed column
+// keeps the base seed either way.
+func TestRunnerRepSeeds(t *testing.T) {
+	slice, _ := Lookup("go_slice_int64")
+	for _, identical := range []bool{false, true} {
+		w := &memWriter{}
+		r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 2, IdenticalReps: identical, NoBatch: true, Writers: []ResultWriter{w}}
+		if _, err := r.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		rep1, rep2 := w.rows[0], w.rows[1]
+		if rep1.EffectiveSeed != 1 || (rep2.EffectiveSeed == 1) != identical || rep2.Seed != 1 || rep2.EffectiveSeed != RepSeed(1, 2) && !identical {
+			t.Fatalf("identical reps %v: effective seeds %d and %d from seed %d", identical, rep1.EffectiveSeed, rep2.EffectiveSeed, rep2.Seed)
+		}
+	}
+}
+
+// panicArray panics on every Write.
+type panicArray struct{ *SliceImpl }
+
+func (panicArray) Write(int, int64) { panic("boom") }
+
+// TestRunnerRecover checks that an in-process run that panics becomes an
+// error row and that the sweep goes on to the next cell.
+func TestRunnerRecover(t *testing.T) {
+	bad := Impl{Name: "go_panic_int64", New: func(n int) Array { return panicArray{NewSliceImpl(n)} }}
+	slice, _ := Lookup("go_slice_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{bad, slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 1, NoBatch: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.rows) != 2 || w.rows[0].Status != "error: panic: boom" || !w.rows[1].OK() {
+		t.Fatalf("%d rows: %+v", len(w.rows), w.rows)
+	}
+}
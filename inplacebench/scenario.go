@@ -0,0 +1,2118 @@
+package inplacebench
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Sink is what the runs read, folded together, so that no timed read is
+// provably unused: each scenario accumulates into a local and passes it to
+// consume once its clock has stopped, and measureCell then reads one
+// element at a seeded index, so a scenario that only writes leaves no
+// provably dead store either. The CLI prints it under run -v, at the end
+// of the sweep. Under Runner.Isolate each child folds into its own.
+var Sink int64
+
+var sinkMu sync.Mutex
+
+// consume folds v into Sink. It is never inlined: the lock already keeps
+// the compiler from dropping it, but inlining would let it see v's source
+// and the accumulation at once.
+//
+//go:noinline
+func consume(v int64) { sinkMu.Lock(); Sink ^= v; sinkMu.Unlock() }
+
+// probe consumes arr's element at an index drawn from seed.
+func probe(arr Array, seed int64) {
+	if n := arr.Len(); n > 0 {
+		consume(arr.Read(rand.New(rand.NewSource(seed)).Intn(n)))
+	}
+}
+
+// Context is what a Scenario's Run receives besides the array: the scenario
+// name and its parameters, the declared defaults overlaid with any
+// overrides, and the Buffers to draw indices into (nil allocates). Dispatch
+// is the cell's dispatch column, empty for per-op: under DispatchBatch the
+// composites run each phase through the array's BatchRunner and under
+// DispatchStatic through its static loop, where it has them; the other
+// scenarios ignore it.
+type Context struct {
+	Scenario string
+	Params   map[string]float64
+	Buffers  *Buffers
+	Dispatch string
+}
+
+// RunResult is what one scenario run measured. InitNs is set when Init is
+// the timed part. Path names the code path taken by a scenario that uses an
+// optional interface when the array has it ("fill", or "init" for the
+// fallback); it is empty for the others. Relocations and Conversions, when
+// set, are the scenario's own values for relocations_count and
+// conversions_count, replacing the array's Stats.
+type RunResult struct {
+	Ops         int
+	TotalNs     int64
+	NsPerOp     float64
+	InitNs      int64
+	Path        string
+	Relocations *int64
+	Conversions *int64
+	// Phases is the per-phase timing of a composite run with more than one
+	// phase.
+	Phases []PhaseResult
+	// Curve is FIRST_TOUCH_CURVE's samples.
+	Curve []CurvePoint
+}
+
+// Scenario is a registered workload. Params lists every parameter it accepts
+// with its default; they can be overridden with -scenario-params and are
+// recorded in each row. Run does its own setup (usually an Init) and times
+// only the measured part; rng is seeded from the cell, so the same seed gives
+// the same call sequence. Ops is the op count Run performs at N, for the
+// estimator; nil means min(1e6, N).
+type Scenario struct {
+	Name   string
+	Params map[string]float64
+	Run    func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult
+	Ops    func(N int) int
+	// OptIn scenarios are left out of DefaultScenarios.
+	OptIn bool
+	// Exclusive scenarios start their own goroutines: they run alone under
+	// parallel cells and are only planned for concurrent impls.
+	Exclusive bool
+	// ReadOnly scenarios never Write after their Init; they are the only
+	// ones planned for CapReadOnly impls, and verify checks the claim.
+	ReadOnly bool
+	// TimingOnly scenarios measure a cost, not what the array holds after
+	// it: INIT_ONLY, or POWER_OF_TWO_MOD, whose writes land differently by
+	// design. Runner.StrictVerify does not compare their contents.
+	TimingOnly bool
+	// Requires lists capabilities an impl must declare to be planned.
+	Requires Capability
+	// Unavailable, if set, returns why the scenario cannot run on this
+	// machine, or "" if it can; unavailable scenarios are not planned.
+	Unavailable func() string
+	// Kind is the workload when several scenarios differ only in their
+	// Params defaults, as the MIXED_* ones do; their Name is then
+	// ScenarioSpec{Kind, Params}.Name(). Empty means Name.
+	Kind string
+	// Check, if set, rejects resolved parameters the scenario cannot run
+	// with; Resolve returns its error, so -scenario-params fails at startup.
+	Check func(params map[string]float64) error
+	// Phases, when set instead of Run, makes this a composite scenario run
+	// by the phase engine, with the phases for the resolved parameters;
+	// Ops defaults to their counts. See Phase. Composites, which never look
+	// at what they read, are planned for CapNoop impls.
+	Phases func(params map[string]float64) []Phase
+}
+
+// ScenarioSpec is a scenario as a kind and its parameters, the structured
+// form of names like MIXED_R90W10: the name is generated from the spec,
+// never parsed back.
+type ScenarioSpec struct {
+	Kind   string
+	Params map[string]float64
+}
+
+// scenarioNamers generate the names of the kinds with more than one
+// scenario, from their parameters.
+var scenarioNamers = map[string]func(p map[string]float64) string{
+	"MIXED": func(p map[string]float64) string {
+		return fmt.Sprintf("MIXED_R%dW%d", int(p["read_pct"]), 100-int(p["read_pct"]))
+	},
+	"LATENCY_AMORTIZATION": func(p map[string]float64) string {
+		return fmt.Sprintf("LATENCY_AMORTIZATION_B%d", int(p["batch"]))
+	},
+	"WRITE_BURST": func(p map[string]float64) string {
+		for _, b := range burstPauses {
+			if b.ns == p["pause_ns"] {
+				return "WRITE_BURST_" + b.suffix
+			}
+		}
+		return fmt.Sprintf("WRITE_BURST_%gNS", p["pause_ns"])
+	},
+	"CACHE_COLORING": func(p map[string]float64) string {
+		return fmt.Sprintf("CACHE_COLORING_O%d", int(p["offset_bytes"]))
+	},
+}
+
+// Name is the scenario name spec is registered under.
+func (spec ScenarioSpec) Name() string {
+	if namer, ok := scenarioNamers[spec.Kind]; ok {
+		return namer(spec.Params)
+	}
+	return spec.Kind
+}
+
+// String renders spec as KIND:key=v,key=v, the form ServeCell's -spec
+// takes.
+func (spec ScenarioSpec) String() string { return spec.Kind + ":" + FormatParams(spec.Params) }
+
+// Spec is sc's kind with its default parameters.
+func (sc Scenario) Spec() ScenarioSpec {
+	kind := sc.Kind
+	if kind == "" {
+		kind = sc.Name
+	}
+	return ScenarioSpec{kind, sc.Params}
+}
+
+// checkPercent is a Check for parameters that must be whole percentages.
+func checkPercent(keys ...string) func(map[string]float64) error {
+	return func(p map[string]float64) error {
+		for _, k := range keys {
+			if v := p[k]; v < 0 || v > 100 || v != math.Trunc(v) {
+				return fmt.Errorf("%s=%g: want a whole percentage in [0, 100]", k, v)
+			}
+		}
+		return nil
+	}
+}
+
+// DefaultScenarios is the legacy sweep shared with the other languages;
+// AllScenarios adds the opt-in ones. Both follow registration order.
+var (
+	DefaultScenarios []string
+	AllScenarios     []string
+)
+
+// ExclusiveScenarios and ReadOnlyScenarios index the registered scenarios
+// by the flags of the same name.
+var (
+	ExclusiveScenarios = map[string]bool{}
+	ReadOnlyScenarios  = map[string]bool{}
+)
+
+var scenarios = map[string]Scenario{}
+
+// RegisterScenario adds a scenario. Like Register it panics on an empty name,
+// a nil Run (without Phases) or a duplicate, and should be called from an
+// init function.
+func RegisterScenario(sc Scenario) {
+	if sc.Name == "" {
+		panic("inplacebench: RegisterScenario with an empty name")
+	}
+	if sc.Phases != nil && sc.Run == nil {
+		phases := sc.Phases
+		sc.Run = func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			var br BatchRunner
+			if ctx.Dispatch == DispatchBatch {
+				br, _ = arr.(BatchRunner)
+			}
+			return runPhases(ctx.Buffers, br, ctx.Dispatch == DispatchStatic, arr, N, rng, phases(ctx.Params))
+		}
+		if sc.Ops == nil {
+			sc.Ops = phaseOps(sc)
+		}
+	}
+	if sc.Run == nil {
+		panic("inplacebench: RegisterScenario " + sc.Name + " with a nil Run")
+	}
+	if _, dup := scenarios[sc.Name]; dup {
+		panic("inplacebench: RegisterScenario called twice for " + sc.Name)
+	}
+	scenarios[sc.Name] = sc
+	AllScenarios = append(AllScenarios, sc.Name)
+	if !sc.OptIn {
+		DefaultScenarios = append(DefaultScenarios, sc.Name)
+	}
+	ExclusiveScenarios[sc.Name] = sc.Exclusive
+	ReadOnlyScenarios[sc.Name] = sc.ReadOnly
+}
+
+// LookupScenario returns the scenario registered under exactly name.
+func LookupScenario(name string) (Scenario, bool) {
+	sc, ok := scenarios[name]
+	return sc, ok
+}
+
+// Scenarios returns every registered scenario in registration order.
+func Scenarios() []Scenario {
+	out := make([]Scenario, len(AllScenarios))
+	for i, name := range AllScenarios {
+		out[i] = scenarios[name]
+	}
+	return out
+}
+
+// SelectScenarios resolves a comma-separated scenario list; "all" selects
+// every known scenario.
+func SelectScenarios(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "all" {
+		return append([]string(nil), AllScenarios...), nil
+	}
+	var out []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if group, ok := scenarioGroups[name]; ok {
+			out = append(out, group...)
+			continue
+		}
+		if !knownScenario(name) {
+			return nil, fmt.Errorf("unknown scenario: %s", name)
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// scenarioGroups are names SelectScenarios expands to several scenarios,
+// for comparisons that are only meaningful side by side.
+var scenarioGroups = map[string][]string{
+	"SIMD_COMPARISON": {"SIMD_COMPARISON_SCALAR", "SIMD_COMPARISON_UNROLL4"},
+}
+
+func knownScenario(name string) bool {
+	_, ok := scenarios[name]
+	return ok
+}
+
+// paramKeys lists the parameters sc accepts, sorted.
+func (sc Scenario) paramKeys() []string {
+	var keys []string
+	for k := range sc.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Resolve overlays overrides (as parsed by ParseScenarioParams) on the
+// declared defaults.
+func (sc Scenario) Resolve(overrides map[string]string) (map[string]float64, error) {
+	out := map[string]float64{}
+	for k, v := range sc.Params {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		if _, ok := sc.Params[k]; !ok {
+			return nil, fmt.Errorf("%s does not take %q (takes %v)", sc.Name, k, sc.paramKeys())
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%s: %v", sc.Name, k, err)
+		}
+		out[k] = f
+	}
+	if sc.Check != nil {
+		if err := sc.Check(out); err != nil {
+			return nil, fmt.Errorf("%s: %v", sc.Name, err)
+		}
+	}
+	return out, nil
+}
+
+// FormatParams renders resolved parameters as sorted "key=value" pairs, as
+// recorded in the scenario_params column.
+func FormatParams(p map[string]float64) string {
+	var parts []string
+	for k, v := range p {
+		parts = append(parts, k+"="+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// ScenarioParams maps a scenario name to its parameters.
+type ScenarioParams map[string]map[string]string
+
+// ParseScenarioParams parses "SCENARIO:key=v,key=v,OTHER:key=v"; a pair
+// without a scenario prefix belongs to the scenario named before it.
+func ParseScenarioParams(s string) (ScenarioParams, error) {
+	out := ScenarioParams{}
+	scenario := ""
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if sc, kv, ok := strings.Cut(part, ":"); ok {
+			scenario, part = sc, kv
+		}
+		if scenario == "" {
+			return nil, fmt.Errorf("scenario-params: %q has no SCENARIO: prefix", part)
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("scenario-params: want key=value, got %q", part)
+		}
+		sc, ok := LookupScenario(scenario)
+		if !ok {
+			return nil, fmt.Errorf("scenario-params: unknown scenario %q", scenario)
+		}
+		if _, err := sc.Resolve(map[string]string{k: v}); err != nil {
+			return nil, fmt.Errorf("scenario-params: %v", err)
+		}
+		if out[scenario] == nil {
+			out[scenario] = map[string]string{}
+		}
+		out[scenario][k] = v
+	}
+	return out, nil
+}
+
+// setDefault sets scenario's key to v unless it is already set.
+func (p ScenarioParams) setDefault(scenario, key, v string) {
+	if _, ok := p[scenario][key]; ok {
+		return
+	}
+	if p[scenario] == nil {
+		p[scenario] = map[string]string{}
+	}
+	p[scenario][key] = v
+}
+
+// RunScenario runs the named scenario against arr with its parameters
+// resolved from params, calling arr's ScenarioHook around it.
+func RunScenario(arr Array, scenario string, N int, seed int64, params map[string]string) (ops int, totalNs int64, nsPerOp float64, initNs int64) {
+	r := runScenario(arr, scenario, N, seed, params)
+	return r.Ops, r.TotalNs, r.NsPerOp, r.InitNs
+}
+
+// runScenario is RunScenario returning the whole RunResult.
+func runScenario(arr Array, scenario string, N int, seed int64, params map[string]string) RunResult {
+	return runScenarioIn(nil, "", arr, scenario, N, seed, params)
+}
+
+// runScenarioIn is runScenario drawing into buf, with Context.Dispatch set
+// to dispatch.
+func runScenarioIn(buf *Buffers, dispatch string, arr Array, scenario string, N int, seed int64, params map[string]string) RunResult {
+	sc, ok := LookupScenario(scenario)
+	if !ok {
+		panic("unknown scenario: " + scenario)
+	}
+	p, err := sc.Resolve(params)
+	if err != nil {
+		panic(err)
+	}
+	if arr.Len() != N {
+		panic(fmt.Sprintf("%s: Len() = %d, but the scenario was asked for N = %d", arr.Name(), arr.Len(), N))
+	}
+	if h, ok := arr.(ScenarioHook); ok {
+		h.BeforeScenario(scenario, N)
+		defer h.AfterScenario(scenario)
+	}
+	return sc.Run(Context{scenario, p, buf, dispatch}, arr, N, rand.New(rand.NewSource(seed)))
+}
+
+func randVal(rng *rand.Rand) int64 { return int64(rng.Intn(2001) - 1000) }
+
+// randIdx draws m indices in [0, N).
+func randIdx(rng *rand.Rand, m, N int) []int {
+	idx := make([]int, m)
+	for i := 0; i < m; i++ {
+		idx[i] = rng.Intn(N)
+	}
+	return idx
+}
+
+// rangeChunk is how many elements the sequential scenarios move per
+// ReadRange or WriteRange call.
+const rangeChunk = 4096
+
+// seqVersus times WRITE_SEQUENTIAL's element path on arr and then on base,
+// of the same size. ns/op is arr's and conversions_count how much slower
+// it was than base in percent (negative when faster).
+func seqVersus(arr, base Array, N int) RunResult {
+	seq := func(a Array) int64 {
+		a.Init(0)
+		start := time.Now()
+		for i := 0; i < N; i++ {
+			a.Write(i, int64(i))
+		}
+		return time.Since(start).Nanoseconds()
+	}
+	el := seq(arr)
+	bl := seq(base)
+	slower := int64(0)
+	if bl > 0 {
+		slower = int64(math.Round(float64(el-bl) / float64(bl) * 100))
+	}
+	res := timedOps(N, el)
+	res.Path, res.Conversions = "element", &slower
+	return res
+}
+
+// perElem is timedOps for an m that may be 0, which then gives 0 ns/op.
+func perElem(m int, el int64) RunResult {
+	return RunResult{Ops: m, TotalNs: el, NsPerOp: float64(el) / float64(max(m, 1))}
+}
+
+// timedOps is the RunResult of m ops taking el ns.
+func timedOps(m int, el int64) RunResult {
+	return RunResult{Ops: m, TotalNs: el, NsPerOp: float64(el) / float64(m)}
+}
+
+func init() {
+	RegisterScenario(Scenario{
+		Name:       "INIT_ONLY",
+		ReadOnly:   true,
+		TimingOnly: true,
+		Ops:        func(int) int { return 0 },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			start := time.Now()
+			arr.Init(42)
+			el := time.Since(start).Nanoseconds()
+			return RunResult{Ops: 1, TotalNs: el, InitNs: el}
+		},
+	})
+	// WRITE_THEN_INIT times Init(0) on an array WRITE_RANDOM has just
+	// dirtied, where INIT_ONLY times it on a fresh one: a flat slice
+	// rewrites all N elements either way, a DirtyPager only the pages
+	// written. Conversions is the number of 4 KiB pages of the index range
+	// the writes touched. Ops counts the untimed writes, which dominate
+	// the run.
+	RegisterScenario(Scenario{
+		Name:  "WRITE_THEN_INIT",
+		OptIn: true,
+		Ops:   func(N int) int { return min(1000000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			d := &dirtyCounter{Array: arr, seen: make([]bool, (N+pageElems-1)/pageElems)}
+			runPhases(ctx.Buffers, nil, false, d, N, rng, []Phase{{Init: true}})
+			start := time.Now()
+			arr.Init(0)
+			el := time.Since(start).Nanoseconds()
+			return RunResult{Ops: 1, TotalNs: el, InitNs: el, Conversions: &d.pages}
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:     "READ_UNWRITTEN",
+		ReadOnly: true,
+		Phases: func(map[string]float64) []Phase {
+			return []Phase{{Init: true, InitValue: 123, Op: PhaseRead, Count: func(N int) int { return min(1000000, 10*N) }}}
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:   "WRITE_SEQUENTIAL",
+		Params: map[string]float64{"bulk": 1},
+		Phases: func(p map[string]float64) []Phase {
+			return []Phase{{Init: true, Dist: DistSequential, Bulk: p["bulk"] != 0, Count: func(N int) int { return N }}}
+		},
+	})
+	// WRITE_SEQUENTIAL's writes, untimed, then a timed read of every element
+	// checked against them. The first mismatch goes to stderr and the count
+	// to conversions_count.
+	RegisterScenario(Scenario{
+		Name:  "READBACK_VERIFY",
+		OptIn: true,
+		Ops:   func(N int) int { return N },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			arr.Init(0)
+			for i := 0; i < N; i++ {
+				arr.Write(i, int64(i))
+			}
+			var bad int64
+			check := func(i int, v int64) {
+				if v != int64(i) {
+					if bad == 0 {
+						fmt.Fprintf(os.Stderr, "%s %s N=%d: Read(%d) = %d, want %d\n", ctx.Scenario, arr.Name(), N, i, v, i)
+					}
+					bad++
+				}
+			}
+			rr, ok := arr.(RangeReader)
+			path := "element"
+			var buf [rangeChunk]int64
+			start := time.Now()
+			if ok {
+				path = "range"
+				for lo := 0; lo < N; lo += rangeChunk {
+					out := buf[:min(rangeChunk, N-lo)]
+					rr.ReadRange(lo, out)
+					for k, v := range out {
+						check(lo+k, v)
+					}
+				}
+			} else {
+				for i := 0; i < N; i++ {
+					check(i, arr.Read(i))
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			res := perElem(N, el)
+			res.Conversions, res.Path = &bad, path
+			return res
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:   "WRITE_RANDOM",
+		Phases: func(map[string]float64) []Phase { return []Phase{{Init: true}} },
+	})
+	// WRITE_RANDOM's writes with storeFence, a full memory barrier, after
+	// each; the difference from WRITE_RANDOM's ns/op is the fence. On amd64
+	// stores are already ordered, so MFENCE mostly waits for the store
+	// buffer to drain; on arm64 DMB ISH orders what the hardware would not.
+	// relocations_count is the number of fences and op_path the fence kind.
+	RegisterScenario(Scenario{
+		Name:  "MEMORY_FENCE",
+		OptIn: true,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			M := min(1000000, N)
+			idx := ctx.Buffers.Idx(rng, M, N)
+			start := time.Now()
+			for _, j := range idx {
+				arr.Write(j, randVal(rng))
+				storeFence()
+			}
+			el := time.Since(start).Nanoseconds()
+			fences := int64(M)
+			res := timedOps(M, el)
+			res.Path, res.Relocations = fenceKind, &fences
+			return res
+		},
+	})
+	// WRITE_SEQUENTIAL's element path on arr and then on a go_slice_int64
+	// of the same size; ns/op is arr's and conversions_count how much slower
+	// it was than the slice in percent (negative when faster). Run on
+	// go_madvise_seq_int64 it estimates what a streaming-store mapping buys.
+	RegisterScenario(Scenario{
+		Name:  "WRITE_COMBINE_TEST",
+		OptIn: true,
+		Ops:   func(N int) int { return 2 * N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return seqVersus(arr, NewSliceImpl(N), N)
+		},
+	})
+	// WRITE_COMBINE_TEST against go_atomic_int64 instead of the slice:
+	// conversions_count is how much slower arr's stores were than
+	// sequentially consistent atomic ones, in percent. Run on
+	// go_relaxed_int64 (amd64 only) it is the price of an XCHG over a MOV.
+	RegisterScenario(Scenario{
+		Name:  "MEMORY_ORDER_RELAXED",
+		OptIn: true,
+		Ops:   func(N int) int { return 2 * N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return seqVersus(arr, NewAtomicSliceImpl(N), N)
+		},
+	})
+	// WRITE_RANDOM with each index reduced inside the timed loop: the
+	// indices are drawn as raw non-negative ints before it, and each write
+	// goes to r & Mask() on an IndexMasker, whose padding takes every masked
+	// index, and to r % N on any other array. N is a run-time value, so the
+	// compiler cannot turn the % into an AND; go_pow2_int64's ns/op against
+	// go_slice_int64's is the division it saves. op_path is mask or mod.
+	RegisterScenario(Scenario{
+		Name:       "POWER_OF_TWO_MOD",
+		OptIn:      true,
+		TimingOnly: true,
+		Ops:        func(N int) int { return min(1000000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			M := min(1000000, N)
+			raw := ctx.Buffers.Ints(M)
+			for k := range raw {
+				// Masked to int's width, so a 32-bit int stays non-negative.
+				raw[k] = int(rng.Int63() & math.MaxInt)
+			}
+			var start time.Time
+			path := "mod"
+			if m, ok := arr.(IndexMasker); ok {
+				path = "mask"
+				mask := m.Mask()
+				start = time.Now()
+				for _, r := range raw {
+					arr.Write(r&mask, randVal(rng))
+				}
+			} else {
+				start = time.Now()
+				for _, r := range raw {
+					arr.Write(r%N, randVal(rng))
+				}
+			}
+			res := timedOps(M, time.Since(start).Nanoseconds())
+			res.Path = path
+			return res
+		},
+	})
+	// BENCHMARK_BENCHMARK measures the harness rather than an array: on
+	// go_noop its ns/op is WRITE_RANDOM's loop with nothing behind Write.
+	// Relocations is the ns of one time.Now/time.Since pair and Conversions
+	// the ns to format one result row and write it as CSV, the costs around
+	// every timed region.
+	RegisterScenario(Scenario{
+		Name:     "BENCHMARK_BENCHMARK",
+		OptIn:    true,
+		Requires: CapNoop,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			res := runPhases(ctx.Buffers, nil, false, arr, N, rng, []Phase{{Init: true}})
+			const pairs = 1000
+			start := time.Now()
+			var el int64
+			for k := 0; k < pairs; k++ {
+				el += time.Since(time.Now()).Nanoseconds()
+			}
+			timer := int64(math.Round(float64(time.Since(start).Nanoseconds()) / pairs))
+			consume(el)
+			row := Result{Impl: arr.Name(), Scenario: "BENCHMARK_BENCHMARK", N: N, Ops: res.Ops, TotalNs: res.TotalNs, NsPerOp: res.NsPerOp, Status: "ok"}
+			w := csv.NewWriter(io.Discard)
+			start = time.Now()
+			for k := 0; k < pairs; k++ {
+				row.Rep = k
+				w.Write(row.Record())
+			}
+			w.Flush()
+			format := int64(math.Round(float64(time.Since(start).Nanoseconds()) / pairs))
+			res.Relocations, res.Conversions = &timer, &format
+			return res
+		},
+	})
+	// NO_ESCAPE is WRITE_SEQUENTIAL on a StackArrayImpl the compiler keeps
+	// on the stack, against the same on the heap, at min(N, maxStackN)
+	// elements. Like BENCHMARK_BENCHMARK it measures no array of its own,
+	// so it runs on go_noop alone; see runNoEscape.
+	RegisterScenario(Scenario{
+		Name:       "NO_ESCAPE",
+		OptIn:      true,
+		TimingOnly: true,
+		Requires:   CapNoop,
+		Run: func(_ Context, _ Array, N int, _ *rand.Rand) RunResult {
+			return runNoEscape(N)
+		},
+	})
+	// read_pct overrides the mix in the name.
+	for _, readPct := range []int{90, 80, 70, 50, 30, 10} {
+		spec := ScenarioSpec{"MIXED", map[string]float64{"read_pct": float64(readPct)}}
+		RegisterScenario(Scenario{
+			Name:   spec.Name(),
+			Kind:   spec.Kind,
+			Params: spec.Params,
+			Check:  checkPercent("read_pct"),
+			Phases: mixedPhases,
+		})
+	}
+	// The mix follows the timings, so what the array holds afterwards
+	// does too.
+	RegisterScenario(Scenario{
+		Name:       "MIXED_ADAPTIVE",
+		OptIn:      true,
+		TimingOnly: true,
+		Params:     map[string]float64{"window": 10000, "step": 5},
+		Check: func(p map[string]float64) error {
+			if p["window"] < 1 {
+				return fmt.Errorf("window=%g: want at least one op", p["window"])
+			}
+			if s := p["step"]; s < 1 || s > 50 || s != math.Trunc(s) {
+				return fmt.Errorf("step=%g: want whole percentage points in [1, 50]", s)
+			}
+			return nil
+		},
+		Ops: func(N int) int { return min(1000000, N) },
+		Run: runMixedAdaptive,
+	})
+	RegisterScenario(Scenario{
+		Name:   "ADVERSARIAL_HOTSPOT",
+		Params: map[string]float64{"hotspot_pct": 10, "hot_write_pct": 50},
+		// A hot region past N would send writes out of range.
+		Check: func(p map[string]float64) error {
+			if v := p["hotspot_pct"]; v < 0 || v > 100 {
+				return fmt.Errorf("hotspot_pct=%g: want a percentage in [0, 100]", v)
+			}
+			return checkPercent("hot_write_pct")(p)
+		},
+		Phases: hotspotPhases,
+	})
+	// FIRST_TOUCH_CURVE is WRITE_RANDOM with the clock sampled every step
+	// distinct indices first written (0: every N/1000), for the impls that
+	// defer Init's cost to first touches: the curve, in an amortization
+	// sidecar under -amortization, shows whether that cost comes early,
+	// evenly or in bursts at block boundaries. See runFirstTouchCurve.
+	RegisterScenario(Scenario{
+		Name:   "FIRST_TOUCH_CURVE",
+		Params: map[string]float64{"step": 0},
+		OptIn:  true,
+		Check: func(p map[string]float64) error {
+			if p["step"] < 0 {
+				return fmt.Errorf("step: want >= 0, got %g", p["step"])
+			}
+			return nil
+		},
+		Run: runFirstTouchCurve,
+	})
+	// TEMPORAL_LOCALITY writes to one of the last window written indices
+	// with probability recent_pct and to a random index otherwise. A recent
+	// pick re-enters the window, so the number of distinct indices in it,
+	// the working set, shrinks and regrows as random picks push the repeats
+	// out. The window is reported as relocations_count.
+	RegisterScenario(Scenario{
+		Name:   "TEMPORAL_LOCALITY",
+		Params: map[string]float64{"window": 1000, "recent_pct": 80},
+		OptIn:  true,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			M := min(1000000, N)
+			W := max(1, int(ctx.Params["window"]))
+			recentP := ctx.Params["recent_pct"] / 100
+			recent := make([]int, 0, W)
+			next := 0
+			start := time.Now()
+			for i := 0; i < M; i++ {
+				var j int
+				if len(recent) > 0 && rng.Float64() < recentP {
+					j = recent[rng.Intn(len(recent))]
+				} else {
+					j = rng.Intn(N)
+				}
+				arr.Write(j, randVal(rng))
+				if len(recent) < W {
+					recent = append(recent, j)
+				} else {
+					recent[next] = j
+					next = (next + 1) % W
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			res := timedOps(M, el)
+			window := int64(W)
+			res.Relocations = &window
+			return res
+		},
+	})
+	// BOUNDS_MIX makes min(1M, N) checked reads and writes, half each, of
+	// which out_pct have an index below 0 or at least N and must be
+	// rejected. Path is "checked" for a CheckedAccessor and "recover" for the
+	// panic-recovering wrapper. Afterwards every in-range write must read
+	// back and nothing else may have changed.
+	RegisterScenario(Scenario{
+		Name:   "BOUNDS_MIX",
+		Params: map[string]float64{"out_pct": 1},
+		OptIn:  true,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			M := min(1000000, N)
+			outP := ctx.Params["out_pct"] / 100
+			idx := make([]int, M)
+			vals := make([]int64, M)
+			want := map[int]int64{}
+			for k := range idx {
+				switch {
+				case rng.Float64() >= outP:
+					idx[k] = rng.Intn(N)
+				case rng.Intn(2) == 0:
+					idx[k] = -1 - rng.Intn(N)
+				default:
+					idx[k] = N + rng.Intn(N)
+				}
+				// A zero value marks a read, so a write of 0 writes 1.
+				if rng.Intn(2) == 0 {
+					if vals[k] = randVal(rng); vals[k] == 0 {
+						vals[k] = 1
+					}
+					if idx[k] >= 0 && idx[k] < N {
+						want[idx[k]] = vals[k]
+					}
+				}
+			}
+			c := Checked(arr)
+			path := "recover"
+			if _, ok := arr.(CheckedAccessor); ok {
+				path = "checked"
+			}
+			accepted, failed := 0, 0
+			var s int64
+			start := time.Now()
+			for k, j := range idx {
+				var err error
+				if vals[k] == 0 {
+					var v int64
+					v, err = c.CheckedRead(j)
+					s ^= v
+				} else {
+					err = c.CheckedWrite(j, vals[k])
+				}
+				inRange := j >= 0 && j < N
+				if err == nil && !inRange {
+					accepted++
+				} else if err != nil && inRange {
+					failed++
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			consume(s)
+			if accepted > 0 || failed > 0 {
+				panic(fmt.Sprintf("BOUNDS_MIX: %d out-of-range ops accepted, %d in-range ops rejected (%s)", accepted, failed, path))
+			}
+			check := func(i int, got int64) {
+				if got != want[i] {
+					panic(fmt.Sprintf("BOUNDS_MIX: element %d = %d after the run, want %d (%s)", i, got, want[i], path))
+				}
+			}
+			if t, ok := arr.(Traverser); ok {
+				i := 0
+				t.Traverse(func(v int64) { check(i, v); i++ })
+			} else {
+				for i := 0; i < N; i++ {
+					check(i, arr.Read(i))
+				}
+			}
+			res := timedOps(M, el)
+			res.Path = path
+			return res
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:      "CONCURRENT_WRITE",
+		Params:    map[string]float64{"goroutines": float64(runtime.GOMAXPROCS(0))},
+		OptIn:     true,
+		Exclusive: true,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			M := min(1000000, N)
+			idx := ctx.Buffers.Idx(rng, M, N)
+			vals := make([]int64, M)
+			for i := range vals {
+				vals[i] = randVal(rng)
+			}
+			G := max(1, int(ctx.Params["goroutines"]))
+			var wg sync.WaitGroup
+			start := time.Now()
+			for g := 0; g < G; g++ {
+				lo, hi := g*M/G, (g+1)*M/G
+				wg.Add(1)
+				go func(idx []int, vals []int64) {
+					defer wg.Done()
+					for k, j := range idx {
+						arr.Write(j, vals[k])
+					}
+				}(idx[lo:hi], vals[lo:hi])
+			}
+			wg.Wait()
+			el := time.Since(start).Nanoseconds()
+			return timedOps(M, el)
+		},
+	})
+	// G goroutines each walk the same M random indices, from their own
+	// offset in them, and make one CompareAndSwap from the value just read
+	// to one more at each, without retrying, so two goroutines on the same
+	// index at once cost one of them its update. ns/op is per attempt, of
+	// G*M; conversions_count is the successful swaps and relocations_count
+	// the failed ones: the smaller N and the more goroutines, the more
+	// fail. The array must then sum to the successes.
+	RegisterScenario(Scenario{
+		Name:      "COMPARE_EXCHANGE",
+		Params:    map[string]float64{"goroutines": float64(runtime.GOMAXPROCS(0))},
+		OptIn:     true,
+		Exclusive: true,
+		Requires:  CapCAS,
+		Ops:       func(N int) int { return runtime.GOMAXPROCS(0) * min(1000000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			cas := arr.(CompareAndSwapper)
+			M := min(1000000, N)
+			idx := ctx.Buffers.Idx(rng, M, N)
+			G := max(1, int(ctx.Params["goroutines"]))
+			var swapped, failed atomic.Int64
+			var wg sync.WaitGroup
+			start := time.Now()
+			for g := 0; g < G; g++ {
+				wg.Add(1)
+				go func(from int) {
+					defer wg.Done()
+					var ok int64
+					for k := range idx {
+						j := idx[(from+k)%M]
+						v := arr.Read(j)
+						if cas.CompareAndSwap(j, v, v+1) {
+							ok++
+						}
+					}
+					swapped.Add(ok)
+					failed.Add(int64(M) - ok)
+				}(g * M / G)
+			}
+			wg.Wait()
+			el := time.Since(start).Nanoseconds()
+			var sum int64
+			for i := 0; i < N; i++ {
+				sum += arr.Read(i)
+			}
+			s, f := swapped.Load(), failed.Load()
+			if sum != s {
+				panic(fmt.Sprintf("COMPARE_EXCHANGE: elements sum to %d after %d successful swaps", sum, s))
+			}
+			res := perElem(G*M, el)
+			res.Conversions, res.Relocations = &s, &f
+			return res
+		},
+	})
+	// Init has no range form, so each goroutine initializes its contiguous
+	// segment with Writes; compare init_time_ns with INIT_ONLY's.
+	RegisterScenario(Scenario{
+		Name:      "CONCURRENT_INIT",
+		Params:    map[string]float64{"goroutines": float64(runtime.GOMAXPROCS(0))},
+		OptIn:     true,
+		Exclusive: true,
+		Ops:       func(N int) int { return N },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			arr.Init(0)
+			G := max(1, int(ctx.Params["goroutines"]))
+			var wg sync.WaitGroup
+			start := time.Now()
+			for g := 0; g < G; g++ {
+				lo, hi := g*N/G, (g+1)*N/G
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := lo; i < hi; i++ {
+						arr.Write(i, 42)
+					}
+				}()
+			}
+			wg.Wait()
+			el := time.Since(start).Nanoseconds()
+			return RunResult{Ops: N, TotalNs: el, NsPerOp: float64(el) / float64(max(N, 1)), InitNs: el}
+		},
+	})
+	// G goroutines each own a contiguous N/G slice of the array and write
+	// to it; every `every` writes one sends up to `sample` of the indices
+	// it changed, with their values, to a random peer, which checks them
+	// with Reads. It runs for duration_ms of wall-clock time, not a fixed
+	// op count; ns/op is per Write and Read, and conversions_count is
+	// the number of messages delivered.
+	RegisterScenario(Scenario{
+		Name:      "GOSSIP",
+		Params:    map[string]float64{"goroutines": float64(runtime.GOMAXPROCS(0)), "every": 100, "sample": 16, "duration_ms": 1000},
+		OptIn:     true,
+		Exclusive: true,
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			p := ctx.Params
+			return runGossip(arr, N, int(p["goroutines"]), int(p["every"]), int(p["sample"]),
+				time.Duration(p["duration_ms"]*float64(time.Millisecond)), rng)
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:  "TRAVERSE_FORWARD",
+		OptIn: true,
+		Ops:   func(N int) int { return N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			arr.Init(7)
+			for i := 0; i < N; i += 3 {
+				arr.Write(i, int64(i))
+			}
+			var s int64 = 0
+			start := time.Now()
+			if t, ok := arr.(Traverser); ok {
+				t.Traverse(func(v int64) { s ^= v })
+			} else {
+				for i := 0; i < N; i++ {
+					s ^= arr.Read(i)
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			consume(s)
+			return perElem(N, el)
+		},
+	})
+	// ITERATE_WRITTEN writes written_pct of N random indices (with repeats)
+	// after Init(0) and then sums the array scanPasses(N) times; ns/op is
+	// per distinct written index. Arrays without Iterator are summed with
+	// Read(0..N-1), which gives the same result because every unwritten
+	// element is 0. The sum and xor do not depend on the visiting order and
+	// are checked against the writes.
+	RegisterScenario(Scenario{
+		Name:   "ITERATE_WRITTEN",
+		Params: map[string]float64{"written_pct": 1},
+		OptIn:  true,
+		Ops:    func(N int) int { return scanPasses(N) * max(1, N/100) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(0)
+			K := min(N, max(1, int(float64(N)*ctx.Params["written_pct"]/100)))
+			written := make(map[int]int64, K)
+			for k := 0; k < K; k++ {
+				j, v := rng.Intn(N), randVal(rng)
+				arr.Write(j, v)
+				written[j] = v
+			}
+			var wantSum, wantXor int64
+			for _, v := range written {
+				wantSum += v
+				wantXor ^= v
+			}
+			passes := scanPasses(N)
+			var sum, xor int64
+			path := "element"
+			start := time.Now()
+			if it, ok := arr.(Iterator); ok {
+				path = "iterator"
+				for p := 0; p < passes; p++ {
+					sum, xor = 0, 0
+					it.ForEach(func(_ int, v int64) bool {
+						sum += v
+						xor ^= v
+						return true
+					})
+				}
+			} else {
+				for p := 0; p < passes; p++ {
+					sum, xor = 0, 0
+					for i := 0; i < N; i++ {
+						v := arr.Read(i)
+						sum += v
+						xor ^= v
+					}
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			if sum != wantSum || xor != wantXor {
+				panic(fmt.Sprintf("ITERATE_WRITTEN: sum %d, xor %d over %s, want %d and %d", sum, xor, path, wantSum, wantXor))
+			}
+			res := perElem(passes*len(written), el)
+			res.Path = path
+			return res
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:     "SAFE_READ_ONLY",
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(99)
+			M := min(1000000, 10*N)
+			idx := ctx.Buffers.Idx(rng, M, N)
+			changed := 0
+			start := time.Now()
+			for _, j := range idx {
+				if arr.Read(j) != 99 {
+					changed++
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			if changed > 0 {
+				panic(fmt.Sprintf("SAFE_READ_ONLY: %d reads differ from the Init value", changed))
+			}
+			return perElem(M, el)
+		},
+	})
+	// 10*N writes, so every slot is overwritten nine times. Impls without Push
+	// get Write(j mod N), a circular array in which this is WRITE_SEQUENTIAL
+	// repeated once the ring is full.
+	RegisterScenario(Scenario{
+		Name:  "RING_OVERWRITE",
+		OptIn: true,
+		Ops:   func(N int) int { return 10 * N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			arr.Init(0)
+			M := 10 * N
+			start := time.Now()
+			if p, ok := arr.(Pusher); ok {
+				for j := 0; j < M; j++ {
+					p.Push(int64(j))
+				}
+			} else {
+				for j, i := 0, 0; j < M; j++ {
+					arr.Write(i, int64(j))
+					if i++; i == N {
+						i = 0
+					}
+				}
+			}
+			el := time.Since(start).Nanoseconds()
+			return perElem(M, el)
+		},
+	})
+	// With the default geometry (32 KiB, 8-way, 64-byte lines) the nine
+	// elements 4 KiB apart all map to one L1 set, one more than it holds.
+	RegisterScenario(Scenario{
+		Name:     "CACHE_ASSOCIATIVITY",
+		Params:   map[string]float64{"cache_bytes": 32768, "assoc": 8, "line_bytes": 64},
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			p := ctx.Params
+			setStride := p["cache_bytes"] / p["assoc"] / p["line_bytes"] // in lines
+			stride := max(1, int(setStride*p["line_bytes"]/8))
+			return runStrided(arr, N, stride, int(p["assoc"])+1)
+		},
+	})
+	// The baseline for CACHE_ASSOCIATIVITY: as many elements, but one line
+	// further apart each, so they fall in different sets.
+	RegisterScenario(Scenario{
+		Name:     "STRIDE_ACCESS",
+		Params:   map[string]float64{"stride_bytes": 4096 + 64, "count": 9},
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return runStrided(arr, N, max(1, int(ctx.Params["stride_bytes"]/8)), int(ctx.Params["count"]))
+		},
+	})
+	// A software model of DRAM bank conflicts: with rows interleaved across
+	// banks, elements one row_bytes apart are assumed to share a bank, so
+	// reads over N/row_bytes of them queue on it. BANK_SPREAD shifts each
+	// access by a further 1/banks of a row to walk across banks instead.
+	RegisterScenario(Scenario{
+		Name:     "BANK_CONFLICT",
+		Params:   map[string]float64{"row_bytes": 8192},
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return runStrided(arr, N, max(1, int(ctx.Params["row_bytes"]/8)), N)
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:     "BANK_SPREAD",
+		Params:   map[string]float64{"row_bytes": 8192, "banks": 8},
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			row := ctx.Params["row_bytes"]
+			return runStrided(arr, N, max(1, int((row+row/ctx.Params["banks"])/8)), N)
+		},
+	})
+	// READ_UNWRITTEN on memory the OS write-protects; afterwards a Write is
+	// attempted outside the timer and must fault.
+	RegisterScenario(Scenario{
+		Name:     "READONLY_MMAP_READ",
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(1000000, 10*N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			r := scenarios["READ_UNWRITTEN"].Run(ctx, arr, N, rng)
+			if p, ok := arr.(interface{ Protected() bool }); ok && p.Protected() && N > 0 && !writeFaults(arr) {
+				panic("READONLY_MMAP_READ: Write to protected memory did not fault")
+			}
+			return r
+		},
+	})
+	// READ_UNWRITTEN's reads, fewer of them, after a CacheDropper has
+	// evicted its pages, so they measure the device rather than the page
+	// cache. Path is "cold" after DropCache and "warm" for other arrays.
+	RegisterScenario(Scenario{
+		Name:     "DISK_BACKED",
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return min(100000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			arr.Init(123)
+			M := min(100000, N)
+			idx := ctx.Buffers.Idx(rng, M, N)
+			path := "warm"
+			if d, ok := arr.(CacheDropper); ok {
+				if err := d.DropCache(); err != nil {
+					panic(err)
+				}
+				path = "cold"
+			}
+			var s int64
+			start := time.Now()
+			for _, j := range idx {
+				s ^= arr.Read(j)
+			}
+			el := time.Since(start).Nanoseconds()
+			consume(s)
+			res := timedOps(M, el)
+			res.Path = path
+			return res
+		},
+	})
+	// Rounds of a burst of random writes to the live array, then as many
+	// random reads split between the snapshot taken before the burst and
+	// the live array, then a new snapshot. The snapshot reads are checked
+	// against the values at snapshot time, outside the timer: mismatches
+	// go to conversions_count (the first one to stderr), and the time spent
+	// in Snapshot to init_time_ns_if_recorded, apart from ns/op.
+	RegisterScenario(Scenario{
+		Name:     "SNAPSHOT_CHURN",
+		Params:   map[string]float64{"snapshots": 100},
+		OptIn:    true,
+		Requires: CapSnapshot,
+		Ops: func(N int) int {
+			if N == 0 {
+				return 0
+			}
+			return 2 * 100 * max(1, min(1000000, N)/100)
+		},
+		Run: runSnapshotChurn,
+	})
+	// M random writes and then reads of the same indices, checked, each one
+	// a request–reply round trip for go_tcp_int64: ns/op is the loopback
+	// round-trip time, and the difference from an in-memory impl is the
+	// cost of the network hop. With batch > 1 the reads go batch indices
+	// per round trip through ReadBatch, where the array has it. Path is
+	// "tcp" (or "tcp_batch") for a NetworkArrayImpl and "local" otherwise.
+	RegisterScenario(Scenario{
+		Name:   "NETWORK_BACKED",
+		Params: map[string]float64{"batch": 1},
+		OptIn:  true,
+		Ops:    func(N int) int { return 2 * min(10000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			path := "local"
+			if _, ok := arr.(*NetworkArrayImpl); ok {
+				path = "tcp"
+			}
+			return runBacked(ctx, arr, N, rng, "NETWORK_BACKED", path)
+		},
+	})
+	// NETWORK_BACKED against a Redis server at RedisAddr: cmdRun adds
+	// go_redis_int64 to the impls, so its WRITE-then-READ ns/op sits next to
+	// go_slice_int64's. Path is "redis" (or "redis_batch") for a
+	// RedisArrayImpl. Without a reachable server the scenario is skipped.
+	RegisterScenario(Scenario{
+		Name:        "REDIS_BACKED",
+		Params:      map[string]float64{"batch": 1},
+		OptIn:       true,
+		Unavailable: redisUnavailable,
+		Ops:         func(N int) int { return 2 * min(10000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			path := "local"
+			if _, ok := arr.(*RedisArrayImpl); ok {
+				path = "redis"
+			}
+			return runBacked(ctx, arr, N, rng, "REDIS_BACKED", path)
+		},
+	})
+	// NETWORK_BACKED's run for go_chan_int64, where each op is a channel
+	// round trip to the goroutine that owns the array instead of a TCP one.
+	// Path is "chan" for a ChannelArrayImpl and "local" otherwise.
+	RegisterScenario(Scenario{
+		Name:   "CHANNEL_BACKED",
+		Params: map[string]float64{"batch": 1},
+		OptIn:  true,
+		Ops:    func(N int) int { return 2 * min(10000, N) },
+		Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+			path := "local"
+			if _, ok := arr.(*ChannelArrayImpl); ok {
+				path = "chan"
+			}
+			return runBacked(ctx, arr, N, rng, "CHANNEL_BACKED", path)
+		},
+	})
+	// WRITE_SEQUENTIAL:bulk=0 from a thread pinned to local_node, with the
+	// array bound to local_node or to remote_node; the ratio of the two is
+	// the cost of the hop between nodes.
+	for _, remote := range []bool{false, true} {
+		remote := remote
+		name := "NUMA_LOCAL"
+		if remote {
+			name = "NUMA_REMOTE"
+		}
+		RegisterScenario(Scenario{
+			Name:        name,
+			Params:      map[string]float64{"local_node": 0, "remote_node": 1},
+			OptIn:       true,
+			Requires:    CapNUMA,
+			Unavailable: numaUnavailable,
+			Ops:         func(N int) int { return N },
+			Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+				local, node := int(ctx.Params["local_node"]), int(ctx.Params["local_node"])
+				if remote {
+					node = int(ctx.Params["remote_node"])
+				}
+				return runNUMA(arr, N, local, node)
+			},
+		})
+	}
+	// The same values either in an even pattern (density 0.5 alternates +1/-1,
+	// which the branch predictor learns) or shuffled, so the difference in
+	// ns/op is the cost of mispredicting the sign branch.
+	for _, shuffled := range []bool{false, true} {
+		shuffled := shuffled
+		name := "BRANCH_PREDICTABLE"
+		if shuffled {
+			name = "BRANCH_UNPREDICTABLE"
+		}
+		RegisterScenario(Scenario{
+			Name:   name,
+			Params: map[string]float64{"density": 0.5},
+			OptIn:  true,
+			Ops:    func(N int) int { return min(1000000, 10*N) },
+			Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+				return runBranches(arr, N, ctx.Params["density"], shuffled, rng)
+			},
+		})
+	}
+	// The same full-array sum with one accumulator and with four, unrolled
+	// by 4. gc does not auto-vectorize, so the unrolled loop gains only
+	// from the four independent dependency chains; a real vector path
+	// would need an assembly file per GOARCH.
+	for _, unroll := range []bool{false, true} {
+		unroll := unroll
+		name := "SIMD_COMPARISON_SCALAR"
+		if unroll {
+			name = "SIMD_COMPARISON_UNROLL4"
+		}
+		RegisterScenario(Scenario{
+			Name:     name,
+			OptIn:    true,
+			ReadOnly: true,
+			Ops:      func(N int) int { return scanPasses(N) * N },
+			Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+				if unroll {
+					return runScanSum(arr, N, sumUnroll4)
+				}
+				return runScanSum(arr, N, sumScalar)
+			},
+		})
+	}
+	// The same sum unrolled by 16 into 16 accumulators: against
+	// SIMD_COMPARISON_SCALAR, what independent chains buy a memory-bound
+	// loop. Every Read is a call, and Go's ABI saves no register across
+	// one, so the accumulators live in the frame between reads.
+	RegisterScenario(Scenario{
+		Name:     "REGISTER_PRESSURE",
+		OptIn:    true,
+		ReadOnly: true,
+		Ops:      func(N int) int { return scanPasses(N) * N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return runScanSum(arr, N, sumUnroll16)
+		},
+	})
+}
+
+func init() {
+	RegisterScenario(Scenario{
+		Name:  "BATCH_FILL",
+		OptIn: true,
+		Ops:   func(N int) int { return scanPasses(N) * N },
+		Run: func(_ Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return runFills(arr, N)
+		},
+	})
+	RegisterScenario(Scenario{
+		Name:  "TRUNCATE_REUSE",
+		OptIn: true,
+		Ops:   func(N int) int { return truncateCycles(N) * N },
+		Run: func(_ Context, arr Array, N int, rng *rand.Rand) RunResult {
+			return runTruncates(arr, N, rng)
+		},
+	})
+}
+
+// runFills bulk-assigns the whole array scanPasses(N) times, a different
+// value each time, through Fill when arr is a Filler and Init otherwise.
+// ns/op is per element assigned.
+func runFills(arr Array, N int) RunResult {
+	arr.Init(0)
+	M := scanPasses(N) * N
+	f, ok := arr.(Filler)
+	path := "init"
+	if ok {
+		path = "fill"
+	}
+	start := time.Now()
+	for p := scanPasses(N); p > 0; p-- {
+		if ok {
+			f.Fill(int64(p))
+		} else {
+			arr.Init(int64(p))
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	res := perElem(M, el)
+	res.Path = path
+	return res
+}
+
+// truncateCycles is how many write-then-truncate cycles TRUNCATE_REUSE runs.
+func truncateCycles(N int) int { return min(1000, scanPasses(N)) }
+
+// runTruncates repeatedly writes up to 1000 random elements and then empties
+// the array for reuse, through Reset when arr is a Resetter and Init(0)
+// otherwise. Only the truncation is timed; ns/op is per element dropped.
+func runTruncates(arr Array, N int, rng *rand.Rand) RunResult {
+	arr.Init(0)
+	C := truncateCycles(N)
+	idx := randIdx(rng, min(N, 1000), max(N, 1))
+	r, ok := arr.(Resetter)
+	path := "init"
+	if ok {
+		path = "reset"
+	}
+	var el int64
+	for c := 0; c < C; c++ {
+		for _, i := range idx {
+			arr.Write(i, int64(c+1))
+		}
+		start := time.Now()
+		if ok {
+			r.Reset()
+		} else {
+			arr.Init(0)
+		}
+		el += time.Since(start).Nanoseconds()
+	}
+	M := C * N
+	res := perElem(M, el)
+	res.Path = path
+	return res
+}
+
+// batchSizes are the LATENCY_AMORTIZATION batch sizes, one scenario each.
+var batchSizes = []int{1, 64, 512, 4096}
+
+func init() {
+	var names []string
+	for _, b := range batchSizes {
+		spec := ScenarioSpec{"LATENCY_AMORTIZATION", map[string]float64{"batch": float64(b)}}
+		names = append(names, spec.Name())
+		RegisterScenario(Scenario{
+			Name:   spec.Name(),
+			Kind:   spec.Kind,
+			Params: spec.Params,
+			OptIn:  true,
+			Ops:    func(N int) int { return min(1000000, 10*N) },
+			Run: func(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+				return runBatches(arr, N, int(ctx.Params["batch"]), rng)
+			},
+		})
+	}
+	scenarioGroups["LATENCY_AMORTIZATION"] = names
+}
+
+// runBatches writes the same volume as WRITE_RANDOM but batch consecutive
+// elements at a time from a random start, through WriteRange when arr has it
+// and a loop of Writes otherwise. The start is drawn inside the timed loop,
+// so batch 1 also pays one RNG call per element.
+func runBatches(arr Array, N, batch int, rng *rand.Rand) RunResult {
+	arr.Init(42)
+	M := min(1000000, 10*N)
+	batch = max(1, min(batch, N))
+	vals := make([]int64, batch)
+	for k := range vals {
+		vals[k] = int64(k)
+	}
+	rw, ok := arr.(RangeWriter)
+	start := time.Now()
+	for j := 0; j < M; j += batch {
+		vs := vals[:min(batch, M-j)]
+		i := rng.Intn(N - len(vs) + 1)
+		if ok {
+			rw.WriteRange(i, vs)
+		} else {
+			for k, v := range vs {
+				arr.Write(i+k, v)
+			}
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	res := perElem(M, el)
+	res.Path = "element"
+	if ok {
+		res.Path = "range"
+	}
+	return res
+}
+
+// burstPauses is the WRITE_BURST sweep of idle times between bursts, one
+// scenario each so every pause gets its own row.
+var burstPauses = []struct {
+	suffix string
+	ns     float64
+}{{"100NS", 100}, {"1US", 1e3}, {"10US", 1e4}, {"100US", 1e5}, {"1MS", 1e6}}
+
+func init() {
+	var names []string
+	for _, p := range burstPauses {
+		spec := ScenarioSpec{"WRITE_BURST", map[string]float64{"burst_size": 1000, "pause_ns": p.ns}}
+		names = append(names, spec.Name())
+		RegisterScenario(Scenario{
+			Name:   spec.Name(),
+			Kind:   spec.Kind,
+			Params: spec.Params,
+			OptIn:  true,
+			Ops:    func(N int) int { return min(1000000, 10*N) },
+			Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+				return runBursts(arr, N, int(ctx.Params["burst_size"]), time.Duration(ctx.Params["pause_ns"]))
+			},
+		})
+	}
+	scenarioGroups["WRITE_BURST"] = names
+}
+
+// runBursts writes sequentially (wrapping at N) in bursts of size elements
+// with a pause between them; only the bursts are timed. Relocations is the
+// pause, in ns.
+func runBursts(arr Array, N, size int, pause time.Duration) RunResult {
+	arr.Init(42)
+	M := min(1000000, 10*N)
+	size = max(size, 1)
+	var el int64
+	for j, i := 0, 0; j < M; {
+		start := time.Now()
+		for end := min(j+size, M); j < end; j++ {
+			arr.Write(i, int64(j))
+			if i++; i == N {
+				i = 0
+			}
+		}
+		el += time.Since(start).Nanoseconds()
+		if j < M {
+			time.Sleep(pause)
+		}
+	}
+	res := perElem(M, el)
+	ns := pause.Nanoseconds()
+	res.Relocations = &ns
+	return res
+}
+
+// CacheColorOffsets are the byte offsets of the CACHE_COLORING scenarios
+// registered by default; -cache-color-offset picks others.
+var CacheColorOffsets = []int{0, 64, 128}
+
+func init() {
+	var names []string
+	for _, off := range CacheColorOffsets {
+		names = append(names, cacheColoring(off))
+	}
+	scenarioGroups["CACHE_COLORING"] = names
+}
+
+// cacheColoring registers the CACHE_COLORING scenario for offset bytes
+// unless it exists, and returns its name, CACHE_COLORING_O<offset>. Each
+// writes a second array of the same impl alongside arr. For impls whose
+// storage can be placed (go_slice_int64, go_atomic_int64, go_rwmutex_int64)
+// both arrays are carved from one allocation with the second starting
+// offset_bytes past a multiple of cache_bytes from the first, so at offset
+// 0 element i of both maps to the same cache set; path is "colored", and
+// "unplaced" for impls that allocate their own. WRITE_SEQUENTIAL's writes
+// run over one array and then the other, and then over both at once,
+// element i of each before i+1. ns/op is the second pass's, per write, and
+// conversions_count is how much slower it was than the first, in percent
+// (negative when faster).
+func cacheColoring(offset int) string {
+	spec := ScenarioSpec{"CACHE_COLORING", map[string]float64{"offset_bytes": float64(offset), "cache_bytes": 32768}}
+	name := spec.Name()
+	if knownScenario(name) {
+		return name
+	}
+	RegisterScenario(Scenario{
+		Name:   name,
+		Kind:   spec.Kind,
+		Params: spec.Params,
+		OptIn:  true,
+		Ops:    func(N int) int { return 4 * N },
+		Run: func(ctx Context, arr Array, N int, _ *rand.Rand) RunResult {
+			return runCacheColoring(arr, N, int(ctx.Params["offset_bytes"]), int(ctx.Params["cache_bytes"]))
+		},
+	})
+	return name
+}
+
+// registerSpec registers the scenario spec names if a kind can build
+// scenarios on demand, as CACHE_COLORING does for -cache-color-offset, so an
+// isolate child knows every scenario its parent does.
+func registerSpec(spec ScenarioSpec) {
+	if spec.Kind == "CACHE_COLORING" {
+		cacheColoring(int(spec.Params["offset_bytes"]))
+	}
+}
+
+// ParseScenarioSpec parses ScenarioSpec.String's KIND:key=v,key=v.
+func ParseScenarioSpec(s string) (ScenarioSpec, error) {
+	kind, kvs, _ := strings.Cut(s, ":")
+	spec := ScenarioSpec{Kind: kind, Params: map[string]float64{}}
+	if kind == "" {
+		return spec, fmt.Errorf("scenario spec %q: no kind", s)
+	}
+	for _, kv := range strings.Split(kvs, ",") {
+		if kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		f, err := strconv.ParseFloat(v, 64)
+		if !ok || err != nil {
+			return spec, fmt.Errorf("scenario spec %q: want key=number, got %q", s, kv)
+		}
+		spec.Params[k] = f
+	}
+	return spec, nil
+}
+
+// placeable is implemented by arrays that can take over a caller-provided
+// backing slice of exactly N elements.
+type placeable interface {
+	place(a []int64)
+}
+
+func (s *SliceImpl) place(a []int64)           { s.A = a }
+func (s *AtomicSliceImpl) place(a []int64)     { s.A = a }
+func (s *ThreadSafeSliceImpl) place(a []int64) { s.A = a }
+
+// colorPair returns two N-element slices from one allocation: the first
+// starts on a cacheBytes boundary and the second offset bytes (rounded down
+// to an element) past the next boundary at or after the first's end.
+func colorPair(N, offset, cacheBytes int) (a, b []int64) {
+	align := max(8, cacheBytes/8*8)
+	span := (N*8 + align - 1) / align * align
+	buf := make([]int64, (2*align+span+offset)/8+N)
+	skip := (align - int(uintptr(unsafe.Pointer(&buf[0]))%uintptr(align))) % align / 8
+	lo := skip + span/8 + offset/8
+	return buf[skip : skip+N : skip+N], buf[lo : lo+N : lo+N]
+}
+
+func runCacheColoring(arr Array, N, offset, cacheBytes int) RunResult {
+	impl, ok := Lookup(arr.Name())
+	if !ok {
+		panic(fmt.Sprintf("CACHE_COLORING: %s is not a registered impl to build a second array of", arr.Name()))
+	}
+	twin := impl.New(N)
+	if c, ok := twin.(io.Closer); ok {
+		defer c.Close()
+	}
+	path := "unplaced"
+	pa, okA := arr.(placeable)
+	pb, okB := twin.(placeable)
+	if okA && okB {
+		a, b := colorPair(N, offset, cacheBytes)
+		pa.place(a)
+		pb.place(b)
+		path = "colored"
+	}
+	arr.Init(0)
+	twin.Init(0)
+	start := time.Now()
+	for i := 0; i < N; i++ {
+		arr.Write(i, int64(i))
+	}
+	for i := 0; i < N; i++ {
+		twin.Write(i, int64(i))
+	}
+	apart := time.Since(start).Nanoseconds()
+	start = time.Now()
+	for i := 0; i < N; i++ {
+		arr.Write(i, int64(-i))
+		twin.Write(i, int64(-i))
+	}
+	el := time.Since(start).Nanoseconds()
+	slower := int64(0)
+	if apart > 0 {
+		slower = int64(math.Round(float64(el-apart) / float64(apart) * 100))
+	}
+	res := perElem(2*N, el)
+	res.Path, res.Conversions = path, &slower
+	return res
+}
+
+// scanPasses is how many times the SIMD_COMPARISON scenarios sum the array,
+// enough for about 1M reads.
+func scanPasses(N int) int { return max(1, 1000000/max(N, 1)) }
+
+// runScanSum sums all N elements scanPasses(N) times with sum.
+func runScanSum(arr Array, N int, sum func(Array, int) int64) RunResult {
+	arr.Init(1)
+	M := scanPasses(N) * N
+	var s int64
+	start := time.Now()
+	for p := scanPasses(N); p > 0; p-- {
+		s += sum(arr, N)
+	}
+	el := time.Since(start).Nanoseconds()
+	consume(s)
+	return perElem(M, el)
+}
+
+func sumScalar(arr Array, N int) int64 {
+	var s int64
+	for i := 0; i < N; i++ {
+		s += arr.Read(i)
+	}
+	return s
+}
+
+func sumUnroll4(arr Array, N int) int64 {
+	var s0, s1, s2, s3 int64
+	i := 0
+	for ; i+4 <= N; i += 4 {
+		s0 += arr.Read(i)
+		s1 += arr.Read(i + 1)
+		s2 += arr.Read(i + 2)
+		s3 += arr.Read(i + 3)
+	}
+	for ; i < N; i++ {
+		s0 += arr.Read(i)
+	}
+	return s0 + s1 + s2 + s3
+}
+
+func sumUnroll16(arr Array, N int) int64 {
+	var s0, s1, s2, s3, s4, s5, s6, s7, s8, s9, s10, s11, s12, s13, s14, s15 int64
+	i := 0
+	for ; i+16 <= N; i += 16 {
+		s0 += arr.Read(i)
+		s1 += arr.Read(i + 1)
+		s2 += arr.Read(i + 2)
+		s3 += arr.Read(i + 3)
+		s4 += arr.Read(i + 4)
+		s5 += arr.Read(i + 5)
+		s6 += arr.Read(i + 6)
+		s7 += arr.Read(i + 7)
+		s8 += arr.Read(i + 8)
+		s9 += arr.Read(i + 9)
+		s10 += arr.Read(i + 10)
+		s11 += arr.Read(i + 11)
+		s12 += arr.Read(i + 12)
+		s13 += arr.Read(i + 13)
+		s14 += arr.Read(i + 14)
+		s15 += arr.Read(i + 15)
+	}
+	for ; i < N; i++ {
+		s0 += arr.Read(i)
+	}
+	return s0 + s1 + s2 + s3 + s4 + s5 + s6 + s7 + s8 + s9 + s10 + s11 + s12 + s13 + s14 + s15
+}
+
+// runBranches sets a density fraction of the elements to -1 and the rest to
+// 1, evenly spread or shuffled, then takes a sign-dependent branch on every
+// read. Each arm stores to memory so the compiler cannot turn the branch
+// into a conditional move.
+func runBranches(arr Array, N int, density float64, shuffled bool, rng *rand.Rand) RunResult {
+	arr.Init(1)
+	vals := make([]int64, N)
+	for i := range vals {
+		vals[i] = 1
+		if math.Floor(float64(i+1)*density) > math.Floor(float64(i)*density) {
+			vals[i] = -1
+		}
+	}
+	if shuffled {
+		rng.Shuffle(N, func(i, j int) { vals[i], vals[j] = vals[j], vals[i] })
+	}
+	for i, v := range vals {
+		if v != 1 {
+			arr.Write(i, v)
+		}
+	}
+	M := min(1000000, 10*N)
+	var acc [2]int64
+	start := time.Now()
+	for k, i := 0, 0; k < M; k++ {
+		if v := arr.Read(i); v > 0 {
+			acc[0] += v * 2
+		} else {
+			acc[1] -= v
+		}
+		if i++; i == N {
+			i = 0
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	consume(acc[0] ^ acc[1])
+	return perElem(M, el)
+}
+
+// writeFaults reports whether arr.Write(0, ...) faults.
+func writeFaults(arr Array) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() { faulted = recover() != nil }()
+	arr.Write(0, arr.Read(0))
+	return false
+}
+
+// runStrided cycles reads over count elements stride apart (as many as fit
+// in N).
+func runStrided(arr Array, N, stride, count int) RunResult {
+	arr.Init(1)
+	var idx []int
+	for j := 0; j < count && j*stride < N; j++ {
+		idx = append(idx, j*stride)
+	}
+	M := min(1000000, 10*N)
+	if len(idx) == 0 {
+		M = 0
+	}
+	start := time.Now()
+	var s int64 = 0
+	for k, j := 0, 0; k < M; k++ {
+		s ^= arr.Read(idx[j])
+		if j++; j == len(idx) {
+			j = 0
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	consume(s)
+	return perElem(M, el)
+}
+
+// hotspotPhases is ADVERSARIAL_HOTSPOT: hot_write_pct percent of the
+// writes to the first hotspot_pct percent of the indices, the rest
+// anywhere. Its original loop drew each write's side, index and value in
+// turn inside the timer; a TimedDraws phase does the same, so both the
+// call sequence and what is timed are kept.
+func hotspotPhases(p map[string]float64) []Phase {
+	return []Phase{{Init: true, Dist: DistHotspot, HotspotPct: p["hotspot_pct"], HotPct: p["hot_write_pct"], TimedDraws: true}}
+}
+
+// hotDraw reports whether a DistHotspot op goes to the hot region, hotPct
+// percent of the time. At 50%, ADVERSARIAL_HOTSPOT's default, it draws
+// Intn(2), as that scenario always did, so default runs keep their
+// historical call sequence; other whole percentages draw Intn(100), as
+// the scenario's hot_write_pct did, and fractional ones a Float64.
+func hotDraw(rng *rand.Rand, hotPct float64) bool {
+	switch {
+	case hotPct == 50:
+		return rng.Intn(2) == 0
+	case hotPct == math.Trunc(hotPct):
+		return rng.Intn(100) < int(hotPct)
+	}
+	return rng.Float64()*100 < hotPct
+}
+
+// mixedPhases is MIXED: reads and writes interleaved, read_pct percent of
+// them reads.
+func mixedPhases(p map[string]float64) []Phase {
+	return []Phase{{Init: true, InitValue: 42, Op: PhaseMixed, ReadPct: int(p["read_pct"])}}
+}
+
+// runSnapshotChurn is SNAPSHOT_CHURN. It shadows the live array in a
+// slice, and the snapshot in the values the burst overwrote.
+func runSnapshotChurn(ctx Context, arr Array, N int, rng *rand.Rand) RunResult {
+	sn, ok := arr.(Snapshotter)
+	if !ok {
+		panic(arr.Name() + " cannot take snapshots")
+	}
+	arr.Init(0)
+	// There is no index to draw in an empty array.
+	if N == 0 {
+		return perElem(0, 0)
+	}
+	rounds := max(1, int(ctx.Params["snapshots"]))
+	burst := max(1, min(1000000, N)/rounds)
+	live := make([]int64, N)
+	undo := map[int]int64{}
+	vals := make([]int64, burst)
+	got := make([]int64, burst)
+	var opsNs, snapNs, bad int64
+	take := func() Array {
+		start := time.Now()
+		s := sn.Snapshot()
+		snapNs += time.Since(start).Nanoseconds()
+		return s
+	}
+	snap := take()
+	for r := 0; r < rounds; r++ {
+		widx, ridx := randIdx(rng, burst, N), randIdx(rng, burst, N)
+		for k := range vals {
+			vals[k] = randVal(rng)
+		}
+		start := time.Now()
+		for k, j := range widx {
+			arr.Write(j, vals[k])
+		}
+		for k, j := range ridx {
+			if k%2 == 0 {
+				got[k] = snap.Read(j)
+			} else {
+				got[k] = arr.Read(j)
+			}
+		}
+		opsNs += time.Since(start).Nanoseconds()
+		for k, j := range widx {
+			if _, ok := undo[j]; !ok {
+				undo[j] = live[j]
+			}
+			live[j] = vals[k]
+		}
+		for k, j := range ridx {
+			want, from := live[j], "live array"
+			if old, ok := undo[j]; ok && k%2 == 0 {
+				want = old
+			}
+			if k%2 == 0 {
+				from = "snapshot"
+			}
+			if got[k] != want {
+				if bad == 0 {
+					fmt.Fprintf(os.Stderr, "%s %s N=%d: round %d: %s Read(%d) = %d, want %d\n", ctx.Scenario, arr.Name(), N, r, from, j, got[k], want)
+				}
+				bad++
+			}
+		}
+		if c, ok := snap.(io.Closer); ok {
+			c.Close()
+		}
+		clear(undo)
+		snap = take()
+	}
+	if c, ok := snap.(io.Closer); ok {
+		c.Close()
+	}
+	res := timedOps(2*rounds*burst, opsNs)
+	res.InitNs, res.Conversions = snapNs, &bad
+	return res
+}
+
+// runBacked is NETWORK_BACKED, REDIS_BACKED and CHANNEL_BACKED: min(10000, N) random writes
+// of j+1, then reads of the same indices, batch at a time through ReadBatch
+// where the array has it and batch > 1, each checked.
+func runBacked(ctx Context, arr Array, N int, rng *rand.Rand, scenario, path string) RunResult {
+	arr.Init(0)
+	M := min(10000, N)
+	idx := ctx.Buffers.Idx(rng, M, N)
+	batch := max(1, int(ctx.Params["batch"]))
+	br, batched := arr.(interface{ ReadBatch(idx []int, out []int64) })
+	batched = batched && batch > 1
+	if batched {
+		path += "_batch"
+	}
+	out := make([]int64, batch)
+	start := time.Now()
+	for _, j := range idx {
+		arr.Write(j, int64(j)+1)
+	}
+	for lo := 0; lo < M; lo += batch {
+		chunk, vals := idx[lo:min(lo+batch, M)], out[:min(batch, M-lo)]
+		if batched {
+			br.ReadBatch(chunk, vals)
+		} else {
+			for k, j := range chunk {
+				vals[k] = arr.Read(j)
+			}
+		}
+		for k, j := range chunk {
+			if vals[k] != int64(j)+1 {
+				panic(fmt.Sprintf("%s: Read(%d) = %d, want %d", scenario, j, vals[k], j+1))
+			}
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	res := timedOps(2*M, el)
+	res.Path = path
+	return res
+}
+
+// gossipMsg is one GOSSIP update: indices of the sender's partition and
+// the values it wrote there.
+type gossipMsg struct {
+	idx  []int
+	vals []int64
+}
+
+// runGossip is GOSSIP. Owners write increasing values, so a peer's Read of
+// a gossiped index must return at least the value in the message. A send
+// to a full inbox is dropped, as a gossip protocol would under backpressure,
+// so no goroutine ever blocks on another.
+func runGossip(arr Array, N, G, every, sample int, dur time.Duration, rng *rand.Rand) RunResult {
+	arr.Init(0)
+	if N == 0 {
+		return RunResult{}
+	}
+	G, every, sample = max(1, min(G, N)), max(1, every), max(1, sample)
+	inbox := make([]chan gossipMsg, G)
+	for g := range inbox {
+		inbox[g] = make(chan gossipMsg, 64)
+	}
+	seeds := make([]int64, G)
+	for g := range seeds {
+		seeds[g] = rng.Int63()
+	}
+	var ops, msgs atomic.Int64
+	var once sync.Once
+	bad := ""
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(dur)
+	for g := 0; g < G; g++ {
+		g, lo, hi := g, g*N/G, (g+1)*N/G
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seeds[g]))
+			own := make([]int64, hi-lo)
+			var changed []int
+			var seq, done int64
+			for time.Now().Before(deadline) {
+				for k := 0; k < every; k++ {
+					i := lo + r.Intn(hi-lo)
+					seq++
+					arr.Write(i, seq)
+					own[i-lo] = seq
+					changed = append(changed, i)
+				}
+				done += int64(every)
+				if G > 1 {
+					r.Shuffle(len(changed), func(a, b int) { changed[a], changed[b] = changed[b], changed[a] })
+					m := gossipMsg{idx: append([]int(nil), changed[:min(sample, len(changed))]...)}
+					for _, i := range m.idx {
+						m.vals = append(m.vals, own[i-lo])
+					}
+					peer := (g + 1 + r.Intn(G-1)) % G
+					select {
+					case inbox[peer] <- m:
+						msgs.Add(1)
+					default:
+					}
+				}
+				changed = changed[:0]
+				for drained := false; !drained; {
+					select {
+					case m := <-inbox[g]:
+						for k, i := range m.idx {
+							if got := arr.Read(i); got < m.vals[k] {
+								once.Do(func() { bad = fmt.Sprintf("GOSSIP: Read(%d) = %d after a peer gossiped %d", i, got, m.vals[k]) })
+							}
+						}
+						done += int64(len(m.idx))
+					default:
+						drained = true
+					}
+				}
+			}
+			ops.Add(done)
+		}()
+	}
+	wg.Wait()
+	el := time.Since(start).Nanoseconds()
+	if bad != "" {
+		panic(bad)
+	}
+	res := timedOps(int(ops.Load()), el)
+	delivered := msgs.Load()
+	res.Conversions = &delivered
+	return res
+}
+
+// numaUnavailable is the NUMA scenarios' Unavailable: they need a second
+// node to bind to.
+func numaUnavailable() string {
+	if n := len(numaNodes()); n < 2 {
+		return fmt.Sprintf("needs two NUMA nodes, found %d", n)
+	}
+	return ""
+}
+
+// runNUMA binds arr to node and writes every element in order from a
+// thread pinned to local.
+func runNUMA(arr Array, N, local, node int) RunResult {
+	b, ok := arr.(NodeBinder)
+	if !ok {
+		panic(arr.Name() + " cannot bind its memory to a NUMA node")
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	restore, err := pinToNode(local)
+	if err != nil {
+		panic(err)
+	}
+	defer restore()
+	if err := b.BindNode(node); err != nil {
+		panic(err)
+	}
+	arr.Init(0)
+	start := time.Now()
+	for i := 0; i < N; i++ {
+		arr.Write(i, int64(i))
+	}
+	el := time.Since(start).Nanoseconds()
+	return timedOps(N, el)
+}
@@ -0,0 +1,37 @@
+package inplacebench
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestScenarioGolden replays every scenario in scenarioGolden against a
+// recording array and checks the hash of its call sequence, so a change to
+// a scenario's RNG use fails go test as well as -selftest.
+func TestScenarioGolden(t *testing.T) {
+	var names []string
+	for name := range scenarioGolden {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			if got, want := scenarioChecksum(name, 1000, 42), scenarioGolden[name]; got != want {
+				t.Fatalf("call sequence checksum %#x, want %#x", got, want)
+			}
+		})
+	}
+}
+
+// TestBurstPause checks that each WRITE_BURST scenario reports its pause,
+// in ns, as its relocations_count.
+func TestBurstPause(t *testing.T) {
+	slice, _ := Lookup("go_slice_int64")
+	for _, p := range burstPauses[:2] {
+		res := runBursts(slice.New(10), 10, 4, time.Duration(p.ns))
+		if res.Relocations == nil || *res.Relocations != int64(p.ns) || res.Ops != 100 {
+			t.Fatalf("WRITE_BURST_%s: %d ops, relocations %v, want 100 and %g", p.suffix, res.Ops, res.Relocations, p.ns)
+		}
+	}
+}
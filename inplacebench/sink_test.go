@@ -0,0 +1,68 @@
+package inplacebench
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestNothingElided builds the package with -gcflags='-m=2 -S' and checks
+// that consume is never inlined and that every read loop of the phase
+// engine, through the interface and the static copies, still has code
+// attributed to it: had the compiler proved a loop's reads dead and
+// dropped it, READ_UNWRITTEN would time an empty loop.
+func TestNothingElided(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the package")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool:", err)
+	}
+	out, err := exec.Command(gobin, "build", "-gcflags=-m=2 -S", ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%.2000s", err, out)
+	}
+	build := string(out)
+	if !strings.Contains(build, "cannot inline consume: marked go:noinline") {
+		t.Error("the compiler did not report consume as go:noinline")
+	}
+	if m := regexp.MustCompile(`(?m)^.*(can inline consume|inlining call to consume)\b.*$`).FindString(build); m != "" {
+		t.Errorf("consume was inlined: %s", m)
+	}
+	for _, file := range []string{"phases.go", "static.go"} {
+		lines := readLoopLines(t, file)
+		if len(lines) == 0 {
+			t.Fatalf("%s: no read loops found", file)
+		}
+		abs, _ := filepath.Abs(file)
+		for _, n := range lines {
+			if !strings.Contains(build, fmt.Sprintf("(%s:%d)", abs, n)) {
+				t.Errorf("%s:%d: the read loop has no code", file, n)
+			}
+		}
+	}
+}
+
+// readLoopLines are the lines of file that fold a Read into x, the body
+// of each read loop.
+func readLoopLines(t *testing.T, file string) []int {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []int
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if strings.Contains(sc.Text(), "x ^= arr.Read(") {
+			lines = append(lines, n)
+		}
+	}
+	return lines
+}
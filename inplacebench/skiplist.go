@@ -0,0 +1,168 @@
+package inplacebench
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+const skipMaxLevel = 24
+
+type skipNode struct {
+	key  int
+	val  atomic.Int64
+	next []atomic.Pointer[skipNode]
+}
+
+// SkipListImpl is a lock-free skip list holding only written indices; reads of
+// other indices return the Init value. Writes update an existing node's value
+// atomically or insert a node by CAS-linking it bottom-up, level by level, so
+// concurrent writers never block each other. There is no deletion: Init swaps
+// in a fresh list. Tower heights come from a hashed insert counter (p = 1/2),
+// which keeps single-threaded runs deterministic.
+type SkipListImpl struct {
+	N    int
+	Def  int64
+	head *skipNode
+	ctr  atomic.Uint64
+}
+
+func NewSkipListImpl(n int) *SkipListImpl {
+	s := &SkipListImpl{N: n}
+	s.head = &skipNode{key: -1, next: make([]atomic.Pointer[skipNode], skipMaxLevel)}
+	return s
+}
+func (s *SkipListImpl) Name() string { return "go_skiplist_int64" }
+func (s *SkipListImpl) Len() int     { return s.N }
+func (s *SkipListImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Def = v
+	s.head = &skipNode{key: -1, next: make([]atomic.Pointer[skipNode], skipMaxLevel)}
+	return time.Since(start).Nanoseconds()
+}
+
+// find fills preds/succs with the nodes around key at every level and returns
+// the number of nodes visited.
+func (s *SkipListImpl) find(key int, preds, succs *[skipMaxLevel]*skipNode) int {
+	steps := 0
+	x := s.head
+	for l := skipMaxLevel - 1; l >= 0; l-- {
+		nx := x.next[l].Load()
+		for nx != nil && nx.key < key {
+			x = nx
+			nx = x.next[l].Load()
+			steps++
+		}
+		preds[l], succs[l] = x, nx
+	}
+	return steps
+}
+
+func (s *SkipListImpl) lookup(key int) *skipNode {
+	x := s.head
+	for l := skipMaxLevel - 1; l >= 0; l-- {
+		nx := x.next[l].Load()
+		for nx != nil && nx.key < key {
+			x = nx
+			nx = x.next[l].Load()
+		}
+		if nx != nil && nx.key == key {
+			return nx
+		}
+	}
+	return nil
+}
+
+func (s *SkipListImpl) Read(i int) int64 {
+	if n := s.lookup(i); n != nil {
+		return n.val.Load()
+	}
+	return s.Def
+}
+
+func (s *SkipListImpl) Write(i int, v int64) {
+	if n := s.lookup(i); n != nil {
+		n.val.Store(v)
+		return
+	}
+	h := s.ctr.Add(1) * 0x9E3779B97F4A7C15
+	level := min(skipMaxLevel, bits.TrailingZeros64(h>>1|1<<62)+1)
+	n := &skipNode{key: i, next: make([]atomic.Pointer[skipNode], level)}
+	n.val.Store(v)
+	var preds, succs [skipMaxLevel]*skipNode
+	for {
+		s.find(i, &preds, &succs)
+		if succs[0] != nil && succs[0].key == i {
+			succs[0].val.Store(v)
+			return
+		}
+		for l := 0; l < level; l++ {
+			n.next[l].Store(succs[l])
+		}
+		if preds[0].next[0].CompareAndSwap(succs[0], n) {
+			break
+		}
+	}
+	for l := 1; l < level; l++ {
+		for !preds[l].next[l].CompareAndSwap(succs[l], n) {
+			s.find(i, &preds, &succs)
+			n.next[l].Store(succs[l])
+		}
+	}
+}
+
+// CheckedRead and CheckedWrite bound the key, which the list itself would
+// insert whatever its value.
+func (s *SkipListImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *SkipListImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// ForEach walks the bottom level, which holds the written indices in order.
+// Nodes linked in by concurrent writers during the walk may or may not be
+// visited.
+func (s *SkipListImpl) ForEach(fn func(i int, v int64) bool) {
+	for x := s.head.next[0].Load(); x != nil; x = x.next[0].Load() {
+		if !fn(x.key, x.val.Load()) {
+			return
+		}
+	}
+}
+
+// Stats reports the mean search path length (nodes visited) over up to 1024
+// evenly spaced keys as relocations. It is sampled after the run so the
+// timed loop carries no counters.
+func (s *SkipListImpl) Stats() (relocations, conversions int64) {
+	if s.N == 0 {
+		return 0, 0
+	}
+	samples := min(s.N, 1024)
+	var preds, succs [skipMaxLevel]*skipNode
+	total := 0
+	for k := 0; k < samples; k++ {
+		total += s.find(k*s.N/samples, &preds, &succs) + 1
+	}
+	return int64(total / samples), 0
+}
+
+// MemoryFootprint walks the bottom level and adds up the nodes and their
+// towers, the head included.
+func (s *SkipListImpl) MemoryFootprint() int64 {
+	node := int64(unsafe.Sizeof(skipNode{}))
+	ptr := int64(unsafe.Sizeof(atomic.Pointer[skipNode]{}))
+	n := int64(0)
+	for x := s.head; x != nil; x = x.next[0].Load() {
+		n += node + int64(cap(x.next))*ptr
+	}
+	return n
+}
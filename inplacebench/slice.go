@@ -0,0 +1,482 @@
+package inplacebench
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+type SliceImpl struct {
+	N   int
+	A   []int64
+	def int64
+}
+
+func NewSliceImpl(n int) *SliceImpl { return &SliceImpl{N: n, A: make([]int64, n)} }
+func (s *SliceImpl) Name() string   { return "go_slice_int64" }
+func (s *SliceImpl) Len() int       { return s.N }
+func (s *SliceImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Fill(v)
+	elapsed := time.Since(start)
+	return elapsed.Nanoseconds()
+}
+func (s *SliceImpl) Fill(v int64) {
+	s.def = v
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+}
+func (s *SliceImpl) Reset()                           { clear(s.A); s.def = 0 }
+func (s *SliceImpl) Delete(i int)                     { s.A[i] = s.def }
+func (s *SliceImpl) Read(i int) int64                 { return s.A[i] }
+func (s *SliceImpl) Write(i int, v int64)             { s.A[i] = v }
+func (s *SliceImpl) ReadRange(start int, out []int64) { copy(out, s.A[start:start+len(out)]) }
+func (s *SliceImpl) WriteRange(start int, vals []int64) {
+	copy(s.A[start:start+len(vals)], vals)
+}
+
+// RunOps is the batch path: one loop over ops with the slice in a local,
+// the access a caller that inlined it would make.
+func (s *SliceImpl) RunOps(ops []Op) int64 {
+	a := s.A
+	var x int64
+	for _, op := range ops {
+		if op.Kind == OpRead {
+			x ^= a[op.I]
+		} else {
+			a[op.I] = op.V
+		}
+	}
+	return x
+}
+func (s *SliceImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.A[i], nil
+}
+func (s *SliceImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.A[i] = v
+	return nil
+}
+func (s *SliceImpl) ForEach(fn func(i int, v int64) bool) {
+	for i, v := range s.A {
+		if !fn(i, v) {
+			return
+		}
+	}
+}
+
+// Snapshot copies every element.
+func (s *SliceImpl) Snapshot() Array {
+	return &SliceImpl{N: s.N, A: append([]int64(nil), s.A...), def: s.def}
+}
+
+// SliceOf is SliceImpl for element type T, the port RegisterOf gives the
+// typed path.
+type SliceOf[T any] struct {
+	N int
+	A []T
+}
+
+func NewSliceOf[T any](n int) *SliceOf[T] { return &SliceOf[T]{N: n, A: make([]T, n)} }
+func (s *SliceOf[T]) Name() string        { return "go_slice_" + elemName[T]() }
+func (s *SliceOf[T]) Len() int            { return s.N }
+func (s *SliceOf[T]) Init(v T) int64 {
+	start := time.Now()
+	s.Fill(v)
+	return time.Since(start).Nanoseconds()
+}
+func (s *SliceOf[T]) Fill(v T) {
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+}
+func (s *SliceOf[T]) Read(i int) T     { return s.A[i] }
+func (s *SliceOf[T]) Write(i int, v T) { s.A[i] = v }
+
+// AtomicSliceImpl accesses a []int64 only through sync/atomic loads and stores.
+type AtomicSliceImpl struct {
+	N   int
+	A   []int64
+	def atomic.Int64
+}
+
+func NewAtomicSliceImpl(n int) *AtomicSliceImpl { return &AtomicSliceImpl{N: n, A: make([]int64, n)} }
+func (s *AtomicSliceImpl) Name() string         { return "go_atomic_int64" }
+func (s *AtomicSliceImpl) Len() int             { return s.N }
+func (s *AtomicSliceImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Fill(v)
+	return time.Since(start).Nanoseconds()
+}
+func (s *AtomicSliceImpl) Fill(v int64) {
+	s.def.Store(v)
+	for i := 0; i < s.N; i++ {
+		atomic.StoreInt64(&s.A[i], v)
+	}
+}
+func (s *AtomicSliceImpl) Read(i int) int64     { return atomic.LoadInt64(&s.A[i]) }
+func (s *AtomicSliceImpl) Write(i int, v int64) { atomic.StoreInt64(&s.A[i], v) }
+func (s *AtomicSliceImpl) Delete(i int)         { atomic.StoreInt64(&s.A[i], s.def.Load()) }
+func (s *AtomicSliceImpl) CompareAndSwap(i int, old, new int64) bool {
+	return atomic.CompareAndSwapInt64(&s.A[i], old, new)
+}
+func (s *AtomicSliceImpl) ForEach(fn func(i int, v int64) bool) {
+	for i := range s.A {
+		if !fn(i, atomic.LoadInt64(&s.A[i])) {
+			return
+		}
+	}
+}
+
+// Snapshot copies with atomic loads, so each element is a value some Write
+// stored; under concurrent writes the copy is not one point in time.
+func (s *AtomicSliceImpl) Snapshot() Array {
+	c := NewAtomicSliceImpl(s.N)
+	c.def.Store(s.def.Load())
+	for i := range s.A {
+		c.A[i] = atomic.LoadInt64(&s.A[i])
+	}
+	return c
+}
+
+// ThreadSafeSliceImpl guards a []int64 with a sync.RWMutex: reads share the
+// lock, writes and Init take it exclusively.
+type ThreadSafeSliceImpl struct {
+	N   int
+	A   []int64
+	def int64
+	mu  sync.RWMutex
+}
+
+func NewThreadSafeSliceImpl(n int) *ThreadSafeSliceImpl {
+	return &ThreadSafeSliceImpl{N: n, A: make([]int64, n)}
+}
+func (s *ThreadSafeSliceImpl) Name() string { return "go_rwmutex_int64" }
+func (s *ThreadSafeSliceImpl) Len() int     { return s.N }
+func (s *ThreadSafeSliceImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Fill(v)
+	return time.Since(start).Nanoseconds()
+}
+func (s *ThreadSafeSliceImpl) Fill(v int64) {
+	s.mu.Lock()
+	s.def = v
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+	s.mu.Unlock()
+}
+func (s *ThreadSafeSliceImpl) Read(i int) int64 {
+	s.mu.RLock()
+	v := s.A[i]
+	s.mu.RUnlock()
+	return v
+}
+func (s *ThreadSafeSliceImpl) Write(i int, v int64) {
+	s.mu.Lock()
+	s.A[i] = v
+	s.mu.Unlock()
+}
+func (s *ThreadSafeSliceImpl) Delete(i int) {
+	s.mu.Lock()
+	s.A[i] = s.def
+	s.mu.Unlock()
+}
+
+// CompareAndSwap holds the write lock for the compare and the store, the
+// way a lock-based array makes a conditional update atomic.
+func (s *ThreadSafeSliceImpl) CompareAndSwap(i int, old, new int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.A[i] != old {
+		return false
+	}
+	s.A[i] = new
+	return true
+}
+
+// ThreadSafeSliceOf is ThreadSafeSliceImpl for element type T.
+type ThreadSafeSliceOf[T any] struct {
+	N  int
+	A  []T
+	mu sync.RWMutex
+}
+
+func NewThreadSafeSliceOf[T any](n int) *ThreadSafeSliceOf[T] {
+	return &ThreadSafeSliceOf[T]{N: n, A: make([]T, n)}
+}
+func (s *ThreadSafeSliceOf[T]) Name() string { return "go_rwmutex_" + elemName[T]() }
+func (s *ThreadSafeSliceOf[T]) Len() int     { return s.N }
+func (s *ThreadSafeSliceOf[T]) Init(v T) int64 {
+	start := time.Now()
+	s.mu.Lock()
+	for i := 0; i < s.N; i++ {
+		s.A[i] = v
+	}
+	s.mu.Unlock()
+	return time.Since(start).Nanoseconds()
+}
+func (s *ThreadSafeSliceOf[T]) Read(i int) T {
+	s.mu.RLock()
+	v := s.A[i]
+	s.mu.RUnlock()
+	return v
+}
+func (s *ThreadSafeSliceOf[T]) Write(i int, v T) {
+	s.mu.Lock()
+	s.A[i] = v
+	s.mu.Unlock()
+}
+
+// Snapshot copies under the read lock.
+func (s *ThreadSafeSliceImpl) Snapshot() Array {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &ThreadSafeSliceImpl{N: s.N, A: append([]int64(nil), s.A...), def: s.def}
+}
+
+// CheckedRead and CheckedWrite check the index before taking the lock, so a
+// bad one cannot leave it held the way a panicking Read or Write would.
+func (s *ThreadSafeSliceImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *ThreadSafeSliceImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// ForEach holds the read lock for the whole walk, so fn must not Write.
+func (s *ThreadSafeSliceImpl) ForEach(fn func(i int, v int64) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i, v := range s.A {
+		if !fn(i, v) {
+			return
+		}
+	}
+}
+
+// ReadRange and WriteRange take the lock once for the whole range.
+func (s *ThreadSafeSliceImpl) ReadRange(start int, out []int64) {
+	s.mu.RLock()
+	copy(out, s.A[start:start+len(out)])
+	s.mu.RUnlock()
+}
+func (s *ThreadSafeSliceImpl) WriteRange(start int, vals []int64) {
+	s.mu.Lock()
+	copy(s.A[start:start+len(vals)], vals)
+	s.mu.Unlock()
+}
+
+// ReadWriteLockFreeImpl is a per-element seqlock. A writer moves the element's
+// sequence number from even to odd with a CAS (excluding other writers),
+// stores the value, then makes it even again; a reader retries until it sees
+// the same even sequence before and after loading the value. The value is
+// loaded and stored with sync/atomic as the Go memory model requires for data
+// shared without a lock; on 64-bit targets these are plain moves.
+type ReadWriteLockFreeImpl struct {
+	N   int
+	seq []uint32
+	A   []int64
+}
+
+func NewReadWriteLockFreeImpl(n int) *ReadWriteLockFreeImpl {
+	return &ReadWriteLockFreeImpl{N: n, seq: make([]uint32, n), A: make([]int64, n)}
+}
+func (s *ReadWriteLockFreeImpl) Name() string { return "go_seqlock_int64" }
+func (s *ReadWriteLockFreeImpl) Len() int     { return s.N }
+func (s *ReadWriteLockFreeImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := 0; i < s.N; i++ {
+		s.Write(i, v)
+	}
+	return time.Since(start).Nanoseconds()
+}
+func (s *ReadWriteLockFreeImpl) Read(i int) int64 {
+	for {
+		s0 := atomic.LoadUint32(&s.seq[i])
+		if s0&1 != 0 {
+			continue
+		}
+		v := atomic.LoadInt64(&s.A[i])
+		if atomic.LoadUint32(&s.seq[i]) == s0 {
+			return v
+		}
+	}
+}
+func (s *ReadWriteLockFreeImpl) Write(i int, v int64) {
+	for {
+		s0 := atomic.LoadUint32(&s.seq[i])
+		if s0&1 == 0 && atomic.CompareAndSwapUint32(&s.seq[i], s0, s0+1) {
+			break
+		}
+	}
+	atomic.StoreInt64(&s.A[i], v)
+	atomic.AddUint32(&s.seq[i], 1)
+}
+
+type shard struct {
+	mu  sync.Mutex
+	a   []int64
+	def int64
+}
+
+// ShardedSliceImpl splits the index space into S contiguous shards, each
+// guarded by its own sync.Mutex.
+type ShardedSliceImpl struct {
+	N, S   int
+	size   int
+	shards []shard
+}
+
+func NewShardedSliceImpl(n, shards int) *ShardedSliceImpl {
+	if shards < 1 {
+		shards = 1
+	}
+	size := (n + shards - 1) / shards
+	if size == 0 {
+		size = 1
+	}
+	s := &ShardedSliceImpl{N: n, S: shards, size: size, shards: make([]shard, shards)}
+	for k := range s.shards {
+		lo := min(n, k*size)
+		s.shards[k].a = make([]int64, min(n, lo+size)-lo)
+	}
+	return s
+}
+func (s *ShardedSliceImpl) Name() string { return fmt.Sprintf("go_sharded_S%d_int64", s.S) }
+func (s *ShardedSliceImpl) Len() int     { return s.N }
+func (s *ShardedSliceImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Fill(v)
+	return time.Since(start).Nanoseconds()
+}
+
+// Fill locks one shard at a time, so concurrent readers see each shard
+// either before or after it.
+func (s *ShardedSliceImpl) Fill(v int64) {
+	for k := range s.shards {
+		sh := &s.shards[k]
+		sh.mu.Lock()
+		sh.def = v
+		for i := range sh.a {
+			sh.a[i] = v
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// Snapshot copies one shard at a time under its lock, like Fill.
+func (s *ShardedSliceImpl) Snapshot() Array {
+	c := &ShardedSliceImpl{N: s.N, S: s.S, size: s.size, shards: make([]shard, len(s.shards))}
+	for k := range s.shards {
+		sh := &s.shards[k]
+		sh.mu.Lock()
+		c.shards[k].a = append([]int64(nil), sh.a...)
+		c.shards[k].def = sh.def
+		sh.mu.Unlock()
+	}
+	return c
+}
+func (s *ShardedSliceImpl) Read(i int) int64 {
+	sh := &s.shards[i/s.size]
+	sh.mu.Lock()
+	v := sh.a[i%s.size]
+	sh.mu.Unlock()
+	return v
+}
+func (s *ShardedSliceImpl) Write(i int, v int64) {
+	sh := &s.shards[i/s.size]
+	sh.mu.Lock()
+	sh.a[i%s.size] = v
+	sh.mu.Unlock()
+}
+
+// Delete writes back the value of the last Fill of i's shard, which is the
+// array's unless a Fill is still running.
+func (s *ShardedSliceImpl) Delete(i int) {
+	sh := &s.shards[i/s.size]
+	sh.mu.Lock()
+	sh.a[i%s.size] = sh.def
+	sh.mu.Unlock()
+}
+
+// CheckedRead and CheckedWrite check the index before taking a shard lock.
+func (s *ShardedSliceImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *ShardedSliceImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// ForEach walks shard by shard and holds each shard's lock while it visits
+// that shard, so fn must not Write.
+func (s *ShardedSliceImpl) ForEach(fn func(i int, v int64) bool) {
+	for k := range s.shards {
+		sh := &s.shards[k]
+		sh.mu.Lock()
+		for j, v := range sh.a {
+			if !fn(k*s.size+j, v) {
+				sh.mu.Unlock()
+				return
+			}
+		}
+		sh.mu.Unlock()
+	}
+}
+
+// ReadRange and WriteRange copy shard by shard, holding each shard's lock
+// for its part of the range.
+func (s *ShardedSliceImpl) ReadRange(start int, out []int64) {
+	for len(out) > 0 {
+		sh := &s.shards[start/s.size]
+		sh.mu.Lock()
+		n := copy(out, sh.a[start%s.size:])
+		sh.mu.Unlock()
+		start, out = start+n, out[n:]
+	}
+}
+func (s *ShardedSliceImpl) WriteRange(start int, vals []int64) {
+	for len(vals) > 0 {
+		sh := &s.shards[start/s.size]
+		sh.mu.Lock()
+		n := copy(sh.a[start%s.size:], vals)
+		sh.mu.Unlock()
+		start, vals = start+n, vals[n:]
+	}
+}
+
+func (s *SliceImpl) MemoryFootprint() int64           { return int64(cap(s.A)) * 8 }
+func (s *AtomicSliceImpl) MemoryFootprint() int64     { return int64(cap(s.A)) * 8 }
+func (s *ThreadSafeSliceImpl) MemoryFootprint() int64 { return int64(cap(s.A)) * 8 }
+func (s *ReadWriteLockFreeImpl) MemoryFootprint() int64 {
+	return int64(cap(s.A))*8 + int64(cap(s.seq))*4
+}
+func (s *ShardedSliceImpl) MemoryFootprint() int64 {
+	n := int64(len(s.shards)) * int64(unsafe.Sizeof(shard{}))
+	for k := range s.shards {
+		n += int64(cap(s.shards[k].a)) * 8
+	}
+	return n
+}
@@ -0,0 +1,120 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+// maxStackN is the most elements a StackArrayImpl holds: 8 KiB, small
+// enough for the compiler to keep in a frame.
+const maxStackN = 1024
+
+// StackArrayImpl holds its elements in a fixed [maxStackN]int64 rather
+// than a slice, so a value of it that does not escape lives in its
+// caller's stack frame instead of on the heap. Only its first N elements
+// are the array. Reached through the Array interface it always escapes, so
+// it is not registered: NO_ESCAPE calls its methods on the concrete type.
+type StackArrayImpl struct {
+	A [maxStackN]int64
+	N int
+}
+
+func (s *StackArrayImpl) Name() string         { return "go_stack_int64" }
+func (s *StackArrayImpl) Len() int             { return s.N }
+func (s *StackArrayImpl) Read(i int) int64     { return s.A[i] }
+func (s *StackArrayImpl) Write(i int, v int64) { s.A[i] = v }
+
+func (s *StackArrayImpl) Init(v int64) {
+	for i := range s.A[:s.N] {
+		s.A[i] = v
+	}
+}
+
+// seqWrites times passes of WRITE_SEQUENTIAL's element loop over s. It
+// keeps no reference to s, so s does not escape through it.
+//
+//go:noinline
+func seqWrites(s *StackArrayImpl, passes int) int64 {
+	start := time.Now()
+	for p := 0; p < passes; p++ {
+		for i := 0; i < s.N; i++ {
+			s.Write(i, int64(i+p))
+		}
+	}
+	el := time.Since(start).Nanoseconds()
+	consume(s.Read(passes % s.N))
+	return el
+}
+
+// stackSeq is seqWrites on a StackArrayImpl in its own frame.
+//
+//go:noinline
+func stackSeq(n, passes int) int64 {
+	var s StackArrayImpl
+	s.N = n
+	return seqWrites(&s, passes)
+}
+
+// heapSeq is seqWrites on a StackArrayImpl that escapes to the heap.
+//
+//go:noinline
+func heapSeq(n, passes int) int64 {
+	s := &StackArrayImpl{N: n}
+	keep(s)
+	return seqWrites(s, passes)
+}
+
+// runNoEscape is NO_ESCAPE: passes over min(N, maxStackN) elements, about
+// a million writes, in a StackArrayImpl on the stack and then in one on the
+// heap. ns/op is the stack's and conversions_count how much slower it was
+// than the heap's in percent (negative when faster).
+func runNoEscape(N int) RunResult {
+	n := min(N, maxStackN)
+	if n == 0 {
+		return perElem(0, 0)
+	}
+	passes := max(1, 1000000/n)
+	el, hl := stackSeq(n, passes), heapSeq(n, passes)
+	slower := int64(0)
+	if hl > 0 {
+		slower = int64(math.Round(float64(el-hl) / float64(hl) * 100))
+	}
+	res := timedOps(n*passes, el)
+	res.Conversions = &slower
+	return res
+}
+
+// mallocs returns the heap allocations fn makes.
+func mallocs(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.Mallocs - before.Mallocs
+}
+
+// checkNoEscape checks that escape analysis keeps stackSeq's array on the
+// stack and heapSeq's off it, and that NO_ESCAPE counts min(N, maxStackN)
+// elements a pass and runs on go_noop alone.
+func checkNoEscape() error {
+	const passes = 10
+	if m := mallocs(func() { stackSeq(maxStackN, passes) }); m != 0 {
+		return fmt.Errorf("no-escape: the stack StackArrayImpl made %d heap allocations", m)
+	}
+	if m := mallocs(func() { heapSeq(maxStackN, passes) }); m == 0 {
+		return fmt.Errorf("no-escape: the heap StackArrayImpl made no heap allocation")
+	}
+	noop, _ := Lookup(OverheadImpl)
+	for n, want := range map[int]int{0: 0, 100: 1000000, 5000: 1000000 / maxStackN * maxStackN} {
+		if res := runScenario(noop.New(n), "NO_ESCAPE", n, 1, nil); res.Ops != want || (res.Conversions == nil) != (n == 0) {
+			return fmt.Errorf("no-escape: N=%d gave %d ops, counter %v; want %d ops", n, res.Ops, res.Conversions, want)
+		}
+	}
+	slice, _ := Lookup("go_slice_int64")
+	if cells := PlanCells([]Impl{noop, slice}, []int{10}, []string{"NO_ESCAPE"}, []int64{1}, 1, false); len(cells) != 1 || cells[0].Impl.Name != OverheadImpl {
+		return fmt.Errorf("no-escape: planned %d cells", len(cells))
+	}
+	return nil
+}
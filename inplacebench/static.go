@@ -0,0 +1,243 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// The static dispatch path runs a composite's per-op loop against the
+// array's concrete type instead of the Array interface, so the compiler
+// calls, and inlines, its Read and Write directly. The dispatch=static
+// row's ns/op against the per-op row's is then what the interface call
+// and the inlining it prevents cost in this harness.
+//
+// A generic loop would not do it: the gc compiler instantiates generic
+// functions once per GC shape, every pointer type sharing one, and calls a
+// type parameter's methods through a dictionary, as indirectly as through
+// an interface. So each impl with a static path has its own copy of
+// elementLoop.run below, with l's draws in the same order; checkStatic
+// holds them to the interface loop. Impls that have one declare
+// CapStatic.
+
+// hasStatic reports whether runStatic has a loop for arr's type.
+func hasStatic(arr Array) bool {
+	switch arr.(type) {
+	case *SliceImpl, *AtomicSliceImpl:
+		return true
+	}
+	return false
+}
+
+// runStatic is l.run on arr's concrete type; ok is false, and nothing run,
+// when there is no copy for it.
+func (l elementLoop) runStatic(arr Array) (x, el int64, ok bool) {
+	switch a := arr.(type) {
+	case *SliceImpl:
+		x, el = l.runSlice(a)
+	case *AtomicSliceImpl:
+		x, el = l.runAtomic(a)
+	default:
+		return 0, 0, false
+	}
+	return x, el, true
+}
+
+func (l elementLoop) runSlice(arr *SliceImpl) (x, el int64) {
+	var start time.Time
+	switch {
+	case l.seq:
+		start = time.Now()
+		for p := 0; p < l.M; p += l.N {
+			n := min(l.N, l.M-p)
+			switch l.op {
+			case PhaseWrite:
+				for i := 0; i < n; i++ {
+					arr.Write(i, int64(i))
+				}
+			case PhaseRead:
+				for i := 0; i < n; i++ {
+					x ^= arr.Read(i)
+				}
+			default:
+				for i := 0; i < n; i++ {
+					if l.reads[p+i] {
+						x ^= arr.Read(i)
+					} else {
+						arr.Write(i, int64(i))
+					}
+				}
+			}
+		}
+	case l.timed:
+		start = time.Now()
+		for k := 0; k < l.M; k++ {
+			var j int
+			if l.hot > 0 && hotDraw(l.rng, l.hotPct) {
+				j = l.rng.Intn(l.hot)
+			} else {
+				j = l.rng.Intn(l.N)
+			}
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseWrite:
+		start = time.Now()
+		for _, j := range l.idx {
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseRead:
+		start = time.Now()
+		for _, j := range l.idx {
+			x ^= arr.Read(j)
+		}
+	default:
+		start = time.Now()
+		for k, j := range l.idx {
+			if l.reads[k] {
+				x ^= arr.Read(j)
+			} else {
+				arr.Write(j, randVal(l.rng))
+			}
+		}
+	}
+	return x, time.Since(start).Nanoseconds()
+}
+
+func (l elementLoop) runAtomic(arr *AtomicSliceImpl) (x, el int64) {
+	var start time.Time
+	switch {
+	case l.seq:
+		start = time.Now()
+		for p := 0; p < l.M; p += l.N {
+			n := min(l.N, l.M-p)
+			switch l.op {
+			case PhaseWrite:
+				for i := 0; i < n; i++ {
+					arr.Write(i, int64(i))
+				}
+			case PhaseRead:
+				for i := 0; i < n; i++ {
+					x ^= arr.Read(i)
+				}
+			default:
+				for i := 0; i < n; i++ {
+					if l.reads[p+i] {
+						x ^= arr.Read(i)
+					} else {
+						arr.Write(i, int64(i))
+					}
+				}
+			}
+		}
+	case l.timed:
+		start = time.Now()
+		for k := 0; k < l.M; k++ {
+			var j int
+			if l.hot > 0 && hotDraw(l.rng, l.hotPct) {
+				j = l.rng.Intn(l.hot)
+			} else {
+				j = l.rng.Intn(l.N)
+			}
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseWrite:
+		start = time.Now()
+		for _, j := range l.idx {
+			arr.Write(j, randVal(l.rng))
+		}
+	case l.op == PhaseRead:
+		start = time.Now()
+		for _, j := range l.idx {
+			x ^= arr.Read(j)
+		}
+	default:
+		start = time.Now()
+		for k, j := range l.idx {
+			if l.reads[k] {
+				x ^= arr.Read(j)
+			} else {
+				arr.Write(j, randVal(l.rng))
+			}
+		}
+	}
+	return x, time.Since(start).Nanoseconds()
+}
+
+// WithStatic adds after each per-op cell of plan whose impl declares
+// CapStatic and whose scenario is a composite its dispatch=static twin,
+// and renumbers them. Like the batch twin it draws the same indices and
+// values from the same seed.
+func WithStatic(plan []Cell) []Cell {
+	var out []Cell
+	for _, c := range plan {
+		c.Ordinal = len(out)
+		out = append(out, c)
+		if sc, _ := LookupScenario(c.Scenario); c.Impl.Meta.Has(CapStatic) && sc.Phases != nil && c.Dispatch == "" {
+			c.Ordinal, c.Dispatch = len(out), DispatchStatic
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// runComposite runs sc's phases on a fresh impl.New(N) from seed, through
+// the static loops when static is set, and returns the XOR of the values
+// read, a checksum of the final contents and the RNG's next draw.
+func runComposite(sc Scenario, impl Impl, N int, seed int64, static bool) (x, sum, next int64) {
+	arr := impl.New(N)
+	rng := rand.New(rand.NewSource(seed))
+	for _, ph := range sc.Phases(sc.Params) {
+		if ph.Init {
+			arr.Init(ph.InitValue)
+		}
+		runPhase(nil, nil, static, ph, arr, N, rng, &x)
+	}
+	for i := 0; i < N; i++ {
+		sum = sum*31 + arr.Read(i)
+	}
+	return x, sum, rng.Int63()
+}
+
+// checkStatic checks that every composite's phases read the same values,
+// leave the same contents and draw the RNG to the same point through the
+// static loops as through the interface, and that a Runner with Static
+// plans the twins and writes their rows apart.
+func checkStatic() error {
+	const N = 1000
+	for name := range scenarioGolden {
+		sc, _ := LookupScenario(name)
+		if sc.Phases == nil {
+			continue
+		}
+		for _, impl := range Impls() {
+			if !impl.Meta.Has(CapStatic) {
+				continue
+			}
+			var xs, sums, next [2]int64
+			for k, static := range []bool{false, true} {
+				xs[k], sums[k], next[k] = runComposite(sc, impl, N, 42, static)
+			}
+			if xs[0] != xs[1] || sums[0] != sums[1] || next[0] != next[1] {
+				return fmt.Errorf("static: %s on %s: reads %#x, contents %#x, next draw %d; per-op %#x, %#x, %d",
+					name, impl.Name, xs[1], sums[1], next[1], xs[0], sums[0], next[0])
+			}
+		}
+	}
+	slice, _ := Lookup("go_slice_int64")
+	chain, _ := Lookup("go_xorlist_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{slice, chain}, Scenarios: []string{"WRITE_RANDOM", "INIT_ONLY"}, Ns: []int{100}, Seeds: []int64{1}, Reps: 2, NoBatch: true, Static: true, Writers: []ResultWriter{w}}
+	res, err := r.Run(context.Background())
+	if err != nil {
+		return fmt.Errorf("static: %v", err)
+	}
+	static := res.Where(func(r Result) bool { return r.Dispatch == DispatchStatic })
+	if len(res) != 10 || len(static) != 2 || len(static.Impl("go_slice_int64").Scenario("WRITE_RANDOM")) != 2 {
+		return fmt.Errorf("static: %d rows, %d with dispatch=static, want 10 and the 2 of go_slice_int64/WRITE_RANDOM", len(res), len(static))
+	}
+	if len(res.Cells()) != 5 || static[0].RunID != "go_slice_int64/WRITE_RANDOM/100/1/1/static" {
+		return fmt.Errorf("static: %d cells, static run id %q", len(res.Cells()), static[0].RunID)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package inplacebench
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestStaticChecksums runs every composite through the static loops and
+// through the Array interface on each CapStatic impl, and checks that both
+// read the same values, leave the same contents and stop the RNG at the
+// same draw.
+func TestStaticChecksums(t *testing.T) {
+	for _, sc := range Scenarios() {
+		if sc.Phases == nil {
+			continue
+		}
+		for _, impl := range Impls() {
+			if !impl.Meta.Has(CapStatic) {
+				continue
+			}
+			for _, N := range []int{1, 7, 1000, 4097} {
+				for _, seed := range []int64{1, 42} {
+					t.Run(fmt.Sprintf("%s/%s/N=%d/seed=%d", sc.Name, impl.Name, N, seed), func(t *testing.T) {
+						x, sum, next := runComposite(sc, impl, N, seed, false)
+						sx, ssum, snext := runComposite(sc, impl, N, seed, true)
+						if sx != x || ssum != sum || snext != next {
+							t.Fatalf("static: reads %#x, contents %#x, next draw %d; per-op %#x, %#x, %d", sx, ssum, snext, x, sum, next)
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+// TestWithStatic checks that only composites on CapStatic impls get a
+// dispatch=static twin, right after the per-op cell.
+func TestWithStatic(t *testing.T) {
+	slice, _ := Lookup("go_slice_int64")
+	chain, _ := Lookup("go_xorlist_int64")
+	var plan []Cell
+	for _, impl := range []Impl{slice, chain} {
+		for _, sc := range []string{"WRITE_RANDOM", "INIT_ONLY"} {
+			plan = append(plan, Cell{Impl: impl, Scenario: sc, N: 100})
+		}
+	}
+	got := WithStatic(plan)
+	if len(got) != 5 || got[1].Dispatch != DispatchStatic || got[1].Impl.Name != "go_slice_int64" || got[1].Scenario != "WRITE_RANDOM" {
+		t.Fatalf("WithStatic: %d cells, second %s/%s dispatch %q", len(got), got[1].Impl.Name, got[1].Scenario, got[1].Dispatch)
+	}
+	for k, c := range got {
+		if c.Ordinal != k {
+			t.Fatalf("cell %d has ordinal %d", k, c.Ordinal)
+		}
+	}
+}
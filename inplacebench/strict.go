@@ -0,0 +1,149 @@
+package inplacebench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+// StrictBaseline is the impl Runner.StrictVerify holds every other one
+// to: a cell's checksum must be what the same scenario, N and seed leave
+// in it. The baseline's runs are made on demand, untimed, so the
+// reference does not depend on which impl a sweep lists or finishes first.
+const StrictBaseline = "go_slice_int64"
+
+// The verify column under Runner.StrictVerify. A row that was not
+// compared (a TimingOnly or Exclusive scenario, a CapNoop impl, the typed
+// path, a scenario the baseline does not run) leaves it empty.
+const (
+	VerifyOK   = "ok"
+	VerifyFail = "fail"
+)
+
+// VerifyError is the error a Runner under StrictVerify stops with at the
+// first row whose checksum is not the baseline's.
+type VerifyError struct {
+	Row Result
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("strict verify: %s left checksum %s, not what %s leaves", e.Row.RunID, e.Row.Checksum, StrictBaseline)
+}
+
+// verifiable reports whether StrictVerify compares c's contents.
+func verifiable(c Cell) bool {
+	sc, _ := LookupScenario(c.Scenario)
+	return c.Elem == "" && !c.Impl.Meta.Has(CapNoop) && !sc.Exclusive && !sc.TimingOnly
+}
+
+// contentsChecksum hashes Read(0..Len-1) of arr, for the checksum column.
+func contentsChecksum(arr Array) string {
+	h := fnv.New64a()
+	var b [8]byte
+	for i := 0; i < arr.Len(); i++ {
+		v := uint64(arr.Read(i))
+		for k := range b {
+			b[k] = byte(v >> (8 * k))
+		}
+		h.Write(b[:])
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// strictRefs caches the baseline's checksums by cell. Workers under
+// Runner.Parallel share it.
+type strictRefs struct {
+	mu     sync.Mutex
+	params ScenarioParams
+	sums   map[strictKey]string
+}
+
+type strictKey struct {
+	scenario string
+	N        int
+	seed     int64
+}
+
+func newStrictRefs(params ScenarioParams) *strictRefs {
+	return &strictRefs{params: params, sums: map[strictKey]string{}}
+}
+
+// check sets res's verify column against the baseline's checksum for c,
+// computing it first if no cell before needed it. A cell the baseline is
+// not planned for, or fails on, is left unverified.
+func (s *strictRefs) check(c Cell, res *Result) {
+	base, ok := Lookup(StrictBaseline)
+	if !ok || len(PlanCells([]Impl{base}, []int{c.N}, []string{c.Scenario}, []int64{c.Seed}, 1, false)) == 0 {
+		return
+	}
+	k := strictKey{c.Scenario, c.N, c.EffectiveSeed()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want, ok := s.sums[k]
+	if !ok {
+		arr := base.New(c.N)
+		if err := catchPanic(func() { runScenarioIn(nil, "", arr, c.Scenario, c.N, k.seed, s.params[c.Scenario]) }); err != nil {
+			return
+		}
+		want = contentsChecksum(arr)
+		s.sums[k] = want
+	}
+	res.Verify = VerifyOK
+	if res.Checksum != want {
+		res.Verify = VerifyFail
+	}
+}
+
+// staleArray drops every seventh index's writes, the way an impl with a
+// branch that keeps returning the default would.
+type staleArray struct{ *SliceImpl }
+
+func (s staleArray) Write(i int, v int64) {
+	if i%7 != 3 {
+		s.SliceImpl.Write(i, v)
+	}
+}
+
+// checkStrictVerify checks that StrictVerify passes a few correct impls
+// on the default scenarios, stops at one that keeps stale values after
+// writing its rows, and under KeepGoing marks them and goes on.
+func checkStrictVerify() error {
+	var impls []Impl
+	for _, name := range []string{"go_atomic_int64", "go_paged_int64", "go_pow2_int64", "go_btree_int64", "go_skiplist_int64"} {
+		impl, _ := Lookup(name)
+		impls = append(impls, impl)
+	}
+	r := &Runner{Impls: impls, Scenarios: DefaultScenarios, Ns: []int{300}, Seeds: []int64{1}, Reps: 2, StrictVerify: true, Static: true}
+	res, err := r.Run(context.Background())
+	if err != nil {
+		return fmt.Errorf("strict verify: %v", err)
+	}
+	for _, row := range res.Phase("") {
+		sc, _ := LookupScenario(row.Scenario)
+		if want := map[bool]string{true: "", false: VerifyOK}[sc.TimingOnly]; row.Verify != want || (row.Checksum == "") != (want == "") {
+			return fmt.Errorf("strict verify: %s has verify %q and checksum %q", row.RunID, row.Verify, row.Checksum)
+		}
+	}
+	slice, _ := Lookup("go_slice_int64")
+	stale := Impl{Name: "stale", Meta: slice.Meta, New: func(n int) Array { return staleArray{NewSliceImpl(n)} }}
+	for _, keepGoing := range []bool{false, true} {
+		r := &Runner{Impls: []Impl{slice, stale}, Scenarios: []string{"WRITE_RANDOM", "INIT_ONLY"}, Ns: []int{100}, Seeds: []int64{1}, Reps: 1, NoBatch: true, StrictVerify: true, KeepGoing: keepGoing}
+		res, err := r.Run(context.Background())
+		var verr *VerifyError
+		if keepGoing != (err == nil) || !keepGoing && (!errors.As(err, &verr) || verr.Row.RunID != "stale/WRITE_RANDOM/100/1/1") {
+			return fmt.Errorf("strict verify: KeepGoing=%v: stale impl gave error %v", keepGoing, err)
+		}
+		want := []string{VerifyOK, "", VerifyFail, ""}[:map[bool]int{false: 3, true: 4}[keepGoing]]
+		var got []string
+		for _, row := range res {
+			got = append(got, row.Verify)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("strict verify: KeepGoing=%v: verify column %q, want %q", keepGoing, got, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+package inplacebench
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TelemetryWriter streams every result to a WebSocket server as a text
+// message holding the NDJSON object of its row, as soon as the result is
+// written. Telemetry is best effort: Write never fails or blocks the
+// sweep. A background goroutine owns the connection, logs when it goes
+// down or comes back, and redials with exponential backoff, resending the
+// message that failed; results that arrive while the queue is full are
+// dropped and counted, and one written just as the server drops the
+// connection can be lost in the socket buffer. Close sends what is queued while the connection is
+// up, for at most telemetryCloseWait. The client speaks RFC 6455 itself,
+// as the module keeps no dependency beyond google/btree.
+type TelemetryWriter struct {
+	URL string
+
+	log     io.Writer
+	backoff time.Duration
+	queue   chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+	closed  bool
+}
+
+const (
+	telemetryQueue      = 4096
+	telemetryMaxBackoff = 30 * time.Second
+	telemetryCloseWait  = 5 * time.Second
+)
+
+// NewTelemetryWriter starts streaming to rawURL, a ws:// or wss:// URL;
+// connection failures are logged to log. Only a malformed URL is an error,
+// so a server that is down when the sweep starts is redialled like one that
+// goes away later.
+func NewTelemetryWriter(rawURL string, log io.Writer) (*TelemetryWriter, error) {
+	return newTelemetryWriter(rawURL, log, 250*time.Millisecond)
+}
+
+// newTelemetryWriter is NewTelemetryWriter with the first retry after
+// backoff.
+func newTelemetryWriter(rawURL string, log io.Writer, backoff time.Duration) (*TelemetryWriter, error) {
+	if _, _, err := wsTarget(rawURL); err != nil {
+		return nil, err
+	}
+	t := &TelemetryWriter{URL: rawURL, log: log, backoff: backoff,
+		queue: make(chan []byte, telemetryQueue), stop: make(chan struct{}), done: make(chan struct{})}
+	go t.send()
+	return t, nil
+}
+
+func (t *TelemetryWriter) Write(r Result) error {
+	select {
+	case t.queue <- rowObject(r):
+	default:
+		t.dropped.Add(1)
+	}
+	return nil
+}
+
+// Close stops streaming and reports how many results never reached the
+// server on the log; like Write it returns nil whatever happened.
+func (t *TelemetryWriter) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.queue)
+	close(t.stop)
+	select {
+	case <-t.done:
+	case <-time.After(telemetryCloseWait):
+		t.dropped.Add(int64(len(t.queue)))
+	}
+	if n := t.dropped.Load(); n > 0 {
+		fmt.Fprintf(t.log, "telemetry: %d results not sent to %s\n", n, t.URL)
+	}
+	return nil
+}
+
+// send delivers the queue in order until it is closed and drained, or the
+// connection fails after Close.
+func (t *TelemetryWriter) send() {
+	defer close(t.done)
+	var conn *wsConn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	var msg []byte
+	wait, down := t.backoff, false
+	fail := func(err error) bool {
+		if !down {
+			fmt.Fprintf(t.log, "telemetry: %v; results still go to the outfiles, retrying\n", err)
+			down = true
+		}
+		select {
+		case <-t.stop:
+			return false
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, telemetryMaxBackoff)
+		return true
+	}
+	for {
+		if msg == nil {
+			var ok bool
+			if msg, ok = <-t.queue; !ok {
+				return
+			}
+		}
+		if conn == nil {
+			c, err := dialWebSocket(t.URL)
+			if err != nil {
+				if !fail(err) {
+					t.dropped.Add(1 + int64(len(t.queue)))
+					return
+				}
+				continue
+			}
+			if down {
+				fmt.Fprintf(t.log, "telemetry: reconnected to %s\n", t.URL)
+			}
+			conn, wait, down = c, t.backoff, false
+		}
+		if err := conn.WriteMessage(msg); err != nil {
+			conn.Close()
+			conn = nil
+			if !fail(err) {
+				t.dropped.Add(1 + int64(len(t.queue)))
+				return
+			}
+			continue
+		}
+		msg = nil
+	}
+}
+
+// WebSocket opcodes.
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// wsGUID is the fixed key suffix of RFC 6455's accept hash.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxFrame bounds the frames a connection reads; the other side only
+// sends control frames.
+const wsMaxFrame = 1 << 20
+
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// wsTarget checks that rawURL is ws:// or wss:// and returns it parsed,
+// with the port to dial.
+func wsTarget(rawURL string) (*url.URL, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("telemetry: %v", err)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "ws":
+		port = portOr(port, "80")
+	case "wss":
+		port = portOr(port, "443")
+	default:
+		return nil, "", fmt.Errorf("telemetry: want a ws:// or wss:// URL, got %q", rawURL)
+	}
+	if u.Hostname() == "" {
+		return nil, "", fmt.Errorf("telemetry: no host in %q", rawURL)
+	}
+	return u, net.JoinHostPort(u.Hostname(), port), nil
+}
+
+func portOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// wsConn is the client side of one WebSocket connection. A reader
+// goroutine answers pings and closes the connection when the server closes
+// it or the stream breaks, so the next WriteMessage fails.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	mu   sync.Mutex
+}
+
+func dialWebSocket(rawURL string) (*wsConn, error) {
+	u, addr, err := wsTarget(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	d := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	if u.Scheme == "wss" {
+		conn, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = d.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{Method: "GET", URL: u, Host: u.Host, Header: http.Header{
+		"Upgrade":               {"websocket"},
+		"Connection":            {"Upgrade"},
+		"Sec-WebSocket-Key":     {key},
+		"Sec-WebSocket-Version": {"13"},
+	}}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%s: not a WebSocket endpoint (%s)", rawURL, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	c := &wsConn{conn: conn, br: br}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *wsConn) readLoop() {
+	defer c.conn.Close()
+	for {
+		op, payload, err := readWSFrame(c.br)
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsPing:
+			c.write(wsPong, payload)
+		case wsClose:
+			c.write(wsClose, nil)
+			return
+		}
+	}
+}
+
+// WriteMessage sends msg as one text frame.
+func (c *wsConn) WriteMessage(msg []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.write(wsText, msg)
+}
+
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(wsFrame(op, payload, true))
+	return err
+}
+
+// Close sends a close frame and closes the connection.
+func (c *wsConn) Close() error {
+	c.write(wsClose, nil)
+	return c.conn.Close()
+}
+
+// wsFrame encodes one final frame; client frames must be masked.
+func wsFrame(op byte, payload []byte, masked bool) []byte {
+	b := []byte{0x80 | op, 0}
+	var bit byte
+	if masked {
+		bit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = bit | byte(n)
+	case n <= 0xffff:
+		b[1] = bit | 126
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b[1] = bit | 127
+		b = binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	if !masked {
+		return append(b, payload...)
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	b = append(b, mask[:]...)
+	for i, p := range payload {
+		b = append(b, p^mask[i%4])
+	}
+	return b
+}
+
+// readWSFrame reads one frame, unmasking it if it is masked. Fragmented
+// messages are not reassembled; neither side here sends them.
+func readWSFrame(r *bufio.Reader) (op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var l [2]byte
+		_, err = io.ReadFull(r, l[:])
+		n = uint64(binary.BigEndian.Uint16(l[:]))
+	case 127:
+		var l [8]byte
+		_, err = io.ReadFull(r, l[:])
+		n = binary.BigEndian.Uint64(l[:])
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if n > wsMaxFrame {
+		return 0, nil, fmt.Errorf("websocket: %d-byte frame", n)
+	}
+	var mask [4]byte
+	if h[1]&0x80 != 0 {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return h[0] & 0x0f, payload, nil
+}
+
+// checkTelemetry streams results to a loopback WebSocket server that drops
+// the first connection after one message, and checks that every result
+// arrives once, in order, as its NDJSON object; then that a writer whose
+// server is gone neither fails nor hangs.
+func checkTelemetry() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	got := make(chan []byte, 16)
+	go func() {
+		for first := true; ; first = false {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			br := bufio.NewReader(conn)
+			req, err := http.ReadRequest(br)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+				wsAccept(req.Header.Get("Sec-WebSocket-Key")))
+			conn.Write(wsFrame(wsPing, []byte("hi"), false))
+			for {
+				op, payload, err := readWSFrame(br)
+				if err != nil || op == wsClose {
+					break
+				}
+				if op == wsText {
+					got <- payload
+					if first {
+						break
+					}
+				}
+			}
+			conn.Close()
+		}
+	}()
+	var log strings.Builder
+	t, err := newTelemetryWriter("ws://"+ln.Addr().String()+"/bench", &log, time.Millisecond)
+	if err != nil {
+		return err
+	}
+	var want [][]byte
+	for i := 0; i < 5; i++ {
+		r := Result{Impl: "a", Scenario: "WRITE_RANDOM", N: 10, Rep: i + 1, Ordinal: i, NsPerOp: float64(i), Status: "ok"}
+		want = append(want, rowObject(r))
+		t.Write(r)
+		// Space the writes so the dropped connection is noticed before
+		// the next one; a write racing the drop can be lost in the kernel.
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i, w := range want {
+		select {
+		case b := <-got:
+			var obj map[string]string
+			if err := json.Unmarshal(b, &obj); err != nil || string(b) != string(w) {
+				return fmt.Errorf("telemetry: message %d is %s (%v), want %s", i, b, err, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Close()
+			return fmt.Errorf("telemetry: message %d never arrived; log: %s", i, log.String())
+		}
+	}
+	t.Close()
+	if !strings.Contains(log.String(), "reconnected") {
+		return fmt.Errorf("telemetry: no reconnect logged: %q", log.String())
+	}
+	ln.Close()
+	log.Reset()
+	t, _ = NewTelemetryWriter("ws://"+ln.Addr().String(), &log)
+	start := time.Now()
+	if err := errors.Join(t.Write(Result{Status: "ok"}), t.Close()); err != nil {
+		return fmt.Errorf("telemetry: %v without a server", err)
+	}
+	if d := time.Since(start); d > telemetryCloseWait || !strings.Contains(log.String(), "1 results not sent") {
+		return fmt.Errorf("telemetry: closing without a server took %v and logged %q", d, log.String())
+	}
+	if _, err := NewTelemetryWriter("http://localhost/", io.Discard); err == nil {
+		return fmt.Errorf("telemetry: http:// URL accepted")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package inplacebench
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// TimerCalibration is what CalibrateTimer measured of the clock the
+// scenarios time themselves with.
+type TimerCalibration struct {
+	// ResolutionNs is the smallest step the clock was seen to take, 0 if
+	// it never moved.
+	ResolutionNs float64 `json:"resolution_ns"`
+	// OverheadNs is the mean cost of one time.Now.
+	OverheadNs float64 `json:"overhead_ns"`
+}
+
+// Granularity is the shortest interval the clock can tell apart from
+// nothing: its resolution, or a read when reading costs more than a step,
+// since two reads can then be no closer than that.
+func (t TimerCalibration) Granularity() float64 { return max(t.ResolutionNs, t.OverheadNs) }
+
+// DefaultMinRunFactor is -min-run-factor's default: a run must take a
+// thousand granularities, so the two clock reads around it are at most a
+// few tenths of a percent of it.
+const DefaultMinRunFactor = 1000
+
+// maxLoops bounds how often a short run is repeated to reach MinRunNs.
+const maxLoops = 1 << 16
+
+var clockEpoch = time.Now()
+
+// monotonicNs is time.Now on the monotonic clock, as the scenarios read it
+// with time.Since.
+func monotonicNs() int64 { return int64(time.Since(clockEpoch)) }
+
+// CalibrateTimer measures the resolution and the call overhead of
+// time.Now on this machine, in about a millisecond.
+func CalibrateTimer() TimerCalibration { return calibrateTimer(monotonicNs) }
+
+// calibrateTimer is CalibrateTimer for the clock now. The resolution is
+// the least nonzero difference between a read and the next that differs,
+// over a thousand tries; the overhead, the span of at least ten thousand
+// reads over their count. The span starts as the clock steps and lasts a
+// hundred steps, so the rounding at its ends is at most a percent of it.
+func calibrateTimer(now func() int64) TimerCalibration {
+	const tries, spins, reads, maxReads = 1000, 1 << 20, 10000, 1 << 22
+	res := int64(math.MaxInt64)
+	for k := 0; k < tries; k++ {
+		t0 := now()
+		t1 := now()
+		for s := 0; t1 == t0 && s < spins; s++ {
+			t1 = now()
+		}
+		if t1 == t0 {
+			break // the clock is stuck
+		}
+		res = min(res, t1-t0)
+	}
+	if res == math.MaxInt64 {
+		res = 0
+	}
+	start := now()
+	for s := 0; s < spins; s++ {
+		if t := now(); t != start {
+			start = t
+			break
+		}
+	}
+	n, end := 0, start
+	for n < reads || end-start < 100*res && n < maxReads {
+		end = now()
+		n++
+	}
+	return TimerCalibration{ResolutionNs: float64(res), OverheadNs: float64(end-start) / float64(n)}
+}
+
+// loopToMin is Runner.MinRunNs: for a run of 0 < TotalNs < minNs it calls
+// again until the runs together have taken minNs, or maxLoops of them,
+// and returns run with the mean timing of them all, phases included, and
+// how many there were. Everything else is the first run's. The first
+// error from again ends it.
+func loopToMin(run RunResult, minNs int64, again func() (RunResult, error)) (RunResult, int, error) {
+	if !tooShort(run, minNs) {
+		return run, 1, nil
+	}
+	total, init, perOp := run.TotalNs, run.InitNs, run.NsPerOp
+	phaseNs := make([]int64, len(run.Phases))
+	phasePerOp := make([]float64, len(run.Phases))
+	for i, p := range run.Phases {
+		phaseNs[i], phasePerOp[i] = p.TotalNs, p.NsPerOp
+	}
+	loops := 1
+	for ; total < minNs && loops < maxLoops; loops++ {
+		r, err := again()
+		if err != nil {
+			return run, loops, err
+		}
+		total, init, perOp = total+r.TotalNs, init+r.InitNs, perOp+r.NsPerOp
+		for i := range phaseNs {
+			if i < len(r.Phases) {
+				phaseNs[i] += r.Phases[i].TotalNs
+				phasePerOp[i] += r.Phases[i].NsPerOp
+			}
+		}
+	}
+	n := int64(loops)
+	run.TotalNs, run.InitNs, run.NsPerOp = total/n, init/n, perOp/float64(n)
+	run.Phases = slices.Clone(run.Phases)
+	for i := range run.Phases {
+		run.Phases[i].TotalNs, run.Phases[i].NsPerOp = phaseNs[i]/n, phasePerOp[i]/float64(n)
+	}
+	return run, loops, nil
+}
+
+// tooShort reports whether loopToMin would repeat run: it took some time,
+// but less than minNs.
+func tooShort(run RunResult, minNs int64) bool { return run.TotalNs > 0 && run.TotalNs < minNs }
@@ -0,0 +1,99 @@
+package inplacebench
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for calibrateTimer: each read advances the true
+// time by cost ns and returns it rounded down to a multiple of step.
+type fakeClock struct{ t, cost, step int64 }
+
+func (c *fakeClock) now() int64 {
+	c.t += c.cost
+	return c.t - c.t%c.step
+}
+
+func TestCalibrateTimer(t *testing.T) {
+	for _, c := range []struct {
+		name             string
+		cost, step       int64
+		res, over, grain float64
+	}{
+		{"coarse", 30, 100, 100, 30, 100},
+		{"fine", 25, 1, 25, 25, 25},
+		{"windows tick", 10000, 15600000, 15600000, 10000, 15600000},
+		{"stuck", 0, 1, 0, 0, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := calibrateTimer((&fakeClock{cost: c.cost, step: c.step}).now)
+			if got.ResolutionNs != c.res {
+				t.Errorf("resolution %v ns, want %v", got.ResolutionNs, c.res)
+			}
+			if math.Abs(got.OverheadNs-c.over) > 0.02*c.over {
+				t.Errorf("overhead %v ns, want %v", got.OverheadNs, c.over)
+			}
+			if math.Abs(got.Granularity()-c.grain) > 0.02*c.grain {
+				t.Errorf("granularity %v ns, want %v", got.Granularity(), c.grain)
+			}
+		})
+	}
+}
+
+func TestLoopToMin(t *testing.T) {
+	runs := 0
+	again := func() (RunResult, error) {
+		runs++
+		return RunResult{Ops: 10, TotalNs: 300, NsPerOp: 30, Phases: []PhaseResult{{Name: "a", TotalNs: 300, NsPerOp: 30}}}, nil
+	}
+	first := RunResult{Ops: 10, TotalNs: 100, NsPerOp: 10, Path: "element", Phases: []PhaseResult{{Name: "a", TotalNs: 100, NsPerOp: 10}}}
+	got, loops, err := loopToMin(first, 1000, again)
+	if err != nil || loops != 4 || runs != 3 {
+		t.Fatalf("%d loops, %d reruns, %v; want 4 and 3", loops, runs, err)
+	}
+	if got.TotalNs != 250 || got.NsPerOp != 25 || got.Path != "element" || got.Phases[0].TotalNs != 250 || got.Phases[0].NsPerOp != 25 {
+		t.Errorf("mean of the loops is %+v, want 250 ns at 25 ns/op", got)
+	}
+	if first.Phases[0].TotalNs != 100 {
+		t.Errorf("loopToMin changed the first run's phases")
+	}
+	for _, min := range []int64{0, 100} {
+		if _, loops, _ := loopToMin(first, min, again); loops != 1 {
+			t.Errorf("a %d ns run looped %d times for %d ns", first.TotalNs, loops, min)
+		}
+	}
+}
+
+// TestMinRunNs checks that a Runner under MinRunNs loops a short run on a
+// fresh array each time, flags it, and leaves its contents alone.
+func TestMinRunNs(t *testing.T) {
+	slice, _ := Lookup("go_slice_int64")
+	rows := map[int64]Result{}
+	built := map[int64]int{}
+	for _, minNs := range []int64{0, int64(5 * time.Millisecond)} {
+		impl := slice
+		impl.New = func(n int) Array {
+			built[minNs]++
+			return slice.New(n)
+		}
+		w := &memWriter{}
+		r := &Runner{Impls: []Impl{impl}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{100}, Seeds: []int64{1}, Reps: 1,
+			NoBatch: true, StrictVerify: true, MinRunNs: minNs, Writers: []ResultWriter{w}}
+		if _, err := r.Run(context.Background()); err != nil || len(w.rows) != 1 {
+			t.Fatalf("MinRunNs %d: %d rows, %v", minNs, len(w.rows), err)
+		}
+		rows[minNs] = w.rows[0]
+	}
+	short, long := rows[0], rows[int64(5*time.Millisecond)]
+	if short.Loops != 1 || short.ShortRun || built[0] != 1 {
+		t.Errorf("without MinRunNs: %d loops on %d arrays, short_run %v", short.Loops, built[0], short.ShortRun)
+	}
+	if long.Loops < 2 || !long.ShortRun || built[int64(5*time.Millisecond)] != long.Loops {
+		t.Errorf("under 5ms: %d loops on %d arrays, short_run %v; want one array a loop", long.Loops, built[int64(5*time.Millisecond)], long.ShortRun)
+	}
+	if long.Checksum != short.Checksum || long.Verify != "ok" {
+		t.Errorf("looping changed the contents: checksum %s, verify %s; want %s", long.Checksum, long.Verify, short.Checksum)
+	}
+}
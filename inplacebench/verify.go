@@ -0,0 +1,1060 @@
+package inplacebench
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"strings"
+)
+
+// OpKind is one kind of operation in a verification sequence.
+type OpKind uint8
+
+const (
+	OpInit OpKind = iota
+	OpRead
+	OpWrite
+	// OpFill sets every element like Init; only impls with Fill(v) get it.
+	OpFill
+	// OpDelete returns index I to the default (see Deleter); only impls with
+	// Delete(i) get it.
+	OpDelete
+)
+
+// Op is one step of a verification sequence.
+type Op struct {
+	Kind OpKind
+	I    int
+	V    int64
+}
+
+func (op Op) String() string {
+	switch op.Kind {
+	case OpInit:
+		return fmt.Sprintf("Init(%d)", op.V)
+	case OpRead:
+		return fmt.Sprintf("Read(%d)", op.I)
+	case OpWrite:
+		return fmt.Sprintf("Write(%d, %d)", op.I, op.V)
+	case OpFill:
+		return fmt.Sprintf("Fill(%d)", op.V)
+	default:
+		return fmt.Sprintf("Delete(%d)", op.I)
+	}
+}
+
+// GenOps derives a random interleaved sequence of ops operations over N
+// elements from seed; the same arguments always give the same sequence. It
+// starts with Init(0). Init and Fill are rarer at large N so one sequence
+// stays cheap at N around 1M.
+func GenOps(N, ops int, seed int64) []Op {
+	rng := rand.New(rand.NewSource(seed))
+	val := func() int64 { return int64(rng.Intn(2001) - 1000) }
+	bulk := max(100, N/100)
+	seq := make([]Op, 0, ops+1)
+	seq = append(seq, Op{Kind: OpInit})
+	for k := 0; k < ops; k++ {
+		if N == 0 || rng.Intn(bulk) == 0 {
+			kind := OpInit
+			if rng.Intn(4) == 0 {
+				kind = OpFill
+			}
+			seq = append(seq, Op{Kind: kind, V: val()})
+			continue
+		}
+		i := rng.Intn(N)
+		switch r := rng.Intn(100); {
+		case r < 47:
+			seq = append(seq, Op{Kind: OpWrite, I: i, V: val()})
+		case r < 95:
+			seq = append(seq, Op{Kind: OpRead, I: i})
+		default:
+			seq = append(seq, Op{Kind: OpDelete, I: i})
+		}
+	}
+	return seq
+}
+
+// Divergence is the first point where an implementation disagreed with the
+// reference model. Panic is set when the op panicked instead. Repro is the
+// smallest failing (N, ops, seed) that shrinking found, for a short replay.
+type Divergence struct {
+	Impl      string
+	Op        int
+	Step      Op
+	Want, Got int64
+	Panic     string
+	Repro     struct {
+		N, Ops int
+		Seed   int64
+	}
+}
+
+func (d *Divergence) Error() string {
+	if d.Panic != "" {
+		return fmt.Sprintf("op %d: %v: panic: %s", d.Op, d.Step, d.Panic)
+	}
+	return fmt.Sprintf("op %d: %v = %d, want %d", d.Op, d.Step, d.Got, d.Want)
+}
+
+// Replay applies seq to arr and to a reference model (O(1) Init through
+// generation stamps, so the model stays cheap at large N), returning the
+// first divergence or nil. Fill and Delete ops are skipped for arrays that do
+// not have them.
+func Replay(arr Array, N int, seq []Op) (d *Divergence) {
+	vals := make([]int64, N)
+	stamp := make([]uint32, N)
+	gen, def := uint32(1), int64(0)
+	k := 0
+	defer func() {
+		if r := recover(); r != nil {
+			d = &Divergence{Impl: arr.Name(), Op: k, Step: seq[k], Panic: fmt.Sprint(r)}
+		}
+	}()
+	filler, canFill := arr.(Filler)
+	deleter, canDelete := arr.(Deleter)
+	for ; k < len(seq); k++ {
+		op := seq[k]
+		switch op.Kind {
+		case OpFill:
+			if !canFill {
+				continue
+			}
+			filler.Fill(op.V)
+			gen, def = gen+1, op.V
+		case OpInit:
+			arr.Init(op.V)
+			gen, def = gen+1, op.V
+		case OpWrite:
+			arr.Write(op.I, op.V)
+			vals[op.I], stamp[op.I] = op.V, gen
+		case OpDelete:
+			if !canDelete {
+				continue
+			}
+			deleter.Delete(op.I)
+			stamp[op.I] = 0
+		case OpRead:
+			want := def
+			if stamp[op.I] == gen {
+				want = vals[op.I]
+			}
+			if got := arr.Read(op.I); got != want {
+				return &Divergence{Impl: arr.Name(), Op: k, Step: op, Want: want, Got: got}
+			}
+		}
+	}
+	return nil
+}
+
+// Verify replays GenOps(N, ops, seed) against arr and the reference model,
+// returning the first divergence (a *Divergence) or nil.
+func Verify(arr Array, N, ops int, seed int64) error {
+	if d := Replay(arr, N, GenOps(N, ops, seed)); d != nil {
+		return d
+	}
+	return nil
+}
+
+// VerifyImpl is Verify on a fresh impl.New(N) that, on failure, also shrinks
+// the case: it tries smaller sizes (1, 2, 4, 8, ...) and seeds 0..31 and
+// records the first failing combination, with its op count cut to the
+// failing op, as the reproduction.
+func VerifyImpl(impl Impl, N, ops int, seed int64) *Divergence {
+	run := func(N, ops int, seed int64) *Divergence {
+		arr := impl.New(N)
+		defer func() {
+			if c, ok := arr.(io.Closer); ok {
+				c.Close()
+			}
+		}()
+		return Replay(arr, N, GenOps(N, ops, seed))
+	}
+	d := run(N, ops, seed)
+	if d == nil {
+		return nil
+	}
+	d.Impl = impl.Name
+	d.Repro.N, d.Repro.Ops, d.Repro.Seed = N, d.Op, seed
+	for n := 1; n < N; n = max(n+1, n*2) {
+		for s := int64(0); s < 32; s++ {
+			if small := run(n, min(ops, 64*n+64), s); small != nil {
+				d.Repro.N, d.Repro.Ops, d.Repro.Seed = n, small.Op, s
+				return d
+			}
+		}
+	}
+	return d
+}
+
+// SelftestSizes cover the empty array, a single element, an odd size and
+// sizes straddling 64-element word, 512-element block and 4096-element page
+// boundaries.
+var SelftestSizes = []int{0, 1, 7, 1000, 4097}
+
+// PropertySizes are the sizes Selftest runs CheckProperties at.
+var PropertySizes = []int{1, 2, 7, 100}
+
+// selftestImpl drives arr through a scripted Init/Read/Write sequence and
+// compares every read against a reference model (a map over a default value).
+func selftestImpl(arr Array, N int) (err error) {
+	def := int64(0)
+	ref := map[int]int64{}
+	step := ""
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: panic: %v", step, r)
+		}
+	}()
+	initTo := func(v int64) { arr.Init(v); def = v; ref = map[int]int64{} }
+	write := func(i int, v int64) { arr.Write(i, v); ref[i] = v }
+	check := func(i int) error {
+		want, ok := ref[i]
+		if !ok {
+			want = def
+		}
+		if got := arr.Read(i); got != want {
+			return fmt.Errorf("%s: Read(%d) = %d, want %d", step, i, got, want)
+		}
+		return nil
+	}
+	checkAll := func() error {
+		for i := 0; i < N; i++ {
+			if err := check(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var edges []int
+	for _, i := range []int{0, 1, 63, 64, 65, 511, 512, 513, 4095, 4096, N - 2, N - 1} {
+		if i >= 0 && i < N && (len(edges) == 0 || edges[len(edges)-1] < i) {
+			edges = append(edges, i)
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"Len() is N", func() error {
+			if got := arr.Len(); got != N {
+				return fmt.Errorf("Len() = %d, want %d", got, N)
+			}
+			return nil
+		}},
+		{"read after Init(7)", func() error { initTo(7); return checkAll() }},
+		{"write index 0 and N-1", func() error {
+			if N > 0 {
+				write(0, -1)
+				write(N-1, -2)
+			}
+			return checkAll()
+		}},
+		{"writes at block/page/word edges", func() error {
+			for k, i := range edges {
+				write(i, int64(1000+k))
+			}
+			return checkAll()
+		}},
+		{"Init(-3) discards writes", func() error { initTo(-3); return checkAll() }},
+		{"Init(-3) again after writes", func() error {
+			for _, i := range edges {
+				write(i, 5)
+			}
+			initTo(-3)
+			return checkAll()
+		}},
+		{"random writes and reads", func() error {
+			for k := 0; k < 2*N; k++ {
+				i := rng.Intn(N)
+				if rng.Intn(2) == 0 {
+					write(i, int64(rng.Intn(2001)-1000))
+				} else if err := check(i); err != nil {
+					return err
+				}
+			}
+			return checkAll()
+		}},
+		{"Fill(9) after writes", func() error {
+			f, ok := arr.(Filler)
+			if !ok {
+				return nil
+			}
+			for _, i := range edges {
+				write(i, 11)
+			}
+			f.Fill(9)
+			def, ref = 9, map[int]int64{}
+			return checkAll()
+		}},
+		{"Delete returns cells to the current default", func() error {
+			d, ok := arr.(Deleter)
+			if !ok || N == 0 {
+				return nil
+			}
+			for _, i := range edges {
+				write(i, 21)
+			}
+			del := func(i int) { d.Delete(i); delete(ref, i) }
+			del(edges[0])
+			if f, ok := arr.(Filler); ok {
+				// The default is the latest Fill's, not the one the write saw.
+				f.Fill(4)
+				def, ref = 4, map[int]int64{}
+				for _, i := range edges {
+					write(i, 22)
+				}
+			}
+			for k, i := range edges {
+				if k%2 == 0 {
+					del(i)
+				}
+			}
+			if err := checkAll(); err != nil {
+				return err
+			}
+			del(edges[len(edges)-1])
+			write(edges[len(edges)-1], 23)
+			return checkAll()
+		}},
+		{"Reset() after writes", func() error {
+			r, ok := arr.(Resetter)
+			if !ok {
+				return nil
+			}
+			for _, i := range edges {
+				write(i, 12)
+			}
+			r.Reset()
+			def, ref = 0, map[int]int64{}
+			return checkAll()
+		}},
+		{"WriteRange and ReadRange across edges", func() error {
+			rw, canWrite := arr.(RangeWriter)
+			rr, canRead := arr.(RangeReader)
+			if N == 0 || !canWrite && !canRead {
+				return nil
+			}
+			// Spans starting at each edge, straddling the next one, plus
+			// random partial ranges across shard and chunk boundaries.
+			type span struct{ lo, n int }
+			var spans []span
+			for _, i := range edges {
+				spans = append(spans, span{i, min(N-i, 70)})
+			}
+			for k := 0; k < 32; k++ {
+				lo := rng.Intn(N)
+				spans = append(spans, span{lo, rng.Intn(min(N-lo, 300) + 1)})
+			}
+			for k, sp := range spans {
+				if canWrite {
+					vals := make([]int64, sp.n)
+					for j := range vals {
+						vals[j] = int64(k*1000 + j)
+						ref[sp.lo+j] = vals[j]
+					}
+					rw.WriteRange(sp.lo, vals)
+				}
+				if canRead {
+					out := make([]int64, sp.n)
+					rr.ReadRange(sp.lo, out)
+					for j, got := range out {
+						want, ok := ref[sp.lo+j]
+						if !ok {
+							want = def
+						}
+						if got != want {
+							return fmt.Errorf("%s: ReadRange(%d, [%d]) [%d] = %d, want %d", step, sp.lo, sp.n, j, got, want)
+						}
+					}
+				}
+			}
+			return checkAll()
+		}},
+		{"alternating Init values", func() error {
+			for k := 0; k < 4; k++ {
+				initTo(int64(k%2*100 - 50))
+				if err := checkAll(); err != nil {
+					return err
+				}
+				for _, i := range edges {
+					write(i, int64(k))
+				}
+			}
+			return checkAll()
+		}},
+		{"Traverse matches Read", func() error {
+			t, ok := arr.(Traverser)
+			if !ok {
+				return nil
+			}
+			i := 0
+			var err error
+			t.Traverse(func(v int64) {
+				if err == nil && (i >= N || v != arr.Read(i)) {
+					err = fmt.Errorf("%s: element %d = %d", step, i, v)
+				}
+				i++
+			})
+			if err == nil && i != N {
+				err = fmt.Errorf("%s: visited %d of %d elements", step, i, N)
+			}
+			return err
+		}},
+		{"ForEach visits written cells", func() error {
+			it, ok := arr.(Iterator)
+			if !ok {
+				return nil
+			}
+			for _, i := range edges {
+				write(i, int64(-i))
+			}
+			seen := map[int]bool{}
+			var err error
+			it.ForEach(func(i int, v int64) bool {
+				want, ok := ref[i]
+				if !ok {
+					want = def
+				}
+				switch {
+				case i < 0 || i >= N:
+					err = fmt.Errorf("%s: visited index %d outside [0, %d)", step, i, N)
+				case seen[i]:
+					err = fmt.Errorf("%s: visited index %d twice", step, i)
+				case v != want:
+					err = fmt.Errorf("%s: index %d = %d, want %d", step, i, v, want)
+				}
+				seen[i] = true
+				return err == nil
+			})
+			if err != nil {
+				return err
+			}
+			for i := range ref {
+				if !seen[i] {
+					return fmt.Errorf("%s: written index %d not visited", step, i)
+				}
+			}
+			calls := 0
+			it.ForEach(func(int, int64) bool { calls++; return false })
+			if calls > 1 {
+				return fmt.Errorf("%s: ForEach went on for %d calls after fn returned false", step, calls)
+			}
+			return nil
+		}},
+		{"CheckedRead/CheckedWrite reject -1 and N", func() error {
+			c, ok := arr.(CheckedAccessor)
+			if !ok {
+				return nil
+			}
+			for _, i := range []int{-1, N, N + 64, math.MinInt} {
+				if _, err := c.CheckedRead(i); !errors.Is(err, ErrOutOfRange) {
+					return fmt.Errorf("%s: CheckedRead(%d) error = %v", step, i, err)
+				}
+				if err := c.CheckedWrite(i, 77); !errors.Is(err, ErrOutOfRange) {
+					return fmt.Errorf("%s: CheckedWrite(%d) error = %v", step, i, err)
+				}
+			}
+			for _, i := range edges {
+				if err := c.CheckedWrite(i, int64(i)); err != nil {
+					return fmt.Errorf("%s: CheckedWrite(%d): %v", step, i, err)
+				}
+				ref[i] = int64(i)
+				if v, err := c.CheckedRead(i); err != nil || v != int64(i) {
+					return fmt.Errorf("%s: CheckedRead(%d) = %d, %v, want %d", step, i, v, err, i)
+				}
+			}
+			return checkAll()
+		}},
+		{"ReadBatch matches Read", func() error {
+			br, ok := arr.(interface{ ReadBatch(idx []int, out []int64) })
+			if !ok || N == 0 {
+				return nil
+			}
+			idx := append(randIdx(rng, 100, N), edges...)
+			out := make([]int64, len(idx))
+			br.ReadBatch(idx, out)
+			for k, i := range idx {
+				if want := arr.Read(i); out[k] != want {
+					return fmt.Errorf("%s: out[%d] = %d for index %d, want %d", step, k, out[k], i, want)
+				}
+			}
+			return nil
+		}},
+		{"Snapshot and live array are independent", func() error {
+			sn, ok := arr.(Snapshotter)
+			if !ok {
+				return nil
+			}
+			snap := sn.Snapshot()
+			if c, ok := snap.(io.Closer); ok {
+				defer c.Close()
+			}
+			if snap.Len() != N {
+				return fmt.Errorf("%s: snapshot Len() = %d, want %d", step, snap.Len(), N)
+			}
+			isEdge := map[int]bool{}
+			for _, i := range edges {
+				isEdge[i] = true
+				arr.Write(i, int64(1000+i))
+				ref[i] = int64(1000 + i)
+				snap.Write(i, int64(-1000-i))
+			}
+			for i := 0; i < N; i++ {
+				want := arr.Read(i)
+				if isEdge[i] {
+					want = int64(-1000 - i)
+				}
+				if got := snap.Read(i); got != want {
+					return fmt.Errorf("%s: snapshot Read(%d) = %d, want %d", step, i, got, want)
+				}
+			}
+			return checkAll()
+		}},
+		{"Init(0)", func() error { initTo(0); return checkAll() }},
+	}
+	for _, st := range steps {
+		step = st.name
+		if err := st.run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selftestReadOnly checks a CapReadOnly impl: reads after Init see the Init
+// value, a second Init is allowed, and Write afterwards panics (or, for
+// OS-protected memory, faults, which SetPanicOnFault turns into a panic).
+func selftestReadOnly(arr Array, N int) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	for _, v := range []int64{7, -3} {
+		arr.Init(v)
+		for i := 0; i < N; i++ {
+			if got := arr.Read(i); got != v {
+				return fmt.Errorf("after Init(%d): Read(%d) = %d", v, i, got)
+			}
+		}
+	}
+	if N == 0 {
+		return nil
+	}
+	defer func() {
+		if recover() == nil {
+			err = fmt.Errorf("Write(0) after Init did not panic")
+		}
+	}()
+	arr.Write(0, 1)
+	return nil
+}
+
+// checkRegistry checks that every registered impl is found by its exact name
+// only, that iteration order is stable and that the declared capabilities
+// match what the constructed array implements.
+func checkRegistry() error {
+	a, b := Impls(), Impls()
+	for i, impl := range a {
+		if b[i].Name != impl.Name {
+			return fmt.Errorf("registry: iteration order changed at %d: %s vs %s", i, impl.Name, b[i].Name)
+		}
+		if got, ok := Lookup(impl.Name); !ok || got.Name != impl.Name {
+			return fmt.Errorf("registry: Lookup(%q) failed", impl.Name)
+		}
+		if upper := strings.ToUpper(impl.Name); upper != impl.Name {
+			if _, ok := Lookup(upper); ok {
+				return fmt.Errorf("registry: Lookup(%q) matched case-insensitively", upper)
+			}
+		}
+		if _, ok := Lookup(impl.Name + " "); ok {
+			return fmt.Errorf("registry: Lookup matched %q inexactly", impl.Name+" ")
+		}
+		arr := impl.New(0)
+		_, stats := arr.(StatsReporter)
+		_, fill := arr.(Filler)
+		_, del := arr.(Deleter)
+		_, iter := arr.(Iterator)
+		_, numa := arr.(NodeBinder)
+		_, snap := arr.(Snapshotter)
+		_, cas := arr.(CompareAndSwapper)
+		_, batch := arr.(BatchRunner)
+		static := hasStatic(arr)
+		if c, ok := arr.(io.Closer); ok {
+			c.Close()
+		}
+		if stats != impl.Meta.Has(CapStats) {
+			return fmt.Errorf("registry: %s declares stats=%v but StatsReporter=%v", impl.Name, impl.Meta.Has(CapStats), stats)
+		}
+		// Read-only impls may have a Fill or Delete that only rejects the
+		// write.
+		if fill != impl.Meta.Has(CapFill) && !impl.Meta.Has(CapReadOnly) {
+			return fmt.Errorf("registry: %s declares fill=%v but Filler=%v", impl.Name, impl.Meta.Has(CapFill), fill)
+		}
+		if del != impl.Meta.Has(CapDelete) && !impl.Meta.Has(CapReadOnly) {
+			return fmt.Errorf("registry: %s declares delete=%v but Deleter=%v", impl.Name, impl.Meta.Has(CapDelete), del)
+		}
+		if numa != impl.Meta.Has(CapNUMA) {
+			return fmt.Errorf("registry: %s declares numa=%v but NodeBinder=%v", impl.Name, impl.Meta.Has(CapNUMA), numa)
+		}
+		if snap != impl.Meta.Has(CapSnapshot) {
+			return fmt.Errorf("registry: %s declares snapshot=%v but Snapshotter=%v", impl.Name, impl.Meta.Has(CapSnapshot), snap)
+		}
+		if cas != impl.Meta.Has(CapCAS) {
+			return fmt.Errorf("registry: %s declares cas=%v but CompareAndSwapper=%v", impl.Name, impl.Meta.Has(CapCAS), cas)
+		}
+		if batch != impl.Meta.Has(CapBatch) {
+			return fmt.Errorf("registry: %s declares batch=%v but BatchRunner=%v", impl.Name, impl.Meta.Has(CapBatch), batch)
+		}
+		if static != impl.Meta.Has(CapStatic) {
+			return fmt.Errorf("registry: %s declares static=%v but has a static loop=%v", impl.Name, impl.Meta.Has(CapStatic), static)
+		}
+		if impl.Meta.Has(CapSorted) {
+			if !iter {
+				return fmt.Errorf("registry: %s declares sorted but is not an Iterator", impl.Name)
+			}
+			if err := checkSorted(impl); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSorted writes two indices out of order (unless impl is read-only)
+// and checks that ForEach visits in ascending order.
+func checkSorted(impl Impl) error {
+	arr := impl.New(70)
+	if c, ok := arr.(io.Closer); ok {
+		defer c.Close()
+	}
+	arr.Init(0)
+	if !impl.Meta.Has(CapReadOnly) {
+		arr.Write(65, 1)
+		arr.Write(3, 2)
+	}
+	last := -1
+	var err error
+	arr.(Iterator).ForEach(func(i int, _ int64) bool {
+		if i <= last {
+			err = fmt.Errorf("registry: %s declares sorted but ForEach visited %d after %d", impl.Name, i, last)
+		}
+		last = i
+		return err == nil
+	})
+	return err
+}
+
+// Selftest checks the registry, the scenario checksums, the fuzz seed
+// corpus and CheckProperties, then runs selftestImpl (selftestReadOnly for
+// CapReadOnly impls) for every registered implementation and size, stopping
+// at the first divergence.
+func Selftest() error {
+	if err := checkRegistry(); err != nil {
+		return err
+	}
+	if err := checkOptions(); err != nil {
+		return err
+	}
+	if err := checkScenarios(); err != nil {
+		return err
+	}
+	if err := checkPhases(); err != nil {
+		return err
+	}
+	if err := checkNoop(); err != nil {
+		return err
+	}
+	if err := checkGC(); err != nil {
+		return err
+	}
+	if err := checkBuffers(); err != nil {
+		return err
+	}
+	if err := checkWarmup(); err != nil {
+		return err
+	}
+	if err := checkReruns(); err != nil {
+		return err
+	}
+	if err := checkBatch(); err != nil {
+		return err
+	}
+	if err := checkStatic(); err != nil {
+		return err
+	}
+	if err := checkStrictVerify(); err != nil {
+		return err
+	}
+	if err := checkBoundsCheckFree(); err != nil {
+		return err
+	}
+	if err := checkSink(); err != nil {
+		return err
+	}
+	if err := checkNoEscape(); err != nil {
+		return err
+	}
+	if err := checkFirstTouchCurve(); err != nil {
+		return err
+	}
+	if err := checkRegisterPressure(); err != nil {
+		return err
+	}
+	if err := checkCacheSizes(); err != nil {
+		return err
+	}
+	if err := checkNUMAPolicy(); err != nil {
+		return err
+	}
+	if err := checkMemoryBandwidth(); err != nil {
+		return err
+	}
+	if err := checkFreq(); err != nil {
+		return err
+	}
+	if err := checkMixedAdaptive(); err != nil {
+		return err
+	}
+	if err := checkSnapshotChurn(); err != nil {
+		return err
+	}
+	if err := checkTelemetry(); err != nil {
+		return err
+	}
+	if err := checkFuzzSeeds(); err != nil {
+		return err
+	}
+	if err := checkConcurrentImpls(impls, 4, 5000); err != nil {
+		return err
+	}
+	if err := checkTyped(); err != nil {
+		return err
+	}
+	if err := checkRedis(); err != nil {
+		return err
+	}
+	if err := checkInstrument(); err != nil {
+		return err
+	}
+	for _, impl := range impls {
+		if impl.Meta.Has(CapReadOnly) || impl.Meta.Has(CapNoop) {
+			continue
+		}
+		if f := CheckProperties(impl, PropertySizes, 4); f != nil {
+			return f
+		}
+	}
+	for _, impl := range impls {
+		if impl.Meta.Has(CapNoop) {
+			continue
+		}
+		for _, N := range SelftestSizes {
+			arr := impl.New(N)
+			var err error
+			if impl.Meta.Has(CapReadOnly) {
+				err = selftestReadOnly(arr, N)
+			} else {
+				err = selftestImpl(arr, N)
+			}
+			if c, ok := arr.(io.Closer); ok {
+				c.Close()
+			}
+			if err != nil {
+				return fmt.Errorf("%s N=%d: %v", impl.Name, N, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSnapshotChurn runs SNAPSHOT_CHURN on every writable Snapshotter at
+// SelftestSizes, the empty array among them, and checks its op count and
+// that no snapshot read came back wrong.
+func checkSnapshotChurn() error {
+	sc, _ := LookupScenario("SNAPSHOT_CHURN")
+	for _, impl := range impls {
+		// A read-only impl snapshots, but SNAPSHOT_CHURN is not planned
+		// for it.
+		if !impl.Meta.Has(CapSnapshot) || impl.Meta.Has(CapReadOnly) {
+			continue
+		}
+		for _, N := range SelftestSizes {
+			arr := impl.New(N)
+			res := runScenario(arr, "SNAPSHOT_CHURN", N, 1, nil)
+			if c, ok := arr.(io.Closer); ok {
+				c.Close()
+			}
+			if res.Ops != sc.Ops(N) || res.Conversions == nil && N > 0 || res.Conversions != nil && *res.Conversions != 0 {
+				return fmt.Errorf("%s SNAPSHOT_CHURN N=%d: %d ops, mismatches %v; want %d ops and none", impl.Name, N, res.Ops, res.Conversions, sc.Ops(N))
+			}
+		}
+	}
+	return nil
+}
+
+// traceArray is a plain slice that hashes every call made on it, so a
+// scenario's exact Init/Read/Write sequence can be compared across versions.
+type traceArray struct {
+	A []int64
+	h hash.Hash64
+}
+
+func (t *traceArray) Name() string { return "trace" }
+func (t *traceArray) Len() int     { return len(t.A) }
+func (t *traceArray) Init(v int64) int64 {
+	t.op('I', 0, v)
+	for i := range t.A {
+		t.A[i] = v
+	}
+	return 0
+}
+func (t *traceArray) Read(i int) int64     { t.op('R', i, 0); return t.A[i] }
+func (t *traceArray) Write(i int, v int64) { t.op('W', i, v); t.A[i] = v }
+func (t *traceArray) op(kind byte, i int, v int64) {
+	var b [17]byte
+	b[0] = kind
+	binary.LittleEndian.PutUint64(b[1:], uint64(i))
+	binary.LittleEndian.PutUint64(b[9:], uint64(v))
+	t.h.Write(b[:])
+}
+
+// scenarioChecksum hashes the call sequence scenario makes at N and seed,
+// followed by the op count it reports.
+func scenarioChecksum(scenario string, N int, seed int64) uint64 {
+	t := &traceArray{A: make([]int64, N), h: fnv.New64a()}
+	ops, _, _, _ := RunScenario(t, scenario, N, seed, nil)
+	t.op('O', ops, 0)
+	return t.h.Sum64()
+}
+
+// scenarioGolden holds scenarioChecksum(name, 1000, 42) as recorded before
+// the scenarios moved out of a switch into the registry. A mismatch means a
+// scenario's RNG or call sequence changed and new results are no longer
+// comparable with historical ones.
+var scenarioGolden = map[string]uint64{
+	"INIT_ONLY":           0xe815eddbcbd31412,
+	"READ_UNWRITTEN":      0xcc0b92fdc42d01f3,
+	"WRITE_SEQUENTIAL":    0xec83a6f8441422b0,
+	"WRITE_RANDOM":        0x5fbdfda1f715e385,
+	"MIXED_R90W10":        0xec09011f69c5e279,
+	"MIXED_R80W20":        0xec7f6f0afd4896ba,
+	"MIXED_R70W30":        0x859d1899bc268a6a,
+	"MIXED_R50W50":        0xd90876f90a0aa764,
+	"MIXED_R30W70":        0xc795a1a5345e7621,
+	"MIXED_R10W90":        0x9e81339be244d452,
+	"ADVERSARIAL_HOTSPOT": 0x4d19c4ee91dc2c78,
+	"TRAVERSE_FORWARD":    0x22dd6bdcc1ffe0af,
+	"SAFE_READ_ONLY":      0xcf3ea5fea996a24b,
+}
+
+// checkScenarios compares every scenario with a golden checksum against it
+// (the six MIXED mixes among them), and MEMORY_FENCE's call sequence with
+// WRITE_RANDOM's, which it fences. It checks that every name is the one its
+// ScenarioSpec generates and that out-of-range percentages are rejected.
+func checkScenarios() error {
+	for _, sc := range Scenarios() {
+		spec := sc.Spec()
+		if spec.Name() != sc.Name {
+			return fmt.Errorf("scenario %s: its spec %v is named %s", sc.Name, spec, spec.Name())
+		}
+		back, err := ParseScenarioSpec(spec.String())
+		if err != nil || back.String() != spec.String() {
+			return fmt.Errorf("scenario %s: spec %v parses back as %v (%v)", sc.Name, spec, back, err)
+		}
+	}
+	for _, bad := range []string{"MIXED_R50W50:read_pct=150", "MIXED_R90W10:read_pct=-1", "MIXED_R10W90:read_pct=12.5", "ADVERSARIAL_HOTSPOT:hotspot_pct=101"} {
+		if _, err := ParseScenarioParams(bad); err == nil {
+			return fmt.Errorf("scenario-params: %s accepted", bad)
+		}
+	}
+	if scenarioChecksum("MEMORY_FENCE", 1000, 42) != scenarioGolden["WRITE_RANDOM"] {
+		return fmt.Errorf("scenario MEMORY_FENCE: calls differ from WRITE_RANDOM's")
+	}
+	if err := checkWriteThenInit(); err != nil {
+		return err
+	}
+	if err := checkPowerOfTwoMod(); err != nil {
+		return err
+	}
+	for _, name := range AllScenarios {
+		want, ok := scenarioGolden[name]
+		if !ok {
+			continue
+		}
+		if got := scenarioChecksum(name, 1000, 42); got != want {
+			return fmt.Errorf("scenario %s: call sequence checksum %#x, want %#x", name, got, want)
+		}
+	}
+	return nil
+}
+
+// checkWriteThenInit checks that WRITE_THEN_INIT makes WRITE_RANDOM's
+// calls and then one Init(0), and that its dirty-page count is what
+// go_paged_int64 tracks itself, and that the pager reads 0 after it.
+func checkWriteThenInit() error {
+	t := &traceArray{A: make([]int64, 1000), h: fnv.New64a()}
+	RunScenario(t, "WRITE_RANDOM", 1000, 42, nil)
+	t.op('I', 0, 0)
+	t.op('O', 1, 0)
+	if scenarioChecksum("WRITE_THEN_INIT", 1000, 42) != t.h.Sum64() {
+		return fmt.Errorf("scenario WRITE_THEN_INIT: calls are not WRITE_RANDOM's and an Init(0)")
+	}
+	const N = 1 << 16
+	p := NewPagedImpl(N)
+	p.Init(7)
+	d := &dirtyCounter{Array: p, seen: make([]bool, N/pageElems)}
+	for _, i := range []int{0, 1, 511, 512, 40000, N - 1} {
+		d.Write(i, 9)
+	}
+	if dirty := p.DirtyPages(); int64(dirty) != d.pages || dirty != 4 {
+		return fmt.Errorf("scenario WRITE_THEN_INIT: %d dirty pages counted, go_paged_int64 tracks %d, want 4", d.pages, dirty)
+	}
+	slice, _ := Lookup("go_slice_int64")
+	want := runScenario(slice.New(N), "WRITE_THEN_INIT", N, 1, nil).Conversions
+	got := runScenario(p, "WRITE_THEN_INIT", N, 1, nil).Conversions
+	if got == nil || want == nil || *got != *want || *got == 0 {
+		return fmt.Errorf("scenario WRITE_THEN_INIT: conversions %v on go_paged_int64, %v on go_slice_int64", got, want)
+	}
+	if dirty := p.DirtyPages(); dirty != 0 {
+		return fmt.Errorf("scenario WRITE_THEN_INIT: %d pages still dirty after Init", dirty)
+	}
+	for i := 0; i < N; i += 97 {
+		if v := p.Read(i); v != 0 {
+			return fmt.Errorf("scenario WRITE_THEN_INIT: go_paged_int64 reads %d at %d after Init(0)", v, i)
+		}
+	}
+	return nil
+}
+
+// checkPowerOfTwoMod checks go_pow2_int64's rounding and wrapping, that
+// POWER_OF_TWO_MOD writes where its mask or modulo says with the values
+// WRITE_RANDOM would draw, and that a Runner records the padded size in
+// allocated_N.
+func checkPowerOfTwoMod() error {
+	for n, want := range map[int]int{0: 1, 1: 1, 2: 2, 1000: 1024, 1024: 1024, 1025: 2048} {
+		if p := NewPowerOfTwoPaddedSliceImpl(n); p.Capacity() != want || p.Mask() != want-1 || p.Len() != n {
+			return fmt.Errorf("pow2: N=%d allocates %d, mask %d, Len %d; want %d", n, p.Capacity(), p.Mask(), p.Len(), want)
+		}
+	}
+	const N = 1000
+	p := NewPowerOfTwoPaddedSliceImpl(N)
+	p.Write(1024+3, 5)
+	if p.Read(3) != 5 {
+		return fmt.Errorf("pow2: Write(1027) did not land on element 3")
+	}
+	slice := NewSliceImpl(N)
+	for _, tc := range []struct {
+		arr    Array
+		path   string
+		reduce func(r int) int
+	}{
+		{slice, "mod", func(r int) int { return r % N }},
+		{p, "mask", func(r int) int { return r & 1023 }},
+	} {
+		res := runScenario(tc.arr, "POWER_OF_TWO_MOD", N, 42, nil)
+		want := make([]int64, 1024)
+		rng := rand.New(rand.NewSource(42))
+		raw := make([]int, N)
+		for k := range raw {
+			raw[k] = int(rng.Int63() & math.MaxInt)
+		}
+		for _, r := range raw {
+			want[tc.reduce(r)] = randVal(rng)
+		}
+		for i := 0; i < N; i++ {
+			if v := tc.arr.Read(i); v != want[i] {
+				return fmt.Errorf("pow2: POWER_OF_TWO_MOD on %s left %d at %d, want %d", tc.arr.Name(), v, i, want[i])
+			}
+		}
+		if res.Path != tc.path || res.Ops != N {
+			return fmt.Errorf("pow2: POWER_OF_TWO_MOD on %s: op_path %q, %d ops", tc.arr.Name(), res.Path, res.Ops)
+		}
+	}
+	pow2, _ := Lookup("go_pow2_int64")
+	sl, _ := Lookup("go_slice_int64")
+	w := &memWriter{}
+	r := &Runner{Impls: []Impl{pow2, sl}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{N}, Seeds: []int64{1}, Reps: 1, NoBatch: true, Writers: []ResultWriter{w}}
+	if _, err := r.Run(context.Background()); err != nil {
+		return fmt.Errorf("pow2: %v", err)
+	}
+	if len(w.rows) != 2 || w.rows[0].AllocatedN != 1024 || w.rows[1].AllocatedN != N {
+		return fmt.Errorf("pow2: allocated_N of %d rows: %+v", len(w.rows), w.rows)
+	}
+	return nil
+}
+
+// memWriter is a ResultWriter that keeps the results in memory.
+type memWriter struct {
+	rows    []Result
+	flushes int
+}
+
+func (m *memWriter) Write(r Result) error { m.rows = append(m.rows, r); return nil }
+func (m *memWriter) Flush() error         { m.flushes++; return nil }
+func (m *memWriter) Close() error         { return nil }
+
+// checkFuzzSeeds replays the fuzz seed corpus against every implementation,
+// so the tricky patterns are covered without running go test -fuzz.
+func checkFuzzSeeds() error {
+	for _, seed := range FuzzSeeds() {
+		N, seq := DecodeOps(seed)
+		if len(seq) < 2 || !bytes.Equal(EncodeOps(N, seq[1:]), seed) {
+			return fmt.Errorf("fuzz seed %x does not round-trip through DecodeOps", seed)
+		}
+		for _, impl := range impls {
+			if d := fuzzImpl(impl, N, seq); d != nil {
+				return fmt.Errorf("%s N=%d: fuzz seed: %v", impl.Name, N, d)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSink checks that consume folds into Sink and that measureCell
+// probes what a write-only run left: WRITE_RANDOM reads nothing, so Sink
+// moves by exactly the element at the probe's index.
+func checkSink() error {
+	before := Sink
+	consume(0x5a)
+	if Sink != before^0x5a {
+		return fmt.Errorf("sink: consume(0x5a) moved Sink from %#x to %#x", before, Sink)
+	}
+	const N = 10
+	slice, _ := Lookup("go_slice_int64")
+	c := PlanCells([]Impl{slice}, []int{N}, []string{"WRITE_RANDOM"}, []int64{1}, 1, false)[0]
+	arr := NewSliceImpl(N)
+	runScenario(arr, "WRITE_RANDOM", N, c.EffectiveSeed(), nil)
+	want := arr.Read(rand.New(rand.NewSource(c.EffectiveSeed())).Intn(N))
+	before = Sink
+	if res := measureCell(c, false, nil, nil); !res.OK() {
+		return fmt.Errorf("sink: %s", res.Status)
+	}
+	if got := Sink ^ before; got != want || want == 0 {
+		return fmt.Errorf("sink: a WRITE_RANDOM cell moved Sink by %#x, want the probed element %#x", got, want)
+	}
+	return nil
+}
+
+// checkRegisterPressure checks that the unrolled sums, tails included,
+// add up to the plain one.
+func checkRegisterPressure() error {
+	for _, N := range []int{0, 3, 15, 16, 17, 100} {
+		arr := NewSliceImpl(N)
+		for i := 0; i < N; i++ {
+			arr.Write(i, int64(i*i-7))
+		}
+		want := sumScalar(arr, N)
+		if s4, s16 := sumUnroll4(arr, N), sumUnroll16(arr, N); s4 != want || s16 != want {
+			return fmt.Errorf("register pressure: N=%d sums to %d unrolled by 4 and %d by 16, want %d", N, s4, s16, want)
+		}
+	}
+	return nil
+}
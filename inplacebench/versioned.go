@@ -0,0 +1,248 @@
+package inplacebench
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+	"unsafe"
+)
+
+// VersionedArrayImpl keeps up to K most recent writes per index. Unwritten
+// indices read as the value passed to the last Init.
+type VersionedArrayImpl struct {
+	N, K int
+	Def  int64
+	M    map[int][]int64
+}
+
+func NewVersionedArrayImpl(n, k int) *VersionedArrayImpl {
+	if k < 1 {
+		k = 1
+	}
+	return &VersionedArrayImpl{N: n, K: k, M: make(map[int][]int64)}
+}
+func (s *VersionedArrayImpl) Name() string { return fmt.Sprintf("go_versioned_K%d_int64", s.K) }
+func (s *VersionedArrayImpl) Len() int     { return s.N }
+
+// Init replaces the history map; Fill clears it and keeps its buckets.
+func (s *VersionedArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Def = v
+	s.M = make(map[int][]int64)
+	return time.Since(start).Nanoseconds()
+}
+func (s *VersionedArrayImpl) Fill(v int64) {
+	s.Def = v
+	clear(s.M)
+}
+func (s *VersionedArrayImpl) Reset() { s.Fill(0) }
+func (s *VersionedArrayImpl) Read(i int) int64 {
+	if h, ok := s.M[i]; ok {
+		return h[len(h)-1]
+	}
+	return s.Def
+}
+func (s *VersionedArrayImpl) Write(i int, v int64) {
+	h := s.M[i]
+	if len(h) == s.K {
+		copy(h, h[1:])
+		h[len(h)-1] = v
+	} else {
+		h = append(h, v)
+	}
+	s.M[i] = h
+}
+
+// Delete drops i's whole history, so ReadVersion reads the Init value too.
+func (s *VersionedArrayImpl) Delete(i int) { delete(s.M, i) }
+
+// CheckedRead and CheckedWrite add the bounds check the map does not need:
+// Write would otherwise store any key.
+func (s *VersionedArrayImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *VersionedArrayImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// Snapshot copies the map and every history, which Write shifts in place.
+func (s *VersionedArrayImpl) Snapshot() Array {
+	c := &VersionedArrayImpl{N: s.N, K: s.K, Def: s.Def, M: make(map[int][]int64, len(s.M))}
+	for i, h := range s.M {
+		c.M[i] = append([]int64(nil), h...)
+	}
+	return c
+}
+
+// ForEach visits the written indices in map order.
+func (s *VersionedArrayImpl) ForEach(fn func(i int, v int64) bool) {
+	for i, h := range s.M {
+		if !fn(i, h[len(h)-1]) {
+			return
+		}
+	}
+}
+
+// ReadVersion returns the value written `version` writes before the latest
+// (0 is the latest). Versions older than the retained history read as the
+// Init value.
+func (s *VersionedArrayImpl) ReadVersion(i, version int) int64 {
+	h := s.M[i]
+	if version < 0 || version >= len(h) {
+		return s.Def
+	}
+	return h[len(h)-1-version]
+}
+
+// Stats reports the total number of retained versions as conversions.
+func (s *VersionedArrayImpl) Stats() (relocations, conversions int64) {
+	for _, h := range s.M {
+		conversions += int64(len(h))
+	}
+	return 0, conversions
+}
+
+// maxDeltaChain bounds the number of deltas kept per index so reads stay cheap.
+const maxDeltaChain = 64
+
+// deltaCell holds the first write in full followed by int8 deltas, one per
+// later write.
+type deltaCell struct {
+	base   int64
+	deltas []int8
+}
+
+func (c *deltaCell) value() int64 {
+	v := c.base
+	for _, d := range c.deltas {
+		v += int64(d)
+	}
+	return v
+}
+
+// DeltaArrayImpl stores the first write to each index in full and later writes
+// as deltas from the previous value. A delta that does not fit in an int8, or
+// a full chain, rebases the cell to a full value.
+type DeltaArrayImpl struct {
+	N          int
+	Def        int64
+	M          map[int]*deltaCell
+	deltaSum   int64
+	deltaCount int64
+}
+
+func NewDeltaArrayImpl(n int) *DeltaArrayImpl {
+	return &DeltaArrayImpl{N: n, M: make(map[int]*deltaCell)}
+}
+func (s *DeltaArrayImpl) Name() string { return "go_delta_int64" }
+func (s *DeltaArrayImpl) Len() int     { return s.N }
+
+// Init replaces the cell map; Fill clears it and keeps its buckets.
+func (s *DeltaArrayImpl) Init(v int64) int64 {
+	start := time.Now()
+	s.Def = v
+	s.M = make(map[int]*deltaCell)
+	s.deltaSum, s.deltaCount = 0, 0
+	return time.Since(start).Nanoseconds()
+}
+func (s *DeltaArrayImpl) Fill(v int64) {
+	s.Def = v
+	clear(s.M)
+	s.deltaSum, s.deltaCount = 0, 0
+}
+func (s *DeltaArrayImpl) Reset() { s.Fill(0) }
+func (s *DeltaArrayImpl) Read(i int) int64 {
+	if c, ok := s.M[i]; ok {
+		return c.value()
+	}
+	return s.Def
+}
+func (s *DeltaArrayImpl) Write(i int, v int64) {
+	c, ok := s.M[i]
+	if !ok {
+		s.M[i] = &deltaCell{base: v}
+		return
+	}
+	d := v - c.value()
+	if d < math.MinInt8 || d > math.MaxInt8 || len(c.deltas) == maxDeltaChain {
+		c.base = v
+		c.deltas = c.deltas[:0]
+		return
+	}
+	c.deltas = append(c.deltas, int8(d))
+	if d < 0 {
+		d = -d
+	}
+	s.deltaSum += d
+	s.deltaCount++
+}
+
+// Delete drops i's cell and its chain; the delta statistics keep its deltas.
+func (s *DeltaArrayImpl) Delete(i int) { delete(s.M, i) }
+
+func (s *DeltaArrayImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *DeltaArrayImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+
+// ForEach visits the written indices in map order.
+func (s *DeltaArrayImpl) ForEach(fn func(i int, v int64) bool) {
+	for i, c := range s.M {
+		if !fn(i, c.value()) {
+			return
+		}
+	}
+}
+
+// Stats reports the mean magnitude of the stored deltas (rounded down) as
+// conversions.
+func (s *DeltaArrayImpl) Stats() (relocations, conversions int64) {
+	if s.deltaCount == 0 {
+		return 0, 0
+	}
+	return 0, s.deltaSum / s.deltaCount
+}
+
+// mapBytes estimates a map of n entries with kv bytes of key and value each:
+// the table grows in powers of two up to a load factor of 7/8, with a control
+// byte per slot.
+func mapBytes(n int, kv uintptr) int64 {
+	if n == 0 {
+		return 0
+	}
+	slots := int64(1) << bits.Len64(uint64(n*8/7))
+	return slots * (int64(kv) + 1)
+}
+
+func (s *VersionedArrayImpl) MemoryFootprint() int64 {
+	n := mapBytes(len(s.M), unsafe.Sizeof(0)+unsafe.Sizeof([]int64(nil)))
+	for _, h := range s.M {
+		n += int64(cap(h)) * 8
+	}
+	return n
+}
+
+func (s *DeltaArrayImpl) MemoryFootprint() int64 {
+	n := mapBytes(len(s.M), unsafe.Sizeof(0)+unsafe.Sizeof((*deltaCell)(nil)))
+	for _, c := range s.M {
+		n += int64(unsafe.Sizeof(*c)) + int64(cap(c.deltas))
+	}
+	return n
+}
@@ -0,0 +1,83 @@
+package inplacebench
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// warmKey identifies the runs one warmup run stands for.
+type warmKey struct {
+	impl, scenario, elem string
+	N                    int
+	dispatch             string
+}
+
+func warmKeyOf(c Cell) warmKey { return warmKey{c.implName(), c.Scenario, c.Elem, c.N, c.Dispatch} }
+
+// WarmupCells returns the first cell of each (impl, scenario, N) of cells,
+// of each element type on the typed path and of each dispatch: the runs
+// Runner.Warmup makes before the measured ones.
+func WarmupCells(cells []Cell) []Cell {
+	seen := map[warmKey]bool{}
+	var out []Cell
+	for _, c := range cells {
+		if k := warmKeyOf(c); !seen[k] {
+			seen[k] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// warmup runs WarmupCells(cells) in this process, one after another and
+// with no hooks, and drops their results. It returns the keys whose run
+// completed; a run that panics is reported to stderr and leaves its key
+// out, so its rows say warmup_completed=false, and the sweep goes on. It
+// stops early when ctx ends.
+func (r *Runner) warmup(ctx context.Context, cells []Cell) map[warmKey]bool {
+	done := map[warmKey]bool{}
+	buf := r.buffers(cells)
+	for _, c := range WarmupCells(cells) {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := catchPanic(func() { measureCell(c, false, r.Params, buf) }); err != nil {
+			fmt.Fprintf(os.Stderr, "warmup %s: %v\n", c.RunID(), err)
+			continue
+		}
+		done[warmKeyOf(c)] = true
+	}
+	return done
+}
+
+// checkWarmup checks that a Runner with Warmup makes one unrecorded run
+// per (impl, scenario, N) before the measured ones and marks the rows,
+// and that rows without it say false.
+func checkWarmup() error {
+	slice, _ := Lookup("go_slice_int64")
+	cells := PlanCells([]Impl{slice}, []int{10, 20}, []string{"WRITE_RANDOM", "READ_UNWRITTEN"}, []int64{1, 2}, 3, false)
+	if n := len(WarmupCells(cells)); n != 4 {
+		return fmt.Errorf("warmup: %d warmup cells for 2 scenarios x 2 sizes", n)
+	}
+	for _, warm := range []bool{false, true} {
+		var befores, news int
+		counted := slice
+		counted.New = func(n int) Array { news++; return slice.New(n) }
+		w := &memWriter{}
+		r := &Runner{Impls: []Impl{counted}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 2, Warmup: warm, NoBatch: true, Writers: []ResultWriter{w},
+			Hooks: []Hooks{{BeforeRun: func(RunInfo) error { befores++; return nil }}}}
+		if _, err := r.Run(context.Background()); err != nil {
+			return fmt.Errorf("warmup: %v", err)
+		}
+		if want := map[bool]int{false: 2, true: 3}[warm]; len(w.rows) != 2 || befores != 2 || news != want {
+			return fmt.Errorf("warmup=%v: %d rows, %d BeforeRun calls and %d arrays, want the 2 measured reps and %d arrays", warm, len(w.rows), befores, news, want)
+		}
+		for _, row := range w.rows {
+			if row.WarmupCompleted != warm {
+				return fmt.Errorf("warmup=%v: row says warmup_completed=%v", warm, row.WarmupCompleted)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package inplacebench
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writerResults are two reps of one cell around a failed run of another,
+// with a quoted status, a parameter and a rep CV to round-trip.
+func writerResults() []Result {
+	cv := 0.0125
+	return []Result{
+		{Impl: "a", Scenario: "WRITE_RANDOM", N: 10, Seed: 1, Rep: 1, Ops: 10, TotalNs: 123, NsPerOp: 12.3, Status: "ok", Params: map[string]float64{"k": 1}},
+		{Impl: "b", Scenario: "INIT_ONLY", N: 10, Seed: 1, Rep: 1, Ops: 1, TotalNs: 7, InitNs: 7, Ordinal: 1, Status: "failed: exit status 2, \"quoted\""},
+		{Impl: "a", Scenario: "WRITE_RANDOM", N: 10, Seed: 1, Rep: 2, Ops: 10, TotalNs: 99, NsPerOp: 9.9, Ordinal: 2, RepCV: &cv, Status: "ok"},
+	}
+}
+
+// readCSV parses s and checks its header, returning the records under it.
+func readCSV(t *testing.T, s string) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(strings.NewReader(s)).ReadAll()
+	if err != nil || len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(Header, ",") {
+		t.Fatalf("bad CSV header (%v)", err)
+	}
+	return rows[1:]
+}
+
+// fromObjects lays JSON objects out as records in Header order.
+func fromObjects(objs []map[string]string) [][]string {
+	var out [][]string
+	for _, o := range objs {
+		rec := make([]string, len(Header))
+		for i, h := range Header {
+			rec[i] = o[h]
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+// TestWriters feeds the same results to every backend, through MultiWriter
+// and CellBuffer, and checks that each reads back as the same records.
+func TestWriters(t *testing.T) {
+	results := writerResults()
+	var want [][]string
+	for _, r := range results {
+		want = append(want, r.Record())
+	}
+	var csvBuf, gzBuf, ndBuf, jsBuf, bufBuf, sumBuf strings.Builder
+	c, _ := NewCSVWriter(&csvBuf, false)
+	gz, _ := NewCSVWriter(&gzBuf, true)
+	buffered, _ := NewCSVWriter(&bufBuf, false)
+	all := MultiWriter(c, gz, NewNDJSONWriter(&ndBuf), NewJSONWriter(&jsBuf), CellBuffer(buffered, nil), NewSummaryWriter(&sumBuf))
+	if err := (Results(results)).Export(all); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string][][]string{}
+	got["csv"] = readCSV(t, csvBuf.String())
+	zr, err := gzip.NewReader(strings.NewReader(gzBuf.String()))
+	if err != nil {
+		t.Fatalf("csv.gz: %v", err)
+	}
+	unzipped, _ := io.ReadAll(zr)
+	got["csv.gz"] = readCSV(t, string(unzipped))
+	var objs []map[string]string
+	for _, line := range strings.Split(strings.TrimSpace(ndBuf.String()), "\n") {
+		var o map[string]string
+		if err := json.Unmarshal([]byte(line), &o); err != nil {
+			t.Fatalf("ndjson: %v", err)
+		}
+		objs = append(objs, o)
+	}
+	got["ndjson"] = fromObjects(objs)
+	objs = nil
+	if err := json.Unmarshal([]byte(jsBuf.String()), &objs); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	got["json"] = fromObjects(objs)
+	buf := readCSV(t, bufBuf.String())
+	if buf[1][1] != "a" || buf[2][1] != "b" {
+		t.Fatalf("cell buffer did not group by cell: %q", buf)
+	}
+	// CellBuffer regroups: both reps of cell a come before cell b.
+	got["cell buffer"] = [][]string{buf[0], buf[2], buf[1]}
+	for _, name := range []string{"csv", "csv.gz", "ndjson", "json", "cell buffer"} {
+		t.Run(name, func(t *testing.T) {
+			rows := got[name]
+			if len(rows) != len(want) {
+				t.Fatalf("%d rows, want %d", len(rows), len(want))
+			}
+			for i := range want {
+				if strings.Join(rows[i], "\x00") != strings.Join(want[i], "\x00") {
+					t.Fatalf("row %d = %q, want %q", i, rows[i], want[i])
+				}
+			}
+		})
+	}
+
+	// The summary has a line per cell: a's median over its two reps, and b
+	// counted as failed, with no median.
+	t.Run("summary", func(t *testing.T) {
+		var lines []string
+		for _, l := range strings.Split(strings.TrimSpace(sumBuf.String()), "\n") {
+			lines = append(lines, strings.Join(strings.Fields(l), " "))
+		}
+		want := []string{
+			"impl scenario N seed reps failed median_ns_per_op",
+			"a WRITE_RANDOM 10 1 2 0 11.1000",
+			"b INIT_ONLY 10 1 1 1 NaN",
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("summary\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+		}
+	})
+}
+
+// TestRateLimiter checks that 3 rows at 500/s take two 2ms ticks and that
+// the limiter reports the rate it kept to.
+func TestRateLimiter(t *testing.T) {
+	w := &memWriter{}
+	limited := newRateLimiter(w, 500)
+	start := time.Now()
+	for _, r := range writerResults() {
+		if err := limited.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if el := time.Since(start); el < 4*time.Millisecond {
+		t.Fatalf("3 rows at 500/s written in %v", el)
+	}
+	if rows, rate := limited.throughput(); rows != 3 || rate > 550 || len(w.rows) != 3 {
+		t.Fatalf("rate limiter reports %d rows at %.0f/s (%d written), want 3 at up to 500/s", rows, rate, len(w.rows))
+	}
+	if err := limited.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAppend appends a second sweep's rows to a CSV, each stamped with its
+// Runner's BenchmarkID, and checks that a file with an older header is
+// refused.
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.csv")
+	slice, _ := Lookup("go_slice_int64")
+	for _, id := range []string{"ci-1", "ci-2"} {
+		w, err := AppendResultWriter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &Runner{Impls: []Impl{slice}, Scenarios: []string{"WRITE_RANDOM"}, Ns: []int{10}, Seeds: []int64{1}, Reps: 1, BenchmarkID: id, NoBatch: true, Writers: []ResultWriter{w}}
+		if _, err := r.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readCSV(t, string(b))
+	id := slices.Index(Header, "benchmark_id")
+	if len(rows) != 2 || rows[0][id] != "ci-1" || rows[1][id] != "ci-2" {
+		t.Fatalf("read back %q", rows)
+	}
+	old := filepath.Join(dir, "old.csv")
+	if err := os.WriteFile(old, []byte(strings.Join(Header[:id], ",")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AppendResultWriter(old); err == nil || !strings.Contains(err.Error(), "no benchmark_id") {
+		t.Fatalf("appending to a file without benchmark_id: %v", err)
+	}
+}
@@ -0,0 +1,475 @@
+package inplacebench
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// The ResultWriter backends below write to an io.Writer and close it on
+// Close when it is an io.Closer. They are not safe for concurrent use;
+// Runner calls them from one goroutine.
+
+func closeUnderlying(w io.Writer) error {
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// CSVWriter writes Columns(meta), then Result.Record for every result;
+// when gzipped the stream is gzip-compressed.
+type CSVWriter struct {
+	dst  io.Writer
+	gz   *gzip.Writer
+	w    *csv.Writer
+	meta []string
+}
+
+// NewCSVWriter writes the header row to w and flushes it. meta are the
+// Annotations keys of the rows to come, which the header needs up front; a
+// row's columns are its own, so a Runner's rows must all carry them.
+func NewCSVWriter(w io.Writer, gzipped bool, meta ...string) (*CSVWriter, error) {
+	c := &CSVWriter{dst: w, meta: meta}
+	if gzipped {
+		c.gz = gzip.NewWriter(w)
+		c.w = csv.NewWriter(c.gz)
+	} else {
+		c.w = csv.NewWriter(w)
+	}
+	if err := c.w.Write(Columns(meta)); err != nil {
+		return nil, err
+	}
+	return c, c.Flush()
+}
+
+// Write writes r's Header columns and its values for the writer's meta
+// keys, so every row has the header's width.
+func (c *CSVWriter) Write(r Result) error {
+	rec := r.Record()[:len(Header)]
+	for _, k := range c.meta {
+		rec = append(rec, r.Annotations[k])
+	}
+	return c.w.Write(rec)
+}
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+	if c.gz != nil {
+		return c.gz.Flush()
+	}
+	return nil
+}
+func (c *CSVWriter) Close() error {
+	err := c.Flush()
+	if c.gz != nil && err == nil {
+		err = c.gz.Close()
+	}
+	if cerr := closeUnderlying(c.dst); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rowObject encodes r as a JSON object keyed by its columns, in column
+// order.
+func rowObject(r Result) []byte {
+	rec := r.Record()
+	b := []byte{'{'}
+	for i, h := range Columns(r.Annotations.Keys()) {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		k, _ := json.Marshal(h)
+		v, _ := json.Marshal(rec[i])
+		b = append(append(append(b, k...), ':'), v...)
+	}
+	return append(b, '}')
+}
+
+// NDJSONWriter writes one JSON object per result and line, with the same
+// keys and string values as the CSV columns.
+type NDJSONWriter struct {
+	dst io.Writer
+	w   *bufio.Writer
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{dst: w, w: bufio.NewWriter(w)}
+}
+
+func (n *NDJSONWriter) Write(r Result) error {
+	n.w.Write(rowObject(r))
+	return n.w.WriteByte('\n')
+}
+func (n *NDJSONWriter) Flush() error { return n.w.Flush() }
+func (n *NDJSONWriter) Close() error {
+	err := n.w.Flush()
+	if cerr := closeUnderlying(n.dst); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// JSONWriter writes a single JSON array of the NDJSON objects. It can only
+// do so once the array is complete, so nothing is written before Close.
+type JSONWriter struct {
+	dst  io.Writer
+	rows [][]byte
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter { return &JSONWriter{dst: w} }
+
+func (j *JSONWriter) Write(r Result) error {
+	j.rows = append(j.rows, rowObject(r))
+	return nil
+}
+func (j *JSONWriter) Close() error {
+	w := bufio.NewWriter(j.dst)
+	w.WriteString("[\n")
+	for i, r := range j.rows {
+		if i > 0 {
+			w.WriteString(",\n")
+		}
+		w.Write(r)
+	}
+	w.WriteString("\n]\n")
+	err := w.Flush()
+	if cerr := closeUnderlying(j.dst); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// cellKey identifies the cell a result belongs to, across reps; each phase
+// of a composite scenario is a cell of its own, and so is each dispatch.
+func cellKey(r Result) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%d", r.Impl, r.Scenario, r.Phase, r.Dispatch, r.N, r.Seed)
+}
+
+// groupByCell splits results into cells in first-seen order.
+func groupByCell(results []Result) [][]Result {
+	var groups [][]Result
+	index := map[string]int{}
+	for _, r := range results {
+		k := cellKey(r)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], r)
+	}
+	return groups
+}
+
+// SummaryWriter writes, at Close, a table with the rep count and median
+// ns/op of every cell; failed runs are counted but not in the median. With
+// Baseline set it writes Results.WriteNetSummary's table instead.
+type SummaryWriter struct {
+	Baseline string
+	dst      io.Writer
+	results  []Result
+}
+
+func NewSummaryWriter(w io.Writer) *SummaryWriter { return &SummaryWriter{dst: w} }
+
+func (s *SummaryWriter) Write(r Result) error {
+	s.results = append(s.results, r)
+	return nil
+}
+func (s *SummaryWriter) Close() error {
+	err := Results(s.results).WriteNetSummary(s.dst, s.Baseline)
+	if cerr := closeUnderlying(s.dst); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// MultiWriter fans every result out to all of ws. Flush and Close reach
+// every writer and return the first error.
+func MultiWriter(ws ...ResultWriter) ResultWriter { return multiWriter(ws) }
+
+type multiWriter []ResultWriter
+
+func (m multiWriter) Write(r Result) error {
+	for _, w := range m {
+		if err := w.Write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m multiWriter) Flush() error {
+	var first error
+	for _, w := range m {
+		if f, ok := w.(Flusher); ok {
+			if err := f.Flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+func (m multiWriter) Close() error {
+	var first error
+	for _, w := range m {
+		if err := w.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// FlushEvery flushes w after every k results (never for k < 1, leaving it to
+// Close and to explicit Flush calls).
+func FlushEvery(w ResultWriter, k int) ResultWriter { return &flushEvery{w: w, k: k} }
+
+type flushEvery struct {
+	w    ResultWriter
+	k    int
+	rows int
+}
+
+func (f *flushEvery) Write(r Result) error {
+	if err := f.w.Write(r); err != nil {
+		return err
+	}
+	f.rows++
+	if f.k > 0 && f.rows%f.k == 0 {
+		return f.Flush()
+	}
+	return nil
+}
+func (f *flushEvery) Flush() error {
+	if fl, ok := f.w.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+func (f *flushEvery) Close() error { return f.w.Close() }
+
+// RateLimit passes results on to w at no more than perSec a second, for a
+// downstream consumer such as a live dashboard that cannot take a sweep's
+// rows at full speed: Write blocks until the next row is due. The wait falls
+// between runs, never inside a timed region, but it holds up the sweep while
+// rows come faster than perSec. perSec <= 0 does not limit.
+func RateLimit(w ResultWriter, perSec float64) ResultWriter { return newRateLimiter(w, perSec) }
+
+// rateLimiter spaces Writes one tick apart. The ticker starts at the first
+// row and keeps at most one tick, so after a pause one row goes at once.
+type rateLimiter struct {
+	w           ResultWriter
+	every       time.Duration
+	ticker      *time.Ticker
+	rows        int
+	first, last time.Time
+}
+
+func newRateLimiter(w ResultWriter, perSec float64) *rateLimiter {
+	l := &rateLimiter{w: w}
+	if perSec > 0 {
+		l.every = time.Duration(float64(time.Second) / perSec)
+	}
+	return l
+}
+
+func (l *rateLimiter) Write(r Result) error {
+	switch {
+	case l.ticker != nil:
+		<-l.ticker.C
+	case l.every > 0:
+		l.ticker = time.NewTicker(l.every)
+	}
+	if err := l.w.Write(r); err != nil {
+		return err
+	}
+	l.last = time.Now()
+	if l.rows == 0 {
+		l.first = l.last
+	}
+	l.rows++
+	return nil
+}
+
+// throughput is the rows written and their rate, from the first row to
+// the last; the rate is 0 until there are two.
+func (l *rateLimiter) throughput() (rows int, perSec float64) {
+	if l.rows > 1 {
+		perSec = float64(l.rows-1) / l.last.Sub(l.first).Seconds()
+	}
+	return l.rows, perSec
+}
+func (l *rateLimiter) Flush() error {
+	if fl, ok := l.w.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+func (l *rateLimiter) Close() error {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+	return l.w.Close()
+}
+
+// CellBuffer holds every result until Close, then passes each cell's results
+// (all reps of one impl, scenario, N and seed, in first-seen cell order) to
+// process and writes what it returns to w. It is for outputs that need a
+// whole cell at once, such as per-cell quality flags; the rows reach w only
+// at the end of the run.
+func CellBuffer(w ResultWriter, process func(cell []Result) []Result) ResultWriter {
+	return &cellBuffer{w: w, process: process}
+}
+
+type cellBuffer struct {
+	w       ResultWriter
+	process func([]Result) []Result
+	results []Result
+}
+
+func (b *cellBuffer) Write(r Result) error {
+	b.results = append(b.results, r)
+	return nil
+}
+func (b *cellBuffer) Close() error {
+	var err error
+	for _, g := range groupByCell(b.results) {
+		if b.process != nil {
+			g = b.process(g)
+		}
+		for _, r := range g {
+			if err == nil {
+				err = b.w.Write(r)
+			}
+		}
+	}
+	if cerr := b.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// OutputFormat infers the output format from the extension of path.
+func OutputFormat(path string) (string, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".csv.gz"):
+		return "csv.gz", nil
+	case strings.HasSuffix(lower, ".csv"):
+		return "csv", nil
+	case strings.HasSuffix(lower, ".ndjson"):
+		return "ndjson", nil
+	case strings.HasSuffix(lower, ".json"):
+		return "json", nil
+	case strings.HasSuffix(lower, ".txt"):
+		return "summary", nil
+	case strings.HasSuffix(lower, ".db"), strings.HasSuffix(lower, ".parquet"):
+		return "", fmt.Errorf("%s: SQLite and Parquet output are not supported by this build", path)
+	}
+	return "", fmt.Errorf("%s: unknown output format (want .csv, .csv.gz, .json, .ndjson or .txt)", path)
+}
+
+// AppendResultWriter opens the CSV at path to add rows after an earlier
+// run's, without writing the header again; an empty or missing file is
+// started as by OpenResultWriter. The file's header must be this build's
+// Columns(meta), so rows of different layouts (or annotations) never share
+// a file. Only .csv can be appended to.
+func AppendResultWriter(path string, meta ...string) (ResultWriter, error) {
+	if kind, err := OutputFormat(path); err != nil {
+		return nil, err
+	} else if kind != "csv" {
+		return nil, fmt.Errorf("%s: only .csv outputs can be appended to", path)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if st, err := f.Stat(); err != nil || st.Size() == 0 {
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		return OpenResultWriter(path, meta...)
+	}
+	header, err := csv.NewReader(f).Read()
+	if err == nil {
+		err = headerMismatch(header, Columns(meta))
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: cannot append: %v", path, err)
+	}
+	return &CSVWriter{dst: f, w: csv.NewWriter(f), meta: meta}, nil
+}
+
+// headerMismatch says how header differs from want, or returns nil.
+func headerMismatch(header, want []string) error {
+	if strings.Join(header, ",") == strings.Join(want, ",") {
+		return nil
+	}
+	missing, extra := columnDiff(want, header), columnDiff(header, want)
+	switch {
+	case len(missing) > 0:
+		return fmt.Errorf("its header has no %s column", strings.Join(missing, ", "))
+	case len(extra) > 0:
+		return fmt.Errorf("its header has extra %s column", strings.Join(extra, ", "))
+	}
+	return fmt.Errorf("its header is not this build's (%d columns, want %d in Header order)", len(header), len(want))
+}
+
+// columnDiff returns the columns of a that b lacks.
+func columnDiff(a, b []string) []string {
+	has := map[string]bool{}
+	for _, h := range b {
+		has[h] = true
+	}
+	var out []string
+	for _, h := range a {
+		if !has[h] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// OpenResultWriter creates path and returns the backend its extension
+// names. meta are the Annotations keys for a CSV header; the JSON backends
+// key each row by its own columns.
+func OpenResultWriter(path string, meta ...string) (ResultWriter, error) {
+	kind, err := OutputFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "csv", "csv.gz":
+		w, err := NewCSVWriter(f, kind == "csv.gz", meta...)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return w, nil
+	case "ndjson":
+		return NewNDJSONWriter(f), nil
+	case "json":
+		return NewJSONWriter(f), nil
+	default:
+		return NewSummaryWriter(f), nil
+	}
+}
@@ -0,0 +1,100 @@
+package inplacebench
+
+import (
+	"math/rand"
+	"time"
+	"unsafe"
+)
+
+// xorNode is one list element; link is prev XOR next, as arena slots plus one
+// so that 0 marks either end.
+type xorNode struct {
+	link uint
+	v    int64
+}
+
+// XORLinkedListImpl is an XOR doubly linked list: each node keeps prev XOR
+// next instead of two links, halving the link overhead. The GC cannot trace
+// XORed pointers, so nodes live in an arena and links are slot numbers; the
+// slots are assigned in a fixed shuffled order so a walk chases links rather
+// than streaming memory. Read and Write walk from the head, O(i); Traverse
+// visits every element in one pass.
+type XORLinkedListImpl struct {
+	N     int
+	nodes []xorNode
+	head  uint
+}
+
+func NewXORLinkedListImpl(n int) *XORLinkedListImpl {
+	s := &XORLinkedListImpl{N: n, nodes: make([]xorNode, n)}
+	slot := rand.New(rand.NewSource(int64(n))).Perm(n)
+	for i := 0; i < n; i++ {
+		var prev, next uint
+		if i > 0 {
+			prev = uint(slot[i-1]) + 1
+		}
+		if i+1 < n {
+			next = uint(slot[i+1]) + 1
+		}
+		s.nodes[slot[i]].link = prev ^ next
+	}
+	if n > 0 {
+		s.head = uint(slot[0]) + 1
+	}
+	return s
+}
+func (s *XORLinkedListImpl) Name() string { return "go_xorlist_int64" }
+func (s *XORLinkedListImpl) Len() int     { return s.N }
+func (s *XORLinkedListImpl) Init(v int64) int64 {
+	start := time.Now()
+	for i := range s.nodes {
+		s.nodes[i].v = v
+	}
+	return time.Since(start).Nanoseconds()
+}
+
+// node walks i links from the head.
+func (s *XORLinkedListImpl) node(i int) *xorNode {
+	var prev uint
+	cur := s.head
+	for ; i > 0; i-- {
+		prev, cur = cur, prev^s.nodes[cur-1].link
+	}
+	return &s.nodes[cur-1]
+}
+func (s *XORLinkedListImpl) Read(i int) int64     { return s.node(i).v }
+func (s *XORLinkedListImpl) Write(i int, v int64) { s.node(i).v = v }
+func (s *XORLinkedListImpl) Traverse(fn func(v int64)) {
+	var prev uint
+	for cur := s.head; cur != 0; prev, cur = cur, prev^s.nodes[cur-1].link {
+		fn(s.nodes[cur-1].v)
+	}
+}
+
+// CheckedRead and CheckedWrite check the index first: node(i) returns the
+// head for a negative i.
+func (s *XORLinkedListImpl) CheckedRead(i int) (int64, error) {
+	if uint(i) >= uint(s.N) {
+		return 0, outOfRange("Read", i, s.N)
+	}
+	return s.Read(i), nil
+}
+func (s *XORLinkedListImpl) CheckedWrite(i int, v int64) error {
+	if uint(i) >= uint(s.N) {
+		return outOfRange("Write", i, s.N)
+	}
+	s.Write(i, v)
+	return nil
+}
+func (s *XORLinkedListImpl) ForEach(fn func(i int, v int64) bool) {
+	var prev uint
+	for i, cur := 0, s.head; cur != 0; i, prev, cur = i+1, cur, prev^s.nodes[cur-1].link {
+		if !fn(i, s.nodes[cur-1].v) {
+			return
+		}
+	}
+}
+
+func (s *XORLinkedListImpl) MemoryFootprint() int64 {
+	return int64(cap(s.nodes)) * int64(unsafe.Sizeof(xorNode{}))
+}